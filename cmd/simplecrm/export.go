@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/exports"
+	"simplecrm/internal/ops"
+)
+
+// runExport implements `simplecrm export`, writing leads, contacts or tasks
+// straight from the SQLite file without going through the HTTP server.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := fs.String("db", envString("SIMPLECRM_DB", defaultDBPath), "path to the SQLite database")
+	resource := fs.String("resource", "leads", "leads, contacts or tasks")
+	format := fs.String("format", exports.FormatCSV, "csv, ndjson or vcard (contacts only)")
+	output := fs.String("o", "-", "output file, - for stdout")
+	status := fs.String("status", "", "only export records with this status")
+	assignedTo := fs.String("assigned-to", "", "only export records assigned to this user id")
+	from := fs.String("from", "", "only export records created (or, for tasks, due) at or after this time")
+	to := fs.String("to", "", "only export records created (or, for tasks, due) before this time")
+	segment := fs.String("segment", "", "export the leads or contacts in this segment id instead of filtering")
+	workspace := fs.String("workspace", db.DefaultWorkspaceID, "id of the workspace to export from")
+	fs.Parse(args)
+
+	dbc, err := connect(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer dbc.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	querier := db.NewQueries()
+	ctx, err := workspaceContext(context.Background(), dbc, querier, *workspace)
+	if err != nil {
+		return err
+	}
+
+	if *segment != "" {
+		s, err := querier.GetSegment(ctx, dbc, *segment)
+		if err != nil {
+			return fmt.Errorf("segment %s: %w", *segment, err)
+		}
+		filter, err := ops.SegmentFilter(ctx, dbc, querier, s)
+		if err != nil {
+			return fmt.Errorf("segment %s: %w", *segment, err)
+		}
+		return exports.Entities(ctx, w, dbc, querier, *format, filter)
+	}
+
+	switch *resource {
+	case "leads", "contacts":
+		kind := ops.ObjectTypeLead
+		if *resource == "contacts" {
+			kind = ops.ObjectTypeContact
+		}
+		return exports.Entities(ctx, w, dbc, querier, *format, db.EntityFilter{
+			Kind:        kind,
+			Status:      *status,
+			AssignedTo:  *assignedTo,
+			CreatedFrom: *from,
+			CreatedTo:   *to,
+		})
+	case "tasks":
+		return exports.Tasks(ctx, w, dbc, querier, *format, db.TaskFilter{
+			Status:     *status,
+			AssignedTo: *assignedTo,
+			DueFrom:    *from,
+			DueTo:      *to,
+		})
+	default:
+		return fmt.Errorf("unknown resource %q", *resource)
+	}
+}
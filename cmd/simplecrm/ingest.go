@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/inbound"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+)
+
+// runIngest implements `simplecrm ingest`, logging the messages in .eml or
+// mbox files, or standard input, on the timelines of matching leads and
+// contacts.
+func runIngest(args []string) error {
+	fs := flag.NewFlagSet("ingest", flag.ExitOnError)
+	dbPath := fs.String("db", envString("SIMPLECRM_DB", defaultDBPath), "path to the SQLite database")
+	workspace := fs.String("workspace", db.DefaultWorkspaceID, "id of the workspace to log the messages in")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	dbc, err := connect(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer dbc.Close()
+
+	if err := database.Migrate(context.Background(), dbc); err != nil {
+		return err
+	}
+
+	querier := db.NewQueries()
+	ctx, err := workspaceContext(context.Background(), dbc, querier, *workspace)
+	if err != nil {
+		return err
+	}
+	eventService := pubsub.NewEventService()
+
+	var ingested, duplicates, failed int
+	for _, path := range paths {
+		data, err := readInput(path)
+		if err != nil {
+			return err
+		}
+
+		n := 0
+		err = inbound.Messages(data, func(raw []byte) error {
+			n++
+			result, err := ops.IngestEmail(ctx, dbc, querier, raw, eventService)
+			switch {
+			case errors.Is(err, ops.ErrInvalidCommand):
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: message %d: %s\n", path, n, err)
+			case err != nil:
+				return err
+			case result.Duplicate:
+				duplicates++
+			default:
+				ingested++
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	fmt.Printf("ingested %d emails, %d duplicates, %d could not be parsed\n", ingested, duplicates, failed)
+
+	return nil
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
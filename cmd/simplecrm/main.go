@@ -2,20 +2,83 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 
+	"simplecrm/database"
 	"simplecrm/internal/db"
 	"simplecrm/internal/handlers"
+	"simplecrm/internal/imports"
+	"simplecrm/internal/mail"
+	"simplecrm/internal/normalize"
+	"simplecrm/internal/notifications"
 	"simplecrm/internal/pubsub"
+	"simplecrm/internal/ratelimit"
+	"simplecrm/internal/reminders"
+	"simplecrm/internal/reports"
+	"simplecrm/internal/scoring"
+	"simplecrm/internal/storage"
+	"simplecrm/internal/workflows"
+)
+
+const (
+	defaultDBPath      = "./simplecrm.db"
+	defaultPhoneRegion = "US"
+	defaultMailFrom    = "SimpleCRM <noreply@localhost>"
+	// defaultAttachmentDir holds the content of attached files
+	defaultAttachmentDir = "./attachments"
+	// formRateLimit is how many submissions a client may post to a form
+	// within SIMPLECRM_FORM_RATE_WINDOW
+	formRateLimit = 5
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+		case "export":
+			if err := runExport(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		case "normalize":
+			if err := runNormalize(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		case "ingest":
+			if err := runIngest(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		case "workspace":
+			if err := runWorkspace(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		case "rates":
+			if err := runRates(os.Args[2:]); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		default:
+			log.Fatalf("unknown command %q, expected serve, export, normalize, ingest, workspace or rates", os.Args[1])
+		}
+	}
+
+	serve()
+}
+
+func serve() {
 	userCreatedEventService := pubsub.NewUserCreatedEventService()
 
 	userCreatedConsumer := func(event pubsub.UserCreatedEvent) {
@@ -24,17 +87,92 @@ func main() {
 
 	go userCreatedEventService.Consume(context.Background(), userCreatedConsumer)
 
-	dbc, err := sqlx.Connect("sqlite3", "./simplecrm.db")
+	dbc, err := connect(envString("SIMPLECRM_DB", defaultDBPath))
 	if err != nil {
 		log.Fatalln(err)
 	}
 	defer dbc.Close()
 
+	if err := database.Migrate(context.Background(), dbc); err != nil {
+		log.Fatalln(err)
+	}
+
 	querier := db.NewQueries()
 
+	phoneRegion := envString("SIMPLECRM_PHONE_REGION", defaultPhoneRegion)
+	if !normalize.ValidRegion(phoneRegion) {
+		log.Fatalf("unsupported SIMPLECRM_PHONE_REGION %q", phoneRegion)
+	}
+
+	idempotencyTTL := envDuration("SIMPLECRM_IDEMPOTENCY_TTL", 24*time.Hour)
+	go purgeExpiredIdempotencyKeys(context.Background(), dbc, querier, time.Hour)
+
+	eventService := pubsub.NewEventService()
+
+	importer := imports.NewImporter(dbc, querier, eventService, phoneRegion)
+	go importer.Run(context.Background())
+
+	mailer := mail.NewOutbox(dbc, querier, mailTransport(), envString("SIMPLECRM_MAIL_FROM", defaultMailFrom))
+	go mailer.Run(context.Background(), envDuration("SIMPLECRM_OUTBOX_INTERVAL", time.Minute))
+
+	workflowEngine := workflows.NewEngine(dbc, querier, eventService, mailer)
+	go workflowEngine.Run(context.Background())
+
+	reminderScheduler := reminders.NewScheduler(
+		dbc,
+		querier,
+		eventService,
+		mailer,
+		time.Now,
+		envDuration("SIMPLECRM_REMINDER_WINDOW", 24*time.Hour),
+	)
+	go reminderScheduler.Run(context.Background(), envDuration("SIMPLECRM_REMINDER_INTERVAL", time.Minute))
+
+	scorer := scoring.NewScorer(dbc, querier, eventService)
+	go scorer.Run(context.Background(), envDuration("SIMPLECRM_RESCORE_INTERVAL", 24*time.Hour))
+
+	notifier := notifications.NewNotifier(dbc, querier, eventService)
+	notifier.Start(context.Background())
+
+	reportScheduler := reports.NewScheduler(dbc, querier, mailer, time.Now)
+	go reportScheduler.Run(context.Background(), envDuration("SIMPLECRM_REPORT_INTERVAL", time.Minute))
+
+	attachments, err := storage.NewLocalStore(
+		envString("SIMPLECRM_ATTACHMENT_DIR", defaultAttachmentDir),
+		envInt("SIMPLECRM_ATTACHMENT_MAX_SIZE", storage.DefaultMaxSize),
+	)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Form submissions are limited per client, whose address only the
+	// proxies in front of the server know: those in SIMPLECRM_TRUSTED_PROXIES,
+	// a comma separated list of addresses and CIDR ranges
+	trustedProxies, err := handlers.ParseTrustedProxies(os.Getenv("SIMPLECRM_TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	r := chi.NewRouter()
 
-	handlers.MountRoutes(r, dbc, querier, userCreatedEventService)
+	handlers.MountRoutes(
+		r,
+		dbc,
+		querier,
+		userCreatedEventService,
+		eventService,
+		importer,
+		scorer,
+		notifier,
+		reportScheduler,
+		mailer,
+		ratelimit.New(formRateLimit, envDuration("SIMPLECRM_FORM_RATE_WINDOW", time.Minute)),
+		trustedProxies,
+		idempotencyTTL,
+		phoneRegion,
+		defaultWorkspace(),
+		attachments,
+	)
 
 	server := http.Server{
 		Addr:    ":8080",
@@ -44,3 +182,106 @@ func main() {
 	slog.Info("Server started", "addr", server.Addr)
 	log.Fatal(server.ListenAndServe())
 }
+
+func purgeExpiredIdempotencyKeys(ctx context.Context, dbc *sqlx.DB, querier db.Querier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var n int64
+			err := db.EachWorkspace(ctx, dbc, querier, func(ctx context.Context) error {
+				purged, err := querier.DeleteExpiredIdempotencyKeys(ctx, dbc, time.Now().UTC().Format(db.TimeFormat))
+				n += purged
+				return err
+			})
+			if err != nil {
+				slog.Error("Failed to purge idempotency keys", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("Purged expired idempotency keys", "count", n)
+			}
+		}
+	}
+}
+
+// mailTransport delivers email over SMTP when SIMPLECRM_SMTP_ADDR is set,
+// into the Maildir at SIMPLECRM_MAILDIR for local development, or else only
+// logs it.
+func mailTransport() mail.Sender {
+	from := envString("SIMPLECRM_MAIL_FROM", defaultMailFrom)
+
+	if addr := os.Getenv("SIMPLECRM_SMTP_ADDR"); addr != "" {
+		return &mail.SMTPSender{
+			Addr:     addr,
+			Username: os.Getenv("SIMPLECRM_SMTP_USERNAME"),
+			Password: os.Getenv("SIMPLECRM_SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	if dir := os.Getenv("SIMPLECRM_MAILDIR"); dir != "" {
+		return &mail.MaildirSender{Dir: dir, From: from}
+	}
+	return mail.LogSender{}
+}
+
+// defaultWorkspace is the workspace of requests that do not say which user
+// makes them, from SIMPLECRM_DEFAULT_WORKSPACE. It is empty unless set, so
+// that such requests are rejected; single-tenant deployments that front
+// the server without setting X-User-ID opt in with
+// SIMPLECRM_DEFAULT_WORKSPACE=default.
+func defaultWorkspace() string {
+	return os.Getenv("SIMPLECRM_DEFAULT_WORKSPACE")
+}
+
+// workspaceContext confines the queries made with the returned context to
+// the given workspace, which must exist.
+func workspaceContext(ctx context.Context, dbc *sqlx.DB, querier db.Querier, workspaceID string) (context.Context, error) {
+	if _, err := querier.GetWorkspace(ctx, dbc, workspaceID); err != nil {
+		return nil, fmt.Errorf("workspace %s: %w", workspaceID, err)
+	}
+	return db.WithWorkspace(ctx, workspaceID), nil
+}
+
+func connect(path string) (*sqlx.DB, error) {
+	return sqlx.Connect("sqlite3", path+"?_busy_timeout=5000")
+}
+
+func envString(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(name string, fallback int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		log.Fatalf("invalid %s: %q", name, value)
+	}
+
+	return n
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+
+	return d
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/normalize"
+	"simplecrm/internal/ops"
+)
+
+// runNormalize implements `simplecrm normalize`, backfilling normalized
+// emails and E.164 phone numbers for rows written before they were enforced.
+func runNormalize(args []string) error {
+	fs := flag.NewFlagSet("normalize", flag.ExitOnError)
+	dbPath := fs.String("db", envString("SIMPLECRM_DB", defaultDBPath), "path to the SQLite database")
+	region := fs.String(
+		"region",
+		envString("SIMPLECRM_PHONE_REGION", defaultPhoneRegion),
+		"region used to parse phone numbers without a country code",
+	)
+	dryRun := fs.Bool("dry-run", false, "report changes without writing them")
+	workspace := fs.String("workspace", db.DefaultWorkspaceID, "id of the workspace to normalize")
+	fs.Parse(args)
+
+	if !normalize.ValidRegion(*region) {
+		return fmt.Errorf("unsupported region %q", *region)
+	}
+
+	dbc, err := connect(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer dbc.Close()
+
+	if err := database.Migrate(context.Background(), dbc); err != nil {
+		return err
+	}
+
+	querier := db.NewQueries()
+	ctx, err := workspaceContext(context.Background(), dbc, querier, *workspace)
+	if err != nil {
+		return err
+	}
+
+	report, err := ops.NormalizeExisting(ctx, dbc, querier, *region, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range report.Failures {
+		fmt.Fprintf(os.Stderr, "%s %s: %s %q: %s\n", f.Table, f.ID, f.Field, f.Value, f.Error)
+	}
+
+	verb := "updated"
+	if *dryRun {
+		verb = "would update"
+	}
+	fmt.Printf("scanned %d rows, %s %d, %d could not be parsed\n", report.Scanned, verb, report.Updated, len(report.Failures))
+
+	return nil
+}
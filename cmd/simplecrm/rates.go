@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+)
+
+// runRates implements `simplecrm rates load`, which loads exchange rates
+// from CSV files, or standard input, with from, to, rate and effective_on
+// columns, and `simplecrm rates list`.
+func runRates(args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected rates load or rates list")
+	}
+
+	fs := flag.NewFlagSet("rates "+args[0], flag.ExitOnError)
+	dbPath := fs.String("db", envString("SIMPLECRM_DB", defaultDBPath), "path to the SQLite database")
+	workspace := fs.String("workspace", db.DefaultWorkspaceID, "id of the workspace the rates are for")
+	currency := fs.String("currency", "", "list only the rates from or to this currency")
+	fs.Parse(args[1:])
+
+	dbc, err := connect(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer dbc.Close()
+
+	if err := database.Migrate(context.Background(), dbc); err != nil {
+		return err
+	}
+
+	querier := db.NewQueries()
+	ctx, err := workspaceContext(context.Background(), dbc, querier, *workspace)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "load":
+		paths := fs.Args()
+		if len(paths) == 0 {
+			paths = []string{"-"}
+		}
+
+		var params []ops.ExchangeRateParams
+		for _, path := range paths {
+			data, err := readInput(path)
+			if err != nil {
+				return err
+			}
+			rates, err := readRates(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			params = append(params, rates...)
+		}
+
+		rates, err := ops.SetExchangeRates(ctx, dbc, querier, params)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("loaded %d exchange rates\n", len(rates))
+		return nil
+	case "list":
+		rates, err := querier.ListExchangeRates(ctx, dbc, db.ExchangeRateFilter{Currency: *currency})
+		if err != nil {
+			return err
+		}
+		for _, rate := range rates {
+			fmt.Printf("%s\t%s\t%s\t%s\n", rate.EffectiveOn, rate.FromCurrency, rate.ToCurrency, rate.Rate)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown rates command %q, expected load or list", args[0])
+	}
+}
+
+// readRates reads exchange rates from CSV with a header row naming the
+// from, to, rate and effective_on columns, in any order.
+func readRates(data []byte) ([]ops.ExchangeRateParams, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"from", "to", "rate", "effective_on"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	rates := make([]ops.ExchangeRateParams, 0, len(records)-1)
+	for _, record := range records[1:] {
+		rates = append(rates, ops.ExchangeRateParams{
+			From:        strings.TrimSpace(record[columns["from"]]),
+			To:          strings.TrimSpace(record[columns["to"]]),
+			Rate:        strings.TrimSpace(record[columns["rate"]]),
+			EffectiveOn: strings.TrimSpace(record[columns["effective_on"]]),
+		})
+	}
+	return rates, nil
+}
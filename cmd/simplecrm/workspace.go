@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/normalize"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+)
+
+// runWorkspace implements `simplecrm workspace create`, which sets up a
+// workspace with its first user, `simplecrm workspace currency`, which
+// changes the base currency of a workspace's reports, and `simplecrm
+// workspace list`.
+func runWorkspace(args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected workspace create, workspace currency or workspace list")
+	}
+
+	fs := flag.NewFlagSet("workspace "+args[0], flag.ExitOnError)
+	dbPath := fs.String("db", envString("SIMPLECRM_DB", defaultDBPath), "path to the SQLite database")
+	name := fs.String("name", "", "name of the workspace to create")
+	firstName := fs.String("first-name", "", "first name of the workspace's first user")
+	lastName := fs.String("last-name", "", "last name of the workspace's first user")
+	email := fs.String("email", "", "email of the workspace's first user")
+	id := fs.String("id", "", "id of the workspace to change")
+	currency := fs.String("currency", "", "base currency reports convert amounts into, USD for new workspaces by default")
+	fs.Parse(args[1:])
+
+	dbc, err := connect(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer dbc.Close()
+
+	ctx := context.Background()
+	if err := database.Migrate(ctx, dbc); err != nil {
+		return err
+	}
+
+	querier := db.NewQueries()
+
+	switch args[0] {
+	case "create":
+		if *name == "" || *firstName == "" || *lastName == "" || *email == "" {
+			return errors.New("-name, -first-name, -last-name and -email are required")
+		}
+		canonical, err := normalize.Email(*email)
+		if err != nil {
+			return err
+		}
+
+		workspace, user, err := ops.CreateWorkspace(ctx, dbc, querier, ops.CreateWorkspaceParams{
+			Name:         *name,
+			BaseCurrency: *currency,
+			FirstName:    *firstName,
+			LastName:     *lastName,
+			Email:        canonical,
+		}, pubsub.NewUserCreatedEventService())
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("workspace %s, user %s\n", workspace.ID, user.ID)
+		return nil
+	case "currency":
+		if *id == "" || *currency == "" {
+			return errors.New("-id and -currency are required")
+		}
+		workspace, err := ops.SetBaseCurrency(ctx, dbc, querier, *id, *currency)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("workspace %s reports in %s\n", workspace.ID, workspace.BaseCurrency)
+		return nil
+	case "list":
+		workspaces, err := querier.ListWorkspaces(ctx, dbc)
+		if err != nil {
+			return err
+		}
+		for _, workspace := range workspaces {
+			fmt.Printf("%s\t%s\t%s\n", workspace.ID, workspace.Name, workspace.BaseCurrency)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown workspace command %q, expected create, currency or list", args[0])
+	}
+}
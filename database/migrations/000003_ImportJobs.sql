@@ -0,0 +1,30 @@
+-- Represents a CSV upload of leads or contacts processed in the background
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id TEXT PRIMARY KEY,
+    object_type TEXT NOT NULL,
+    status TEXT NOT NULL,
+    -- JSON object mapping entity fields to CSV column headers
+    mapping TEXT NOT NULL,
+    source BLOB NOT NULL,
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    succeeded_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TEXT,
+    finished_at TEXT
+);
+
+-- One row per CSV line that could not be imported
+CREATE TABLE IF NOT EXISTS import_errors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id TEXT NOT NULL,
+    row_number INTEGER NOT NULL,
+    field TEXT NOT NULL DEFAULT '',
+    message TEXT NOT NULL,
+    row_data TEXT NOT NULL,
+    FOREIGN KEY(job_id) REFERENCES import_jobs(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_errors_job_id ON import_errors (job_id, row_number);
@@ -0,0 +1,16 @@
+-- Links a task to the lead or contact it is about
+ALTER TABLE tasks ADD COLUMN entity_id TEXT REFERENCES entities(id);
+
+CREATE INDEX IF NOT EXISTS idx_tasks_entity_id ON tasks (entity_id);
+
+-- Audit trail of changes made to an entity, such as merges
+CREATE TABLE IF NOT EXISTS entity_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    -- JSON object describing the change
+    details TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_entity_history_entity_id ON entity_history (entity_id, id);
@@ -0,0 +1,6 @@
+-- Form of entities.email used to match the same mailbox written differently
+-- (see normalize.CanonicalEmail). Existing rows are filled in by
+-- `simplecrm normalize`.
+ALTER TABLE entities ADD COLUMN email_canonical TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_entities_email_canonical ON entities (email_canonical);
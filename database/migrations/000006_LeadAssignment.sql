@@ -0,0 +1,33 @@
+-- Deactivated users keep their history but receive no new assignments
+ALTER TABLE users ADD COLUMN active INTEGER NOT NULL DEFAULT 1;
+
+-- Where a lead came from and the region it belongs to, used for routing
+ALTER TABLE entities ADD COLUMN source TEXT NOT NULL DEFAULT '';
+ALTER TABLE entities ADD COLUMN region TEXT NOT NULL DEFAULT '';
+
+-- Routes new leads to a pool of users. Rules are tried in priority order and
+-- the first whose condition matches and has an active member wins. A rule
+-- with an empty field matches every lead.
+CREATE TABLE IF NOT EXISTS assignment_rules (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    priority INTEGER NOT NULL,
+    -- One of email_domain, source, region or status
+    field TEXT NOT NULL DEFAULT '',
+    -- Comma separated values, compared case-insensitively
+    value TEXT NOT NULL DEFAULT '',
+    -- round_robin or weighted
+    strategy TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS assignment_rule_members (
+    rule_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 1,
+    -- Smooth weighted round-robin state, see assignment.pick
+    current_weight INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (rule_id, user_id),
+    FOREIGN KEY(rule_id) REFERENCES assignment_rules(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
@@ -0,0 +1,40 @@
+-- Interactions logged against an entity, such as calls and meetings
+CREATE TABLE IF NOT EXISTS activities (
+    id TEXT PRIMARY KEY,
+    entity_id TEXT NOT NULL,
+    -- One of call, meeting, email or note
+    kind TEXT NOT NULL,
+    user_id TEXT,
+    notes TEXT NOT NULL DEFAULT '',
+    occurred_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(entity_id) REFERENCES entities(id),
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS activities_entity_id ON activities (entity_id, occurred_at);
+
+-- Points awarded to entities. Attribute rules match a field of the entity,
+-- activity rules award their points once per activity of the given kind. A
+-- positive half life makes points decay with the age of the entity or
+-- activity they were earned for.
+CREATE TABLE IF NOT EXISTS scoring_rules (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    -- attribute or activity
+    kind TEXT NOT NULL,
+    -- Entity field for attribute rules, activity kind for activity rules. An
+    -- activity rule with an empty field counts every activity.
+    field TEXT NOT NULL DEFAULT '',
+    -- Comma separated values, compared case-insensitively
+    value TEXT NOT NULL DEFAULT '',
+    points INTEGER NOT NULL,
+    half_life_days INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The last computed score, kept on the record so listings can sort by it
+ALTER TABLE entities ADD COLUMN score INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE entities ADD COLUMN scored_at TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS entities_score ON entities (score);
@@ -0,0 +1,34 @@
+-- Automation rules run when an event of their trigger type is published and
+-- its payload satisfies the condition
+CREATE TABLE IF NOT EXISTS workflows (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    -- Event type, such as entity.status_changed
+    trigger TEXT NOT NULL,
+    -- Expression over the event payload, empty to always run
+    condition TEXT NOT NULL DEFAULT '',
+    -- JSON array of {"type": ..., "params": {...}}, run in order
+    actions TEXT NOT NULL,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS workflows_trigger ON workflows (trigger, enabled);
+
+-- One row per workflow considered for an event
+CREATE TABLE IF NOT EXISTS workflow_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workflow_id TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    -- succeeded, failed or skipped when the condition did not match
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    -- JSON array with the outcome of each action
+    log TEXT NOT NULL DEFAULT '[]',
+    started_at TEXT NOT NULL,
+    finished_at TEXT NOT NULL,
+    FOREIGN KEY(workflow_id) REFERENCES workflows(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS workflow_runs_workflow_id ON workflow_runs (workflow_id, id);
@@ -0,0 +1,14 @@
+-- Reminders already sent, so that a restarted server does not send them
+-- again. Keyed on the due date as well so a rescheduled task is reminded of
+-- its new due date.
+CREATE TABLE IF NOT EXISTS task_reminders (
+    task_id TEXT NOT NULL,
+    -- due_soon or overdue
+    kind TEXT NOT NULL,
+    due_date TEXT NOT NULL,
+    sent_at TEXT NOT NULL,
+    PRIMARY KEY (task_id, kind, due_date),
+    FOREIGN KEY(task_id) REFERENCES tasks(id)
+);
+
+CREATE INDEX IF NOT EXISTS tasks_status_due_date ON tasks (status, due_date);
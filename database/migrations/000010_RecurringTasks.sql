@@ -0,0 +1,7 @@
+-- RFC 5545 RRULE of a recurring task, empty for one-off tasks. Completing an
+-- occurrence creates the next one, which shares the series id, the id of the
+-- first occurrence.
+ALTER TABLE tasks ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN series_id TEXT NOT NULL DEFAULT '';
+-- 1-based position of the task in its series, used to honour COUNT
+ALTER TABLE tasks ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+-- Secret that authorizes reading the user's task calendar feed, empty when
+-- the feed is disabled. Rotating it revokes previously shared feed URLs.
+ALTER TABLE users ADD COLUMN calendar_token TEXT NOT NULL DEFAULT '';
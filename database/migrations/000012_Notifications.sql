@@ -0,0 +1,21 @@
+-- In-app notifications, created by consumers of domain events
+CREATE TABLE IF NOT EXISTS notifications (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    -- assigned, mentioned, task_due_soon, task_overdue or workflow
+    kind TEXT NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    -- Records the notification is about, empty when not applicable
+    entity_id TEXT NOT NULL DEFAULT '',
+    task_id TEXT NOT NULL DEFAULT '',
+    -- The event that caused the notification, so that redelivered events do
+    -- not notify twice
+    event_id TEXT NOT NULL,
+    read_at TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL,
+    UNIQUE (event_id, user_id),
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_id ON notifications (user_id, created_at);
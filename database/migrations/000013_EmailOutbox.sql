@@ -0,0 +1,20 @@
+-- Email waiting to be, or already, delivered by the outbox worker
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id TEXT PRIMARY KEY,
+    from_address TEXT NOT NULL,
+    -- Comma separated recipient addresses
+    to_addresses TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    html TEXT NOT NULL DEFAULT '',
+    -- pending until delivered (sent) or out of attempts (failed)
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    -- When a pending message is next tried, pushed back after each failure
+    next_attempt_at TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    sent_at TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS email_outbox_status ON email_outbox (status, next_attempt_at);
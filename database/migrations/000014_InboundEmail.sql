@@ -0,0 +1,34 @@
+-- Received email ingested from .eml or mbox files, one row per Message-ID
+CREATE TABLE IF NOT EXISTS inbound_emails (
+    id TEXT PRIMARY KEY,
+    -- The Message-ID header, or a hash of the raw message when it has none
+    message_id TEXT NOT NULL,
+    from_address TEXT NOT NULL,
+    -- Comma separated recipient addresses
+    to_addresses TEXT NOT NULL DEFAULT '',
+    cc_addresses TEXT NOT NULL DEFAULT '',
+    subject TEXT NOT NULL DEFAULT '',
+    -- The plain text body, or the HTML body with tags removed
+    body TEXT NOT NULL DEFAULT '',
+    -- The Date header, or the ingestion time when it is missing or invalid
+    sent_at TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS inbound_emails_message_id ON inbound_emails (message_id);
+
+-- Files attached to an inbound email. Only their metadata is kept.
+CREATE TABLE IF NOT EXISTS inbound_email_attachments (
+    email_id TEXT NOT NULL,
+    -- Position of the attachment within the message
+    position INTEGER NOT NULL,
+    filename TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL,
+    -- Decoded size in bytes
+    size INTEGER NOT NULL,
+    PRIMARY KEY (email_id, position),
+    FOREIGN KEY(email_id) REFERENCES inbound_emails(id)
+);
+
+-- The inbound email an email activity was logged from, if any
+ALTER TABLE activities ADD COLUMN email_id TEXT NOT NULL DEFAULT '';
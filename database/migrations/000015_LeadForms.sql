@@ -0,0 +1,24 @@
+-- Public web-to-lead forms. Submissions are posted to a URL containing the
+-- form's key, which is not secret but hard to guess.
+CREATE TABLE IF NOT EXISTS lead_forms (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    key TEXT NOT NULL UNIQUE,
+    -- JSON object mapping entity fields onto submitted field names
+    mapping TEXT NOT NULL DEFAULT '{}',
+    -- A field hidden from people; submissions filling it in are dropped
+    honeypot TEXT NOT NULL DEFAULT '',
+    -- Where browsers are sent after a successful submission, if anywhere
+    redirect_url TEXT NOT NULL DEFAULT '',
+    -- Source recorded on leads that do not submit one
+    source TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Campaign attribution of leads, from UTM parameters
+ALTER TABLE entities ADD COLUMN utm_source TEXT NOT NULL DEFAULT '';
+ALTER TABLE entities ADD COLUMN utm_medium TEXT NOT NULL DEFAULT '';
+ALTER TABLE entities ADD COLUMN utm_campaign TEXT NOT NULL DEFAULT '';
+ALTER TABLE entities ADD COLUMN utm_term TEXT NOT NULL DEFAULT '';
+ALTER TABLE entities ADD COLUMN utm_content TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,29 @@
+-- Admin-defined fields of leads, contacts and tasks
+CREATE TABLE IF NOT EXISTS custom_fields (
+    id TEXT PRIMARY KEY,
+    -- lead, contact or task
+    object_type TEXT NOT NULL,
+    -- Name used in API requests, responses and filters
+    key TEXT NOT NULL,
+    label TEXT NOT NULL,
+    -- text, number, date, enum, bool or user
+    type TEXT NOT NULL,
+    -- JSON array of the allowed values of enum fields
+    options TEXT NOT NULL DEFAULT '[]',
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (object_type, key)
+);
+
+-- Custom field values in canonical text form: numbers without exponent,
+-- dates as YYYY-MM-DD, booleans as true or false and users by id
+CREATE TABLE IF NOT EXISTS custom_field_values (
+    field_id TEXT NOT NULL,
+    -- The entity or task the value belongs to
+    record_id TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (field_id, record_id),
+    FOREIGN KEY(field_id) REFERENCES custom_fields(id)
+);
+
+CREATE INDEX IF NOT EXISTS custom_field_values_record_id ON custom_field_values (record_id);
+CREATE INDEX IF NOT EXISTS custom_field_values_value ON custom_field_values (field_id, value);
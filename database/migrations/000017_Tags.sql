@@ -0,0 +1,30 @@
+-- Labels attached to leads and contacts, such as conference-2026 or VIP
+CREATE TABLE IF NOT EXISTS tags (
+    id TEXT PRIMARY KEY,
+    -- Unique regardless of case, keeping the case it was first written in
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS entity_tags (
+    tag_id TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tag_id, entity_id),
+    FOREIGN KEY(tag_id) REFERENCES tags(id),
+    FOREIGN KEY(entity_id) REFERENCES entities(id)
+);
+
+CREATE INDEX IF NOT EXISTS entity_tags_entity_id ON entity_tags (entity_id);
+
+-- Saved sets of leads or contacts, evaluated whenever they are read
+CREATE TABLE IF NOT EXISTS segments (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    -- lead or contact
+    object_type TEXT NOT NULL,
+    -- Query string in the syntax of the list filters, such as
+    -- status=qualified&tag=vip&cf.industry=saas
+    filter TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
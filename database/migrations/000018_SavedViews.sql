@@ -0,0 +1,29 @@
+-- Named list queries users can run again, for themselves or everyone
+CREATE TABLE IF NOT EXISTS saved_views (
+    id TEXT PRIMARY KEY,
+    owner_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    -- leads, contacts or tasks
+    resource TEXT NOT NULL,
+    -- Query string in the syntax of the list filters, without sort or paging
+    filter TEXT NOT NULL DEFAULT '',
+    -- Sort key of the list, such as -score or cf.budget
+    sort TEXT NOT NULL DEFAULT '',
+    -- JSON array of the response fields to return, all of them if empty
+    columns TEXT NOT NULL DEFAULT '[]',
+    -- Whether users other than the owner can see and run the view
+    shared INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_id, name),
+    FOREIGN KEY(owner_id) REFERENCES users(id)
+);
+
+-- The saved view each user opens a list with
+CREATE TABLE IF NOT EXISTS list_preferences (
+    user_id TEXT NOT NULL,
+    resource TEXT NOT NULL,
+    view_id TEXT NOT NULL,
+    PRIMARY KEY (user_id, resource),
+    FOREIGN KEY(user_id) REFERENCES users(id),
+    FOREIGN KEY(view_id) REFERENCES saved_views(id)
+);
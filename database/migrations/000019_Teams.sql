@@ -0,0 +1,32 @@
+-- Groups of users that can own leads, contacts and tasks together. Teams
+-- nest: the managers of a team also manage every team below it.
+CREATE TABLE IF NOT EXISTS teams (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    -- The team this one is part of, NULL for top-level teams
+    parent_id TEXT,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(parent_id) REFERENCES teams(id)
+);
+
+CREATE INDEX IF NOT EXISTS teams_parent_id ON teams (parent_id);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    -- member or manager. Members see the records their team owns, managers
+    -- also those of the team's members and sub-teams.
+    role TEXT NOT NULL DEFAULT 'member',
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, user_id),
+    FOREIGN KEY(team_id) REFERENCES teams(id),
+    FOREIGN KEY(user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS team_members_user_id ON team_members (user_id);
+
+-- The team owning a record, alongside or instead of its assignee
+ALTER TABLE entities ADD COLUMN team_id TEXT REFERENCES teams(id);
+ALTER TABLE tasks ADD COLUMN team_id TEXT REFERENCES teams(id);
+-- The team a saved view is shared with, besides its owner
+ALTER TABLE saved_views ADD COLUMN team_id TEXT REFERENCES teams(id);
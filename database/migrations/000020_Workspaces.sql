@@ -0,0 +1,153 @@
+-- Business units hosted in the same deployment. Every record belongs to
+-- exactly one workspace and is never visible from another.
+CREATE TABLE IF NOT EXISTS workspaces (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Holds every record that existed before workspaces were introduced
+INSERT INTO workspaces (id, name) VALUES ('default', 'Default') ON CONFLICT DO NOTHING;
+
+-- Tables whose unique constraints now only hold within a workspace are
+-- rebuilt with the workspace as part of them.
+CREATE TABLE users_new (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    active INTEGER NOT NULL DEFAULT 1,
+    calendar_token TEXT NOT NULL DEFAULT '',
+    UNIQUE (workspace_id, email),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id)
+);
+INSERT INTO users_new (id, first_name, last_name, email, created_at, active, calendar_token)
+SELECT id, first_name, last_name, email, created_at, active, calendar_token FROM users;
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE TABLE idempotency_keys_new (
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    key TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    -- 0 while the original request is still being processed
+    status_code INTEGER NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL DEFAULT '',
+    response_body BLOB,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TEXT NOT NULL,
+    PRIMARY KEY (workspace_id, key, method, path)
+);
+INSERT INTO idempotency_keys_new (key, method, path, request_hash, status_code, content_type, response_body, created_at, expires_at)
+SELECT key, method, path, request_hash, status_code, content_type, response_body, created_at, expires_at FROM idempotency_keys;
+DROP TABLE idempotency_keys;
+ALTER TABLE idempotency_keys_new RENAME TO idempotency_keys;
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+CREATE TABLE custom_fields_new (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    -- lead, contact or task
+    object_type TEXT NOT NULL,
+    -- Name used in API requests, responses and filters
+    key TEXT NOT NULL,
+    label TEXT NOT NULL,
+    -- text, number, date, enum, bool or user
+    type TEXT NOT NULL,
+    -- JSON array of the allowed values of enum fields
+    options TEXT NOT NULL DEFAULT '[]',
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, object_type, key),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id)
+);
+INSERT INTO custom_fields_new (id, object_type, key, label, type, options, created_at)
+SELECT id, object_type, key, label, type, options, created_at FROM custom_fields;
+DROP TABLE custom_fields;
+ALTER TABLE custom_fields_new RENAME TO custom_fields;
+
+CREATE TABLE tags_new (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    -- Unique within the workspace regardless of case, keeping the case it
+    -- was first written in
+    name TEXT NOT NULL COLLATE NOCASE,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, name),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id)
+);
+INSERT INTO tags_new (id, name, created_at) SELECT id, name, created_at FROM tags;
+DROP TABLE tags;
+ALTER TABLE tags_new RENAME TO tags;
+
+CREATE TABLE segments_new (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    -- lead or contact
+    object_type TEXT NOT NULL,
+    -- Query string in the syntax of the list filters, such as
+    -- status=qualified&tag=vip&cf.industry=saas
+    filter TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, name),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id)
+);
+INSERT INTO segments_new (id, name, object_type, filter, created_at)
+SELECT id, name, object_type, filter, created_at FROM segments;
+DROP TABLE segments;
+ALTER TABLE segments_new RENAME TO segments;
+
+CREATE TABLE teams_new (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    -- The team this one is part of, NULL for top-level teams
+    parent_id TEXT,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, name),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id),
+    FOREIGN KEY(parent_id) REFERENCES teams(id)
+);
+INSERT INTO teams_new (id, name, parent_id, created_at) SELECT id, name, parent_id, created_at FROM teams;
+DROP TABLE teams;
+ALTER TABLE teams_new RENAME TO teams;
+CREATE INDEX IF NOT EXISTS teams_parent_id ON teams (parent_id);
+
+-- Message-IDs are unique per workspace, as the same message may be
+-- ingested by several of them
+DROP INDEX IF EXISTS inbound_emails_message_id;
+ALTER TABLE inbound_emails ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+CREATE UNIQUE INDEX IF NOT EXISTS inbound_emails_message_id ON inbound_emails (workspace_id, message_id);
+
+-- The workspace of every other record. Lead form keys stay unique across
+-- workspaces, as the public submission URL has nothing else to go by.
+ALTER TABLE entities ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE tasks ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE magic_links ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE import_jobs ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE import_errors ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE entity_history ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE assignment_rules ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE assignment_rule_members ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE activities ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE scoring_rules ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE workflows ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE workflow_runs ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE task_reminders ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE notifications ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE email_outbox ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE inbound_email_attachments ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE lead_forms ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE custom_field_values ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE entity_tags ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE saved_views ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE list_preferences ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE team_members ADD COLUMN workspace_id TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX IF NOT EXISTS users_workspace_id ON users (workspace_id);
+CREATE INDEX IF NOT EXISTS entities_workspace_id ON entities (workspace_id);
+CREATE INDEX IF NOT EXISTS tasks_workspace_id ON tasks (workspace_id);
@@ -0,0 +1,24 @@
+-- Files attached to leads, contacts and tasks, such as contracts and
+-- proposals. The content is kept by the blob store under blob_key, shared by
+-- every attachment with the same content.
+CREATE TABLE IF NOT EXISTS attachments (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    -- The record the file is attached to, exactly one of them set
+    entity_id TEXT NOT NULL DEFAULT '',
+    task_id TEXT NOT NULL DEFAULT '',
+    filename TEXT NOT NULL,
+    -- Sniffed from the content when uploaded
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    blob_key TEXT NOT NULL,
+    -- The user who uploaded the file, empty when not known
+    uploaded_by TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((entity_id = '') != (task_id = '')),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id)
+);
+
+CREATE INDEX IF NOT EXISTS attachments_entity_id ON attachments (entity_id) WHERE entity_id != '';
+CREATE INDEX IF NOT EXISTS attachments_task_id ON attachments (task_id) WHERE task_id != '';
+CREATE INDEX IF NOT EXISTS attachments_blob_key ON attachments (blob_key);
@@ -0,0 +1,43 @@
+-- Reports run on a schedule, such as a digest every Monday morning. Each run
+-- is kept as a snapshot and emailed to the recipients.
+CREATE TABLE IF NOT EXISTS scheduled_reports (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    name TEXT NOT NULL,
+    -- JSON array of the reports to run: leads, conversion and tasks
+    metrics TEXT NOT NULL,
+    -- JSON array of the addresses the digest is sent to
+    recipients TEXT NOT NULL,
+    -- Cron expression such as 0 8 * * MON, read in timezone
+    schedule TEXT NOT NULL,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    -- The range each run reports on, ending when it runs: day, week or month
+    period TEXT NOT NULL DEFAULT 'week',
+    -- Query string of report parameters such as group_by=source, without a
+    -- range
+    filter TEXT NOT NULL DEFAULT '',
+    -- When the report next runs, in UTC
+    next_run_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, name),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id)
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_reports_next_run_at ON scheduled_reports (next_run_at);
+
+-- The results of a scheduled report's runs, for comparing them over time
+CREATE TABLE IF NOT EXISTS report_snapshots (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    report_id TEXT NOT NULL,
+    -- The range reported on, from inclusive and to exclusive
+    range_from TEXT NOT NULL,
+    range_to TEXT NOT NULL,
+    -- JSON object with the rows of each metric
+    result TEXT NOT NULL,
+    ran_at TEXT NOT NULL,
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id),
+    FOREIGN KEY(report_id) REFERENCES scheduled_reports(id)
+);
+
+CREATE INDEX IF NOT EXISTS report_snapshots_report_id ON report_snapshots (report_id, ran_at);
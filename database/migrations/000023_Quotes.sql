@@ -0,0 +1,80 @@
+-- The catalog of products and services quotes are made of
+CREATE TABLE IF NOT EXISTS products (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    sku TEXT NOT NULL COLLATE NOCASE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    -- Inactive products stay on existing quotes but cannot be added to new
+    -- lines
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (workspace_id, sku),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id)
+);
+
+-- The price of a product in each currency it is sold in. The prices in one
+-- currency make up that currency's price book.
+CREATE TABLE IF NOT EXISTS product_prices (
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    product_id TEXT NOT NULL,
+    -- ISO 4217 code such as USD
+    currency TEXT NOT NULL,
+    -- In the currency's minor unit, such as cents
+    unit_price INTEGER NOT NULL,
+    PRIMARY KEY (product_id, currency),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id),
+    FOREIGN KEY(product_id) REFERENCES products(id)
+);
+
+-- Priced offers made to a lead or contact
+CREATE TABLE IF NOT EXISTS quotes (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    -- Sequential within the workspace, for quoting to customers
+    number INTEGER NOT NULL,
+    entity_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    -- draft, sent, accepted or declined
+    status TEXT NOT NULL DEFAULT 'draft',
+    -- Tax on the discounted subtotal, in basis points: 825 is 8.25%
+    tax_rate INTEGER NOT NULL DEFAULT 0,
+    valid_until TEXT NOT NULL DEFAULT '',
+    notes TEXT NOT NULL DEFAULT '',
+    -- The user who made the quote, empty when not known
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TEXT NOT NULL DEFAULT '',
+    -- When the quote was accepted or declined
+    decided_at TEXT NOT NULL DEFAULT '',
+    UNIQUE (workspace_id, number),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id),
+    FOREIGN KEY(entity_id) REFERENCES entities(id)
+);
+
+CREATE INDEX IF NOT EXISTS quotes_entity_id ON quotes (entity_id);
+
+CREATE TABLE IF NOT EXISTS quote_lines (
+    id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    quote_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    -- The catalog product the line was made from, NULL for custom lines. Its
+    -- SKU, description and price are copied so that later catalog changes do
+    -- not alter the quote.
+    product_id TEXT,
+    sku TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    -- In the quote currency's minor unit
+    unit_price INTEGER NOT NULL,
+    -- In basis points of the line's price
+    discount INTEGER NOT NULL DEFAULT 0,
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id),
+    FOREIGN KEY(quote_id) REFERENCES quotes(id),
+    FOREIGN KEY(product_id) REFERENCES products(id)
+);
+
+CREATE INDEX IF NOT EXISTS quote_lines_quote_id ON quote_lines (quote_id, position);
@@ -0,0 +1,19 @@
+-- ISO 4217 code of the currency reports convert amounts into
+ALTER TABLE workspaces ADD COLUMN base_currency TEXT NOT NULL DEFAULT 'USD';
+
+-- The price of one unit of a currency in another, from a date until the
+-- next rate for the same pair
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    workspace_id TEXT NOT NULL DEFAULT 'default',
+    from_currency TEXT NOT NULL,
+    to_currency TEXT NOT NULL,
+    -- A positive decimal kept as written, such as 1.0845, so that it is
+    -- exact
+    rate TEXT NOT NULL,
+    effective_on TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, from_currency, to_currency, effective_on),
+    FOREIGN KEY(workspace_id) REFERENCES workspaces(id)
+);
+
+CREATE INDEX IF NOT EXISTS quotes_decided_at ON quotes (workspace_id, decided_at);
@@ -0,0 +1,8 @@
+-- When a task was marked done, '' while it is open. Tasks completed before
+-- this was recorded are taken to have been completed when they were due, as
+-- reports counted them until now.
+ALTER TABLE tasks ADD COLUMN completed_at TEXT NOT NULL DEFAULT '';
+
+UPDATE tasks SET completed_at = due_date WHERE status = 'done';
+
+CREATE INDEX IF NOT EXISTS tasks_completed_at ON tasks (workspace_id, completed_at);
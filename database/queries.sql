@@ -1,5 +1,722 @@
 -- name: InsertAndReturnUser :one
-INSERT INTO users (id, first_name, last_name, email) VALUES (?, ?, ?, ?) RETURNING *;
+INSERT INTO users (workspace_id, id, first_name, last_name, email) VALUES (?, ?, ?, ?, ?) RETURNING *;
 
 -- name: GetUser :one
-SELECT * FROM users WHERE id = ?;
+SELECT * FROM users WHERE workspace_id = ? AND id = ?;
+
+-- name: ReserveIdempotencyKey :execrows
+INSERT INTO idempotency_keys (workspace_id, key, method, path, request_hash, created_at, expires_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (workspace_id, key, method, path) DO UPDATE SET
+    request_hash = excluded.request_hash,
+    status_code = 0,
+    content_type = '',
+    response_body = NULL,
+    created_at = excluded.created_at,
+    expires_at = excluded.expires_at
+WHERE idempotency_keys.expires_at <= excluded.created_at;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys WHERE workspace_id = ? AND key = ? AND method = ? AND path = ?;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys SET status_code = ?, content_type = ?, response_body = ?
+WHERE workspace_id = ? AND key = ? AND method = ? AND path = ?;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE workspace_id = ? AND key = ? AND method = ? AND path = ?;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE workspace_id = ? AND expires_at <= ?;
+
+-- name: GetEntity :one
+SELECT * FROM entities WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertAndReturnEntity :one
+INSERT INTO entities (
+    workspace_id, id, first_name, last_name, email, email_canonical, phone, status, assigned_to, created_at, converted_at,
+    source, region, utm_source, utm_medium, utm_campaign, utm_term, utm_content, team_id
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: InsertImportJob :one
+INSERT INTO import_jobs (workspace_id, id, object_type, status, mapping, source) VALUES (?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: GetImportJob :one
+SELECT * FROM import_jobs WHERE workspace_id = ? AND id = ?;
+
+-- name: ListImportJobsByStatus :many
+SELECT * FROM import_jobs WHERE workspace_id = ? AND status IN (sqlc.slice('statuses')) ORDER BY created_at;
+
+-- name: UpdateImportJobProgress :exec
+UPDATE import_jobs SET status = ?, total_rows = ?, processed_rows = ?, succeeded_rows = ?,
+    failed_rows = ?, error = ?, started_at = ?, finished_at = ?
+WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertImportError :exec
+INSERT INTO import_errors (workspace_id, job_id, row_number, field, message, row_data) VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: ListImportErrors :many
+SELECT * FROM import_errors WHERE workspace_id = ? AND job_id = ? ORDER BY row_number, id;
+
+-- name: ListEntities :many
+-- Filters on kind, status, assigned_to, created_at, team and visibility are appended at runtime
+SELECT * FROM entities WHERE workspace_id = ? ORDER BY created_at, id;
+
+-- name: ListTasks :many
+-- Filters on status, assigned_to, due_date, team and visibility are appended at runtime
+SELECT * FROM tasks WHERE workspace_id = ? ORDER BY due_date, id;
+
+-- name: UpdateEntity :one
+UPDATE entities SET first_name = ?, last_name = ?, email = ?, email_canonical = ?, phone = ?, status = ?,
+    assigned_to = ?, converted_at = ?, source = ?, region = ?, team_id = ?
+WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: DeleteEntity :exec
+DELETE FROM entities WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertEntityHistory :exec
+INSERT INTO entity_history (workspace_id, entity_id, action, details) VALUES (?, ?, ?, ?);
+
+-- name: ListEntityHistory :many
+SELECT * FROM entity_history WHERE workspace_id = ? AND entity_id = ? ORDER BY id;
+
+-- name: ReassignTasksEntity :execrows
+UPDATE tasks SET entity_id = ? WHERE workspace_id = ? AND entity_id = ?;
+
+-- name: ListUsers :many
+SELECT * FROM users WHERE workspace_id = ? ORDER BY created_at, id;
+
+-- name: UpdateUserEmail :exec
+UPDATE users SET email = ? WHERE workspace_id = ? AND id = ?;
+
+-- name: SetUserActive :one
+UPDATE users SET active = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: SetUserCalendarToken :one
+UPDATE users SET calendar_token = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: UpdateEntityAssignee :one
+UPDATE entities SET assigned_to = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: UpdateEntityTeam :one
+UPDATE entities SET team_id = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: InsertAssignmentRule :one
+INSERT INTO assignment_rules (workspace_id, id, name, priority, field, value, strategy) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: InsertAssignmentRuleMember :exec
+INSERT INTO assignment_rule_members (workspace_id, rule_id, user_id, weight) VALUES (?, ?, ?, ?);
+
+-- name: ListAssignmentRules :many
+SELECT * FROM assignment_rules WHERE workspace_id = ? ORDER BY priority, created_at, id;
+
+-- name: ListAssignmentRuleMembers :many
+SELECT m.rule_id, m.user_id, m.weight, m.current_weight, u.active
+FROM assignment_rule_members m
+JOIN users u ON u.id = m.user_id AND u.workspace_id = m.workspace_id
+WHERE m.workspace_id = ? AND m.rule_id = ?
+ORDER BY m.user_id;
+
+-- name: UpdateAssignmentRuleMemberWeight :exec
+UPDATE assignment_rule_members SET current_weight = ? WHERE workspace_id = ? AND rule_id = ? AND user_id = ?;
+
+-- name: DeleteAssignmentRuleMembers :exec
+DELETE FROM assignment_rule_members WHERE workspace_id = ? AND rule_id = ?;
+
+-- name: DeleteAssignmentRule :exec
+DELETE FROM assignment_rules WHERE workspace_id = ? AND id = ?;
+
+-- name: UpdateEntityScore :exec
+UPDATE entities SET score = ?, scored_at = ? WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertActivity :one
+INSERT INTO activities (workspace_id, id, entity_id, kind, user_id, notes, occurred_at, email_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: ListActivities :many
+SELECT * FROM activities WHERE workspace_id = ? AND entity_id = ? ORDER BY occurred_at, id;
+
+-- name: ReassignActivitiesEntity :execrows
+UPDATE activities SET entity_id = ? WHERE workspace_id = ? AND entity_id = ?;
+
+-- name: InsertScoringRule :one
+INSERT INTO scoring_rules (workspace_id, id, name, kind, field, value, points, half_life_days) VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: ListScoringRules :many
+SELECT * FROM scoring_rules WHERE workspace_id = ? ORDER BY created_at, id;
+
+-- name: DeleteScoringRule :execrows
+DELETE FROM scoring_rules WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertTask :one
+INSERT INTO tasks (
+    workspace_id, id, name, description, due_date, assigned_to, status, entity_id, recurrence, series_id, occurrence, team_id
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: UpdateTaskTeam :one
+UPDATE tasks SET team_id = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: UpdateEntityStatus :one
+UPDATE entities SET status = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: InsertWorkflow :one
+INSERT INTO workflows (workspace_id, id, name, trigger, condition, actions) VALUES (?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: ListWorkflows :many
+SELECT * FROM workflows WHERE workspace_id = ? ORDER BY created_at, id;
+
+-- name: ListEnabledWorkflowsByTrigger :many
+SELECT * FROM workflows WHERE workspace_id = ? AND trigger = ? AND enabled = 1 ORDER BY created_at, id;
+
+-- name: SetWorkflowEnabled :one
+UPDATE workflows SET enabled = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: DeleteWorkflowRuns :exec
+DELETE FROM workflow_runs WHERE workspace_id = ? AND workflow_id = ?;
+
+-- name: DeleteWorkflow :exec
+DELETE FROM workflows WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertWorkflowRun :one
+INSERT INTO workflow_runs (workspace_id, workflow_id, event_id, event_type, status, error, log, started_at, finished_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: ListWorkflowRuns :many
+SELECT * FROM workflow_runs WHERE workspace_id = ? AND workflow_id = ? ORDER BY id DESC LIMIT ?;
+
+-- name: ListTasksPendingReminder :many
+SELECT t.* FROM tasks t
+WHERE t.workspace_id = ? AND t.status = 'open' AND t.due_date != '' AND t.due_date < ?
+AND NOT EXISTS (
+    SELECT 1 FROM task_reminders r
+    WHERE r.task_id = t.id AND r.workspace_id = t.workspace_id AND r.kind = 'overdue' AND r.due_date = t.due_date
+)
+ORDER BY t.due_date, t.id;
+
+-- name: ClaimTaskReminder :execrows
+INSERT INTO task_reminders (workspace_id, task_id, kind, due_date, sent_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING;
+
+-- name: GetTask :one
+SELECT * FROM tasks WHERE workspace_id = ? AND id = ?;
+
+-- name: UpdateTaskStatus :one
+UPDATE tasks SET
+    status = @status,
+    completed_at = CASE WHEN @status = 'done' THEN CURRENT_TIMESTAMP ELSE '' END
+WHERE workspace_id = @workspace_id AND id = @id RETURNING *;
+
+-- name: InsertNotification :execrows
+INSERT INTO notifications (workspace_id, id, user_id, kind, title, body, entity_id, task_id, event_id, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING;
+
+-- name: GetNotification :one
+SELECT * FROM notifications WHERE workspace_id = ? AND id = ?;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications WHERE workspace_id = ? AND user_id = ? ORDER BY created_at DESC, rowid DESC;
+
+-- name: ListUnreadNotifications :many
+SELECT * FROM notifications WHERE workspace_id = ? AND user_id = ? AND read_at = '' ORDER BY created_at DESC, rowid DESC;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE workspace_id = ? AND user_id = ? AND read_at = '';
+
+-- name: MarkNotificationRead :one
+UPDATE notifications SET read_at = CASE WHEN read_at = '' THEN ? ELSE read_at END
+WHERE workspace_id = ? AND id = ? AND user_id = ? RETURNING *;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications SET read_at = ? WHERE workspace_id = ? AND user_id = ? AND read_at = '';
+
+-- name: InsertOutboxEmail :one
+INSERT INTO email_outbox (workspace_id, id, from_address, to_addresses, subject, body, html, next_attempt_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: GetOutboxEmail :one
+SELECT * FROM email_outbox WHERE workspace_id = ? AND id = ?;
+
+-- name: ListDueOutboxEmails :many
+SELECT * FROM email_outbox WHERE workspace_id = ? AND status = 'pending' AND next_attempt_at <= ?
+ORDER BY next_attempt_at, created_at, id LIMIT ?;
+
+-- name: ListOutboxEmails :many
+SELECT * FROM email_outbox WHERE workspace_id = ? ORDER BY created_at DESC, id;
+
+-- name: UpdateOutboxEmail :one
+UPDATE email_outbox SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, sent_at = ?
+WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: InsertInboundEmail :execrows
+INSERT INTO inbound_emails (workspace_id, id, message_id, from_address, to_addresses, cc_addresses, subject, body, sent_at, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING;
+
+-- name: GetInboundEmail :one
+SELECT * FROM inbound_emails WHERE workspace_id = ? AND id = ?;
+
+-- name: GetInboundEmailByMessageID :one
+SELECT * FROM inbound_emails WHERE workspace_id = ? AND message_id = ?;
+
+-- name: InsertInboundEmailAttachment :exec
+INSERT INTO inbound_email_attachments (workspace_id, email_id, position, filename, content_type, size) VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: ListInboundEmailAttachments :many
+SELECT * FROM inbound_email_attachments WHERE workspace_id = ? AND email_id = ? ORDER BY position;
+
+-- name: ListEntitiesByEmailCanonical :many
+SELECT * FROM entities WHERE workspace_id = ? AND email_canonical IN (sqlc.slice('canonicals')) ORDER BY created_at, id;
+
+-- name: InsertLeadForm :one
+INSERT INTO lead_forms (workspace_id, id, name, key, mapping, honeypot, redirect_url, source)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: GetLeadFormByKey :one
+SELECT * FROM lead_forms WHERE workspace_id = ? AND key = ?;
+
+-- name: ListLeadForms :many
+SELECT * FROM lead_forms WHERE workspace_id = ? ORDER BY created_at, id;
+
+-- name: SetLeadFormEnabled :one
+UPDATE lead_forms SET enabled = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: SetLeadFormKey :one
+UPDATE lead_forms SET key = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: DeleteLeadForm :exec
+DELETE FROM lead_forms WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertCustomField :one
+INSERT INTO custom_fields (workspace_id, id, object_type, key, label, type, options) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: ListCustomFields :many
+SELECT * FROM custom_fields WHERE workspace_id = ? AND (? = '' OR object_type = ?) ORDER BY created_at, rowid;
+
+-- name: DeleteCustomFieldValues :exec
+DELETE FROM custom_field_values WHERE workspace_id = ? AND field_id = ?;
+
+-- name: DeleteCustomField :exec
+DELETE FROM custom_fields WHERE workspace_id = ? AND id = ?;
+
+-- name: ListCustomFieldValues :many
+SELECT v.field_id, v.record_id, v.value, f.object_type, f.key, f.type
+FROM custom_field_values v JOIN custom_fields f ON f.id = v.field_id
+WHERE v.workspace_id = @workspace_id AND f.workspace_id = @workspace_id
+AND v.record_id IN (sqlc.slice('record_ids'))
+ORDER BY v.record_id, f.created_at, f.rowid;
+
+-- name: SetCustomFieldValue :exec
+INSERT INTO custom_field_values (workspace_id, field_id, record_id, value) VALUES (?, ?, ?, ?)
+ON CONFLICT (field_id, record_id) DO UPDATE SET value = excluded.value;
+
+-- name: DeleteCustomFieldValue :exec
+DELETE FROM custom_field_values WHERE workspace_id = ? AND field_id = ? AND record_id = ?;
+
+-- name: MoveCustomFieldValues :exec
+UPDATE OR IGNORE custom_field_values SET record_id = ? WHERE workspace_id = ? AND record_id = ?;
+
+-- name: DeleteRecordCustomFieldValues :exec
+DELETE FROM custom_field_values WHERE workspace_id = ? AND record_id = ?;
+
+-- name: EnsureTag :exec
+INSERT INTO tags (workspace_id, id, name) VALUES (?, ?, ?) ON CONFLICT (workspace_id, name) DO NOTHING;
+
+-- name: GetTagByName :one
+SELECT * FROM tags WHERE workspace_id = ? AND name = ?;
+
+-- name: ListTags :many
+SELECT t.id, t.name, t.created_at, COUNT(et.entity_id) AS entities
+FROM tags t LEFT JOIN entity_tags et ON et.tag_id = t.id AND et.workspace_id = t.workspace_id
+WHERE t.workspace_id = ?
+GROUP BY t.id
+ORDER BY t.name;
+
+-- name: DeleteEntityTagsByTag :exec
+DELETE FROM entity_tags WHERE workspace_id = ? AND tag_id = ?;
+
+-- name: DeleteTag :exec
+DELETE FROM tags WHERE workspace_id = ? AND id = ?;
+
+-- name: TagEntity :execrows
+INSERT INTO entity_tags (workspace_id, tag_id, entity_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING;
+
+-- name: UntagEntity :execrows
+DELETE FROM entity_tags WHERE workspace_id = ? AND tag_id = ? AND entity_id = ?;
+
+-- name: ListEntityTags :many
+SELECT et.entity_id, t.name
+FROM entity_tags et JOIN tags t ON t.id = et.tag_id
+WHERE et.workspace_id = @workspace_id AND t.workspace_id = @workspace_id
+AND et.entity_id IN (sqlc.slice('entity_ids'))
+ORDER BY et.entity_id, t.name;
+
+-- name: MoveEntityTags :exec
+UPDATE OR IGNORE entity_tags SET entity_id = ? WHERE workspace_id = ? AND entity_id = ?;
+
+-- name: DeleteEntityTags :exec
+DELETE FROM entity_tags WHERE workspace_id = ? AND entity_id = ?;
+
+-- name: InsertSegment :one
+INSERT INTO segments (workspace_id, id, name, object_type, filter) VALUES (?, ?, ?, ?, ?) RETURNING *;
+
+-- name: GetSegment :one
+SELECT * FROM segments WHERE workspace_id = ? AND id = ?;
+
+-- name: ListSegments :many
+SELECT * FROM segments WHERE workspace_id = ? ORDER BY name;
+
+-- name: DeleteSegment :execrows
+DELETE FROM segments WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertSavedView :one
+INSERT INTO saved_views (workspace_id, id, owner_id, name, resource, filter, sort, columns, shared, team_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetSavedView :one
+SELECT * FROM saved_views WHERE workspace_id = ? AND id = ?;
+
+-- name: ListSavedViews :many
+SELECT * FROM saved_views
+WHERE workspace_id = @workspace_id
+AND (owner_id = @viewer_id OR shared = 1 OR team_id IN (
+    WITH RECURSIVE managed(id) AS (
+        SELECT team_id FROM team_members
+        WHERE workspace_id = @workspace_id AND user_id = @viewer_id AND role = 'manager'
+        UNION
+        SELECT teams.id FROM teams JOIN managed ON teams.parent_id = managed.id
+        WHERE teams.workspace_id = @workspace_id
+    )
+    SELECT id FROM managed
+    UNION
+    SELECT team_id FROM team_members WHERE workspace_id = @workspace_id AND user_id = @viewer_id
+))
+AND (@resource = '' OR resource = @resource)
+ORDER BY name, id;
+
+-- name: DeleteSavedViewPreferences :exec
+DELETE FROM list_preferences WHERE workspace_id = ? AND view_id = ?;
+
+-- name: DeleteSavedView :exec
+DELETE FROM saved_views WHERE workspace_id = ? AND id = ?;
+
+-- name: SetListPreference :exec
+INSERT INTO list_preferences (workspace_id, user_id, resource, view_id) VALUES (?, ?, ?, ?)
+ON CONFLICT (user_id, resource) DO UPDATE SET view_id = excluded.view_id;
+
+-- name: DeleteListPreference :exec
+DELETE FROM list_preferences WHERE workspace_id = ? AND user_id = ? AND resource = ?;
+
+-- name: ListListPreferences :many
+SELECT * FROM list_preferences WHERE workspace_id = ? AND user_id = ? ORDER BY resource;
+
+-- name: InsertTeam :one
+INSERT INTO teams (workspace_id, id, name, parent_id) VALUES (?, ?, ?, ?) RETURNING *;
+
+-- name: GetTeam :one
+SELECT * FROM teams WHERE workspace_id = ? AND id = ?;
+
+-- name: ListTeams :many
+SELECT * FROM teams WHERE workspace_id = ? ORDER BY name;
+
+-- name: ReparentSubTeams :exec
+UPDATE teams SET parent_id = (SELECT parent_id FROM teams WHERE id = @id AND workspace_id = @workspace_id) WHERE workspace_id = @workspace_id AND parent_id = @id;
+
+-- name: ClearEntitiesTeam :exec
+UPDATE entities SET team_id = NULL WHERE workspace_id = ? AND team_id = ?;
+
+-- name: ClearTasksTeam :exec
+UPDATE tasks SET team_id = NULL WHERE workspace_id = ? AND team_id = ?;
+
+-- name: ClearSavedViewsTeam :exec
+UPDATE saved_views SET team_id = NULL WHERE workspace_id = ? AND team_id = ?;
+
+-- name: DeleteTeamMembers :exec
+DELETE FROM team_members WHERE workspace_id = ? AND team_id = ?;
+
+-- name: DeleteTeam :execrows
+DELETE FROM teams WHERE workspace_id = ? AND id = ?;
+
+-- name: SetTeamMember :exec
+INSERT INTO team_members (workspace_id, team_id, user_id, role) VALUES (?, ?, ?, ?)
+ON CONFLICT (team_id, user_id) DO UPDATE SET role = excluded.role;
+
+-- name: DeleteTeamMember :execrows
+DELETE FROM team_members WHERE workspace_id = ? AND team_id = ? AND user_id = ?;
+
+-- name: ListTeamMembers :many
+SELECT * FROM team_members WHERE workspace_id = ? AND team_id IN (sqlc.slice('team_ids')) ORDER BY team_id, created_at, rowid;
+
+-- name: ListVisibleTeamIDs :many
+-- Teams the user belongs to, and those they manage with every team below them
+WITH RECURSIVE managed(id) AS (
+    SELECT team_id FROM team_members
+    WHERE workspace_id = @workspace_id AND user_id = @viewer_id AND role = 'manager'
+    UNION
+    SELECT teams.id FROM teams JOIN managed ON teams.parent_id = managed.id
+    WHERE teams.workspace_id = @workspace_id
+)
+SELECT id FROM managed
+UNION
+SELECT team_id FROM team_members WHERE workspace_id = @workspace_id AND user_id = @viewer_id;
+
+-- name: ListVisibleUserIDs :many
+-- The user and the members of the teams they manage, sub-teams included
+WITH RECURSIVE managed(id) AS (
+    SELECT team_id FROM team_members
+    WHERE workspace_id = @workspace_id AND user_id = @viewer_id AND role = 'manager'
+    UNION
+    SELECT teams.id FROM teams JOIN managed ON teams.parent_id = managed.id
+    WHERE teams.workspace_id = @workspace_id
+)
+SELECT @viewer_id
+UNION
+SELECT user_id FROM team_members WHERE workspace_id = @workspace_id AND team_id IN (SELECT id FROM managed);
+
+-- name: InsertWorkspace :one
+INSERT INTO workspaces (id, name, base_currency) VALUES (?, ?, ?) RETURNING *;
+
+-- name: GetWorkspace :one
+SELECT * FROM workspaces WHERE id = ?;
+
+-- name: SetWorkspaceBaseCurrency :one
+UPDATE workspaces SET base_currency = ? WHERE id = ? RETURNING *;
+
+-- name: ListWorkspaces :many
+SELECT * FROM workspaces ORDER BY created_at, id;
+
+-- name: GetUserWorkspaceID :one
+SELECT workspace_id FROM users WHERE id = ?;
+
+-- name: GetLeadFormWorkspaceID :one
+SELECT workspace_id FROM lead_forms WHERE key = ?;
+
+-- name: InsertAttachment :one
+INSERT INTO attachments (
+    workspace_id, id, entity_id, task_id, filename, content_type, size, blob_key, uploaded_by
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments WHERE workspace_id = ? AND id = ?;
+
+-- name: ListEntityAttachments :many
+SELECT * FROM attachments WHERE workspace_id = ? AND entity_id = ? ORDER BY created_at, rowid;
+
+-- name: ListTaskAttachments :many
+SELECT * FROM attachments WHERE workspace_id = ? AND task_id = ? ORDER BY created_at, rowid;
+
+-- name: DeleteAttachment :execrows
+DELETE FROM attachments WHERE workspace_id = ? AND id = ?;
+
+-- name: MoveEntityAttachments :exec
+UPDATE attachments SET entity_id = ? WHERE workspace_id = ? AND entity_id = ?;
+
+-- name: CountBlobAttachments :one
+-- Blobs are shared across workspaces
+SELECT COUNT(*) FROM attachments WHERE blob_key = ?;
+
+-- name: CountEntitiesCreated :many
+-- The period of created_at, the group column, the range, team and visibility are chosen at runtime
+SELECT strftime('%Y-%m', created_at) AS period, status AS grp, COUNT(*) AS count
+FROM entities WHERE workspace_id = ? AND created_at >= ? AND created_at < ?
+GROUP BY period, grp
+ORDER BY period, grp;
+
+-- name: GetConversionStats :many
+-- The period of created_at, the group column, the range, team and visibility are chosen at runtime
+WITH created AS (
+    SELECT strftime('%Y-%m', created_at) AS period, '' AS grp, created_at, converted_at
+    FROM entities WHERE workspace_id = ? AND created_at >= ? AND created_at < ?
+),
+converted AS (
+    SELECT
+        period,
+        grp,
+        julianday(converted_at) - julianday(created_at) AS days,
+        ROW_NUMBER() OVER (PARTITION BY period, grp ORDER BY julianday(converted_at) - julianday(created_at)) AS n,
+        COUNT(*) OVER (PARTITION BY period, grp) AS total
+    FROM created WHERE converted_at != ''
+)
+SELECT
+    period,
+    grp,
+    COUNT(*) AS created,
+    COUNT(NULLIF(converted_at, '')) AS converted,
+    (
+        SELECT AVG(days) FROM converted c
+        WHERE c.period = created.period AND c.grp = created.grp AND c.n IN ((c.total + 1) / 2, (c.total + 2) / 2)
+    ) AS median_days
+FROM created
+GROUP BY period, grp
+ORDER BY period, grp;
+
+-- name: GetTaskStats :many
+-- The period of due_date, the group column, the range, team and visibility are chosen at runtime
+SELECT
+    '' AS period,
+    COALESCE(assigned_to, '') AS grp,
+    COUNT(*) AS total,
+    COUNT(*) FILTER (WHERE status = 'open') AS open
+FROM tasks WHERE workspace_id = ?
+GROUP BY period, grp
+ORDER BY period, grp;
+-- The open tasks that may be overdue, which recurrence.DueTime then decides
+SELECT '' AS period, COALESCE(assigned_to, '') AS grp, due_date
+FROM tasks
+WHERE workspace_id = ? AND status = 'open' AND due_date != '' AND due_date <= @now;
+-- The tasks completed, by the period of completed_at
+SELECT '' AS period, COALESCE(assigned_to, '') AS grp, COUNT(*) AS completed
+FROM tasks
+WHERE workspace_id = ? AND status = 'done' AND completed_at != ''
+GROUP BY period, grp;
+
+-- name: InsertScheduledReport :one
+INSERT INTO scheduled_reports (
+    workspace_id, id, name, metrics, recipients, schedule, timezone, period, filter, next_run_at
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: GetScheduledReport :one
+SELECT * FROM scheduled_reports WHERE workspace_id = ? AND id = ?;
+
+-- name: ListScheduledReports :many
+SELECT * FROM scheduled_reports WHERE workspace_id = ? ORDER BY name;
+
+-- name: ListDueScheduledReports :many
+SELECT * FROM scheduled_reports WHERE workspace_id = ? AND next_run_at <= ? ORDER BY next_run_at, id;
+
+-- name: ClaimScheduledReportRun :execrows
+UPDATE scheduled_reports SET next_run_at = @following
+WHERE workspace_id = ? AND id = ? AND next_run_at = @next_run_at;
+
+-- name: DeleteScheduledReport :execrows
+DELETE FROM scheduled_reports WHERE workspace_id = ? AND id = ?;
+
+-- name: InsertReportSnapshot :one
+INSERT INTO report_snapshots (workspace_id, id, report_id, range_from, range_to, result, ran_at)
+VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING *;
+
+-- name: ListReportSnapshots :many
+SELECT * FROM report_snapshots WHERE workspace_id = ? AND report_id = ?
+ORDER BY ran_at DESC, rowid DESC LIMIT ? OFFSET ?;
+
+-- name: DeleteReportSnapshots :exec
+DELETE FROM report_snapshots WHERE workspace_id = ? AND report_id = ?;
+
+-- name: InsertProduct :one
+INSERT INTO products (workspace_id, id, sku, name, description) VALUES (?, ?, ?, ?, ?) RETURNING *;
+
+-- name: GetProduct :one
+SELECT * FROM products WHERE workspace_id = ? AND id = ?;
+
+-- name: ListProducts :many
+SELECT * FROM products WHERE workspace_id = ? AND (active OR @include_inactive) ORDER BY sku;
+
+-- name: SetProductActive :one
+UPDATE products SET active = ? WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: SetProductPrice :one
+INSERT INTO product_prices (workspace_id, product_id, currency, unit_price) VALUES (?, ?, ?, ?)
+ON CONFLICT (product_id, currency) DO UPDATE SET unit_price = excluded.unit_price
+RETURNING *;
+
+-- name: DeleteProductPrice :execrows
+DELETE FROM product_prices WHERE workspace_id = ? AND product_id = ? AND currency = ?;
+
+-- name: ListProductPrices :many
+SELECT * FROM product_prices WHERE workspace_id = ? AND product_id IN (sqlc.slice('product_ids'))
+ORDER BY product_id, currency;
+
+-- name: InsertQuote :one
+INSERT INTO quotes (
+    workspace_id, id, number, entity_id, title, currency, tax_rate, valid_until, notes, created_by
+)
+VALUES (
+    @workspace_id,
+    ?,
+    (SELECT COALESCE(MAX(number), 0) + 1 FROM quotes WHERE workspace_id = @workspace_id),
+    ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING *;
+
+-- name: GetQuote :one
+SELECT * FROM quotes WHERE workspace_id = ? AND id = ?;
+
+-- name: ListQuotes :many
+-- The entity_id, status and visibility conditions are appended at runtime when set
+SELECT * FROM quotes WHERE workspace_id = ? ORDER BY number DESC LIMIT ? OFFSET ?;
+
+-- name: UpdateQuote :one
+UPDATE quotes SET title = ?, tax_rate = ?, valid_until = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
+WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: UpdateQuoteStatus :one
+UPDATE quotes SET status = ?, sent_at = ?, decided_at = ?, updated_at = CURRENT_TIMESTAMP
+WHERE workspace_id = ? AND id = ? RETURNING *;
+
+-- name: TouchQuote :exec
+UPDATE quotes SET updated_at = CURRENT_TIMESTAMP WHERE workspace_id = ? AND id = ?;
+
+-- name: MoveEntityQuotes :exec
+UPDATE quotes SET entity_id = @to_entity_id WHERE workspace_id = ? AND entity_id = @from_entity_id;
+
+-- name: InsertQuoteLine :one
+INSERT INTO quote_lines (
+    workspace_id, id, quote_id, position, product_id, sku, description, quantity, unit_price, discount
+)
+VALUES (
+    ?,
+    ?,
+    @quote_id,
+    (SELECT COALESCE(MAX(position), 0) + 1 FROM quote_lines WHERE workspace_id = @workspace_id AND quote_id = @quote_id),
+    ?, ?, ?, ?, ?, ?
+)
+RETURNING *;
+
+-- name: UpdateQuoteLine :one
+UPDATE quote_lines SET quantity = ?, unit_price = ?, discount = ?
+WHERE workspace_id = ? AND id = ? AND quote_id = ? RETURNING *;
+
+-- name: DeleteQuoteLine :execrows
+DELETE FROM quote_lines WHERE workspace_id = ? AND id = ? AND quote_id = ?;
+
+-- name: ListQuoteLines :many
+SELECT * FROM quote_lines WHERE workspace_id = ? AND quote_id IN (sqlc.slice('quote_ids'))
+ORDER BY quote_id, position;
+
+-- name: ListDecidedQuoteTotals :many
+-- The period and group expressions and the range, team and visibility
+-- conditions are chosen at runtime from the report's filter
+SELECT
+    '' AS period,
+    '' AS grp,
+    q.id AS quote_id,
+    q.currency,
+    q.status,
+    q.decided_at,
+    (
+        SELECT subtotal + (subtotal * q.tax_rate + 5000) / 10000
+        FROM (
+            SELECT COALESCE(SUM(l.quantity * l.unit_price - (l.quantity * l.unit_price * l.discount + 5000) / 10000), 0) AS subtotal
+            FROM quote_lines l WHERE l.quote_id = q.id AND l.workspace_id = q.workspace_id
+        )
+    ) AS total
+FROM quotes q JOIN entities e ON e.id = q.entity_id AND e.workspace_id = q.workspace_id
+WHERE q.workspace_id = ? AND q.status IN ('accepted', 'declined')
+ORDER BY period, grp, q.decided_at, q.id;
+
+-- name: SetExchangeRate :one
+INSERT INTO exchange_rates (workspace_id, from_currency, to_currency, rate, effective_on) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (workspace_id, from_currency, to_currency, effective_on) DO UPDATE SET
+    rate = excluded.rate,
+    created_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: ListExchangeRates :many
+-- The currency and effective_on conditions are appended at runtime when set
+SELECT * FROM exchange_rates WHERE workspace_id = ? ORDER BY from_currency, to_currency, effective_on;
+
+-- name: DeleteExchangeRate :execrows
+DELETE FROM exchange_rates
+WHERE workspace_id = ? AND from_currency = ? AND to_currency = ? AND effective_on = ?;
@@ -0,0 +1,155 @@
+package assignment
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"simplecrm/internal/db"
+)
+
+const (
+	StrategyRoundRobin = "round_robin"
+	StrategyWeighted   = "weighted"
+)
+
+// Fields lists the lead attributes a rule can match on.
+var Fields = []string{"email_domain", "source", "region", "status"}
+
+// Lead holds the attributes of a new lead that rules are matched against.
+type Lead struct {
+	Email  string
+	Source string
+	Region string
+	Status string
+}
+
+// Result is the outcome of routing a lead.
+type Result struct {
+	UserID string
+	RuleID string
+}
+
+// Assign routes lead through the assignment rules in priority order and
+// returns the user picked by the first matching rule with an active member.
+// It reports false when no rule applies. The rotation state of the chosen
+// rule is advanced, so dbc should be the transaction inserting the lead.
+func Assign(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	lead Lead,
+) (Result, bool, error) {
+	rules, err := querier.ListAssignmentRules(ctx, dbc)
+	if err != nil {
+		return Result{}, false, err
+	}
+
+	for _, rule := range rules {
+		if !Matches(rule, lead) {
+			continue
+		}
+
+		members, err := querier.ListAssignmentRuleMembers(ctx, dbc, rule.ID)
+		if err != nil {
+			return Result{}, false, err
+		}
+
+		chosen, ok := pick(members, rule.Strategy)
+		if !ok {
+			continue
+		}
+
+		for _, m := range members {
+			if !m.Active {
+				continue
+			}
+			err := querier.UpdateAssignmentRuleMemberWeight(ctx, dbc, rule.ID, m.UserID, m.CurrentWeight)
+			if err != nil {
+				return Result{}, false, err
+			}
+		}
+
+		return Result{UserID: chosen, RuleID: rule.ID}, true, nil
+	}
+
+	return Result{}, false, nil
+}
+
+// Matches reports whether lead satisfies rule's condition.
+func Matches(rule db.AssignmentRule, lead Lead) bool {
+	if rule.Field == "" {
+		return true
+	}
+
+	var value string
+	switch rule.Field {
+	case "email_domain":
+		if at := strings.LastIndex(lead.Email, "@"); at >= 0 {
+			value = lead.Email[at+1:]
+		}
+	case "source":
+		value = lead.Source
+	case "region":
+		value = lead.Region
+	case "status":
+		value = lead.Status
+	default:
+		return false
+	}
+
+	for _, v := range strings.Split(rule.Value, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// pick runs one step of smooth weighted round-robin over the active members,
+// updating their CurrentWeight in place. Every member gains its weight, the
+// heaviest is chosen and loses the total, which spreads picks evenly in
+// proportion to weight. With round_robin every weight counts as 1.
+func pick(members []db.AssignmentRuleMember, strategy string) (string, bool) {
+	total := 0
+	best := -1
+	for n := range members {
+		m := &members[n]
+		if !m.Active {
+			continue
+		}
+
+		weight := 1
+		if strategy == StrategyWeighted {
+			weight = max(m.Weight, 0)
+		}
+		if weight == 0 {
+			continue
+		}
+
+		m.CurrentWeight += weight
+		total += weight
+		if best == -1 || m.CurrentWeight > members[best].CurrentWeight {
+			best = n
+		}
+	}
+
+	if best == -1 {
+		return "", false
+	}
+
+	members[best].CurrentWeight -= total
+	return members[best].UserID, true
+}
+
+// ValidateRule checks a rule definition before it is stored.
+func ValidateRule(field, strategy string) error {
+	if field != "" && !slices.Contains(Fields, field) {
+		return fmt.Errorf("unknown field %q", field)
+	}
+	if strategy != StrategyRoundRobin && strategy != StrategyWeighted {
+		return fmt.Errorf("unknown strategy %q", strategy)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package assignment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+)
+
+func setupTest(t *testing.T) *sqlx.DB {
+	a := require.New(t)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	t.Cleanup(func() { dbc.Close() })
+
+	err = database.Migrate(context.Background(), dbc)
+	a.NoError(err)
+
+	for _, id := range []string{"alice", "bob", "carol"} {
+		_, err = dbc.Exec(
+			"INSERT INTO users (id, first_name, last_name, email) VALUES (?, ?, 'Test', ?)",
+			id, id, id+"@example.com",
+		)
+		a.NoError(err)
+	}
+	return dbc
+}
+
+func addRule(t *testing.T, dbc *sqlx.DB, arg db.InsertAssignmentRuleParams, weights map[string]int) {
+	a := require.New(t)
+	querier := db.NewQueries()
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+
+	_, err := querier.InsertAssignmentRule(ctx, dbc, arg)
+	a.NoError(err)
+	for userID, weight := range weights {
+		err = querier.InsertAssignmentRuleMember(ctx, dbc, db.InsertAssignmentRuleMemberParams{
+			RuleID: arg.ID,
+			UserID: userID,
+			Weight: weight,
+		})
+		a.NoError(err)
+	}
+}
+
+func TestAssignRoundRobin(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc := setupTest(t)
+	querier := db.NewQueries()
+	addRule(t, dbc, db.InsertAssignmentRuleParams{
+		ID:       "rr",
+		Name:     "Everyone",
+		Strategy: StrategyRoundRobin,
+	}, map[string]int{"alice": 1, "bob": 5})
+
+	// Test
+	counts := map[string]int{}
+	for range 6 {
+		result, ok, err := Assign(ctx, dbc, querier, Lead{Email: "jane@example.com"})
+		a.NoError(err)
+		a.True(ok)
+		a.Equal("rr", result.RuleID)
+		counts[result.UserID]++
+	}
+	a.Equal(map[string]int{"alice": 3, "bob": 3}, counts)
+
+	// Deactivated users are skipped
+	_, err := querier.SetUserActive(ctx, dbc, "alice", false)
+	a.NoError(err)
+	for range 3 {
+		result, ok, err := Assign(ctx, dbc, querier, Lead{Email: "jane@example.com"})
+		a.NoError(err)
+		a.True(ok)
+		a.Equal("bob", result.UserID)
+	}
+}
+
+func TestAssignWeighted(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc := setupTest(t)
+	querier := db.NewQueries()
+	addRule(t, dbc, db.InsertAssignmentRuleParams{
+		ID:       "weighted",
+		Name:     "Weighted",
+		Strategy: StrategyWeighted,
+	}, map[string]int{"alice": 3, "bob": 1})
+
+	// Test
+	counts := map[string]int{}
+	for range 8 {
+		result, ok, err := Assign(ctx, dbc, querier, Lead{})
+		a.NoError(err)
+		a.True(ok)
+		counts[result.UserID]++
+	}
+	a.Equal(map[string]int{"alice": 6, "bob": 2}, counts)
+}
+
+func TestAssignRulePriority(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc := setupTest(t)
+	querier := db.NewQueries()
+	addRule(t, dbc, db.InsertAssignmentRuleParams{
+		ID:       "fallback",
+		Name:     "Fallback",
+		Priority: 10,
+		Strategy: StrategyRoundRobin,
+	}, map[string]int{"carol": 1})
+	addRule(t, dbc, db.InsertAssignmentRuleParams{
+		ID:       "emea",
+		Name:     "EMEA",
+		Priority: 1,
+		Field:    "region",
+		Value:    "DE, FR",
+		Strategy: StrategyRoundRobin,
+	}, map[string]int{"alice": 1})
+
+	// Test
+	result, ok, err := Assign(ctx, dbc, querier, Lead{Region: "fr"})
+	a.NoError(err)
+	a.True(ok)
+	a.Equal(Result{UserID: "alice", RuleID: "emea"}, result)
+
+	result, ok, err = Assign(ctx, dbc, querier, Lead{Region: "US"})
+	a.NoError(err)
+	a.True(ok)
+	a.Equal(Result{UserID: "carol", RuleID: "fallback"}, result)
+
+	a.True(Matches(db.AssignmentRule{Field: "email_domain", Value: "acme.com"}, Lead{Email: "jo@acme.com"}))
+	a.False(Matches(db.AssignmentRule{Field: "source", Value: "web"}, Lead{Source: "referral"}))
+}
@@ -0,0 +1,125 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	tcs := []struct {
+		name     string
+		spec     string
+		from     string
+		expected []string
+	}{
+		{
+			name:     "Monday mornings",
+			spec:     "0 8 * * MON",
+			from:     "2026-01-05 08:00:00",
+			expected: []string{"2026-01-12 08:00:00", "2026-01-19 08:00:00", "2026-01-26 08:00:00"},
+		},
+		{
+			name:     "Every quarter hour during office hours",
+			spec:     "*/15 9-17 * * 1-5",
+			from:     "2026-01-09 17:40:00",
+			expected: []string{"2026-01-09 17:45:00", "2026-01-12 09:00:00", "2026-01-12 09:15:00"},
+		},
+		{
+			name:     "Month ends skip short months",
+			spec:     "30 6 31 * *",
+			from:     "2026-01-31 07:00:00",
+			expected: []string{"2026-03-31 06:30:00", "2026-05-31 06:30:00", "2026-07-31 06:30:00"},
+		},
+		{
+			name:     "Day of month or of week",
+			spec:     "0 0 1 * 7",
+			from:     "2026-01-30 12:00:00",
+			expected: []string{"2026-02-01 00:00:00", "2026-02-08 00:00:00", "2026-02-15 00:00:00"},
+		},
+		{
+			name:     "Shorthand",
+			spec:     "@monthly",
+			from:     "2026-11-15 12:00:00",
+			expected: []string{"2026-12-01 00:00:00", "2027-01-01 00:00:00", "2027-02-01 00:00:00"},
+		},
+		{
+			name: "Never",
+			spec: "0 0 30 feb *",
+			from: "2026-01-01 00:00:00",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := require.New(t)
+
+			schedule, err := Parse(tc.spec)
+			a.NoError(err)
+			current, err := time.Parse(time.DateTime, tc.from)
+			a.NoError(err)
+
+			var got []string
+			for range 3 {
+				next, ok := schedule.Next(current)
+				if !ok {
+					break
+				}
+				got = append(got, next.Format(time.DateTime))
+				current = next
+			}
+			a.Equal(tc.expected, got)
+		})
+	}
+}
+
+func TestNext_InLocation(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	loc, err := time.LoadLocation("Europe/Berlin")
+	a.NoError(err)
+	schedule := MustParse("0 8 * * 1")
+
+	// Test
+	// 08:00 in Berlin is 07:00 UTC in winter and 06:00 UTC in summer
+	next, ok := schedule.Next(time.Date(2026, 3, 24, 0, 0, 0, 0, time.UTC).In(loc))
+	a.True(ok)
+	a.Equal(time.Date(2026, 3, 30, 6, 0, 0, 0, time.UTC), next.UTC())
+
+	next, ok = schedule.Next(time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC).In(loc))
+	a.True(ok)
+	a.Equal(time.Date(2026, 3, 23, 7, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestParse(t *testing.T) {
+	tcs := []struct {
+		name  string
+		spec  string
+		valid bool
+	}{
+		{name: "Names", spec: "0 8 * jan-mar mon,wed,fri", valid: true},
+		{name: "Stepped start", spec: "5/20 * * * *", valid: true},
+		{name: "Sunday as 7", spec: "0 0 * * 7", valid: true},
+		{name: "Too few fields", spec: "0 8 * *"},
+		{name: "Too many fields", spec: "0 0 8 * * 1"},
+		{name: "Out of range", spec: "60 * * * *"},
+		{name: "Backwards range", spec: "0 17-9 * * *"},
+		{name: "Zero step", spec: "*/0 * * * *"},
+		{name: "Unknown name", spec: "0 0 * * mo"},
+		{name: "Unknown shorthand", spec: "@fortnightly"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := require.New(t)
+
+			_, err := Parse(tc.spec)
+			if tc.valid {
+				a.NoError(err)
+			} else {
+				a.ErrorIs(err, ErrInvalidSchedule)
+			}
+		})
+	}
+}
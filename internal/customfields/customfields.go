@@ -0,0 +1,127 @@
+package customfields
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	TypeText   = "text"
+	TypeNumber = "number"
+	TypeDate   = "date"
+	TypeEnum   = "enum"
+	TypeBool   = "bool"
+	// TypeUser values are user ids
+	TypeUser = "user"
+)
+
+var Types = []string{TypeText, TypeNumber, TypeDate, TypeEnum, TypeBool, TypeUser}
+
+// DateFormat is the layout of date values.
+const DateFormat = "2006-01-02"
+
+// maxTextLength bounds, in characters, text values.
+const maxTextLength = 1000
+
+var ErrInvalidValue = errors.New("invalid value")
+
+// Canonical checks a value decoded from JSON against a field's type and
+// returns it in the text form it is stored, filtered and sorted in.
+func Canonical(fieldType string, options []string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		if fieldType == TypeNumber || fieldType == TypeBool {
+			return "", fmt.Errorf("%w: expected a %s", ErrInvalidValue, fieldType)
+		}
+		return Parse(fieldType, options, v)
+	case float64:
+		if fieldType != TypeNumber {
+			return "", fmt.Errorf("%w: expected a %s", ErrInvalidValue, fieldType)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if fieldType != TypeBool {
+			return "", fmt.Errorf("%w: expected a %s", ErrInvalidValue, fieldType)
+		}
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("%w: expected a %s", ErrInvalidValue, fieldType)
+	}
+}
+
+// Parse reads a value written as text, such as in a query string, and returns
+// its canonical form.
+func Parse(fieldType string, options []string, s string) (string, error) {
+	switch fieldType {
+	case TypeText:
+		if utf8.RuneCountInString(s) > maxTextLength {
+			return "", fmt.Errorf("%w: longer than %d characters", ErrInvalidValue, maxTextLength)
+		}
+		return s, nil
+	case TypeNumber:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not a number", ErrInvalidValue, s)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case TypeDate:
+		d, err := time.Parse(DateFormat, strings.TrimSpace(s))
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not a date formatted as %s", ErrInvalidValue, s, DateFormat)
+		}
+		return d.Format(DateFormat), nil
+	case TypeEnum:
+		if !slices.Contains(options, s) {
+			return "", fmt.Errorf("%w: %q is not one of %s", ErrInvalidValue, s, strings.Join(options, ", "))
+		}
+		return s, nil
+	case TypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not true or false", ErrInvalidValue, s)
+		}
+		return strconv.FormatBool(b), nil
+	case TypeUser:
+		if strings.TrimSpace(s) == "" {
+			return "", fmt.Errorf("%w: expected a user id", ErrInvalidValue)
+		}
+		return strings.TrimSpace(s), nil
+	default:
+		return "", fmt.Errorf("unknown custom field type %q", fieldType)
+	}
+}
+
+// Options decodes the allowed values of an enum field, stored as a JSON
+// array.
+func Options(encoded string) ([]string, error) {
+	var options []string
+	if err := json.Unmarshal([]byte(encoded), &options); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+	return options, nil
+}
+
+// Decode returns a stored value as the JSON type it was given as.
+func Decode(fieldType, value string) any {
+	switch fieldType {
+	case TypeNumber:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case TypeBool:
+		return value == "true"
+	}
+	return value
+}
+
+// Numeric reports whether values of the type compare as numbers rather than
+// as text. Dates compare correctly as text.
+func Numeric(fieldType string) bool {
+	return fieldType == TypeNumber
+}
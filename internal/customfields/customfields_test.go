@@ -0,0 +1,66 @@
+package customfields
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonical(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	options := []string{"saas", "retail"}
+
+	// Test
+	for _, tc := range []struct {
+		fieldType string
+		value     any
+		want      string
+	}{
+		{TypeText, "Acme", "Acme"},
+		{TypeNumber, 1500.0, "1500"},
+		{TypeNumber, 12.5, "12.5"},
+		{TypeDate, "2026-03-01", "2026-03-01"},
+		{TypeEnum, "saas", "saas"},
+		{TypeBool, true, "true"},
+		{TypeUser, " u1 ", "u1"},
+	} {
+		got, err := Canonical(tc.fieldType, options, tc.value)
+		a.NoError(err, tc.fieldType)
+		a.Equal(tc.want, got)
+	}
+
+	for _, tc := range []struct {
+		fieldType string
+		value     any
+	}{
+		{TypeText, 3.0},
+		{TypeNumber, "1500"},
+		{TypeDate, "01/03/2026"},
+		{TypeEnum, "banking"},
+		{TypeBool, "yes"},
+		{TypeUser, ""},
+		{TypeText, []any{"a"}},
+	} {
+		_, err := Canonical(tc.fieldType, options, tc.value)
+		a.ErrorIs(err, ErrInvalidValue, tc.fieldType)
+	}
+}
+
+func TestParse(t *testing.T) {
+	// Setup
+	a := require.New(t)
+
+	// Test
+	got, err := Parse(TypeNumber, nil, " 1e3 ")
+	a.NoError(err)
+	a.Equal("1000", got)
+	a.Equal(1000.0, Decode(TypeNumber, got))
+
+	got, err = Parse(TypeBool, nil, "1")
+	a.NoError(err)
+	a.Equal(true, Decode(TypeBool, got))
+
+	_, err = Parse(TypeNumber, nil, "lots")
+	a.ErrorIs(err, ErrInvalidValue)
+}
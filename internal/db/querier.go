@@ -2,12 +2,16 @@ package db
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type DBExecutor interface {
 	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+	QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 	sqlx.Ext
 }
 
@@ -18,6 +22,222 @@ type Querier interface {
 		dbc DBExecutor,
 		arg InsertAndReturnUserParams,
 	) (User, error)
+	ListUsers(ctx context.Context, dbc DBExecutor) ([]User, error)
+	UpdateUserEmail(ctx context.Context, dbc DBExecutor, id, email string) error
+	SetUserActive(ctx context.Context, dbc DBExecutor, id string, active bool) (User, error)
+	SetUserCalendarToken(ctx context.Context, dbc DBExecutor, id, token string) (User, error)
+
+	ReserveIdempotencyKey(
+		ctx context.Context,
+		dbc DBExecutor,
+		arg ReserveIdempotencyKeyParams,
+	) (bool, error)
+	GetIdempotencyKey(
+		ctx context.Context,
+		dbc DBExecutor,
+		key, method, path string,
+	) (IdempotencyKey, error)
+	CompleteIdempotencyKey(
+		ctx context.Context,
+		dbc DBExecutor,
+		arg CompleteIdempotencyKeyParams,
+	) error
+	DeleteIdempotencyKey(ctx context.Context, dbc DBExecutor, key, method, path string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, dbc DBExecutor, now string) (int64, error)
+
+	GetEntity(ctx context.Context, dbc DBExecutor, id string) (Entity, error)
+	InsertAndReturnEntity(
+		ctx context.Context,
+		dbc DBExecutor,
+		arg InsertAndReturnEntityParams,
+	) (Entity, error)
+	UpdateEntity(ctx context.Context, dbc DBExecutor, arg UpdateEntityParams) (Entity, error)
+	UpdateEntityAssignee(
+		ctx context.Context,
+		dbc DBExecutor,
+		id string,
+		assignedTo sql.NullString,
+	) (Entity, error)
+	UpdateEntityTeam(ctx context.Context, dbc DBExecutor, id string, teamID sql.NullString) (Entity, error)
+	DeleteEntity(ctx context.Context, dbc DBExecutor, id string) error
+	InsertEntityHistory(ctx context.Context, dbc DBExecutor, arg InsertEntityHistoryParams) error
+	ListEntityHistory(ctx context.Context, dbc DBExecutor, entityID string) ([]EntityHistory, error)
+	ListEntities(ctx context.Context, dbc DBExecutor, filter EntityFilter) ([]Entity, error)
+	IterateEntities(
+		ctx context.Context,
+		dbc DBExecutor,
+		filter EntityFilter,
+		f func(Entity) error,
+	) error
+
+	GetTask(ctx context.Context, dbc DBExecutor, id string) (Task, error)
+	UpdateTaskStatus(ctx context.Context, dbc DBExecutor, id, status string) (Task, error)
+	UpdateTaskTeam(ctx context.Context, dbc DBExecutor, id string, teamID sql.NullString) (Task, error)
+	ListTasks(ctx context.Context, dbc DBExecutor, filter TaskFilter) ([]Task, error)
+	IterateTasks(ctx context.Context, dbc DBExecutor, filter TaskFilter, f func(Task) error) error
+	ReassignTasksEntity(ctx context.Context, dbc DBExecutor, fromEntityID, toEntityID string) (int64, error)
+	InsertTask(ctx context.Context, dbc DBExecutor, arg InsertTaskParams) (Task, error)
+	ListTasksPendingReminder(ctx context.Context, dbc DBExecutor, before string) ([]Task, error)
+	ClaimTaskReminder(ctx context.Context, dbc DBExecutor, taskID, kind, dueDate, sentAt string) (bool, error)
+
+	UpdateEntityStatus(ctx context.Context, dbc DBExecutor, id, status string) (Entity, error)
+	UpdateEntityScore(ctx context.Context, dbc DBExecutor, id string, score int, scoredAt string) error
+	InsertActivity(ctx context.Context, dbc DBExecutor, arg InsertActivityParams) (Activity, error)
+	ListActivities(ctx context.Context, dbc DBExecutor, entityID string) ([]Activity, error)
+	ReassignActivitiesEntity(ctx context.Context, dbc DBExecutor, fromEntityID, toEntityID string) (int64, error)
+	InsertScoringRule(ctx context.Context, dbc DBExecutor, arg InsertScoringRuleParams) (ScoringRule, error)
+	ListScoringRules(ctx context.Context, dbc DBExecutor) ([]ScoringRule, error)
+	DeleteScoringRule(ctx context.Context, dbc DBExecutor, id string) error
+
+	InsertAssignmentRule(
+		ctx context.Context,
+		dbc DBExecutor,
+		arg InsertAssignmentRuleParams,
+	) (AssignmentRule, error)
+	InsertAssignmentRuleMember(ctx context.Context, dbc DBExecutor, arg InsertAssignmentRuleMemberParams) error
+	ListAssignmentRules(ctx context.Context, dbc DBExecutor) ([]AssignmentRule, error)
+	ListAssignmentRuleMembers(ctx context.Context, dbc DBExecutor, ruleID string) ([]AssignmentRuleMember, error)
+	UpdateAssignmentRuleMemberWeight(
+		ctx context.Context,
+		dbc DBExecutor,
+		ruleID, userID string,
+		currentWeight int,
+	) error
+	DeleteAssignmentRule(ctx context.Context, dbc DBExecutor, id string) error
+
+	InsertWorkflow(ctx context.Context, dbc DBExecutor, arg InsertWorkflowParams) (Workflow, error)
+	ListWorkflows(ctx context.Context, dbc DBExecutor) ([]Workflow, error)
+	ListEnabledWorkflowsByTrigger(ctx context.Context, dbc DBExecutor, trigger string) ([]Workflow, error)
+	SetWorkflowEnabled(ctx context.Context, dbc DBExecutor, id string, enabled bool) (Workflow, error)
+	DeleteWorkflow(ctx context.Context, dbc DBExecutor, id string) error
+	InsertWorkflowRun(ctx context.Context, dbc DBExecutor, arg InsertWorkflowRunParams) (WorkflowRun, error)
+	ListWorkflowRuns(ctx context.Context, dbc DBExecutor, workflowID string, limit int) ([]WorkflowRun, error)
+
+	InsertNotification(ctx context.Context, dbc DBExecutor, arg InsertNotificationParams) (Notification, bool, error)
+	GetNotification(ctx context.Context, dbc DBExecutor, id string) (Notification, error)
+	ListNotifications(ctx context.Context, dbc DBExecutor, filter NotificationFilter) ([]Notification, error)
+	CountUnreadNotifications(ctx context.Context, dbc DBExecutor, userID string) (int, error)
+	MarkNotificationRead(ctx context.Context, dbc DBExecutor, userID, id, readAt string) (Notification, error)
+	MarkAllNotificationsRead(ctx context.Context, dbc DBExecutor, userID, readAt string) (int64, error)
+
+	InsertOutboxEmail(ctx context.Context, dbc DBExecutor, arg InsertOutboxEmailParams) (OutboxEmail, error)
+	GetOutboxEmail(ctx context.Context, dbc DBExecutor, id string) (OutboxEmail, error)
+	ListDueOutboxEmails(ctx context.Context, dbc DBExecutor, now string, limit int) ([]OutboxEmail, error)
+	ListOutboxEmails(ctx context.Context, dbc DBExecutor, filter OutboxEmailFilter) ([]OutboxEmail, error)
+	UpdateOutboxEmail(ctx context.Context, dbc DBExecutor, arg UpdateOutboxEmailParams) (OutboxEmail, error)
+
+	InsertInboundEmail(ctx context.Context, dbc DBExecutor, arg InsertInboundEmailParams) (InboundEmail, bool, error)
+	GetInboundEmail(ctx context.Context, dbc DBExecutor, id string) (InboundEmail, error)
+	GetInboundEmailByMessageID(ctx context.Context, dbc DBExecutor, messageID string) (InboundEmail, error)
+	InsertInboundEmailAttachment(ctx context.Context, dbc DBExecutor, arg InboundEmailAttachment) error
+	ListInboundEmailAttachments(ctx context.Context, dbc DBExecutor, emailID string) ([]InboundEmailAttachment, error)
+	ListEntitiesByEmailCanonical(ctx context.Context, dbc DBExecutor, canonicals []string) ([]Entity, error)
+
+	InsertLeadForm(ctx context.Context, dbc DBExecutor, arg InsertLeadFormParams) (LeadForm, error)
+	GetLeadFormByKey(ctx context.Context, dbc DBExecutor, key string) (LeadForm, error)
+	ListLeadForms(ctx context.Context, dbc DBExecutor) ([]LeadForm, error)
+	SetLeadFormEnabled(ctx context.Context, dbc DBExecutor, id string, enabled bool) (LeadForm, error)
+	SetLeadFormKey(ctx context.Context, dbc DBExecutor, id, key string) (LeadForm, error)
+	DeleteLeadForm(ctx context.Context, dbc DBExecutor, id string) error
+
+	InsertCustomField(ctx context.Context, dbc DBExecutor, arg InsertCustomFieldParams) (CustomField, error)
+	ListCustomFields(ctx context.Context, dbc DBExecutor, objectType string) ([]CustomField, error)
+	DeleteCustomField(ctx context.Context, dbc DBExecutor, id string) error
+	ListCustomFieldValues(ctx context.Context, dbc DBExecutor, recordIDs []string) ([]CustomFieldValue, error)
+	SetCustomFieldValue(ctx context.Context, dbc DBExecutor, fieldID, recordID, value string) error
+	DeleteCustomFieldValue(ctx context.Context, dbc DBExecutor, fieldID, recordID string) error
+	MoveCustomFieldValues(ctx context.Context, dbc DBExecutor, fromRecordID, toRecordID string) error
+
+	EnsureTag(ctx context.Context, dbc DBExecutor, id, name string) (Tag, error)
+	GetTagByName(ctx context.Context, dbc DBExecutor, name string) (Tag, error)
+	ListTags(ctx context.Context, dbc DBExecutor) ([]TagCount, error)
+	DeleteTag(ctx context.Context, dbc DBExecutor, id string) error
+	TagEntity(ctx context.Context, dbc DBExecutor, tagID, entityID string) (bool, error)
+	UntagEntity(ctx context.Context, dbc DBExecutor, tagID, entityID string) (bool, error)
+	ListEntityTags(ctx context.Context, dbc DBExecutor, entityIDs []string) ([]EntityTag, error)
+	MoveEntityTags(ctx context.Context, dbc DBExecutor, fromEntityID, toEntityID string) error
+
+	InsertSegment(ctx context.Context, dbc DBExecutor, arg InsertSegmentParams) (Segment, error)
+	GetSegment(ctx context.Context, dbc DBExecutor, id string) (Segment, error)
+	ListSegments(ctx context.Context, dbc DBExecutor) ([]Segment, error)
+	DeleteSegment(ctx context.Context, dbc DBExecutor, id string) error
+
+	InsertSavedView(ctx context.Context, dbc DBExecutor, arg InsertSavedViewParams) (SavedView, error)
+	GetSavedView(ctx context.Context, dbc DBExecutor, id string) (SavedView, error)
+	ListSavedViews(ctx context.Context, dbc DBExecutor, userID, resource string) ([]SavedView, error)
+	DeleteSavedView(ctx context.Context, dbc DBExecutor, id string) error
+	SetListPreference(ctx context.Context, dbc DBExecutor, arg ListPreference) error
+	DeleteListPreference(ctx context.Context, dbc DBExecutor, userID, resource string) error
+	ListListPreferences(ctx context.Context, dbc DBExecutor, userID string) ([]ListPreference, error)
+
+	InsertTeam(ctx context.Context, dbc DBExecutor, arg InsertTeamParams) (Team, error)
+	GetTeam(ctx context.Context, dbc DBExecutor, id string) (Team, error)
+	ListTeams(ctx context.Context, dbc DBExecutor) ([]Team, error)
+	DeleteTeam(ctx context.Context, dbc DBExecutor, id string) error
+	SetTeamMember(ctx context.Context, dbc DBExecutor, arg TeamMember) error
+	DeleteTeamMember(ctx context.Context, dbc DBExecutor, teamID, userID string) error
+	ListTeamMembers(ctx context.Context, dbc DBExecutor, teamIDs []string) ([]TeamMember, error)
+	ListVisibleTeamIDs(ctx context.Context, dbc DBExecutor, userID string) ([]string, error)
+	ListVisibleUserIDs(ctx context.Context, dbc DBExecutor, userID string) ([]string, error)
+
+	InsertAttachment(ctx context.Context, dbc DBExecutor, arg InsertAttachmentParams) (Attachment, error)
+	GetAttachment(ctx context.Context, dbc DBExecutor, id string) (Attachment, error)
+	ListEntityAttachments(ctx context.Context, dbc DBExecutor, entityID string) ([]Attachment, error)
+	ListTaskAttachments(ctx context.Context, dbc DBExecutor, taskID string) ([]Attachment, error)
+	DeleteAttachment(ctx context.Context, dbc DBExecutor, id string) error
+	MoveEntityAttachments(ctx context.Context, dbc DBExecutor, fromEntityID, toEntityID string) error
+	CountBlobAttachments(ctx context.Context, dbc DBExecutor, blobKey string) (int, error)
+
+	CountEntitiesCreated(ctx context.Context, dbc DBExecutor, filter ReportFilter) ([]EntityCount, error)
+	GetConversionStats(ctx context.Context, dbc DBExecutor, filter ReportFilter) ([]ConversionStats, error)
+	GetTaskStats(ctx context.Context, dbc DBExecutor, filter ReportFilter, now string) ([]TaskStats, error)
+	InsertScheduledReport(ctx context.Context, dbc DBExecutor, arg InsertScheduledReportParams) (ScheduledReport, error)
+	GetScheduledReport(ctx context.Context, dbc DBExecutor, id string) (ScheduledReport, error)
+	ListScheduledReports(ctx context.Context, dbc DBExecutor) ([]ScheduledReport, error)
+	ListDueScheduledReports(ctx context.Context, dbc DBExecutor, now string) ([]ScheduledReport, error)
+	ClaimScheduledReportRun(ctx context.Context, dbc DBExecutor, id, nextRunAt, following string) (bool, error)
+	DeleteScheduledReport(ctx context.Context, dbc DBExecutor, id string) error
+	InsertReportSnapshot(ctx context.Context, dbc DBExecutor, arg InsertReportSnapshotParams) (ReportSnapshot, error)
+	ListReportSnapshots(ctx context.Context, dbc DBExecutor, reportID string, limit, offset int) ([]ReportSnapshot, error)
+	DeleteReportSnapshots(ctx context.Context, dbc DBExecutor, reportID string) error
+
+	InsertProduct(ctx context.Context, dbc DBExecutor, arg InsertProductParams) (Product, error)
+	GetProduct(ctx context.Context, dbc DBExecutor, id string) (Product, error)
+	ListProducts(ctx context.Context, dbc DBExecutor, includeInactive bool) ([]Product, error)
+	SetProductActive(ctx context.Context, dbc DBExecutor, id string, active bool) (Product, error)
+	SetProductPrice(ctx context.Context, dbc DBExecutor, arg ProductPrice) (ProductPrice, error)
+	DeleteProductPrice(ctx context.Context, dbc DBExecutor, productID, currency string) error
+	ListProductPrices(ctx context.Context, dbc DBExecutor, productIDs []string) ([]ProductPrice, error)
+	InsertQuote(ctx context.Context, dbc DBExecutor, arg InsertQuoteParams) (Quote, error)
+	GetQuote(ctx context.Context, dbc DBExecutor, id string) (Quote, error)
+	ListQuotes(ctx context.Context, dbc DBExecutor, filter QuoteFilter) ([]Quote, error)
+	UpdateQuote(ctx context.Context, dbc DBExecutor, arg UpdateQuoteParams) (Quote, error)
+	UpdateQuoteStatus(ctx context.Context, dbc DBExecutor, arg UpdateQuoteStatusParams) (Quote, error)
+	TouchQuote(ctx context.Context, dbc DBExecutor, id string) error
+	MoveEntityQuotes(ctx context.Context, dbc DBExecutor, fromEntityID, toEntityID string) error
+	InsertQuoteLine(ctx context.Context, dbc DBExecutor, arg InsertQuoteLineParams) (QuoteLine, error)
+	UpdateQuoteLine(ctx context.Context, dbc DBExecutor, arg UpdateQuoteLineParams) (QuoteLine, error)
+	DeleteQuoteLine(ctx context.Context, dbc DBExecutor, id, quoteID string) error
+	ListQuoteLines(ctx context.Context, dbc DBExecutor, quoteIDs []string) ([]QuoteLine, error)
+	ListDecidedQuoteTotals(ctx context.Context, dbc DBExecutor, filter ReportFilter) ([]QuoteTotal, error)
+
+	SetExchangeRate(ctx context.Context, dbc DBExecutor, arg SetExchangeRateParams) (ExchangeRate, error)
+	ListExchangeRates(ctx context.Context, dbc DBExecutor, filter ExchangeRateFilter) ([]ExchangeRate, error)
+	DeleteExchangeRate(ctx context.Context, dbc DBExecutor, fromCurrency, toCurrency, effectiveOn string) error
+
+	InsertImportJob(ctx context.Context, dbc DBExecutor, arg InsertImportJobParams) (ImportJob, error)
+	GetImportJob(ctx context.Context, dbc DBExecutor, id string) (ImportJob, error)
+	ListImportJobsByStatus(ctx context.Context, dbc DBExecutor, statuses []string) ([]ImportJob, error)
+	UpdateImportJobProgress(ctx context.Context, dbc DBExecutor, arg UpdateImportJobProgressParams) error
+	InsertImportError(ctx context.Context, dbc DBExecutor, arg InsertImportErrorParams) error
+	ListImportErrors(ctx context.Context, dbc DBExecutor, jobID string) ([]ImportError, error)
+
+	InsertWorkspace(ctx context.Context, dbc DBExecutor, arg InsertWorkspaceParams) (Workspace, error)
+	GetWorkspace(ctx context.Context, dbc DBExecutor, id string) (Workspace, error)
+	SetWorkspaceBaseCurrency(ctx context.Context, dbc DBExecutor, id, currency string) (Workspace, error)
+	ListWorkspaces(ctx context.Context, dbc DBExecutor) ([]Workspace, error)
+	GetUserWorkspaceID(ctx context.Context, dbc DBExecutor, userID string) (string, error)
+	GetLeadFormWorkspaceID(ctx context.Context, dbc DBExecutor, key string) (string, error)
 }
 
 var _ Querier = (*Queries)(nil)
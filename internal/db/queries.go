@@ -8,10 +8,10 @@ type Queries struct{}
 
 func (q *Queries) GetUser(ctx context.Context, dbc DBExecutor, id string) (User, error) {
 	query := `
-	SELECT * FROM users WHERE id = :id
+	SELECT * FROM users WHERE id = :id AND workspace_id = :workspace_id
 	`
 
-	query, args, err := dbc.BindNamed(query, map[string]any{
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
 		"id": id,
 	})
 	if err != nil {
@@ -33,12 +33,12 @@ func (q *Queries) InsertAndReturnUser(
 	arg InsertAndReturnUserParams,
 ) (User, error) {
 	query := `
-	INSERT INTO users (id, first_name, last_name, email) 
-	VALUES (:id, :first_name, :last_name, :email) 
+	INSERT INTO users (id, workspace_id, first_name, last_name, email) 
+	VALUES (:id, :workspace_id, :first_name, :last_name, :email) 
 	RETURNING *
 	`
 
-	query, args, err := dbc.BindNamed(query, map[string]any{
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
 		"id":         arg.ID,
 		"first_name": arg.FirstName,
 		"last_name":  arg.LastName,
@@ -57,6 +57,87 @@ func (q *Queries) InsertAndReturnUser(
 	return user, nil
 }
 
+func (q *Queries) ListUsers(ctx context.Context, dbc DBExecutor) ([]User, error) {
+	query := `
+	SELECT * FROM users WHERE workspace_id = :workspace_id ORDER BY created_at, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	users := []User{}
+	err = dbc.SelectContext(ctx, &users, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, dbc DBExecutor, id, email string) error {
+	query := `
+	UPDATE users SET email = :email WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":    id,
+		"email": email,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) SetUserActive(ctx context.Context, dbc DBExecutor, id string, active bool) (User, error) {
+	query := `
+	UPDATE users SET active = :active WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":     id,
+		"active": active,
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	var user User
+	err = dbc.GetContext(ctx, &user, query, args...)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (q *Queries) SetUserCalendarToken(ctx context.Context, dbc DBExecutor, id, token string) (User, error) {
+	query := `
+	UPDATE users SET calendar_token = :calendar_token WHERE id = :id AND workspace_id = :workspace_id
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":             id,
+		"calendar_token": token,
+	})
+	if err != nil {
+		return User{}, err
+	}
+
+	var user User
+	err = dbc.GetContext(ctx, &user, query, args...)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
 func NewQueries() Querier {
 	return &Queries{}
 }
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (q *Queries) InsertAssignmentRule(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertAssignmentRuleParams,
+) (AssignmentRule, error) {
+	query := `
+	INSERT INTO assignment_rules (id, workspace_id, name, priority, field, value, strategy)
+	VALUES (:id, :workspace_id, :name, :priority, :field, :value, :strategy)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":       arg.ID,
+		"name":     arg.Name,
+		"priority": arg.Priority,
+		"field":    arg.Field,
+		"value":    arg.Value,
+		"strategy": arg.Strategy,
+	})
+	if err != nil {
+		return AssignmentRule{}, err
+	}
+
+	var rule AssignmentRule
+	err = dbc.GetContext(ctx, &rule, query, args...)
+	if err != nil {
+		return AssignmentRule{}, err
+	}
+
+	return rule, nil
+}
+
+func (q *Queries) InsertAssignmentRuleMember(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertAssignmentRuleMemberParams,
+) error {
+	query := `
+	INSERT INTO assignment_rule_members (rule_id, user_id, weight, workspace_id)
+	VALUES (:rule_id, :user_id, :weight, :workspace_id)
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"rule_id": arg.RuleID,
+		"user_id": arg.UserID,
+		"weight":  arg.Weight,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) ListAssignmentRules(ctx context.Context, dbc DBExecutor) ([]AssignmentRule, error) {
+	query := `
+	SELECT * FROM assignment_rules WHERE workspace_id = :workspace_id ORDER BY priority, created_at, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []AssignmentRule{}
+	err = dbc.SelectContext(ctx, &rules, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// ListAssignmentRuleMembers returns a rule's members along with whether each
+// user is still active.
+func (q *Queries) ListAssignmentRuleMembers(
+	ctx context.Context,
+	dbc DBExecutor,
+	ruleID string,
+) ([]AssignmentRuleMember, error) {
+	query := `
+	SELECT m.rule_id, m.user_id, m.weight, m.current_weight, u.active
+	FROM assignment_rule_members m
+	JOIN users u ON u.id = m.user_id AND u.workspace_id = m.workspace_id
+	WHERE m.rule_id = :rule_id AND m.workspace_id = :workspace_id
+	ORDER BY m.user_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"rule_id": ruleID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	members := []AssignmentRuleMember{}
+	err = dbc.SelectContext(ctx, &members, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (q *Queries) UpdateAssignmentRuleMemberWeight(
+	ctx context.Context,
+	dbc DBExecutor,
+	ruleID, userID string,
+	currentWeight int,
+) error {
+	query := `
+	UPDATE assignment_rule_members SET current_weight = :current_weight
+	WHERE rule_id = :rule_id AND user_id = :user_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"rule_id":        ruleID,
+		"user_id":        userID,
+		"current_weight": currentWeight,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) DeleteAssignmentRule(ctx context.Context, dbc DBExecutor, id string) error {
+	var deleted int64
+	for _, query := range []string{
+		`DELETE FROM assignment_rule_members WHERE rule_id = :id AND workspace_id = :workspace_id`,
+		`DELETE FROM assignment_rules WHERE id = :id AND workspace_id = :workspace_id`,
+	} {
+		query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+			"id": id,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := dbc.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (q *Queries) InsertAttachment(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertAttachmentParams,
+) (Attachment, error) {
+	query := `
+	INSERT INTO attachments (
+		id, workspace_id, entity_id, task_id, filename, content_type, size, blob_key, uploaded_by
+	)
+	VALUES (
+		:id, :workspace_id, :entity_id, :task_id, :filename, :content_type, :size, :blob_key, :uploaded_by
+	)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":           arg.ID,
+		"entity_id":    arg.EntityID,
+		"task_id":      arg.TaskID,
+		"filename":     arg.Filename,
+		"content_type": arg.ContentType,
+		"size":         arg.Size,
+		"blob_key":     arg.BlobKey,
+		"uploaded_by":  arg.UploadedBy,
+	})
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	var attachment Attachment
+	err = dbc.GetContext(ctx, &attachment, query, args...)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+func (q *Queries) GetAttachment(ctx context.Context, dbc DBExecutor, id string) (Attachment, error) {
+	query := `
+	SELECT * FROM attachments WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	var attachment Attachment
+	err = dbc.GetContext(ctx, &attachment, query, args...)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// ListEntityAttachments returns the files attached to a lead or contact,
+// oldest first.
+func (q *Queries) ListEntityAttachments(ctx context.Context, dbc DBExecutor, entityID string) ([]Attachment, error) {
+	return q.listAttachments(ctx, dbc, "entity_id", entityID)
+}
+
+// ListTaskAttachments returns the files attached to a task, oldest first.
+func (q *Queries) ListTaskAttachments(ctx context.Context, dbc DBExecutor, taskID string) ([]Attachment, error) {
+	return q.listAttachments(ctx, dbc, "task_id", taskID)
+}
+
+func (q *Queries) listAttachments(ctx context.Context, dbc DBExecutor, column, recordID string) ([]Attachment, error) {
+	query := `
+	SELECT * FROM attachments WHERE ` + column + ` = :record_id AND workspace_id = :workspace_id
+	ORDER BY created_at, rowid
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"record_id": recordID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := []Attachment{}
+	err = dbc.SelectContext(ctx, &attachments, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+func (q *Queries) DeleteAttachment(ctx context.Context, dbc DBExecutor, id string) error {
+	query := `
+	DELETE FROM attachments WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// MoveEntityAttachments moves one entity's attachments onto another.
+func (q *Queries) MoveEntityAttachments(ctx context.Context, dbc DBExecutor, fromEntityID, toEntityID string) error {
+	query := `
+	UPDATE attachments SET entity_id = :to_entity_id
+	WHERE entity_id = :from_entity_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"from_entity_id": fromEntityID,
+		"to_entity_id":   toEntityID,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+// CountBlobAttachments returns how many attachments share the content stored
+// under blobKey. Blobs are shared across workspaces, so every workspace is
+// counted.
+func (q *Queries) CountBlobAttachments(ctx context.Context, dbc DBExecutor, blobKey string) (int, error) {
+	query := `
+	SELECT COUNT(*) FROM attachments WHERE blob_key = :blob_key
+	`
+
+	query, args, err := dbc.BindNamed(query, map[string]any{
+		"blob_key": blobKey,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = dbc.GetContext(ctx, &count, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+func (q *Queries) InsertCustomField(ctx context.Context, dbc DBExecutor, arg InsertCustomFieldParams) (CustomField, error) {
+	query := `
+	INSERT INTO custom_fields (id, workspace_id, object_type, key, label, type, options)
+	VALUES (:id, :workspace_id, :object_type, :key, :label, :type, :options)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"object_type": arg.ObjectType,
+		"key":         arg.Key,
+		"label":       arg.Label,
+		"type":        arg.Type,
+		"options":     arg.Options,
+	})
+	if err != nil {
+		return CustomField{}, err
+	}
+
+	var field CustomField
+	err = dbc.GetContext(ctx, &field, query, args...)
+	if err != nil {
+		return CustomField{}, err
+	}
+
+	return field, nil
+}
+
+// ListCustomFields returns the custom fields of an object type, or of every
+// object type if it is empty, oldest first.
+func (q *Queries) ListCustomFields(ctx context.Context, dbc DBExecutor, objectType string) ([]CustomField, error) {
+	query := `
+	SELECT * FROM custom_fields
+	WHERE workspace_id = :workspace_id AND (:object_type = '' OR object_type = :object_type)
+	ORDER BY created_at, rowid
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"object_type": objectType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []CustomField{}
+	err = dbc.SelectContext(ctx, &fields, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// DeleteCustomField deletes a custom field along with its values.
+func (q *Queries) DeleteCustomField(ctx context.Context, dbc DBExecutor, id string) error {
+	var deleted int64
+	for _, query := range []string{
+		`DELETE FROM custom_field_values WHERE field_id = :id AND workspace_id = :workspace_id`,
+		`DELETE FROM custom_fields WHERE id = :id AND workspace_id = :workspace_id`,
+	} {
+		query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+			"id": id,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := dbc.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListCustomFieldValues returns the custom field values of the given records.
+func (q *Queries) ListCustomFieldValues(
+	ctx context.Context,
+	dbc DBExecutor,
+	recordIDs []string,
+) ([]CustomFieldValue, error) {
+	if len(recordIDs) == 0 {
+		return []CustomFieldValue{}, nil
+	}
+
+	query, args, err := bindWorkspaceIn(ctx, dbc, `
+	SELECT v.field_id, v.record_id, v.value, f.object_type, f.key, f.type
+	FROM custom_field_values v JOIN custom_fields f ON f.id = v.field_id
+	WHERE v.record_id IN (:record_ids) AND v.workspace_id = :workspace_id AND f.workspace_id = :workspace_id
+	ORDER BY v.record_id, f.created_at, f.rowid
+	`, map[string]any{
+		"record_ids": recordIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := []CustomFieldValue{}
+	err = dbc.SelectContext(ctx, &values, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (q *Queries) SetCustomFieldValue(ctx context.Context, dbc DBExecutor, fieldID, recordID, value string) error {
+	query := `
+	INSERT INTO custom_field_values (field_id, record_id, value, workspace_id)
+	VALUES (:field_id, :record_id, :value, :workspace_id)
+	ON CONFLICT (field_id, record_id) DO UPDATE SET value = excluded.value
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"field_id":  fieldID,
+		"record_id": recordID,
+		"value":     value,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) DeleteCustomFieldValue(ctx context.Context, dbc DBExecutor, fieldID, recordID string) error {
+	query := `
+	DELETE FROM custom_field_values
+	WHERE field_id = :field_id AND record_id = :record_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"field_id":  fieldID,
+		"record_id": recordID,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+// MoveCustomFieldValues moves one record's custom field values onto another.
+// Values the other record already has are kept and the moved ones dropped.
+func (q *Queries) MoveCustomFieldValues(ctx context.Context, dbc DBExecutor, fromRecordID, toRecordID string) error {
+	for _, query := range []string{
+		`UPDATE OR IGNORE custom_field_values SET record_id = :to_record_id
+		WHERE record_id = :from_record_id AND workspace_id = :workspace_id`,
+		`DELETE FROM custom_field_values WHERE record_id = :from_record_id AND workspace_id = :workspace_id`,
+	} {
+		query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+			"from_record_id": fromRecordID,
+			"to_record_id":   toRecordID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := dbc.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// customFieldClauses returns the WHERE conditions applying filters to the rows
+// of table and, if sort is set, the ORDER BY expression it sorts by.
+func customFieldClauses(
+	table string,
+	filters []CustomFieldFilter,
+	sort *CustomFieldSort,
+	params map[string]any,
+) (where, orderBy string) {
+	column := func(numeric bool) string {
+		if numeric {
+			return "CAST(v.value AS REAL)"
+		}
+		return "v.value"
+	}
+
+	for n, filter := range filters {
+		field, value := fmt.Sprintf("cf_field_%d", n), fmt.Sprintf("cf_value_%d", n)
+		op := "="
+		switch filter.Op {
+		case CustomFieldMin:
+			op = ">="
+		case CustomFieldMax:
+			op = "<="
+		}
+		where += fmt.Sprintf(
+			" AND EXISTS (SELECT 1 FROM custom_field_values v WHERE v.record_id = %s.id AND v.workspace_id = %s.workspace_id AND v.field_id = :%s AND %s %s :%s)",
+			table, table, field, column(filter.Numeric), op, value,
+		)
+		params[field] = filter.FieldID
+		if filter.Numeric {
+			// Bound as a number so SQLite compares numerically
+			var f float64
+			fmt.Sscan(filter.Value, &f)
+			params[value] = f
+		} else {
+			params[value] = filter.Value
+		}
+	}
+
+	if sort != nil {
+		params["cf_sort_field"] = sort.FieldID
+		sortValue := fmt.Sprintf(
+			"(SELECT %s FROM custom_field_values v WHERE v.record_id = %s.id AND v.workspace_id = %s.workspace_id AND v.field_id = :cf_sort_field)",
+			column(sort.Numeric), table, table,
+		)
+		orderBy = sortValue + " IS NULL, " + sortValue
+		if sort.Desc {
+			orderBy += " DESC"
+		}
+		orderBy += ", id"
+	}
+
+	return where, orderBy
+}
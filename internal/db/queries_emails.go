@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+)
+
+// InsertOutboxEmail queues a message for immediate delivery.
+func (q *Queries) InsertOutboxEmail(ctx context.Context, dbc DBExecutor, arg InsertOutboxEmailParams) (OutboxEmail, error) {
+	query := `
+	INSERT INTO email_outbox (id, workspace_id, from_address, to_addresses, subject, body, html, next_attempt_at, created_at)
+	VALUES (:id, :workspace_id, :from_address, :to_addresses, :subject, :body, :html, :created_at, :created_at)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":           arg.ID,
+		"from_address": arg.FromAddress,
+		"to_addresses": arg.ToAddresses,
+		"subject":      arg.Subject,
+		"body":         arg.Body,
+		"html":         arg.HTML,
+		"created_at":   arg.CreatedAt,
+	})
+	if err != nil {
+		return OutboxEmail{}, err
+	}
+
+	var email OutboxEmail
+	err = dbc.GetContext(ctx, &email, query, args...)
+	if err != nil {
+		return OutboxEmail{}, err
+	}
+
+	return email, nil
+}
+
+func (q *Queries) GetOutboxEmail(ctx context.Context, dbc DBExecutor, id string) (OutboxEmail, error) {
+	query := `
+	SELECT * FROM email_outbox WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return OutboxEmail{}, err
+	}
+
+	var email OutboxEmail
+	err = dbc.GetContext(ctx, &email, query, args...)
+	if err != nil {
+		return OutboxEmail{}, err
+	}
+
+	return email, nil
+}
+
+// ListDueOutboxEmails returns up to limit pending messages whose next attempt
+// is due by now, oldest first.
+func (q *Queries) ListDueOutboxEmails(ctx context.Context, dbc DBExecutor, now string, limit int) ([]OutboxEmail, error) {
+	query := `
+	SELECT * FROM email_outbox
+	WHERE workspace_id = :workspace_id AND status = 'pending' AND next_attempt_at <= :now
+	ORDER BY next_attempt_at, created_at, id
+	LIMIT :limit
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"now":   now,
+		"limit": limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	emails := []OutboxEmail{}
+	err = dbc.SelectContext(ctx, &emails, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
+
+func (q *Queries) ListOutboxEmails(ctx context.Context, dbc DBExecutor, filter OutboxEmailFilter) ([]OutboxEmail, error) {
+	query := `
+	SELECT * FROM email_outbox WHERE workspace_id = :workspace_id
+	`
+	params := map[string]any{}
+
+	if filter.Status != "" {
+		query += " AND status = :status"
+		params["status"] = filter.Status
+	}
+	query += " ORDER BY created_at DESC, id"
+	query += limitClause(filter.Limit, filter.Offset, params)
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	emails := []OutboxEmail{}
+	err = dbc.SelectContext(ctx, &emails, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
+
+// UpdateOutboxEmail records the outcome of a delivery attempt.
+func (q *Queries) UpdateOutboxEmail(ctx context.Context, dbc DBExecutor, arg UpdateOutboxEmailParams) (OutboxEmail, error) {
+	query := `
+	UPDATE email_outbox
+	SET status = :status, attempts = :attempts, last_error = :last_error,
+		next_attempt_at = :next_attempt_at, sent_at = :sent_at
+	WHERE id = :id AND workspace_id = :workspace_id
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":              arg.ID,
+		"status":          arg.Status,
+		"attempts":        arg.Attempts,
+		"last_error":      arg.LastError,
+		"next_attempt_at": arg.NextAttemptAt,
+		"sent_at":         arg.SentAt,
+	})
+	if err != nil {
+		return OutboxEmail{}, err
+	}
+
+	var email OutboxEmail
+	err = dbc.GetContext(ctx, &email, query, args...)
+	if err != nil {
+		return OutboxEmail{}, err
+	}
+
+	return email, nil
+}
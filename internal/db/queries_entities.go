@@ -0,0 +1,390 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+func (q *Queries) GetEntity(ctx context.Context, dbc DBExecutor, id string) (Entity, error) {
+	query := `
+	SELECT * FROM entities WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Entity{}, err
+	}
+
+	var entity Entity
+	err = dbc.GetContext(ctx, &entity, query, args...)
+	if err != nil {
+		return Entity{}, err
+	}
+
+	return entity, nil
+}
+
+func (q *Queries) InsertAndReturnEntity(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertAndReturnEntityParams,
+) (Entity, error) {
+	query := `
+	INSERT INTO entities (
+		id, workspace_id, first_name, last_name, email, email_canonical, phone, status, assigned_to, created_at,
+		converted_at, source, region, utm_source, utm_medium, utm_campaign, utm_term, utm_content, team_id
+	)
+	VALUES (
+		:id, :workspace_id, :first_name, :last_name, :email, :email_canonical, :phone, :status, :assigned_to, :created_at,
+		:converted_at, :source, :region, :utm_source, :utm_medium, :utm_campaign, :utm_term, :utm_content, :team_id
+	)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":              arg.ID,
+		"first_name":      arg.FirstName,
+		"last_name":       arg.LastName,
+		"email":           arg.Email,
+		"email_canonical": arg.EmailCanonical,
+		"phone":           arg.Phone,
+		"status":          arg.Status,
+		"assigned_to":     arg.AssignedTo,
+		"created_at":      arg.CreatedAt,
+		"converted_at":    arg.ConvertedAt,
+		"source":          arg.Source,
+		"region":          arg.Region,
+		"utm_source":      arg.UTMSource,
+		"utm_medium":      arg.UTMMedium,
+		"utm_campaign":    arg.UTMCampaign,
+		"utm_term":        arg.UTMTerm,
+		"utm_content":     arg.UTMContent,
+		"team_id":         arg.TeamID,
+	})
+	if err != nil {
+		return Entity{}, err
+	}
+
+	var entity Entity
+	err = dbc.GetContext(ctx, &entity, query, args...)
+	if err != nil {
+		return Entity{}, err
+	}
+
+	return entity, nil
+}
+
+func (q *Queries) ListEntities(ctx context.Context, dbc DBExecutor, filter EntityFilter) ([]Entity, error) {
+	entities := []Entity{}
+	err := q.IterateEntities(ctx, dbc, filter, func(entity Entity) error {
+		entities = append(entities, entity)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// IterateEntities streams the entities matching filter to f one row at a
+// time, stopping at the first error f returns.
+func (q *Queries) IterateEntities(
+	ctx context.Context,
+	dbc DBExecutor,
+	filter EntityFilter,
+	f func(Entity) error,
+) error {
+	query := `
+	SELECT * FROM entities WHERE workspace_id = :workspace_id
+	`
+	params := map[string]any{}
+
+	switch filter.Kind {
+	case "lead":
+		query += " AND status != 'converted'"
+	case "contact":
+		query += " AND status = 'converted'"
+	}
+	if filter.Status != "" {
+		query += " AND status = :status"
+		params["status"] = filter.Status
+	}
+	if filter.AssignedTo != "" {
+		query += " AND assigned_to = :assigned_to"
+		params["assigned_to"] = filter.AssignedTo
+	}
+	if filter.CreatedFrom != "" {
+		query += " AND created_at >= :created_from"
+		params["created_from"] = filter.CreatedFrom
+	}
+	if filter.CreatedTo != "" {
+		query += " AND created_at < :created_to"
+		params["created_to"] = filter.CreatedTo
+	}
+	if filter.TeamID != "" {
+		query += " AND team_id = :team_id"
+		params["team_id"] = filter.TeamID
+	}
+	if filter.VisibleTo != "" {
+		query += visibilityClause(filter.VisibleTo, params)
+	}
+	for n, tag := range filter.Tags {
+		query += fmt.Sprintf(" AND EXISTS (%s :tag_%d)", entityTagQuery, n)
+		params[fmt.Sprintf("tag_%d", n)] = tag
+	}
+	for n, tag := range filter.ExcludedTags {
+		query += fmt.Sprintf(" AND NOT EXISTS (%s :excluded_tag_%d)", entityTagQuery, n)
+		params[fmt.Sprintf("excluded_tag_%d", n)] = tag
+	}
+	where, customOrderBy := customFieldClauses("entities", filter.CustomFields, filter.CustomSort, params)
+	query += where
+	orderBy, ok := EntitySorts[filter.Sort]
+	if !ok {
+		orderBy = EntitySorts["created_at"]
+	}
+	if customOrderBy != "" {
+		orderBy = customOrderBy
+	}
+	query += " ORDER BY " + orderBy
+	query += limitClause(filter.Limit, filter.Offset, params)
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return err
+	}
+
+	rows, err := dbc.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entity Entity
+		if err := rows.StructScan(&entity); err != nil {
+			return err
+		}
+		if err := f(entity); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func limitClause(limit, offset int, params map[string]any) string {
+	if limit <= 0 {
+		return ""
+	}
+
+	params["limit"] = limit
+	params["offset"] = offset
+	return " LIMIT :limit OFFSET :offset"
+}
+
+func (q *Queries) UpdateEntity(ctx context.Context, dbc DBExecutor, arg UpdateEntityParams) (Entity, error) {
+	query := `
+	UPDATE entities SET
+		first_name = :first_name,
+		last_name = :last_name,
+		email = :email,
+		email_canonical = :email_canonical,
+		phone = :phone,
+		status = :status,
+		assigned_to = :assigned_to,
+		converted_at = :converted_at,
+		source = :source,
+		region = :region,
+		team_id = :team_id
+	WHERE id = :id AND workspace_id = :workspace_id
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":              arg.ID,
+		"first_name":      arg.FirstName,
+		"last_name":       arg.LastName,
+		"email":           arg.Email,
+		"email_canonical": arg.EmailCanonical,
+		"phone":           arg.Phone,
+		"status":          arg.Status,
+		"assigned_to":     arg.AssignedTo,
+		"converted_at":    arg.ConvertedAt,
+		"source":          arg.Source,
+		"region":          arg.Region,
+		"team_id":         arg.TeamID,
+	})
+	if err != nil {
+		return Entity{}, err
+	}
+
+	var entity Entity
+	err = dbc.GetContext(ctx, &entity, query, args...)
+	if err != nil {
+		return Entity{}, err
+	}
+
+	return entity, nil
+}
+
+func (q *Queries) DeleteEntity(ctx context.Context, dbc DBExecutor, id string) error {
+	query := `
+	DELETE FROM entities WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) InsertEntityHistory(ctx context.Context, dbc DBExecutor, arg InsertEntityHistoryParams) error {
+	query := `
+	INSERT INTO entity_history (entity_id, action, details, workspace_id)
+	VALUES (:entity_id, :action, :details, :workspace_id)
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"entity_id": arg.EntityID,
+		"action":    arg.Action,
+		"details":   arg.Details,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) ListEntityHistory(ctx context.Context, dbc DBExecutor, entityID string) ([]EntityHistory, error) {
+	query := `
+	SELECT * FROM entity_history WHERE entity_id = :entity_id AND workspace_id = :workspace_id ORDER BY id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"entity_id": entityID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	history := []EntityHistory{}
+	err = dbc.SelectContext(ctx, &history, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (q *Queries) UpdateEntityAssignee(
+	ctx context.Context,
+	dbc DBExecutor,
+	id string,
+	assignedTo sql.NullString,
+) (Entity, error) {
+	query := `
+	UPDATE entities SET assigned_to = :assigned_to WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          id,
+		"assigned_to": assignedTo,
+	})
+	if err != nil {
+		return Entity{}, err
+	}
+
+	var entity Entity
+	err = dbc.GetContext(ctx, &entity, query, args...)
+	if err != nil {
+		return Entity{}, err
+	}
+
+	return entity, nil
+}
+
+// UpdateEntityTeam hands an entity to a team, or takes it from its team
+// when teamID is NULL.
+func (q *Queries) UpdateEntityTeam(
+	ctx context.Context,
+	dbc DBExecutor,
+	id string,
+	teamID sql.NullString,
+) (Entity, error) {
+	query := `
+	UPDATE entities SET team_id = :team_id WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":      id,
+		"team_id": teamID,
+	})
+	if err != nil {
+		return Entity{}, err
+	}
+
+	var entity Entity
+	err = dbc.GetContext(ctx, &entity, query, args...)
+	if err != nil {
+		return Entity{}, err
+	}
+
+	return entity, nil
+}
+
+func (q *Queries) UpdateEntityScore(
+	ctx context.Context,
+	dbc DBExecutor,
+	id string,
+	score int,
+	scoredAt string,
+) error {
+	query := `
+	UPDATE entities SET score = :score, scored_at = :scored_at WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":        id,
+		"score":     score,
+		"scored_at": scoredAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) UpdateEntityStatus(ctx context.Context, dbc DBExecutor, id, status string) (Entity, error) {
+	query := `
+	UPDATE entities SET status = :status WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":     id,
+		"status": status,
+	})
+	if err != nil {
+		return Entity{}, err
+	}
+
+	var entity Entity
+	err = dbc.GetContext(ctx, &entity, query, args...)
+	if err != nil {
+		return Entity{}, err
+	}
+
+	return entity, nil
+}
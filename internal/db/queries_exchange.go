@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// SetExchangeRate sets the rate of a currency pair from a date, replacing
+// any rate the pair had from that date.
+func (q *Queries) SetExchangeRate(ctx context.Context, dbc DBExecutor, arg SetExchangeRateParams) (ExchangeRate, error) {
+	query := `
+	INSERT INTO exchange_rates (workspace_id, from_currency, to_currency, rate, effective_on)
+	VALUES (:workspace_id, :from_currency, :to_currency, :rate, :effective_on)
+	ON CONFLICT (workspace_id, from_currency, to_currency, effective_on) DO UPDATE SET
+		rate = excluded.rate,
+		created_at = CURRENT_TIMESTAMP
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"from_currency": arg.FromCurrency,
+		"to_currency":   arg.ToCurrency,
+		"rate":          arg.Rate,
+		"effective_on":  arg.EffectiveOn,
+	})
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+
+	var rate ExchangeRate
+	err = dbc.GetContext(ctx, &rate, query, args...)
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+
+	return rate, nil
+}
+
+// ListExchangeRates returns the rates matching filter by currency pair,
+// earliest first.
+func (q *Queries) ListExchangeRates(ctx context.Context, dbc DBExecutor, filter ExchangeRateFilter) ([]ExchangeRate, error) {
+	params := map[string]any{}
+	where := ""
+	if filter.Currency != "" {
+		where += " AND (from_currency = :currency OR to_currency = :currency)"
+		params["currency"] = filter.Currency
+	}
+	if filter.OnOrBefore != "" {
+		where += " AND effective_on <= :on_or_before"
+		params["on_or_before"] = filter.OnOrBefore
+	}
+
+	query := `
+	SELECT * FROM exchange_rates WHERE workspace_id = :workspace_id` + where + `
+	ORDER BY from_currency, to_currency, effective_on
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := []ExchangeRate{}
+	err = dbc.SelectContext(ctx, &rates, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+func (q *Queries) DeleteExchangeRate(
+	ctx context.Context,
+	dbc DBExecutor,
+	fromCurrency, toCurrency, effectiveOn string,
+) error {
+	query := `
+	DELETE FROM exchange_rates
+	WHERE workspace_id = :workspace_id
+	AND from_currency = :from_currency AND to_currency = :to_currency AND effective_on = :effective_on
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"from_currency": fromCurrency,
+		"to_currency":   toCurrency,
+		"effective_on":  effectiveOn,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (q *Queries) InsertLeadForm(ctx context.Context, dbc DBExecutor, arg InsertLeadFormParams) (LeadForm, error) {
+	query := `
+	INSERT INTO lead_forms (id, workspace_id, name, key, mapping, honeypot, redirect_url, source)
+	VALUES (:id, :workspace_id, :name, :key, :mapping, :honeypot, :redirect_url, :source)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":           arg.ID,
+		"name":         arg.Name,
+		"key":          arg.Key,
+		"mapping":      arg.Mapping,
+		"honeypot":     arg.Honeypot,
+		"redirect_url": arg.RedirectURL,
+		"source":       arg.Source,
+	})
+	if err != nil {
+		return LeadForm{}, err
+	}
+
+	var form LeadForm
+	err = dbc.GetContext(ctx, &form, query, args...)
+	if err != nil {
+		return LeadForm{}, err
+	}
+
+	return form, nil
+}
+
+func (q *Queries) GetLeadFormByKey(ctx context.Context, dbc DBExecutor, key string) (LeadForm, error) {
+	query := `
+	SELECT * FROM lead_forms WHERE key = :key AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"key": key,
+	})
+	if err != nil {
+		return LeadForm{}, err
+	}
+
+	var form LeadForm
+	err = dbc.GetContext(ctx, &form, query, args...)
+	if err != nil {
+		return LeadForm{}, err
+	}
+
+	return form, nil
+}
+
+func (q *Queries) ListLeadForms(ctx context.Context, dbc DBExecutor) ([]LeadForm, error) {
+	query := `
+	SELECT * FROM lead_forms WHERE workspace_id = :workspace_id ORDER BY created_at, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	forms := []LeadForm{}
+	err = dbc.SelectContext(ctx, &forms, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return forms, nil
+}
+
+func (q *Queries) SetLeadFormEnabled(ctx context.Context, dbc DBExecutor, id string, enabled bool) (LeadForm, error) {
+	query := `
+	UPDATE lead_forms SET enabled = :enabled WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":      id,
+		"enabled": enabled,
+	})
+	if err != nil {
+		return LeadForm{}, err
+	}
+
+	var form LeadForm
+	err = dbc.GetContext(ctx, &form, query, args...)
+	if err != nil {
+		return LeadForm{}, err
+	}
+
+	return form, nil
+}
+
+func (q *Queries) SetLeadFormKey(ctx context.Context, dbc DBExecutor, id, key string) (LeadForm, error) {
+	query := `
+	UPDATE lead_forms SET key = :key WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":  id,
+		"key": key,
+	})
+	if err != nil {
+		return LeadForm{}, err
+	}
+
+	var form LeadForm
+	err = dbc.GetContext(ctx, &form, query, args...)
+	if err != nil {
+		return LeadForm{}, err
+	}
+
+	return form, nil
+}
+
+func (q *Queries) DeleteLeadForm(ctx context.Context, dbc DBExecutor, id string) error {
+	query := `
+	DELETE FROM lead_forms WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+)
+
+// ReserveIdempotencyKey claims a key for an in-flight request. It returns false
+// when an unexpired row for the same key, method and path already exists.
+func (q *Queries) ReserveIdempotencyKey(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg ReserveIdempotencyKeyParams,
+) (bool, error) {
+	query := `
+	INSERT INTO idempotency_keys (workspace_id, key, method, path, request_hash, created_at, expires_at)
+	VALUES (:workspace_id, :key, :method, :path, :request_hash, :created_at, :expires_at)
+	ON CONFLICT (workspace_id, key, method, path) DO UPDATE SET
+		request_hash = excluded.request_hash,
+		status_code = 0,
+		content_type = '',
+		response_body = NULL,
+		created_at = excluded.created_at,
+		expires_at = excluded.expires_at
+	WHERE idempotency_keys.expires_at <= excluded.created_at
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"key":          arg.Key,
+		"method":       arg.Method,
+		"path":         arg.Path,
+		"request_hash": arg.RequestHash,
+		"created_at":   arg.CreatedAt,
+		"expires_at":   arg.ExpiresAt,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	res, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (q *Queries) GetIdempotencyKey(
+	ctx context.Context,
+	dbc DBExecutor,
+	key, method, path string,
+) (IdempotencyKey, error) {
+	query := `
+	SELECT * FROM idempotency_keys
+	WHERE workspace_id = :workspace_id AND key = :key AND method = :method AND path = :path
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"key":    key,
+		"method": method,
+		"path":   path,
+	})
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+
+	var idempotencyKey IdempotencyKey
+	err = dbc.GetContext(ctx, &idempotencyKey, query, args...)
+	if err != nil {
+		return IdempotencyKey{}, err
+	}
+
+	return idempotencyKey, nil
+}
+
+func (q *Queries) CompleteIdempotencyKey(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg CompleteIdempotencyKeyParams,
+) error {
+	query := `
+	UPDATE idempotency_keys
+	SET status_code = :status_code, content_type = :content_type, response_body = :response_body
+	WHERE workspace_id = :workspace_id AND key = :key AND method = :method AND path = :path
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"key":           arg.Key,
+		"method":        arg.Method,
+		"path":          arg.Path,
+		"status_code":   arg.StatusCode,
+		"content_type":  arg.ContentType,
+		"response_body": arg.ResponseBody,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) DeleteIdempotencyKey(
+	ctx context.Context,
+	dbc DBExecutor,
+	key, method, path string,
+) error {
+	query := `
+	DELETE FROM idempotency_keys
+	WHERE workspace_id = :workspace_id AND key = :key AND method = :method AND path = :path
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"key":    key,
+		"method": method,
+		"path":   path,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) DeleteExpiredIdempotencyKeys(
+	ctx context.Context,
+	dbc DBExecutor,
+	now string,
+) (int64, error) {
+	query := `
+	DELETE FROM idempotency_keys WHERE workspace_id = :workspace_id AND expires_at <= :now
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"now": now,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+)
+
+func (q *Queries) InsertImportJob(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertImportJobParams,
+) (ImportJob, error) {
+	query := `
+	INSERT INTO import_jobs (id, workspace_id, object_type, status, mapping, source)
+	VALUES (:id, :workspace_id, :object_type, :status, :mapping, :source)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"object_type": arg.ObjectType,
+		"status":      arg.Status,
+		"mapping":     arg.Mapping,
+		"source":      arg.Source,
+	})
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	var job ImportJob
+	err = dbc.GetContext(ctx, &job, query, args...)
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	return job, nil
+}
+
+func (q *Queries) GetImportJob(ctx context.Context, dbc DBExecutor, id string) (ImportJob, error) {
+	query := `
+	SELECT * FROM import_jobs WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	var job ImportJob
+	err = dbc.GetContext(ctx, &job, query, args...)
+	if err != nil {
+		return ImportJob{}, err
+	}
+
+	return job, nil
+}
+
+func (q *Queries) ListImportJobsByStatus(
+	ctx context.Context,
+	dbc DBExecutor,
+	statuses []string,
+) ([]ImportJob, error) {
+	query, args, err := bindWorkspaceIn(ctx, dbc, `
+	SELECT * FROM import_jobs WHERE status IN (:statuses) AND workspace_id = :workspace_id ORDER BY created_at
+	`, map[string]any{
+		"statuses": statuses,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []ImportJob
+	err = dbc.SelectContext(ctx, &jobs, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (q *Queries) UpdateImportJobProgress(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg UpdateImportJobProgressParams,
+) error {
+	query := `
+	UPDATE import_jobs SET
+		status = :status,
+		total_rows = :total_rows,
+		processed_rows = :processed_rows,
+		succeeded_rows = :succeeded_rows,
+		failed_rows = :failed_rows,
+		error = :error,
+		started_at = :started_at,
+		finished_at = :finished_at
+	WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":             arg.ID,
+		"status":         arg.Status,
+		"total_rows":     arg.TotalRows,
+		"processed_rows": arg.ProcessedRows,
+		"succeeded_rows": arg.SucceededRows,
+		"failed_rows":    arg.FailedRows,
+		"error":          arg.Error,
+		"started_at":     arg.StartedAt,
+		"finished_at":    arg.FinishedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) InsertImportError(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertImportErrorParams,
+) error {
+	query := `
+	INSERT INTO import_errors (job_id, row_number, field, message, row_data, workspace_id)
+	VALUES (:job_id, :row_number, :field, :message, :row_data, :workspace_id)
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"job_id":     arg.JobID,
+		"row_number": arg.RowNumber,
+		"field":      arg.Field,
+		"message":    arg.Message,
+		"row_data":   arg.RowData,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) ListImportErrors(ctx context.Context, dbc DBExecutor, jobID string) ([]ImportError, error) {
+	query := `
+	SELECT * FROM import_errors WHERE job_id = :job_id AND workspace_id = :workspace_id ORDER BY row_number, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"job_id": jobID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var importErrors []ImportError
+	err = dbc.SelectContext(ctx, &importErrors, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return importErrors, nil
+}
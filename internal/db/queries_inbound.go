@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+)
+
+// InsertInboundEmail stores a received email and reports false if one with
+// the same Message-ID was already stored.
+func (q *Queries) InsertInboundEmail(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertInboundEmailParams,
+) (InboundEmail, bool, error) {
+	query := `
+	INSERT INTO inbound_emails (
+		id, workspace_id, message_id, from_address, to_addresses, cc_addresses, subject, body, sent_at, created_at
+	)
+	VALUES (
+		:id, :workspace_id, :message_id, :from_address, :to_addresses, :cc_addresses, :subject, :body, :sent_at, :created_at
+	)
+	ON CONFLICT DO NOTHING
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":           arg.ID,
+		"message_id":   arg.MessageID,
+		"from_address": arg.FromAddress,
+		"to_addresses": arg.ToAddresses,
+		"cc_addresses": arg.CcAddresses,
+		"subject":      arg.Subject,
+		"body":         arg.Body,
+		"sent_at":      arg.SentAt,
+		"created_at":   arg.CreatedAt,
+	})
+	if err != nil {
+		return InboundEmail{}, false, err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return InboundEmail{}, false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return InboundEmail{}, false, err
+	}
+
+	email, err := q.GetInboundEmail(ctx, dbc, arg.ID)
+	if err != nil {
+		return InboundEmail{}, false, err
+	}
+	return email, true, nil
+}
+
+func (q *Queries) GetInboundEmail(ctx context.Context, dbc DBExecutor, id string) (InboundEmail, error) {
+	query := `
+	SELECT * FROM inbound_emails WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return InboundEmail{}, err
+	}
+
+	var email InboundEmail
+	err = dbc.GetContext(ctx, &email, query, args...)
+	if err != nil {
+		return InboundEmail{}, err
+	}
+
+	return email, nil
+}
+
+func (q *Queries) GetInboundEmailByMessageID(ctx context.Context, dbc DBExecutor, messageID string) (InboundEmail, error) {
+	query := `
+	SELECT * FROM inbound_emails WHERE message_id = :message_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"message_id": messageID,
+	})
+	if err != nil {
+		return InboundEmail{}, err
+	}
+
+	var email InboundEmail
+	err = dbc.GetContext(ctx, &email, query, args...)
+	if err != nil {
+		return InboundEmail{}, err
+	}
+
+	return email, nil
+}
+
+func (q *Queries) InsertInboundEmailAttachment(ctx context.Context, dbc DBExecutor, arg InboundEmailAttachment) error {
+	query := `
+	INSERT INTO inbound_email_attachments (email_id, position, filename, content_type, size, workspace_id)
+	VALUES (:email_id, :position, :filename, :content_type, :size, :workspace_id)
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"email_id":     arg.EmailID,
+		"position":     arg.Position,
+		"filename":     arg.Filename,
+		"content_type": arg.ContentType,
+		"size":         arg.Size,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) ListInboundEmailAttachments(
+	ctx context.Context,
+	dbc DBExecutor,
+	emailID string,
+) ([]InboundEmailAttachment, error) {
+	query := `
+	SELECT * FROM inbound_email_attachments WHERE email_id = :email_id AND workspace_id = :workspace_id ORDER BY position
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"email_id": emailID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := []InboundEmailAttachment{}
+	err = dbc.SelectContext(ctx, &attachments, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+// ListEntitiesByEmailCanonical returns the entities whose canonical email is
+// one of canonicals, oldest first.
+func (q *Queries) ListEntitiesByEmailCanonical(
+	ctx context.Context,
+	dbc DBExecutor,
+	canonicals []string,
+) ([]Entity, error) {
+	if len(canonicals) == 0 {
+		return []Entity{}, nil
+	}
+
+	query, args, err := bindWorkspaceIn(ctx, dbc, `
+	SELECT * FROM entities WHERE email_canonical IN (:canonicals) AND workspace_id = :workspace_id
+	ORDER BY created_at, id
+	`, map[string]any{
+		"canonicals": canonicals,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []Entity{}
+	err = dbc.SelectContext(ctx, &entities, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
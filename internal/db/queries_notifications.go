@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+)
+
+// InsertNotification stores a notification and reports false if the user was
+// already notified of the event.
+func (q *Queries) InsertNotification(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertNotificationParams,
+) (Notification, bool, error) {
+	query := `
+	INSERT INTO notifications (id, workspace_id, user_id, kind, title, body, entity_id, task_id, event_id, created_at)
+	VALUES (:id, :workspace_id, :user_id, :kind, :title, :body, :entity_id, :task_id, :event_id, :created_at)
+	ON CONFLICT DO NOTHING
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":         arg.ID,
+		"user_id":    arg.UserID,
+		"kind":       arg.Kind,
+		"title":      arg.Title,
+		"body":       arg.Body,
+		"entity_id":  arg.EntityID,
+		"task_id":    arg.TaskID,
+		"event_id":   arg.EventID,
+		"created_at": arg.CreatedAt,
+	})
+	if err != nil {
+		return Notification{}, false, err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return Notification{}, false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return Notification{}, false, err
+	}
+
+	notification, err := q.GetNotification(ctx, dbc, arg.ID)
+	if err != nil {
+		return Notification{}, false, err
+	}
+	return notification, true, nil
+}
+
+func (q *Queries) GetNotification(ctx context.Context, dbc DBExecutor, id string) (Notification, error) {
+	query := `
+	SELECT * FROM notifications WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Notification{}, err
+	}
+
+	var notification Notification
+	err = dbc.GetContext(ctx, &notification, query, args...)
+	if err != nil {
+		return Notification{}, err
+	}
+
+	return notification, nil
+}
+
+func (q *Queries) ListNotifications(
+	ctx context.Context,
+	dbc DBExecutor,
+	filter NotificationFilter,
+) ([]Notification, error) {
+	query := `
+	SELECT * FROM notifications WHERE user_id = :user_id AND workspace_id = :workspace_id
+	`
+	params := map[string]any{
+		"user_id": filter.UserID,
+	}
+
+	if filter.Unread {
+		query += " AND read_at = ''"
+	}
+	query += " ORDER BY created_at DESC, rowid DESC"
+	query += limitClause(filter.Limit, filter.Offset, params)
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := []Notification{}
+	err = dbc.SelectContext(ctx, &notifications, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (q *Queries) CountUnreadNotifications(ctx context.Context, dbc DBExecutor, userID string) (int, error) {
+	query := `
+	SELECT COUNT(*) FROM notifications WHERE user_id = :user_id AND workspace_id = :workspace_id AND read_at = ''
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"user_id": userID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	err = dbc.GetContext(ctx, &n, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// MarkNotificationRead marks one of the user's notifications read. Reading a
+// notification again keeps the time it was first read.
+func (q *Queries) MarkNotificationRead(
+	ctx context.Context,
+	dbc DBExecutor,
+	userID, id, readAt string,
+) (Notification, error) {
+	query := `
+	UPDATE notifications
+	SET read_at = CASE WHEN read_at = '' THEN :read_at ELSE read_at END
+	WHERE id = :id AND user_id = :user_id AND workspace_id = :workspace_id
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":      id,
+		"user_id": userID,
+		"read_at": readAt,
+	})
+	if err != nil {
+		return Notification{}, err
+	}
+
+	var notification Notification
+	err = dbc.GetContext(ctx, &notification, query, args...)
+	if err != nil {
+		return Notification{}, err
+	}
+
+	return notification, nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user read
+// and returns how many there were.
+func (q *Queries) MarkAllNotificationsRead(ctx context.Context, dbc DBExecutor, userID, readAt string) (int64, error) {
+	query := `
+	UPDATE notifications SET read_at = :read_at
+	WHERE user_id = :user_id AND workspace_id = :workspace_id AND read_at = ''
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"user_id": userID,
+		"read_at": readAt,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,515 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (q *Queries) InsertProduct(ctx context.Context, dbc DBExecutor, arg InsertProductParams) (Product, error) {
+	query := `
+	INSERT INTO products (id, workspace_id, sku, name, description)
+	VALUES (:id, :workspace_id, :sku, :name, :description)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"sku":         arg.SKU,
+		"name":        arg.Name,
+		"description": arg.Description,
+	})
+	if err != nil {
+		return Product{}, err
+	}
+
+	var product Product
+	err = dbc.GetContext(ctx, &product, query, args...)
+	if err != nil {
+		return Product{}, err
+	}
+
+	return product, nil
+}
+
+func (q *Queries) GetProduct(ctx context.Context, dbc DBExecutor, id string) (Product, error) {
+	query := `
+	SELECT * FROM products WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Product{}, err
+	}
+
+	var product Product
+	err = dbc.GetContext(ctx, &product, query, args...)
+	if err != nil {
+		return Product{}, err
+	}
+
+	return product, nil
+}
+
+// ListProducts returns the catalog ordered by SKU, leaving out inactive
+// products unless includeInactive is set.
+func (q *Queries) ListProducts(ctx context.Context, dbc DBExecutor, includeInactive bool) ([]Product, error) {
+	query := `
+	SELECT * FROM products WHERE workspace_id = :workspace_id AND (active OR :include_inactive)
+	ORDER BY sku
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"include_inactive": includeInactive,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	products := []Product{}
+	err = dbc.SelectContext(ctx, &products, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+func (q *Queries) SetProductActive(ctx context.Context, dbc DBExecutor, id string, active bool) (Product, error) {
+	query := `
+	UPDATE products SET active = :active WHERE id = :id AND workspace_id = :workspace_id
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":     id,
+		"active": active,
+	})
+	if err != nil {
+		return Product{}, err
+	}
+
+	var product Product
+	err = dbc.GetContext(ctx, &product, query, args...)
+	if err != nil {
+		return Product{}, err
+	}
+
+	return product, nil
+}
+
+// SetProductPrice sets a product's price in a currency, replacing any price
+// it had in it.
+func (q *Queries) SetProductPrice(ctx context.Context, dbc DBExecutor, arg ProductPrice) (ProductPrice, error) {
+	query := `
+	INSERT INTO product_prices (workspace_id, product_id, currency, unit_price)
+	VALUES (:workspace_id, :product_id, :currency, :unit_price)
+	ON CONFLICT (product_id, currency) DO UPDATE SET unit_price = excluded.unit_price
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"product_id": arg.ProductID,
+		"currency":   arg.Currency,
+		"unit_price": arg.UnitPrice,
+	})
+	if err != nil {
+		return ProductPrice{}, err
+	}
+
+	var price ProductPrice
+	err = dbc.GetContext(ctx, &price, query, args...)
+	if err != nil {
+		return ProductPrice{}, err
+	}
+
+	return price, nil
+}
+
+func (q *Queries) DeleteProductPrice(ctx context.Context, dbc DBExecutor, productID, currency string) error {
+	query := `
+	DELETE FROM product_prices
+	WHERE product_id = :product_id AND currency = :currency AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"product_id": productID,
+		"currency":   currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ListProductPrices returns the prices of the given products, ordered by
+// product and currency.
+func (q *Queries) ListProductPrices(ctx context.Context, dbc DBExecutor, productIDs []string) ([]ProductPrice, error) {
+	prices := []ProductPrice{}
+	if len(productIDs) == 0 {
+		return prices, nil
+	}
+
+	query := `
+	SELECT * FROM product_prices WHERE workspace_id = :workspace_id AND product_id IN (:product_ids)
+	ORDER BY product_id, currency
+	`
+
+	query, args, err := bindWorkspaceIn(ctx, dbc, query, map[string]any{
+		"product_ids": productIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbc.SelectContext(ctx, &prices, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// InsertQuote creates a draft quote numbered after the workspace's last one.
+func (q *Queries) InsertQuote(ctx context.Context, dbc DBExecutor, arg InsertQuoteParams) (Quote, error) {
+	query := `
+	INSERT INTO quotes (
+		id, workspace_id, number, entity_id, title, currency, tax_rate, valid_until, notes, created_by
+	)
+	VALUES (
+		:id,
+		:workspace_id,
+		(SELECT COALESCE(MAX(number), 0) + 1 FROM quotes WHERE workspace_id = :workspace_id),
+		:entity_id,
+		:title,
+		:currency,
+		:tax_rate,
+		:valid_until,
+		:notes,
+		:created_by
+	)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"entity_id":   arg.EntityID,
+		"title":       arg.Title,
+		"currency":    arg.Currency,
+		"tax_rate":    arg.TaxRate,
+		"valid_until": arg.ValidUntil,
+		"notes":       arg.Notes,
+		"created_by":  arg.CreatedBy,
+	})
+	if err != nil {
+		return Quote{}, err
+	}
+
+	var quote Quote
+	err = dbc.GetContext(ctx, &quote, query, args...)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	return quote, nil
+}
+
+func (q *Queries) GetQuote(ctx context.Context, dbc DBExecutor, id string) (Quote, error) {
+	query := `
+	SELECT * FROM quotes WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Quote{}, err
+	}
+
+	var quote Quote
+	err = dbc.GetContext(ctx, &quote, query, args...)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	return quote, nil
+}
+
+// ListQuotes returns the quotes matching filter, latest first.
+func (q *Queries) ListQuotes(ctx context.Context, dbc DBExecutor, filter QuoteFilter) ([]Quote, error) {
+	params := map[string]any{}
+	where := ""
+	if filter.EntityID != "" {
+		where += " AND entity_id = :entity_id"
+		params["entity_id"] = filter.EntityID
+	}
+	if filter.Status != "" {
+		where += " AND status = :status"
+		params["status"] = filter.Status
+	}
+	if filter.VisibleTo != "" {
+		where += " AND entity_id IN (SELECT id FROM entities WHERE workspace_id = :workspace_id" +
+			visibilityClause(filter.VisibleTo, params) + ")"
+	}
+
+	query := `
+	SELECT * FROM quotes WHERE workspace_id = :workspace_id` + where + `
+	ORDER BY number DESC
+	` + limitClause(filter.Limit, filter.Offset, params)
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := []Quote{}
+	err = dbc.SelectContext(ctx, &quotes, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return quotes, nil
+}
+
+func (q *Queries) UpdateQuote(ctx context.Context, dbc DBExecutor, arg UpdateQuoteParams) (Quote, error) {
+	query := `
+	UPDATE quotes SET
+		title = :title,
+		tax_rate = :tax_rate,
+		valid_until = :valid_until,
+		notes = :notes,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = :id AND workspace_id = :workspace_id
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"title":       arg.Title,
+		"tax_rate":    arg.TaxRate,
+		"valid_until": arg.ValidUntil,
+		"notes":       arg.Notes,
+	})
+	if err != nil {
+		return Quote{}, err
+	}
+
+	var quote Quote
+	err = dbc.GetContext(ctx, &quote, query, args...)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	return quote, nil
+}
+
+func (q *Queries) UpdateQuoteStatus(ctx context.Context, dbc DBExecutor, arg UpdateQuoteStatusParams) (Quote, error) {
+	query := `
+	UPDATE quotes SET
+		status = :status,
+		sent_at = :sent_at,
+		decided_at = :decided_at,
+		updated_at = CURRENT_TIMESTAMP
+	WHERE id = :id AND workspace_id = :workspace_id
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":         arg.ID,
+		"status":     arg.Status,
+		"sent_at":    arg.SentAt,
+		"decided_at": arg.DecidedAt,
+	})
+	if err != nil {
+		return Quote{}, err
+	}
+
+	var quote Quote
+	err = dbc.GetContext(ctx, &quote, query, args...)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	return quote, nil
+}
+
+// TouchQuote marks a quote as updated, for changes made to its lines.
+func (q *Queries) TouchQuote(ctx context.Context, dbc DBExecutor, id string) error {
+	query := `
+	UPDATE quotes SET updated_at = CURRENT_TIMESTAMP WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) MoveEntityQuotes(ctx context.Context, dbc DBExecutor, fromEntityID, toEntityID string) error {
+	query := `
+	UPDATE quotes SET entity_id = :to_entity_id
+	WHERE entity_id = :from_entity_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"from_entity_id": fromEntityID,
+		"to_entity_id":   toEntityID,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+// InsertQuoteLine appends a line to the end of a quote.
+func (q *Queries) InsertQuoteLine(ctx context.Context, dbc DBExecutor, arg InsertQuoteLineParams) (QuoteLine, error) {
+	query := `
+	INSERT INTO quote_lines (
+		id, workspace_id, quote_id, position, product_id, sku, description, quantity, unit_price, discount
+	)
+	VALUES (
+		:id,
+		:workspace_id,
+		:quote_id,
+		(SELECT COALESCE(MAX(position), 0) + 1 FROM quote_lines WHERE workspace_id = :workspace_id AND quote_id = :quote_id),
+		:product_id,
+		:sku,
+		:description,
+		:quantity,
+		:unit_price,
+		:discount
+	)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"quote_id":    arg.QuoteID,
+		"product_id":  arg.ProductID,
+		"sku":         arg.SKU,
+		"description": arg.Description,
+		"quantity":    arg.Quantity,
+		"unit_price":  arg.UnitPrice,
+		"discount":    arg.Discount,
+	})
+	if err != nil {
+		return QuoteLine{}, err
+	}
+
+	var line QuoteLine
+	err = dbc.GetContext(ctx, &line, query, args...)
+	if err != nil {
+		return QuoteLine{}, err
+	}
+
+	return line, nil
+}
+
+func (q *Queries) UpdateQuoteLine(ctx context.Context, dbc DBExecutor, arg UpdateQuoteLineParams) (QuoteLine, error) {
+	query := `
+	UPDATE quote_lines SET
+		quantity = :quantity,
+		unit_price = :unit_price,
+		discount = :discount
+	WHERE id = :id AND quote_id = :quote_id AND workspace_id = :workspace_id
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":         arg.ID,
+		"quote_id":   arg.QuoteID,
+		"quantity":   arg.Quantity,
+		"unit_price": arg.UnitPrice,
+		"discount":   arg.Discount,
+	})
+	if err != nil {
+		return QuoteLine{}, err
+	}
+
+	var line QuoteLine
+	err = dbc.GetContext(ctx, &line, query, args...)
+	if err != nil {
+		return QuoteLine{}, err
+	}
+
+	return line, nil
+}
+
+func (q *Queries) DeleteQuoteLine(ctx context.Context, dbc DBExecutor, id, quoteID string) error {
+	query := `
+	DELETE FROM quote_lines WHERE id = :id AND quote_id = :quote_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":       id,
+		"quote_id": quoteID,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ListQuoteLines returns the lines of the given quotes in the order they
+// appear on each quote.
+func (q *Queries) ListQuoteLines(ctx context.Context, dbc DBExecutor, quoteIDs []string) ([]QuoteLine, error) {
+	lines := []QuoteLine{}
+	if len(quoteIDs) == 0 {
+		return lines, nil
+	}
+
+	query := `
+	SELECT * FROM quote_lines WHERE workspace_id = :workspace_id AND quote_id IN (:quote_ids)
+	ORDER BY quote_id, position
+	`
+
+	query, args, err := bindWorkspaceIn(ctx, dbc, query, map[string]any{
+		"quote_ids": quoteIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = dbc.SelectContext(ctx, &lines, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,522 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"simplecrm/internal/recurrence"
+)
+
+// reportClauses returns the period and group expressions of a report over
+// the dates in dateColumn, grouped by one of groups, and the conditions
+// narrowing it to filter. Without an interval or group, the report is a
+// single period or group named "".
+func reportClauses(
+	filter ReportFilter,
+	dateColumn string,
+	groups map[string]string,
+	params map[string]any,
+) (period, group, where string) {
+	period = "''"
+	if interval, ok := ReportIntervals[filter.Interval]; ok {
+		period = "COALESCE(" + fmt.Sprintf(interval, dateColumn) + ", '')"
+	}
+	group = "''"
+	if column, ok := groups[filter.GroupBy]; ok {
+		group = column
+	}
+
+	if filter.From != "" {
+		where += " AND " + dateColumn + " >= :from"
+		params["from"] = filter.From
+	}
+	if filter.To != "" {
+		where += " AND " + dateColumn + " < :to"
+		params["to"] = filter.To
+	}
+	if filter.TeamID != "" {
+		where += " AND team_id = :team_id"
+		params["team_id"] = filter.TeamID
+	}
+	if filter.VisibleTo != "" {
+		where += visibilityClause(filter.VisibleTo, params)
+	}
+
+	return period, group, where
+}
+
+// CountEntitiesCreated counts the leads created in each period and group of
+// a report, including those converted to contacts since.
+func (q *Queries) CountEntitiesCreated(ctx context.Context, dbc DBExecutor, filter ReportFilter) ([]EntityCount, error) {
+	params := map[string]any{}
+	period, group, where := reportClauses(filter, "created_at", EntityReportGroups, params)
+
+	query := `
+	SELECT ` + period + ` AS period, ` + group + ` AS grp, COUNT(*) AS count
+	FROM entities WHERE workspace_id = :workspace_id` + where + `
+	GROUP BY period, grp
+	ORDER BY period, grp
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := []EntityCount{}
+	err = dbc.SelectContext(ctx, &counts, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// GetConversionStats reports, for the leads created in each period and
+// group, how many converted and the median number of days they took.
+func (q *Queries) GetConversionStats(ctx context.Context, dbc DBExecutor, filter ReportFilter) ([]ConversionStats, error) {
+	params := map[string]any{}
+	period, group, where := reportClauses(filter, "created_at", EntityReportGroups, params)
+
+	// The median is the middle time to convert, or the mean of the middle
+	// two when there is an even number of them
+	query := `
+	WITH created AS (
+		SELECT ` + period + ` AS period, ` + group + ` AS grp, created_at, converted_at
+		FROM entities WHERE workspace_id = :workspace_id` + where + `
+	),
+	converted AS (
+		SELECT
+			period,
+			grp,
+			julianday(converted_at) - julianday(created_at) AS days,
+			ROW_NUMBER() OVER (PARTITION BY period, grp ORDER BY julianday(converted_at) - julianday(created_at)) AS n,
+			COUNT(*) OVER (PARTITION BY period, grp) AS total
+		FROM created WHERE converted_at != ''
+	)
+	SELECT
+		period,
+		grp,
+		COUNT(*) AS created,
+		COUNT(NULLIF(converted_at, '')) AS converted,
+		(
+			SELECT AVG(days) FROM converted c
+			WHERE c.period = created.period AND c.grp = created.grp AND c.n IN ((c.total + 1) / 2, (c.total + 2) / 2)
+		) AS median_days
+	FROM created
+	GROUP BY period, grp
+	ORDER BY period, grp
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []ConversionStats{}
+	err = dbc.SelectContext(ctx, &stats, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetTaskStats counts the tasks due in each period and group of a report by
+// how they stand, as of now, and the tasks completed in each whenever they
+// were due.
+func (q *Queries) GetTaskStats(ctx context.Context, dbc DBExecutor, filter ReportFilter, now string) ([]TaskStats, error) {
+	asOf, err := time.Parse(TimeFormat, now)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]any{
+		"now": now,
+	}
+	period, group, where := reportClauses(filter, "due_date", TaskReportGroups, params)
+
+	query := `
+	SELECT
+		` + period + ` AS period,
+		` + group + ` AS grp,
+		COUNT(*) AS total,
+		COUNT(*) FILTER (WHERE status = 'open') AS open
+	FROM tasks WHERE workspace_id = :workspace_id` + where + `
+	GROUP BY period, grp
+	ORDER BY period, grp
+	`
+
+	bound, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []TaskStats{}
+	err = dbc.SelectContext(ctx, &stats, bound, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Whether a task is overdue is left to recurrence.DueTime, which
+	// reminders and calendar exports go by too. Due dates sort before now
+	// whenever they are overdue, so only those are read.
+	query = `
+	SELECT ` + period + ` AS period, ` + group + ` AS grp, due_date
+	FROM tasks
+	WHERE workspace_id = :workspace_id AND status = 'open' AND due_date != '' AND due_date <= :now` + where
+
+	bound, args, err = bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var open []struct {
+		Period  string `db:"period"`
+		Group   string `db:"grp"`
+		DueDate string `db:"due_date"`
+	}
+	err = dbc.SelectContext(ctx, &open, bound, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[[2]string]*TaskStats, len(stats))
+	for i := range stats {
+		rows[[2]string{stats[i].Period, stats[i].Group}] = &stats[i]
+	}
+	for _, task := range open {
+		due, err := recurrence.DueTime(task.DueDate)
+		if err != nil || due.After(asOf) {
+			continue
+		}
+		if row, ok := rows[[2]string{task.Period, task.Group}]; ok {
+			row.Overdue++
+		}
+	}
+
+	completedParams := map[string]any{}
+	period, group, where = reportClauses(filter, "completed_at", TaskReportGroups, completedParams)
+
+	query = `
+	SELECT ` + period + ` AS period, ` + group + ` AS grp, COUNT(*) AS completed
+	FROM tasks
+	WHERE workspace_id = :workspace_id AND status = 'done' AND completed_at != ''` + where + `
+	GROUP BY period, grp`
+
+	bound, args, err = bindWorkspace(ctx, dbc, query, completedParams)
+	if err != nil {
+		return nil, err
+	}
+
+	var completed []TaskStats
+	err = dbc.SelectContext(ctx, &completed, bound, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Periods with completions but nothing due get a row of their own
+	for _, c := range completed {
+		if row, ok := rows[[2]string{c.Period, c.Group}]; ok {
+			row.Completed = c.Completed
+			continue
+		}
+		stats = append(stats, c)
+	}
+	slices.SortFunc(stats, func(a, b TaskStats) int {
+		return cmp.Or(cmp.Compare(a.Period, b.Period), cmp.Compare(a.Group, b.Group))
+	})
+
+	return stats, nil
+}
+
+// quoteTotalExpr is the total of quote q, computed as ops.PriceQuote does:
+// each line's discount and the tax are rounded half up to the minor unit.
+// Amounts are never negative, so half up is half away from zero.
+const quoteTotalExpr = `(
+		SELECT subtotal + (subtotal * q.tax_rate + 5000) / 10000
+		FROM (
+			SELECT COALESCE(SUM(l.quantity * l.unit_price - (l.quantity * l.unit_price * l.discount + 5000) / 10000), 0) AS subtotal
+			FROM quote_lines l WHERE l.quote_id = q.id AND l.workspace_id = q.workspace_id
+		)
+	)`
+
+// ListDecidedQuoteTotals returns the total of each quote accepted or
+// declined in the range of a report, by the period and group it falls in.
+// Teams and visibility are those of the lead or contact quoted.
+func (q *Queries) ListDecidedQuoteTotals(ctx context.Context, dbc DBExecutor, filter ReportFilter) ([]QuoteTotal, error) {
+	params := map[string]any{}
+	period, group, where := reportClauses(filter, "q.decided_at", QuoteReportGroups, params)
+
+	query := `
+	SELECT
+		` + period + ` AS period,
+		` + group + ` AS grp,
+		q.id AS quote_id,
+		q.currency,
+		q.status,
+		q.decided_at,
+		` + quoteTotalExpr + ` AS total
+	FROM quotes q JOIN entities e ON e.id = q.entity_id AND e.workspace_id = q.workspace_id
+	WHERE q.workspace_id = :workspace_id AND q.status IN ('accepted', 'declined')` + where + `
+	ORDER BY period, grp, q.decided_at, q.id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := []QuoteTotal{}
+	err = dbc.SelectContext(ctx, &totals, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+func (q *Queries) InsertScheduledReport(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertScheduledReportParams,
+) (ScheduledReport, error) {
+	query := `
+	INSERT INTO scheduled_reports (
+		id, workspace_id, name, metrics, recipients, schedule, timezone, period, filter, next_run_at
+	)
+	VALUES (
+		:id, :workspace_id, :name, :metrics, :recipients, :schedule, :timezone, :period, :filter, :next_run_at
+	)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"name":        arg.Name,
+		"metrics":     arg.Metrics,
+		"recipients":  arg.Recipients,
+		"schedule":    arg.Schedule,
+		"timezone":    arg.Timezone,
+		"period":      arg.Period,
+		"filter":      arg.Filter,
+		"next_run_at": arg.NextRunAt,
+	})
+	if err != nil {
+		return ScheduledReport{}, err
+	}
+
+	var report ScheduledReport
+	err = dbc.GetContext(ctx, &report, query, args...)
+	if err != nil {
+		return ScheduledReport{}, err
+	}
+
+	return report, nil
+}
+
+func (q *Queries) GetScheduledReport(ctx context.Context, dbc DBExecutor, id string) (ScheduledReport, error) {
+	query := `
+	SELECT * FROM scheduled_reports WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return ScheduledReport{}, err
+	}
+
+	var report ScheduledReport
+	err = dbc.GetContext(ctx, &report, query, args...)
+	if err != nil {
+		return ScheduledReport{}, err
+	}
+
+	return report, nil
+}
+
+func (q *Queries) ListScheduledReports(ctx context.Context, dbc DBExecutor) ([]ScheduledReport, error) {
+	query := `
+	SELECT * FROM scheduled_reports WHERE workspace_id = :workspace_id ORDER BY name
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []ScheduledReport{}
+	err = dbc.SelectContext(ctx, &reports, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// ListDueScheduledReports returns the reports due to run by now, longest
+// due first.
+func (q *Queries) ListDueScheduledReports(ctx context.Context, dbc DBExecutor, now string) ([]ScheduledReport, error) {
+	query := `
+	SELECT * FROM scheduled_reports WHERE workspace_id = :workspace_id AND next_run_at <= :now
+	ORDER BY next_run_at, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"now": now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []ScheduledReport{}
+	err = dbc.SelectContext(ctx, &reports, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// ClaimScheduledReportRun moves a report's next run from nextRunAt to
+// following and reports false if another run already moved it.
+func (q *Queries) ClaimScheduledReportRun(
+	ctx context.Context,
+	dbc DBExecutor,
+	id, nextRunAt, following string,
+) (bool, error) {
+	query := `
+	UPDATE scheduled_reports SET next_run_at = :following
+	WHERE id = :id AND workspace_id = :workspace_id AND next_run_at = :next_run_at
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          id,
+		"next_run_at": nextRunAt,
+		"following":   following,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (q *Queries) DeleteScheduledReport(ctx context.Context, dbc DBExecutor, id string) error {
+	query := `
+	DELETE FROM scheduled_reports WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (q *Queries) InsertReportSnapshot(
+	ctx context.Context,
+	dbc DBExecutor,
+	arg InsertReportSnapshotParams,
+) (ReportSnapshot, error) {
+	query := `
+	INSERT INTO report_snapshots (id, workspace_id, report_id, range_from, range_to, result, ran_at)
+	VALUES (:id, :workspace_id, :report_id, :range_from, :range_to, :result, :ran_at)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":         arg.ID,
+		"report_id":  arg.ReportID,
+		"range_from": arg.RangeFrom,
+		"range_to":   arg.RangeTo,
+		"result":     arg.Result,
+		"ran_at":     arg.RanAt,
+	})
+	if err != nil {
+		return ReportSnapshot{}, err
+	}
+
+	var snapshot ReportSnapshot
+	err = dbc.GetContext(ctx, &snapshot, query, args...)
+	if err != nil {
+		return ReportSnapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+// ListReportSnapshots returns a report's snapshots, latest first.
+func (q *Queries) ListReportSnapshots(
+	ctx context.Context,
+	dbc DBExecutor,
+	reportID string,
+	limit, offset int,
+) ([]ReportSnapshot, error) {
+	params := map[string]any{
+		"report_id": reportID,
+	}
+	query := `
+	SELECT * FROM report_snapshots WHERE report_id = :report_id AND workspace_id = :workspace_id
+	ORDER BY ran_at DESC, rowid DESC
+	` + limitClause(limit, offset, params)
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []ReportSnapshot{}
+	err = dbc.SelectContext(ctx, &snapshots, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+func (q *Queries) DeleteReportSnapshots(ctx context.Context, dbc DBExecutor, reportID string) error {
+	query := `
+	DELETE FROM report_snapshots WHERE report_id = :report_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"report_id": reportID,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (q *Queries) InsertActivity(ctx context.Context, dbc DBExecutor, arg InsertActivityParams) (Activity, error) {
+	query := `
+	INSERT INTO activities (id, workspace_id, entity_id, kind, user_id, notes, occurred_at, email_id)
+	VALUES (:id, :workspace_id, :entity_id, :kind, :user_id, :notes, :occurred_at, :email_id)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"entity_id":   arg.EntityID,
+		"kind":        arg.Kind,
+		"user_id":     arg.UserID,
+		"notes":       arg.Notes,
+		"occurred_at": arg.OccurredAt,
+		"email_id":    arg.EmailID,
+	})
+	if err != nil {
+		return Activity{}, err
+	}
+
+	var activity Activity
+	err = dbc.GetContext(ctx, &activity, query, args...)
+	if err != nil {
+		return Activity{}, err
+	}
+
+	return activity, nil
+}
+
+func (q *Queries) ListActivities(ctx context.Context, dbc DBExecutor, entityID string) ([]Activity, error) {
+	query := `
+	SELECT * FROM activities WHERE entity_id = :entity_id AND workspace_id = :workspace_id ORDER BY occurred_at, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"entity_id": entityID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	activities := []Activity{}
+	err = dbc.SelectContext(ctx, &activities, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return activities, nil
+}
+
+func (q *Queries) ReassignActivitiesEntity(
+	ctx context.Context,
+	dbc DBExecutor,
+	fromEntityID, toEntityID string,
+) (int64, error) {
+	query := `
+	UPDATE activities SET entity_id = :to_entity_id
+	WHERE entity_id = :from_entity_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"from_entity_id": fromEntityID,
+		"to_entity_id":   toEntityID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (q *Queries) InsertScoringRule(ctx context.Context, dbc DBExecutor, arg InsertScoringRuleParams) (ScoringRule, error) {
+	query := `
+	INSERT INTO scoring_rules (id, workspace_id, name, kind, field, value, points, half_life_days)
+	VALUES (:id, :workspace_id, :name, :kind, :field, :value, :points, :half_life_days)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":             arg.ID,
+		"name":           arg.Name,
+		"kind":           arg.Kind,
+		"field":          arg.Field,
+		"value":          arg.Value,
+		"points":         arg.Points,
+		"half_life_days": arg.HalfLifeDays,
+	})
+	if err != nil {
+		return ScoringRule{}, err
+	}
+
+	var rule ScoringRule
+	err = dbc.GetContext(ctx, &rule, query, args...)
+	if err != nil {
+		return ScoringRule{}, err
+	}
+
+	return rule, nil
+}
+
+func (q *Queries) ListScoringRules(ctx context.Context, dbc DBExecutor) ([]ScoringRule, error) {
+	query := `
+	SELECT * FROM scoring_rules WHERE workspace_id = :workspace_id ORDER BY created_at, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []ScoringRule{}
+	err = dbc.SelectContext(ctx, &rules, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (q *Queries) DeleteScoringRule(ctx context.Context, dbc DBExecutor, id string) error {
+	query := `
+	DELETE FROM scoring_rules WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// entityTagQuery selects the tags of the entity in the enclosing query by
+// name, completed with the name parameter. Tag names compare regardless of
+// case.
+const entityTagQuery = `SELECT 1 FROM entity_tags et JOIN tags t ON t.id = et.tag_id
+	WHERE et.entity_id = entities.id AND et.workspace_id = entities.workspace_id
+	AND t.workspace_id = entities.workspace_id AND t.name =`
+
+// EnsureTag returns the tag with the given name, creating it with id if it
+// does not exist yet.
+func (q *Queries) EnsureTag(ctx context.Context, dbc DBExecutor, id, name string) (Tag, error) {
+	query := `
+	INSERT INTO tags (id, workspace_id, name) VALUES (:id, :workspace_id, :name)
+	ON CONFLICT (workspace_id, name) DO NOTHING
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":   id,
+		"name": name,
+	})
+	if err != nil {
+		return Tag{}, err
+	}
+
+	if _, err := dbc.ExecContext(ctx, query, args...); err != nil {
+		return Tag{}, err
+	}
+
+	return q.GetTagByName(ctx, dbc, name)
+}
+
+func (q *Queries) GetTagByName(ctx context.Context, dbc DBExecutor, name string) (Tag, error) {
+	query := `
+	SELECT * FROM tags WHERE name = :name AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"name": name,
+	})
+	if err != nil {
+		return Tag{}, err
+	}
+
+	var tag Tag
+	err = dbc.GetContext(ctx, &tag, query, args...)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	return tag, nil
+}
+
+// ListTags returns every tag with the number of entities carrying it, by
+// name.
+func (q *Queries) ListTags(ctx context.Context, dbc DBExecutor) ([]TagCount, error) {
+	query := `
+	SELECT t.id, t.name, t.created_at, COUNT(et.entity_id) AS entities
+	FROM tags t LEFT JOIN entity_tags et ON et.tag_id = t.id AND et.workspace_id = t.workspace_id
+	WHERE t.workspace_id = :workspace_id
+	GROUP BY t.id
+	ORDER BY t.name
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []TagCount{}
+	err = dbc.SelectContext(ctx, &tags, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// DeleteTag deletes a tag, removing it from every entity.
+func (q *Queries) DeleteTag(ctx context.Context, dbc DBExecutor, id string) error {
+	var deleted int64
+	for _, query := range []string{
+		`DELETE FROM entity_tags WHERE tag_id = :id AND workspace_id = :workspace_id`,
+		`DELETE FROM tags WHERE id = :id AND workspace_id = :workspace_id`,
+	} {
+		query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+			"id": id,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := dbc.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TagEntity attaches a tag to an entity and reports false if it already
+// carried it.
+func (q *Queries) TagEntity(ctx context.Context, dbc DBExecutor, tagID, entityID string) (bool, error) {
+	query := `
+	INSERT INTO entity_tags (tag_id, entity_id, workspace_id) VALUES (:tag_id, :entity_id, :workspace_id)
+	ON CONFLICT DO NOTHING
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"tag_id":    tagID,
+		"entity_id": entityID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UntagEntity removes a tag from an entity and reports false if it did not
+// carry it.
+func (q *Queries) UntagEntity(ctx context.Context, dbc DBExecutor, tagID, entityID string) (bool, error) {
+	query := `
+	DELETE FROM entity_tags WHERE tag_id = :tag_id AND entity_id = :entity_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"tag_id":    tagID,
+		"entity_id": entityID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ListEntityTags returns the tags of the given entities, by name.
+func (q *Queries) ListEntityTags(ctx context.Context, dbc DBExecutor, entityIDs []string) ([]EntityTag, error) {
+	if len(entityIDs) == 0 {
+		return []EntityTag{}, nil
+	}
+
+	query, args, err := bindWorkspaceIn(ctx, dbc, `
+	SELECT et.entity_id, t.name
+	FROM entity_tags et JOIN tags t ON t.id = et.tag_id
+	WHERE et.entity_id IN (:entity_ids) AND et.workspace_id = :workspace_id AND t.workspace_id = :workspace_id
+	ORDER BY et.entity_id, t.name
+	`, map[string]any{
+		"entity_ids": entityIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []EntityTag{}
+	err = dbc.SelectContext(ctx, &tags, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// MoveEntityTags moves one entity's tags onto another.
+func (q *Queries) MoveEntityTags(ctx context.Context, dbc DBExecutor, fromEntityID, toEntityID string) error {
+	for _, query := range []string{
+		`UPDATE OR IGNORE entity_tags SET entity_id = :to_entity_id
+		WHERE entity_id = :from_entity_id AND workspace_id = :workspace_id`,
+		`DELETE FROM entity_tags WHERE entity_id = :from_entity_id AND workspace_id = :workspace_id`,
+	} {
+		query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+			"from_entity_id": fromEntityID,
+			"to_entity_id":   toEntityID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := dbc.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *Queries) InsertSegment(ctx context.Context, dbc DBExecutor, arg InsertSegmentParams) (Segment, error) {
+	query := `
+	INSERT INTO segments (id, workspace_id, name, object_type, filter)
+	VALUES (:id, :workspace_id, :name, :object_type, :filter)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"name":        arg.Name,
+		"object_type": arg.ObjectType,
+		"filter":      arg.Filter,
+	})
+	if err != nil {
+		return Segment{}, err
+	}
+
+	var segment Segment
+	err = dbc.GetContext(ctx, &segment, query, args...)
+	if err != nil {
+		return Segment{}, err
+	}
+
+	return segment, nil
+}
+
+func (q *Queries) GetSegment(ctx context.Context, dbc DBExecutor, id string) (Segment, error) {
+	query := `
+	SELECT * FROM segments WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Segment{}, err
+	}
+
+	var segment Segment
+	err = dbc.GetContext(ctx, &segment, query, args...)
+	if err != nil {
+		return Segment{}, err
+	}
+
+	return segment, nil
+}
+
+func (q *Queries) ListSegments(ctx context.Context, dbc DBExecutor) ([]Segment, error) {
+	query := `
+	SELECT * FROM segments WHERE workspace_id = :workspace_id ORDER BY name
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	segments := []Segment{}
+	err = dbc.SelectContext(ctx, &segments, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return segments, nil
+}
+
+func (q *Queries) DeleteSegment(ctx context.Context, dbc DBExecutor, id string) error {
+	query := `
+	DELETE FROM segments WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
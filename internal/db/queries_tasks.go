@@ -0,0 +1,288 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (q *Queries) GetTask(ctx context.Context, dbc DBExecutor, id string) (Task, error) {
+	query := `
+	SELECT * FROM tasks WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Task{}, err
+	}
+
+	var task Task
+	err = dbc.GetContext(ctx, &task, query, args...)
+	if err != nil {
+		return Task{}, err
+	}
+
+	return task, nil
+}
+
+// UpdateTaskStatus moves a task to status, recording when it is completed
+// and forgetting it again when it is reopened.
+func (q *Queries) UpdateTaskStatus(ctx context.Context, dbc DBExecutor, id, status string) (Task, error) {
+	query := `
+	UPDATE tasks SET
+		status = :status,
+		completed_at = CASE WHEN :status = 'done' THEN CURRENT_TIMESTAMP ELSE '' END
+	WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":     id,
+		"status": status,
+	})
+	if err != nil {
+		return Task{}, err
+	}
+
+	var task Task
+	err = dbc.GetContext(ctx, &task, query, args...)
+	if err != nil {
+		return Task{}, err
+	}
+
+	return task, nil
+}
+
+// UpdateTaskTeam hands a task to a team, or takes it from its team when
+// teamID is NULL.
+func (q *Queries) UpdateTaskTeam(ctx context.Context, dbc DBExecutor, id string, teamID sql.NullString) (Task, error) {
+	query := `
+	UPDATE tasks SET team_id = :team_id WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":      id,
+		"team_id": teamID,
+	})
+	if err != nil {
+		return Task{}, err
+	}
+
+	var task Task
+	err = dbc.GetContext(ctx, &task, query, args...)
+	if err != nil {
+		return Task{}, err
+	}
+
+	return task, nil
+}
+
+func (q *Queries) ListTasks(ctx context.Context, dbc DBExecutor, filter TaskFilter) ([]Task, error) {
+	tasks := []Task{}
+	err := q.IterateTasks(ctx, dbc, filter, func(task Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// IterateTasks streams the tasks matching filter to f one row at a time,
+// stopping at the first error f returns.
+func (q *Queries) IterateTasks(
+	ctx context.Context,
+	dbc DBExecutor,
+	filter TaskFilter,
+	f func(Task) error,
+) error {
+	query := `
+	SELECT * FROM tasks WHERE workspace_id = :workspace_id
+	`
+	params := map[string]any{}
+
+	if filter.Status != "" {
+		query += " AND status = :status"
+		params["status"] = filter.Status
+	}
+	if filter.AssignedTo != "" {
+		query += " AND assigned_to = :assigned_to"
+		params["assigned_to"] = filter.AssignedTo
+	}
+	if filter.DueFrom != "" {
+		query += " AND due_date >= :due_from"
+		params["due_from"] = filter.DueFrom
+	}
+	if filter.DueTo != "" {
+		query += " AND due_date < :due_to"
+		params["due_to"] = filter.DueTo
+	}
+	if filter.TeamID != "" {
+		query += " AND team_id = :team_id"
+		params["team_id"] = filter.TeamID
+	}
+	if filter.VisibleTo != "" {
+		query += visibilityClause(filter.VisibleTo, params)
+	}
+	where, orderBy := customFieldClauses("tasks", filter.CustomFields, filter.CustomSort, params)
+	query += where
+	if orderBy == "" {
+		orderBy = "due_date, id"
+	}
+	query += " ORDER BY " + orderBy
+	query += limitClause(filter.Limit, filter.Offset, params)
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return err
+	}
+
+	rows, err := dbc.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task Task
+		if err := rows.StructScan(&task); err != nil {
+			return err
+		}
+		if err := f(task); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ReassignTasksEntity moves every task about one entity onto another.
+func (q *Queries) ReassignTasksEntity(
+	ctx context.Context,
+	dbc DBExecutor,
+	fromEntityID, toEntityID string,
+) (int64, error) {
+	query := `
+	UPDATE tasks SET entity_id = :to_entity_id WHERE entity_id = :from_entity_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"from_entity_id": fromEntityID,
+		"to_entity_id":   toEntityID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (q *Queries) InsertTask(ctx context.Context, dbc DBExecutor, arg InsertTaskParams) (Task, error) {
+	query := `
+	INSERT INTO tasks (
+		id, workspace_id, name, description, due_date, assigned_to, status, entity_id, recurrence, series_id,
+		occurrence, team_id
+	)
+	VALUES (
+		:id, :workspace_id, :name, :description, :due_date, :assigned_to, :status, :entity_id, :recurrence, :series_id,
+		:occurrence, :team_id
+	)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":          arg.ID,
+		"name":        arg.Name,
+		"description": arg.Description,
+		"due_date":    arg.DueDate,
+		"assigned_to": arg.AssignedTo,
+		"status":      arg.Status,
+		"entity_id":   arg.EntityID,
+		"recurrence":  arg.Recurrence,
+		"series_id":   arg.SeriesID,
+		"occurrence":  arg.Occurrence,
+		"team_id":     arg.TeamID,
+	})
+	if err != nil {
+		return Task{}, err
+	}
+
+	var task Task
+	err = dbc.GetContext(ctx, &task, query, args...)
+	if err != nil {
+		return Task{}, err
+	}
+
+	return task, nil
+}
+
+// ListTasksPendingReminder returns open tasks due before the given time that
+// have not been reminded of being overdue for their current due date.
+func (q *Queries) ListTasksPendingReminder(ctx context.Context, dbc DBExecutor, before string) ([]Task, error) {
+	query := `
+	SELECT t.* FROM tasks t
+	WHERE t.workspace_id = :workspace_id AND t.status = 'open' AND t.due_date != '' AND t.due_date < :before
+	AND NOT EXISTS (
+		SELECT 1 FROM task_reminders r
+		WHERE r.task_id = t.id AND r.workspace_id = t.workspace_id AND r.kind = 'overdue' AND r.due_date = t.due_date
+	)
+	ORDER BY t.due_date, t.id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"before": before,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []Task{}
+	err = dbc.SelectContext(ctx, &tasks, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// ClaimTaskReminder records that a reminder is being sent and reports false
+// if it already was.
+func (q *Queries) ClaimTaskReminder(
+	ctx context.Context,
+	dbc DBExecutor,
+	taskID, kind, dueDate, sentAt string,
+) (bool, error) {
+	query := `
+	INSERT INTO task_reminders (task_id, kind, due_date, sent_at, workspace_id)
+	VALUES (:task_id, :kind, :due_date, :sent_at, :workspace_id)
+	ON CONFLICT DO NOTHING
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"task_id":  taskID,
+		"kind":     kind,
+		"due_date": dueDate,
+		"sent_at":  sentAt,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
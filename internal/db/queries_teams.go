@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// managedTeamsQuery starts a query with the managed table, holding the teams
+// the viewer_id parameter manages together with every team below them.
+const managedTeamsQuery = `WITH RECURSIVE managed(id) AS (
+		SELECT team_id FROM team_members
+		WHERE user_id = :viewer_id AND role = 'manager' AND workspace_id = :workspace_id
+		UNION
+		SELECT teams.id FROM teams JOIN managed ON teams.parent_id = managed.id
+		WHERE teams.workspace_id = :workspace_id
+	)`
+
+// visibleTeamsQuery selects the teams whose records the viewer sees: those
+// they belong to and those they manage.
+const visibleTeamsQuery = managedTeamsQuery + `
+	SELECT id FROM managed
+	UNION
+	SELECT team_id FROM team_members WHERE user_id = :viewer_id AND workspace_id = :workspace_id`
+
+// visibleUsersQuery selects the users whose assigned records the viewer
+// sees: themselves and the members of the teams they manage.
+const visibleUsersQuery = managedTeamsQuery + `
+	SELECT :viewer_id
+	UNION
+	SELECT user_id FROM team_members WHERE team_id IN (SELECT id FROM managed) AND workspace_id = :workspace_id`
+
+// visibilityClause keeps the entities or tasks viewerID can see: those owned
+// by nobody, those assigned to them or to a member of a team they manage,
+// and those owned by a team they belong to or manage.
+func visibilityClause(viewerID string, params map[string]any) string {
+	params["viewer_id"] = viewerID
+	return ` AND ((assigned_to IS NULL AND team_id IS NULL)
+	OR assigned_to IN (` + visibleUsersQuery + `)
+	OR team_id IN (` + visibleTeamsQuery + `))`
+}
+
+func (q *Queries) InsertTeam(ctx context.Context, dbc DBExecutor, arg InsertTeamParams) (Team, error) {
+	query := `
+	INSERT INTO teams (id, workspace_id, name, parent_id) VALUES (:id, :workspace_id, :name, :parent_id) RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":        arg.ID,
+		"name":      arg.Name,
+		"parent_id": arg.ParentID,
+	})
+	if err != nil {
+		return Team{}, err
+	}
+
+	var team Team
+	err = dbc.GetContext(ctx, &team, query, args...)
+	if err != nil {
+		return Team{}, err
+	}
+
+	return team, nil
+}
+
+func (q *Queries) GetTeam(ctx context.Context, dbc DBExecutor, id string) (Team, error) {
+	query := `
+	SELECT * FROM teams WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Team{}, err
+	}
+
+	var team Team
+	err = dbc.GetContext(ctx, &team, query, args...)
+	if err != nil {
+		return Team{}, err
+	}
+
+	return team, nil
+}
+
+func (q *Queries) ListTeams(ctx context.Context, dbc DBExecutor) ([]Team, error) {
+	query := `
+	SELECT * FROM teams WHERE workspace_id = :workspace_id ORDER BY name
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	teams := []Team{}
+	err = dbc.SelectContext(ctx, &teams, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// DeleteTeam deletes a team and its memberships. Its sub-teams move up to
+// its parent, and the records and views it owned are left to their
+// assignees and owners alone.
+func (q *Queries) DeleteTeam(ctx context.Context, dbc DBExecutor, id string) error {
+	var deleted int64
+	for _, query := range []string{
+		`UPDATE teams SET parent_id = (SELECT parent_id FROM teams WHERE id = :id AND workspace_id = :workspace_id)
+		WHERE parent_id = :id AND workspace_id = :workspace_id`,
+		`UPDATE entities SET team_id = NULL WHERE team_id = :id AND workspace_id = :workspace_id`,
+		`UPDATE tasks SET team_id = NULL WHERE team_id = :id AND workspace_id = :workspace_id`,
+		`UPDATE saved_views SET team_id = NULL WHERE team_id = :id AND workspace_id = :workspace_id`,
+		`DELETE FROM team_members WHERE team_id = :id AND workspace_id = :workspace_id`,
+		`DELETE FROM teams WHERE id = :id AND workspace_id = :workspace_id`,
+	} {
+		query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+			"id": id,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := dbc.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetTeamMember adds a user to a team, or changes their role if they are
+// already a member.
+func (q *Queries) SetTeamMember(ctx context.Context, dbc DBExecutor, arg TeamMember) error {
+	query := `
+	INSERT INTO team_members (team_id, user_id, role, workspace_id) VALUES (:team_id, :user_id, :role, :workspace_id)
+	ON CONFLICT (team_id, user_id) DO UPDATE SET role = excluded.role
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"team_id": arg.TeamID,
+		"user_id": arg.UserID,
+		"role":    arg.Role,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) DeleteTeamMember(ctx context.Context, dbc DBExecutor, teamID, userID string) error {
+	query := `
+	DELETE FROM team_members WHERE team_id = :team_id AND user_id = :user_id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"team_id": teamID,
+		"user_id": userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	result, err := dbc.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListTeamMembers returns the members of the given teams in the order they
+// joined.
+func (q *Queries) ListTeamMembers(ctx context.Context, dbc DBExecutor, teamIDs []string) ([]TeamMember, error) {
+	if len(teamIDs) == 0 {
+		return []TeamMember{}, nil
+	}
+
+	query, args, err := bindWorkspaceIn(ctx, dbc, `
+	SELECT * FROM team_members WHERE team_id IN (:team_ids) AND workspace_id = :workspace_id
+	ORDER BY team_id, created_at, rowid
+	`, map[string]any{
+		"team_ids": teamIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	members := []TeamMember{}
+	err = dbc.SelectContext(ctx, &members, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// ListVisibleTeamIDs returns the teams whose records the user sees.
+func (q *Queries) ListVisibleTeamIDs(ctx context.Context, dbc DBExecutor, userID string) ([]string, error) {
+	return q.listIDs(ctx, dbc, visibleTeamsQuery, userID)
+}
+
+// ListVisibleUserIDs returns the users whose assigned records the user sees,
+// the user included.
+func (q *Queries) ListVisibleUserIDs(ctx context.Context, dbc DBExecutor, userID string) ([]string, error) {
+	return q.listIDs(ctx, dbc, visibleUsersQuery, userID)
+}
+
+func (q *Queries) listIDs(ctx context.Context, dbc DBExecutor, query, viewerID string) ([]string, error) {
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"viewer_id": viewerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	err = dbc.SelectContext(ctx, &ids, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
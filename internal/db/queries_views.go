@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (q *Queries) InsertSavedView(ctx context.Context, dbc DBExecutor, arg InsertSavedViewParams) (SavedView, error) {
+	query := `
+	INSERT INTO saved_views (id, workspace_id, owner_id, name, resource, filter, sort, columns, shared, team_id)
+	VALUES (:id, :workspace_id, :owner_id, :name, :resource, :filter, :sort, :columns, :shared, :team_id)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":       arg.ID,
+		"owner_id": arg.OwnerID,
+		"name":     arg.Name,
+		"resource": arg.Resource,
+		"filter":   arg.Filter,
+		"sort":     arg.Sort,
+		"columns":  arg.Columns,
+		"shared":   arg.Shared,
+		"team_id":  arg.TeamID,
+	})
+	if err != nil {
+		return SavedView{}, err
+	}
+
+	var view SavedView
+	err = dbc.GetContext(ctx, &view, query, args...)
+	if err != nil {
+		return SavedView{}, err
+	}
+
+	return view, nil
+}
+
+func (q *Queries) GetSavedView(ctx context.Context, dbc DBExecutor, id string) (SavedView, error) {
+	query := `
+	SELECT * FROM saved_views WHERE id = :id AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return SavedView{}, err
+	}
+
+	var view SavedView
+	err = dbc.GetContext(ctx, &view, query, args...)
+	if err != nil {
+		return SavedView{}, err
+	}
+
+	return view, nil
+}
+
+// ListSavedViews returns the views a user owns along with those shared by
+// others, with everyone or with a team whose records the user can see,
+// optionally only those of one resource, by name.
+func (q *Queries) ListSavedViews(ctx context.Context, dbc DBExecutor, userID, resource string) ([]SavedView, error) {
+	query := `
+	SELECT * FROM saved_views
+	WHERE workspace_id = :workspace_id
+	AND (owner_id = :viewer_id OR shared = 1 OR team_id IN (` + visibleTeamsQuery + `))
+	AND (:resource = '' OR resource = :resource)
+	ORDER BY name, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"viewer_id": userID,
+		"resource":  resource,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	views := []SavedView{}
+	err = dbc.SelectContext(ctx, &views, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return views, nil
+}
+
+// DeleteSavedView deletes a view, unsetting it wherever it is a default.
+func (q *Queries) DeleteSavedView(ctx context.Context, dbc DBExecutor, id string) error {
+	var deleted int64
+	for _, query := range []string{
+		`DELETE FROM list_preferences WHERE view_id = :id AND workspace_id = :workspace_id`,
+		`DELETE FROM saved_views WHERE id = :id AND workspace_id = :workspace_id`,
+	} {
+		query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+			"id": id,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := dbc.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetListPreference makes a view the one the user opens the resource's list
+// with.
+func (q *Queries) SetListPreference(ctx context.Context, dbc DBExecutor, arg ListPreference) error {
+	query := `
+	INSERT INTO list_preferences (user_id, resource, view_id, workspace_id)
+	VALUES (:user_id, :resource, :view_id, :workspace_id)
+	ON CONFLICT (user_id, resource) DO UPDATE SET view_id = excluded.view_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"user_id":  arg.UserID,
+		"resource": arg.Resource,
+		"view_id":  arg.ViewID,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) DeleteListPreference(ctx context.Context, dbc DBExecutor, userID, resource string) error {
+	query := `
+	DELETE FROM list_preferences WHERE user_id = :user_id AND resource = :resource AND workspace_id = :workspace_id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"user_id":  userID,
+		"resource": resource,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (q *Queries) ListListPreferences(ctx context.Context, dbc DBExecutor, userID string) ([]ListPreference, error) {
+	query := `
+	SELECT * FROM list_preferences WHERE user_id = :user_id AND workspace_id = :workspace_id ORDER BY resource
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"user_id": userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := []ListPreference{}
+	err = dbc.SelectContext(ctx, &preferences, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+func (q *Queries) InsertWorkflow(ctx context.Context, dbc DBExecutor, arg InsertWorkflowParams) (Workflow, error) {
+	query := `
+	INSERT INTO workflows (id, workspace_id, name, trigger, condition, actions)
+	VALUES (:id, :workspace_id, :name, :trigger, :condition, :actions)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":        arg.ID,
+		"name":      arg.Name,
+		"trigger":   arg.Trigger,
+		"condition": arg.Condition,
+		"actions":   arg.Actions,
+	})
+	if err != nil {
+		return Workflow{}, err
+	}
+
+	var workflow Workflow
+	err = dbc.GetContext(ctx, &workflow, query, args...)
+	if err != nil {
+		return Workflow{}, err
+	}
+
+	return workflow, nil
+}
+
+func (q *Queries) ListWorkflows(ctx context.Context, dbc DBExecutor) ([]Workflow, error) {
+	query := `
+	SELECT * FROM workflows WHERE workspace_id = :workspace_id ORDER BY created_at, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+
+	workflows := []Workflow{}
+	err = dbc.SelectContext(ctx, &workflows, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return workflows, nil
+}
+
+// ListEnabledWorkflowsByTrigger returns the enabled workflows to run for an
+// event type, oldest first.
+func (q *Queries) ListEnabledWorkflowsByTrigger(
+	ctx context.Context,
+	dbc DBExecutor,
+	trigger string,
+) ([]Workflow, error) {
+	query := `
+	SELECT * FROM workflows WHERE trigger = :trigger AND enabled = 1 AND workspace_id = :workspace_id ORDER BY created_at, id
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"trigger": trigger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	workflows := []Workflow{}
+	err = dbc.SelectContext(ctx, &workflows, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return workflows, nil
+}
+
+func (q *Queries) SetWorkflowEnabled(ctx context.Context, dbc DBExecutor, id string, enabled bool) (Workflow, error) {
+	query := `
+	UPDATE workflows SET enabled = :enabled WHERE id = :id AND workspace_id = :workspace_id RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"id":      id,
+		"enabled": enabled,
+	})
+	if err != nil {
+		return Workflow{}, err
+	}
+
+	var workflow Workflow
+	err = dbc.GetContext(ctx, &workflow, query, args...)
+	if err != nil {
+		return Workflow{}, err
+	}
+
+	return workflow, nil
+}
+
+func (q *Queries) DeleteWorkflow(ctx context.Context, dbc DBExecutor, id string) error {
+	var deleted int64
+	for _, query := range []string{
+		`DELETE FROM workflow_runs WHERE workflow_id = :id AND workspace_id = :workspace_id`,
+		`DELETE FROM workflows WHERE id = :id AND workspace_id = :workspace_id`,
+	} {
+		query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+			"id": id,
+		})
+		if err != nil {
+			return err
+		}
+
+		result, err := dbc.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		if err != nil {
+			return err
+		}
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (q *Queries) InsertWorkflowRun(ctx context.Context, dbc DBExecutor, arg InsertWorkflowRunParams) (WorkflowRun, error) {
+	query := `
+	INSERT INTO workflow_runs (
+		workspace_id, workflow_id, event_id, event_type, status, error, log, started_at, finished_at
+	)
+	VALUES (
+		:workspace_id, :workflow_id, :event_id, :event_type, :status, :error, :log, :started_at, :finished_at
+	)
+	RETURNING *
+	`
+
+	query, args, err := bindWorkspace(ctx, dbc, query, map[string]any{
+		"workflow_id": arg.WorkflowID,
+		"event_id":    arg.EventID,
+		"event_type":  arg.EventType,
+		"status":      arg.Status,
+		"error":       arg.Error,
+		"log":         arg.Log,
+		"started_at":  arg.StartedAt,
+		"finished_at": arg.FinishedAt,
+	})
+	if err != nil {
+		return WorkflowRun{}, err
+	}
+
+	var run WorkflowRun
+	err = dbc.GetContext(ctx, &run, query, args...)
+	if err != nil {
+		return WorkflowRun{}, err
+	}
+
+	return run, nil
+}
+
+func (q *Queries) ListWorkflowRuns(ctx context.Context, dbc DBExecutor, workflowID string, limit int) ([]WorkflowRun, error) {
+	query := `
+	SELECT * FROM workflow_runs WHERE workflow_id = :workflow_id AND workspace_id = :workspace_id ORDER BY id DESC
+	`
+	params := map[string]any{
+		"workflow_id": workflowID,
+	}
+	query += limitClause(limit, 0, params)
+
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []WorkflowRun{}
+	err = dbc.SelectContext(ctx, &runs, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
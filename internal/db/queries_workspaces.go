@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+)
+
+// The queries below are the only ones not confined to the workspace of the
+// context: they manage the workspaces themselves and find the workspace a
+// request acts in.
+
+func (q *Queries) InsertWorkspace(ctx context.Context, dbc DBExecutor, arg InsertWorkspaceParams) (Workspace, error) {
+	query := `
+	INSERT INTO workspaces (id, name, base_currency) VALUES (:id, :name, :base_currency) RETURNING *
+	`
+
+	query, args, err := dbc.BindNamed(query, map[string]any{
+		"id":            arg.ID,
+		"name":          arg.Name,
+		"base_currency": arg.BaseCurrency,
+	})
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	var workspace Workspace
+	err = dbc.GetContext(ctx, &workspace, query, args...)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	return workspace, nil
+}
+
+func (q *Queries) GetWorkspace(ctx context.Context, dbc DBExecutor, id string) (Workspace, error) {
+	query := `
+	SELECT * FROM workspaces WHERE id = :id
+	`
+
+	query, args, err := dbc.BindNamed(query, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	var workspace Workspace
+	err = dbc.GetContext(ctx, &workspace, query, args...)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	return workspace, nil
+}
+
+// SetWorkspaceBaseCurrency changes the currency a workspace's reports
+// convert amounts into.
+func (q *Queries) SetWorkspaceBaseCurrency(ctx context.Context, dbc DBExecutor, id, currency string) (Workspace, error) {
+	query := `
+	UPDATE workspaces SET base_currency = :base_currency WHERE id = :id RETURNING *
+	`
+
+	query, args, err := dbc.BindNamed(query, map[string]any{
+		"id":            id,
+		"base_currency": currency,
+	})
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	var workspace Workspace
+	err = dbc.GetContext(ctx, &workspace, query, args...)
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	return workspace, nil
+}
+
+// ListWorkspaces returns every workspace, for the background jobs that go
+// through all of them.
+func (q *Queries) ListWorkspaces(ctx context.Context, dbc DBExecutor) ([]Workspace, error) {
+	query := `
+	SELECT * FROM workspaces ORDER BY created_at, id
+	`
+
+	workspaces := []Workspace{}
+	err := dbc.SelectContext(ctx, &workspaces, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return workspaces, nil
+}
+
+// GetUserWorkspaceID returns the workspace a user belongs to.
+func (q *Queries) GetUserWorkspaceID(ctx context.Context, dbc DBExecutor, userID string) (string, error) {
+	query := `
+	SELECT workspace_id FROM users WHERE id = :id
+	`
+
+	query, args, err := dbc.BindNamed(query, map[string]any{
+		"id": userID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var workspaceID string
+	err = dbc.GetContext(ctx, &workspaceID, query, args...)
+	if err != nil {
+		return "", err
+	}
+
+	return workspaceID, nil
+}
+
+// GetLeadFormWorkspaceID returns the workspace of the lead form with the
+// given key, for public submissions that come with nothing else.
+func (q *Queries) GetLeadFormWorkspaceID(ctx context.Context, dbc DBExecutor, key string) (string, error) {
+	query := `
+	SELECT workspace_id FROM lead_forms WHERE key = :key
+	`
+
+	query, args, err := dbc.BindNamed(query, map[string]any{
+		"key": key,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var workspaceID string
+	err = dbc.GetContext(ctx, &workspaceID, query, args...)
+	if err != nil {
+		return "", err
+	}
+
+	return workspaceID, nil
+}
@@ -5,32 +5,56 @@ import (
 )
 
 type Entity struct {
-	ID          string         `db:"id"`
-	FirstName   string         `db:"first_name"`
-	LastName    string         `db:"last_name"`
-	Email       string         `db:"email"`
-	Phone       string         `db:"phone"`
-	Status      string         `db:"status"`
-	AssignedTo  sql.NullString `db:"assigned_to"`
-	CreatedAt   string         `db:"created_at"`
-	ConvertedAt string         `db:"converted_at"`
+	ID             string         `db:"id"`
+	WorkspaceID    string         `db:"workspace_id"`
+	FirstName      string         `db:"first_name"`
+	LastName       string         `db:"last_name"`
+	Email          string         `db:"email"`
+	Phone          string         `db:"phone"`
+	Status         string         `db:"status"`
+	AssignedTo     sql.NullString `db:"assigned_to"`
+	CreatedAt      string         `db:"created_at"`
+	ConvertedAt    string         `db:"converted_at"`
+	EmailCanonical string         `db:"email_canonical"`
+	Source         string         `db:"source"`
+	Region         string         `db:"region"`
+	Score          int            `db:"score"`
+	ScoredAt       string         `db:"scored_at"`
+	UTMSource      string         `db:"utm_source"`
+	UTMMedium      string         `db:"utm_medium"`
+	UTMCampaign    string         `db:"utm_campaign"`
+	UTMTerm        string         `db:"utm_term"`
+	UTMContent     string         `db:"utm_content"`
+	TeamID         sql.NullString `db:"team_id"`
 }
 
 type Task struct {
 	ID          string         `db:"id"`
+	WorkspaceID string         `db:"workspace_id"`
 	Name        string         `db:"name"`
 	Description string         `db:"description"`
 	DueDate     string         `db:"due_date"`
 	AssignedTo  sql.NullString `db:"assigned_to"`
 	Status      string         `db:"status"`
+	EntityID    sql.NullString `db:"entity_id"`
+	Recurrence  string         `db:"recurrence"`
+	SeriesID    string         `db:"series_id"`
+	Occurrence  int            `db:"occurrence"`
+	TeamID      sql.NullString `db:"team_id"`
+	// CompletedAt is when the task was marked done, '' while it is open
+	CompletedAt string `db:"completed_at"`
 }
 
 type User struct {
-	ID        string `db:"id"`
-	FirstName string `db:"first_name"`
-	LastName  string `db:"last_name"`
-	Email     string `db:"email"`
-	CreatedAt string `db:"created_at"`
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	FirstName   string `db:"first_name"`
+	LastName    string `db:"last_name"`
+	Email       string `db:"email"`
+	CreatedAt   string `db:"created_at"`
+	Active      bool   `db:"active"`
+	// CalendarToken authorizes the user's calendar feed, empty if disabled
+	CalendarToken string `db:"calendar_token"`
 }
 
 type InsertAndReturnUserParams struct {
@@ -39,3 +63,940 @@ type InsertAndReturnUserParams struct {
 	LastName  string
 	Email     string
 }
+
+// TimeFormat matches SQLite's CURRENT_TIMESTAMP so stored times compare lexically.
+const TimeFormat = "2006-01-02 15:04:05"
+
+type IdempotencyKey struct {
+	Key          string `db:"key"`
+	WorkspaceID  string `db:"workspace_id"`
+	Method       string `db:"method"`
+	Path         string `db:"path"`
+	RequestHash  string `db:"request_hash"`
+	StatusCode   int    `db:"status_code"`
+	ContentType  string `db:"content_type"`
+	ResponseBody []byte `db:"response_body"`
+	CreatedAt    string `db:"created_at"`
+	ExpiresAt    string `db:"expires_at"`
+}
+
+type ReserveIdempotencyKeyParams struct {
+	Key         string
+	Method      string
+	Path        string
+	RequestHash string
+	CreatedAt   string
+	ExpiresAt   string
+}
+
+type CompleteIdempotencyKeyParams struct {
+	Key          string
+	Method       string
+	Path         string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+}
+
+type InsertAndReturnEntityParams struct {
+	ID             string
+	FirstName      string
+	LastName       string
+	Email          string
+	EmailCanonical string
+	Phone          string
+	Status         string
+	AssignedTo     sql.NullString
+	CreatedAt      string
+	ConvertedAt    string
+	Source         string
+	Region         string
+	UTMSource      string
+	UTMMedium      string
+	UTMCampaign    string
+	UTMTerm        string
+	UTMContent     string
+	TeamID         sql.NullString
+}
+
+type ImportJob struct {
+	ID            string         `db:"id"`
+	WorkspaceID   string         `db:"workspace_id"`
+	ObjectType    string         `db:"object_type"`
+	Status        string         `db:"status"`
+	Mapping       string         `db:"mapping"`
+	Source        []byte         `db:"source"`
+	TotalRows     int            `db:"total_rows"`
+	ProcessedRows int            `db:"processed_rows"`
+	SucceededRows int            `db:"succeeded_rows"`
+	FailedRows    int            `db:"failed_rows"`
+	Error         string         `db:"error"`
+	CreatedAt     string         `db:"created_at"`
+	StartedAt     sql.NullString `db:"started_at"`
+	FinishedAt    sql.NullString `db:"finished_at"`
+}
+
+type ImportError struct {
+	ID          int64  `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	JobID       string `db:"job_id"`
+	RowNumber   int    `db:"row_number"`
+	Field       string `db:"field"`
+	Message     string `db:"message"`
+	RowData     string `db:"row_data"`
+}
+
+type InsertImportJobParams struct {
+	ID         string
+	ObjectType string
+	Status     string
+	Mapping    string
+	Source     []byte
+}
+
+type UpdateImportJobProgressParams struct {
+	ID            string
+	Status        string
+	TotalRows     int
+	ProcessedRows int
+	SucceededRows int
+	FailedRows    int
+	Error         string
+	StartedAt     sql.NullString
+	FinishedAt    sql.NullString
+}
+
+type InsertImportErrorParams struct {
+	JobID     string
+	RowNumber int
+	Field     string
+	Message   string
+	RowData   string
+}
+
+// EntityFilter narrows entity listings. Zero values are ignored.
+type EntityFilter struct {
+	// Kind is "lead" for unconverted entities or "contact" for converted ones
+	Kind        string
+	Status      string
+	AssignedTo  string
+	CreatedFrom string
+	CreatedTo   string
+	// Sort is one of EntitySorts, defaulting to oldest first. CustomSort
+	// takes precedence over it.
+	Sort         string
+	CustomFields []CustomFieldFilter
+	CustomSort   *CustomFieldSort
+	// Tags keeps entities carrying every one of the tags, ExcludedTags those
+	// carrying none of them
+	Tags         []string
+	ExcludedTags []string
+	TeamID       string
+	// VisibleTo keeps the entities the user can see, see visibilityClause
+	VisibleTo string
+	Limit     int
+	Offset    int
+}
+
+// EntitySorts maps the supported entity sort orders to their ORDER BY
+// clause. A leading "-" sorts descending.
+var EntitySorts = map[string]string{
+	"created_at":  "created_at, id",
+	"-created_at": "created_at DESC, id",
+	"score":       "score, id",
+	"-score":      "score DESC, id",
+}
+
+// TaskFilter narrows task listings. Zero values are ignored.
+type TaskFilter struct {
+	Status       string
+	AssignedTo   string
+	DueFrom      string
+	DueTo        string
+	CustomFields []CustomFieldFilter
+	// CustomSort replaces the default order by due date
+	CustomSort *CustomFieldSort
+	TeamID     string
+	// VisibleTo keeps the tasks the user can see, see visibilityClause
+	VisibleTo string
+	Limit     int
+	Offset    int
+}
+
+// ReportFilter narrows the records a report aggregates. Zero values are
+// ignored.
+type ReportFilter struct {
+	// From and To bound the date the report is about, From inclusive and To
+	// exclusive
+	From string
+	To   string
+	// Interval is one of ReportIntervals, splitting the report into periods
+	Interval string
+	// GroupBy is one of EntityReportGroups or TaskReportGroups, splitting
+	// the report further
+	GroupBy string
+	TeamID  string
+	// VisibleTo keeps the records the user can see, see visibilityClause
+	VisibleTo string
+}
+
+// ReportIntervals maps the periods reports can be split into to the SQLite
+// expression of the period a date, in place of %s, falls in. Weeks start on
+// Monday.
+var ReportIntervals = map[string]string{
+	"day":   "strftime('%%Y-%%m-%%d', %s)",
+	"week":  "date(%s, '-6 days', 'weekday 1')",
+	"month": "strftime('%%Y-%%m', %s)",
+	"year":  "strftime('%%Y', %s)",
+}
+
+// EntityReportGroups maps the entity fields reports can be grouped by to
+// their column.
+var EntityReportGroups = map[string]string{
+	"status":       "status",
+	"source":       "source",
+	"region":       "region",
+	"assigned_to":  "COALESCE(assigned_to, '')",
+	"team_id":      "COALESCE(team_id, '')",
+	"utm_source":   "utm_source",
+	"utm_medium":   "utm_medium",
+	"utm_campaign": "utm_campaign",
+}
+
+// TaskReportGroups maps the task fields reports can be grouped by to their
+// column.
+var TaskReportGroups = map[string]string{
+	"assigned_to": "COALESCE(assigned_to, '')",
+	"team_id":     "COALESCE(team_id, '')",
+}
+
+// EntityCount is the number of leads created in a period and group of a
+// report.
+type EntityCount struct {
+	Period string `db:"period"`
+	Group  string `db:"grp"`
+	Count  int    `db:"count"`
+}
+
+// ConversionStats describes how the leads created in a period and group of
+// a report went on to convert.
+type ConversionStats struct {
+	Period    string `db:"period"`
+	Group     string `db:"grp"`
+	Created   int    `db:"created"`
+	Converted int    `db:"converted"`
+	// MedianDaysToConvert is not valid when none of the leads converted
+	MedianDaysToConvert sql.NullFloat64 `db:"median_days"`
+}
+
+// TaskStats counts the tasks due in a period and group of a report by how
+// they stand, and the tasks completed in it whenever they were due.
+type TaskStats struct {
+	Period    string `db:"period"`
+	Group     string `db:"grp"`
+	Total     int    `db:"total"`
+	Completed int    `db:"completed"`
+	Open      int    `db:"open"`
+	// Overdue counts the open tasks whose due date has passed, as told by
+	// recurrence.DueTime rather than in SQL
+	Overdue int `db:"-"`
+}
+
+type ScheduledReport struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	Name        string `db:"name"`
+	// Metrics and Recipients are JSON arrays
+	Metrics    string `db:"metrics"`
+	Recipients string `db:"recipients"`
+	Schedule   string `db:"schedule"`
+	Timezone   string `db:"timezone"`
+	Period     string `db:"period"`
+	Filter     string `db:"filter"`
+	NextRunAt  string `db:"next_run_at"`
+	CreatedAt  string `db:"created_at"`
+}
+
+type InsertScheduledReportParams struct {
+	ID         string
+	Name       string
+	Metrics    string
+	Recipients string
+	Schedule   string
+	Timezone   string
+	Period     string
+	Filter     string
+	NextRunAt  string
+}
+
+type ReportSnapshot struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	ReportID    string `db:"report_id"`
+	RangeFrom   string `db:"range_from"`
+	RangeTo     string `db:"range_to"`
+	// Result is a JSON object
+	Result string `db:"result"`
+	RanAt  string `db:"ran_at"`
+}
+
+type InsertReportSnapshotParams struct {
+	ID        string
+	ReportID  string
+	RangeFrom string
+	RangeTo   string
+	Result    string
+	RanAt     string
+}
+
+// Custom field filter operators
+const (
+	CustomFieldEq  = "eq"
+	CustomFieldMin = "min"
+	CustomFieldMax = "max"
+)
+
+// CustomFieldFilter keeps records whose value of a custom field equals, or
+// is at least or at most, Value, which is in canonical form.
+type CustomFieldFilter struct {
+	FieldID string
+	// Numeric compares values as numbers rather than text
+	Numeric bool
+	Op      string
+	Value   string
+}
+
+// CustomFieldSort orders records by their value of a custom field. Records
+// without a value come last.
+type CustomFieldSort struct {
+	FieldID string
+	Numeric bool
+	Desc    bool
+}
+
+type UpdateEntityParams struct {
+	ID             string
+	FirstName      string
+	LastName       string
+	Email          string
+	EmailCanonical string
+	Phone          string
+	Status         string
+	AssignedTo     sql.NullString
+	ConvertedAt    string
+	Source         string
+	Region         string
+	TeamID         sql.NullString
+}
+
+type EntityHistory struct {
+	ID          int64  `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	EntityID    string `db:"entity_id"`
+	Action      string `db:"action"`
+	Details     string `db:"details"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type InsertEntityHistoryParams struct {
+	EntityID string
+	Action   string
+	Details  string
+}
+
+type AssignmentRule struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	Name        string `db:"name"`
+	Priority    int    `db:"priority"`
+	Field       string `db:"field"`
+	Value       string `db:"value"`
+	Strategy    string `db:"strategy"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type AssignmentRuleMember struct {
+	RuleID        string `db:"rule_id"`
+	UserID        string `db:"user_id"`
+	Weight        int    `db:"weight"`
+	CurrentWeight int    `db:"current_weight"`
+	Active        bool   `db:"active"`
+}
+
+type InsertAssignmentRuleParams struct {
+	ID       string
+	Name     string
+	Priority int
+	Field    string
+	Value    string
+	Strategy string
+}
+
+type InsertAssignmentRuleMemberParams struct {
+	RuleID string
+	UserID string
+	Weight int
+}
+
+type Activity struct {
+	ID          string         `db:"id"`
+	WorkspaceID string         `db:"workspace_id"`
+	EntityID    string         `db:"entity_id"`
+	Kind        string         `db:"kind"`
+	UserID      sql.NullString `db:"user_id"`
+	Notes       string         `db:"notes"`
+	OccurredAt  string         `db:"occurred_at"`
+	CreatedAt   string         `db:"created_at"`
+	EmailID     string         `db:"email_id"`
+}
+
+type InsertActivityParams struct {
+	ID         string
+	EntityID   string
+	Kind       string
+	UserID     sql.NullString
+	Notes      string
+	OccurredAt string
+	EmailID    string
+}
+
+type ScoringRule struct {
+	ID           string `db:"id"`
+	WorkspaceID  string `db:"workspace_id"`
+	Name         string `db:"name"`
+	Kind         string `db:"kind"`
+	Field        string `db:"field"`
+	Value        string `db:"value"`
+	Points       int    `db:"points"`
+	HalfLifeDays int    `db:"half_life_days"`
+	CreatedAt    string `db:"created_at"`
+}
+
+type InsertScoringRuleParams struct {
+	ID           string
+	Name         string
+	Kind         string
+	Field        string
+	Value        string
+	Points       int
+	HalfLifeDays int
+}
+
+type InsertTaskParams struct {
+	ID          string
+	Name        string
+	Description string
+	DueDate     string
+	AssignedTo  sql.NullString
+	Status      string
+	EntityID    sql.NullString
+	Recurrence  string
+	SeriesID    string
+	Occurrence  int
+	TeamID      sql.NullString
+}
+
+type Workflow struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	Name        string `db:"name"`
+	Trigger     string `db:"trigger"`
+	Condition   string `db:"condition"`
+	Actions     string `db:"actions"`
+	Enabled     bool   `db:"enabled"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type InsertWorkflowParams struct {
+	ID        string
+	Name      string
+	Trigger   string
+	Condition string
+	Actions   string
+}
+
+type WorkflowRun struct {
+	ID          int64  `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	WorkflowID  string `db:"workflow_id"`
+	EventID     string `db:"event_id"`
+	EventType   string `db:"event_type"`
+	Status      string `db:"status"`
+	Error       string `db:"error"`
+	Log         string `db:"log"`
+	StartedAt   string `db:"started_at"`
+	FinishedAt  string `db:"finished_at"`
+}
+
+type InsertWorkflowRunParams struct {
+	WorkflowID string
+	EventID    string
+	EventType  string
+	Status     string
+	Error      string
+	Log        string
+	StartedAt  string
+	FinishedAt string
+}
+
+type Notification struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	UserID      string `db:"user_id"`
+	Kind        string `db:"kind"`
+	Title       string `db:"title"`
+	Body        string `db:"body"`
+	EntityID    string `db:"entity_id"`
+	TaskID      string `db:"task_id"`
+	EventID     string `db:"event_id"`
+	ReadAt      string `db:"read_at"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type InsertNotificationParams struct {
+	ID        string
+	UserID    string
+	Kind      string
+	Title     string
+	Body      string
+	EntityID  string
+	TaskID    string
+	EventID   string
+	CreatedAt string
+}
+
+// NotificationFilter narrows a user's notifications, newest first.
+type NotificationFilter struct {
+	UserID string
+	Unread bool
+	Limit  int
+	Offset int
+}
+
+type OutboxEmail struct {
+	ID            string `db:"id"`
+	WorkspaceID   string `db:"workspace_id"`
+	FromAddress   string `db:"from_address"`
+	ToAddresses   string `db:"to_addresses"`
+	Subject       string `db:"subject"`
+	Body          string `db:"body"`
+	HTML          string `db:"html"`
+	Status        string `db:"status"`
+	Attempts      int    `db:"attempts"`
+	LastError     string `db:"last_error"`
+	NextAttemptAt string `db:"next_attempt_at"`
+	CreatedAt     string `db:"created_at"`
+	SentAt        string `db:"sent_at"`
+}
+
+type InsertOutboxEmailParams struct {
+	ID          string
+	FromAddress string
+	ToAddresses string
+	Subject     string
+	Body        string
+	HTML        string
+	CreatedAt   string
+}
+
+type UpdateOutboxEmailParams struct {
+	ID            string
+	Status        string
+	Attempts      int
+	LastError     string
+	NextAttemptAt string
+	SentAt        string
+}
+
+// OutboxEmailFilter narrows outbox listings, newest first. Zero values are
+// ignored.
+type OutboxEmailFilter struct {
+	Status string
+	Limit  int
+	Offset int
+}
+
+type InboundEmail struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	MessageID   string `db:"message_id"`
+	FromAddress string `db:"from_address"`
+	ToAddresses string `db:"to_addresses"`
+	CcAddresses string `db:"cc_addresses"`
+	Subject     string `db:"subject"`
+	Body        string `db:"body"`
+	SentAt      string `db:"sent_at"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type InsertInboundEmailParams struct {
+	ID          string
+	MessageID   string
+	FromAddress string
+	ToAddresses string
+	CcAddresses string
+	Subject     string
+	Body        string
+	SentAt      string
+	CreatedAt   string
+}
+
+type InboundEmailAttachment struct {
+	EmailID     string `db:"email_id"`
+	WorkspaceID string `db:"workspace_id"`
+	Position    int    `db:"position"`
+	Filename    string `db:"filename"`
+	ContentType string `db:"content_type"`
+	Size        int64  `db:"size"`
+}
+
+type LeadForm struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	Name        string `db:"name"`
+	Key         string `db:"key"`
+	Mapping     string `db:"mapping"`
+	Honeypot    string `db:"honeypot"`
+	RedirectURL string `db:"redirect_url"`
+	Source      string `db:"source"`
+	Enabled     bool   `db:"enabled"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type InsertLeadFormParams struct {
+	ID          string
+	Name        string
+	Key         string
+	Mapping     string
+	Honeypot    string
+	RedirectURL string
+	Source      string
+}
+
+type CustomField struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	ObjectType  string `db:"object_type"`
+	Key         string `db:"key"`
+	Label       string `db:"label"`
+	Type        string `db:"type"`
+	Options     string `db:"options"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type InsertCustomFieldParams struct {
+	ID         string
+	ObjectType string
+	Key        string
+	Label      string
+	Type       string
+	Options    string
+}
+
+// CustomFieldValue is a record's value of a custom field along with the
+// definition it needs to be read.
+type CustomFieldValue struct {
+	FieldID    string `db:"field_id"`
+	RecordID   string `db:"record_id"`
+	Value      string `db:"value"`
+	ObjectType string `db:"object_type"`
+	Key        string `db:"key"`
+	Type       string `db:"type"`
+}
+
+type Tag struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	Name        string `db:"name"`
+	CreatedAt   string `db:"created_at"`
+}
+
+// TagCount is a tag along with the number of entities carrying it.
+type TagCount struct {
+	ID        string `db:"id"`
+	Name      string `db:"name"`
+	CreatedAt string `db:"created_at"`
+	Entities  int    `db:"entities"`
+}
+
+type EntityTag struct {
+	EntityID string `db:"entity_id"`
+	Name     string `db:"name"`
+}
+
+type Segment struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	Name        string `db:"name"`
+	ObjectType  string `db:"object_type"`
+	Filter      string `db:"filter"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type InsertSegmentParams struct {
+	ID         string
+	Name       string
+	ObjectType string
+	Filter     string
+}
+
+type SavedView struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	OwnerID     string `db:"owner_id"`
+	Name        string `db:"name"`
+	Resource    string `db:"resource"`
+	Filter      string `db:"filter"`
+	Sort        string `db:"sort"`
+	// Columns is a JSON array
+	Columns   string `db:"columns"`
+	Shared    bool   `db:"shared"`
+	CreatedAt string `db:"created_at"`
+	// TeamID is the team the view is shared with, if any
+	TeamID sql.NullString `db:"team_id"`
+}
+
+type InsertSavedViewParams struct {
+	ID       string
+	OwnerID  string
+	Name     string
+	Resource string
+	Filter   string
+	Sort     string
+	Columns  string
+	Shared   bool
+	TeamID   sql.NullString
+}
+
+type ListPreference struct {
+	UserID      string `db:"user_id"`
+	WorkspaceID string `db:"workspace_id"`
+	Resource    string `db:"resource"`
+	ViewID      string `db:"view_id"`
+}
+
+type Team struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	Name        string `db:"name"`
+	// ParentID is the team this one is part of, whose managers also manage it
+	ParentID  sql.NullString `db:"parent_id"`
+	CreatedAt string         `db:"created_at"`
+}
+
+type InsertTeamParams struct {
+	ID       string
+	Name     string
+	ParentID sql.NullString
+}
+
+type TeamMember struct {
+	TeamID      string `db:"team_id"`
+	WorkspaceID string `db:"workspace_id"`
+	UserID      string `db:"user_id"`
+	Role        string `db:"role"`
+	CreatedAt   string `db:"created_at"`
+}
+
+// Attachment is a file attached to a lead, contact or task. Exactly one of
+// EntityID and TaskID is set.
+type Attachment struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	EntityID    string `db:"entity_id"`
+	TaskID      string `db:"task_id"`
+	Filename    string `db:"filename"`
+	ContentType string `db:"content_type"`
+	Size        int64  `db:"size"`
+	// BlobKey names the content in the blob store
+	BlobKey    string `db:"blob_key"`
+	UploadedBy string `db:"uploaded_by"`
+	CreatedAt  string `db:"created_at"`
+}
+
+type InsertAttachmentParams struct {
+	ID          string
+	EntityID    string
+	TaskID      string
+	Filename    string
+	ContentType string
+	Size        int64
+	BlobKey     string
+	UploadedBy  string
+}
+
+type Workspace struct {
+	ID           string `db:"id"`
+	Name         string `db:"name"`
+	CreatedAt    string `db:"created_at"`
+	BaseCurrency string `db:"base_currency"`
+}
+
+type InsertWorkspaceParams struct {
+	ID           string
+	Name         string
+	BaseCurrency string
+}
+
+type Product struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	SKU         string `db:"sku"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+	Active      bool   `db:"active"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type InsertProductParams struct {
+	ID          string
+	SKU         string
+	Name        string
+	Description string
+}
+
+type ProductPrice struct {
+	WorkspaceID string `db:"workspace_id"`
+	ProductID   string `db:"product_id"`
+	Currency    string `db:"currency"`
+	// UnitPrice is in the currency's minor unit
+	UnitPrice int64 `db:"unit_price"`
+}
+
+type Quote struct {
+	ID          string `db:"id"`
+	WorkspaceID string `db:"workspace_id"`
+	Number      int64  `db:"number"`
+	EntityID    string `db:"entity_id"`
+	Title       string `db:"title"`
+	Currency    string `db:"currency"`
+	Status      string `db:"status"`
+	// TaxRate is in basis points
+	TaxRate    int64  `db:"tax_rate"`
+	ValidUntil string `db:"valid_until"`
+	Notes      string `db:"notes"`
+	CreatedBy  string `db:"created_by"`
+	CreatedAt  string `db:"created_at"`
+	UpdatedAt  string `db:"updated_at"`
+	SentAt     string `db:"sent_at"`
+	DecidedAt  string `db:"decided_at"`
+}
+
+type InsertQuoteParams struct {
+	ID         string
+	EntityID   string
+	Title      string
+	Currency   string
+	TaxRate    int64
+	ValidUntil string
+	Notes      string
+	CreatedBy  string
+}
+
+type UpdateQuoteParams struct {
+	ID         string
+	Title      string
+	TaxRate    int64
+	ValidUntil string
+	Notes      string
+}
+
+type UpdateQuoteStatusParams struct {
+	ID        string
+	Status    string
+	SentAt    string
+	DecidedAt string
+}
+
+// QuoteFilter narrows quote listings. Zero values are ignored.
+type QuoteFilter struct {
+	EntityID string
+	Status   string
+	// VisibleTo keeps the quotes on leads and contacts this user can see
+	VisibleTo string
+	Limit     int
+	Offset    int
+}
+
+type QuoteLine struct {
+	ID          string         `db:"id"`
+	WorkspaceID string         `db:"workspace_id"`
+	QuoteID     string         `db:"quote_id"`
+	Position    int            `db:"position"`
+	ProductID   sql.NullString `db:"product_id"`
+	SKU         string         `db:"sku"`
+	Description string         `db:"description"`
+	Quantity    int64          `db:"quantity"`
+	// UnitPrice is in the quote currency's minor unit
+	UnitPrice int64 `db:"unit_price"`
+	// Discount is in basis points
+	Discount int64 `db:"discount"`
+}
+
+type InsertQuoteLineParams struct {
+	ID          string
+	QuoteID     string
+	ProductID   sql.NullString
+	SKU         string
+	Description string
+	Quantity    int64
+	UnitPrice   int64
+	Discount    int64
+}
+
+type UpdateQuoteLineParams struct {
+	ID        string
+	QuoteID   string
+	Quantity  int64
+	UnitPrice int64
+	Discount  int64
+}
+
+type ExchangeRate struct {
+	WorkspaceID  string `db:"workspace_id"`
+	FromCurrency string `db:"from_currency"`
+	ToCurrency   string `db:"to_currency"`
+	// Rate is the price of one unit of FromCurrency in ToCurrency, as a
+	// decimal
+	Rate        string `db:"rate"`
+	EffectiveOn string `db:"effective_on"`
+	CreatedAt   string `db:"created_at"`
+}
+
+type SetExchangeRateParams struct {
+	FromCurrency string
+	ToCurrency   string
+	Rate         string
+	EffectiveOn  string
+}
+
+// ExchangeRateFilter narrows exchange rate listings. Zero values are ignored.
+type ExchangeRateFilter struct {
+	// Currency keeps the rates from or to a currency
+	Currency string
+	// OnOrBefore keeps the rates effective by a date
+	OnOrBefore string
+}
+
+// QuoteReportGroups maps the fields quote reports can be grouped by to their
+// column, on quotes q joined with the entities e they are made to.
+var QuoteReportGroups = map[string]string{
+	"status":      "q.status",
+	"currency":    "q.currency",
+	"created_by":  "q.created_by",
+	"assigned_to": "COALESCE(e.assigned_to, '')",
+	"team_id":     "COALESCE(e.team_id, '')",
+}
+
+// QuoteTotal is the total of a quote decided in a period and group of a
+// report, in the quote's currency.
+type QuoteTotal struct {
+	Period   string `db:"period"`
+	Group    string `db:"grp"`
+	QuoteID  string `db:"quote_id"`
+	Currency string `db:"currency"`
+	// Status is accepted or declined
+	Status    string `db:"status"`
+	DecidedAt string `db:"decided_at"`
+	Total     int64  `db:"total"`
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultWorkspaceID is the workspace holding the records created before
+// workspaces existed, and the one single-tenant deployments keep using.
+const DefaultWorkspaceID = "default"
+
+// ErrNoWorkspace is returned by the queries of workspace records when the
+// context carries no workspace.
+var ErrNoWorkspace = errors.New("no workspace in context")
+
+type workspaceKey struct{}
+
+// WithWorkspace returns a context whose queries are confined to the given
+// workspace.
+func WithWorkspace(ctx context.Context, workspaceID string) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspaceID)
+}
+
+// WorkspaceFrom returns the workspace queries made with ctx are confined to.
+func WorkspaceFrom(ctx context.Context) (string, bool) {
+	workspaceID, ok := ctx.Value(workspaceKey{}).(string)
+	return workspaceID, ok && workspaceID != ""
+}
+
+// bindWorkspace binds the named parameters of a query along with the
+// workspace_id of the context. Every query of workspace records goes
+// through it, so one that forgets to filter on the workspace fails instead
+// of reading or writing another workspace's rows. The check only proves
+// that the parameter appears somewhere in the query: every table a query
+// joins or reads in a subquery must still be matched on workspace_id
+// itself.
+func bindWorkspace(ctx context.Context, dbc DBExecutor, query string, params map[string]any) (string, []any, error) {
+	workspaceID, ok := WorkspaceFrom(ctx)
+	if !ok {
+		return "", nil, ErrNoWorkspace
+	}
+	if !strings.Contains(query, ":workspace_id") {
+		return "", nil, fmt.Errorf("query is not scoped to a workspace: %s", query)
+	}
+
+	params["workspace_id"] = workspaceID
+	return dbc.BindNamed(query, params)
+}
+
+// bindWorkspaceIn is bindWorkspace for queries with slice parameters, as in
+// id IN (:ids).
+func bindWorkspaceIn(ctx context.Context, dbc DBExecutor, query string, params map[string]any) (string, []any, error) {
+	query, args, err := bindWorkspace(ctx, dbc, query, params)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, args, err = sqlx.In(query, args...)
+	if err != nil {
+		return "", nil, err
+	}
+	return dbc.Rebind(query), args, nil
+}
+
+// EachWorkspace calls f with a context confined to each workspace in turn,
+// for the background jobs that go through all of them. A workspace whose
+// call fails does not keep the others from being handled; the errors are
+// returned together.
+func EachWorkspace(ctx context.Context, dbc DBExecutor, querier Querier, f func(ctx context.Context) error) error {
+	workspaces, err := querier.ListWorkspaces(ctx, dbc)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, workspace := range workspaces {
+		if err := f(WithWorkspace(ctx, workspace.ID)); err != nil {
+			errs = append(errs, fmt.Errorf("workspace %s: %w", workspace.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
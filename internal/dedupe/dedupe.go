@@ -0,0 +1,269 @@
+package dedupe
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/normalize"
+)
+
+// Weights of each signal in a candidate pair's score. They add up to 1 so a
+// pair matching on every signal scores 1.
+const (
+	emailWeight = 0.45
+	phoneWeight = 0.30
+	nameWeight  = 0.25
+)
+
+// minNameSimilarity is the Jaro-Winkler similarity below which names are not
+// considered to match at all.
+const minNameSimilarity = 0.85
+
+// Candidate is a pair of entities that likely describe the same person.
+type Candidate struct {
+	A       db.Entity
+	B       db.Entity
+	Score   float64
+	Reasons []string
+}
+
+// FindDuplicates scores pairs of entities matching filter and returns those
+// scoring at least minScore, best first. Only pairs sharing a normalized
+// email, a normalized phone number or a phonetic last name are compared, so
+// the whole table is never compared pairwise.
+func FindDuplicates(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	filter db.EntityFilter,
+	minScore float64,
+) ([]Candidate, error) {
+	var entities []db.Entity
+	blocks := map[string][]int{}
+
+	err := querier.IterateEntities(ctx, dbc, filter, func(entity db.Entity) error {
+		n := len(entities)
+		entities = append(entities, entity)
+
+		if email := NormalizeEmail(entity); email != "" {
+			blocks["email:"+email] = append(blocks["email:"+email], n)
+		}
+		if phone := NormalizePhone(entity.Phone); phone != "" {
+			blocks["phone:"+phone] = append(blocks["phone:"+phone], n)
+		}
+		if key := Soundex(entity.LastName); key != "" {
+			blocks["name:"+key] = append(blocks["name:"+key], n)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type pair struct{ a, b int }
+	seen := map[pair]bool{}
+	candidates := []Candidate{}
+
+	for _, members := range blocks {
+		for i := 0; i < len(members); i++ {
+			for j := i + 1; j < len(members); j++ {
+				p := pair{members[i], members[j]}
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+
+				score, reasons := Score(entities[p.a], entities[p.b])
+				if score >= minScore {
+					candidates = append(candidates, Candidate{
+						A:       entities[p.a],
+						B:       entities[p.b],
+						Score:   score,
+						Reasons: reasons,
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].A.ID != candidates[j].A.ID {
+			return candidates[i].A.ID < candidates[j].A.ID
+		}
+		return candidates[i].B.ID < candidates[j].B.ID
+	})
+
+	return candidates, nil
+}
+
+// Score returns how likely a and b are the same person, between 0 and 1,
+// along with the signals that matched.
+func Score(a, b db.Entity) (float64, []string) {
+	score := 0.0
+	reasons := []string{}
+
+	if email := NormalizeEmail(a); email != "" && email == NormalizeEmail(b) {
+		score += emailWeight
+		reasons = append(reasons, "email")
+	}
+
+	if phone := NormalizePhone(a.Phone); phone != "" && phone == NormalizePhone(b.Phone) {
+		score += phoneWeight
+		reasons = append(reasons, "phone")
+	}
+
+	similarity := JaroWinkler(normalizeName(a), normalizeName(b))
+	if similarity >= minNameSimilarity {
+		score += nameWeight * similarity
+		reasons = append(reasons, "name")
+	}
+
+	return score, reasons
+}
+
+// NormalizeEmail returns the form of an email address used for matching,
+// preferring the canonical form stored with the entity.
+func NormalizeEmail(entity db.Entity) string {
+	if entity.EmailCanonical != "" {
+		return entity.EmailCanonical
+	}
+	return normalize.CanonicalEmail(entity.Email)
+}
+
+// NormalizePhone keeps the last ten digits of a phone number so that the
+// same number written with or without a country code still matches.
+func NormalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+
+	if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	return digits
+}
+
+func normalizeName(entity db.Entity) string {
+	name := strings.ToLower(entity.FirstName + " " + entity.LastName)
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ")
+}
+
+// Soundex returns the American Soundex code of the first word of s, or an
+// empty string if it has no ASCII letters.
+func Soundex(s string) string {
+	codes := map[rune]byte{
+		'b': '1', 'f': '1', 'p': '1', 'v': '1',
+		'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+		'd': '3', 't': '3',
+		'l': '4',
+		'm': '5', 'n': '5',
+		'r': '6',
+	}
+
+	var out []byte
+	var last byte
+	for _, r := range strings.ToLower(s) {
+		if r < 'a' || r > 'z' {
+			if len(out) > 0 && r == ' ' {
+				break
+			}
+			continue
+		}
+
+		code := codes[r]
+		if len(out) == 0 {
+			out = append(out, byte(unicode.ToUpper(r)))
+			last = code
+			continue
+		}
+
+		// h and w do not separate letters with the same code, vowels do
+		if r == 'h' || r == 'w' {
+			continue
+		}
+		if code != 0 && code != last {
+			out = append(out, code)
+			if len(out) == 4 {
+				break
+			}
+		}
+		last = code
+	}
+
+	if len(out) == 0 {
+		return ""
+	}
+	for len(out) < 4 {
+		out = append(out, '0')
+	}
+	return string(out)
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of a and b between 0 and 1.
+func JaroWinkler(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	window := max(len(ra), len(rb))/2 - 1
+	window = max(window, 0)
+
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i := range ra {
+		lo := max(0, i-window)
+		hi := min(len(rb), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !matchedB[j] && ra[i] == rb[j] {
+				matchedA[i] = true
+				matchedB[j] = true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if ra[i] != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(ra), len(rb)) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,64 @@
+package dedupe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+)
+
+func TestSoundex(t *testing.T) {
+	a := require.New(t)
+
+	a.Equal("R163", Soundex("Robert"))
+	a.Equal("R163", Soundex("Rupert"))
+	a.Equal("A261", Soundex("Ashcraft"))
+	a.Equal("T522", Soundex("Tymczak"))
+	a.Equal("P236", Soundex("Pfister"))
+	a.Equal("", Soundex("  "))
+}
+
+func TestJaroWinkler(t *testing.T) {
+	a := require.New(t)
+
+	a.InDelta(0.961, JaroWinkler("martha", "marhta"), 0.001)
+	a.InDelta(0.840, JaroWinkler("dwayne", "duane"), 0.001)
+	a.Equal(1.0, JaroWinkler("jane doe", "jane doe"))
+	a.Equal(0.0, JaroWinkler("abc", ""))
+}
+
+func TestFindDuplicates(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	defer dbc.Close()
+	dbc.SetMaxOpenConns(1)
+
+	err = database.Migrate(context.Background(), dbc)
+	a.NoError(err)
+
+	_, err = dbc.Exec(`
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, created_at, converted_at) VALUES
+	('a', 'Jane', 'Doe', 'Jane.Doe@Example.com', '+1 (555) 010-0100', 'new', '2026-01-01 09:00:00', ''),
+	('b', 'Jane', 'Doe', 'jane.doe@example.com ', '555-010-0100', 'new', '2026-01-02 09:00:00', ''),
+	('c', 'Janet', 'Dough', 'janet@other.com', '555-999-0000', 'new', '2026-01-03 09:00:00', ''),
+	('d', 'Bob', 'Stone', 'bob@example.com', '555-123-4567', 'new', '2026-01-04 09:00:00', '')
+	`)
+	a.NoError(err)
+
+	// Test
+	candidates, err := FindDuplicates(db.WithWorkspace(context.Background(), db.DefaultWorkspaceID), dbc, db.NewQueries(), db.EntityFilter{}, 0.5)
+	a.NoError(err)
+
+	a.Len(candidates, 1)
+	a.Equal("a", candidates[0].A.ID)
+	a.Equal("b", candidates[0].B.ID)
+	a.InDelta(1.0, candidates[0].Score, 0.001)
+	a.Equal([]string{"email", "phone", "name"}, candidates[0].Reasons)
+}
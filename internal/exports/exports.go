@@ -0,0 +1,257 @@
+package exports
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"simplecrm/internal/db"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatVCard  = "vcard"
+	FormatICal   = "ics"
+)
+
+// flushEvery bounds how many records are buffered before they are written
+// out, so large exports reach the client while they are still running.
+const flushEvery = 100
+
+var ContentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+	FormatVCard:  "text/vcard; charset=utf-8",
+	FormatICal:   "text/calendar; charset=utf-8",
+}
+
+var Extensions = map[string]string{
+	FormatCSV:    "csv",
+	FormatNDJSON: "ndjson",
+	FormatVCard:  "vcf",
+	FormatICal:   "ics",
+}
+
+var entityColumns = []string{
+	"id", "first_name", "last_name", "email", "phone", "status", "assigned_to", "created_at", "converted_at",
+	"source", "region", "score",
+}
+
+var taskColumns = []string{
+	"id", "name", "description", "due_date", "assigned_to", "status", "entity_id", "recurrence",
+}
+
+type entityRecord struct {
+	ID          string `json:"id"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Status      string `json:"status"`
+	AssignedTo  string `json:"assigned_to,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	ConvertedAt string `json:"converted_at,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Score       int    `json:"score"`
+}
+
+type taskRecord struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	AssignedTo  string `json:"assigned_to,omitempty"`
+	Status      string `json:"status"`
+	EntityID    string `json:"entity_id,omitempty"`
+	Recurrence  string `json:"recurrence,omitempty"`
+}
+
+// Flusher is implemented by writers such as http.ResponseWriter that buffer
+// output internally.
+type Flusher interface {
+	Flush()
+}
+
+// Entities writes every entity matching filter to w in format. vCards can
+// only be produced for contacts.
+func Entities(
+	ctx context.Context,
+	w io.Writer,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	format string,
+	filter db.EntityFilter,
+) error {
+	if format == FormatVCard && filter.Kind != "contact" {
+		return fmt.Errorf("format %s is only supported for contacts", format)
+	}
+	if format == FormatICal {
+		return fmt.Errorf("format %s is only supported for tasks", format)
+	}
+
+	out := newWriter(w)
+	encode, err := entityEncoder(out, format)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	err = querier.IterateEntities(ctx, dbc, filter, func(entity db.Entity) error {
+		if err := encode(entity); err != nil {
+			return err
+		}
+		n++
+		if n%flushEvery == 0 {
+			return out.flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return out.flush()
+}
+
+// Tasks writes every task matching filter to w as CSV, NDJSON or an
+// iCalendar of to-dos.
+func Tasks(
+	ctx context.Context,
+	w io.Writer,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	format string,
+	filter db.TaskFilter,
+) error {
+	if format == FormatICal {
+		return Calendar(ctx, w, dbc, querier, filter, CalendarOptions{
+			Name:      "Tasks",
+			Component: ComponentTodo,
+			Now:       time.Now(),
+		})
+	}
+
+	out := newWriter(w)
+	encode, err := taskEncoder(out, format)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	err = querier.IterateTasks(ctx, dbc, filter, func(task db.Task) error {
+		if err := encode(task); err != nil {
+			return err
+		}
+		n++
+		if n%flushEvery == 0 {
+			return out.flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return out.flush()
+}
+
+func entityEncoder(out *writer, format string) (func(db.Entity) error, error) {
+	switch format {
+	case FormatCSV:
+		if err := out.csv.Write(entityColumns); err != nil {
+			return nil, err
+		}
+		return func(e db.Entity) error {
+			return out.csv.Write([]string{
+				e.ID, e.FirstName, e.LastName, e.Email, e.Phone, e.Status, e.AssignedTo.String, e.CreatedAt, e.ConvertedAt,
+				e.Source, e.Region, strconv.Itoa(e.Score),
+			})
+		}, nil
+	case FormatNDJSON:
+		enc := json.NewEncoder(out.buf)
+		return func(e db.Entity) error {
+			return enc.Encode(entityRecord{
+				ID:          e.ID,
+				FirstName:   e.FirstName,
+				LastName:    e.LastName,
+				Email:       e.Email,
+				Phone:       e.Phone,
+				Status:      e.Status,
+				AssignedTo:  e.AssignedTo.String,
+				CreatedAt:   e.CreatedAt,
+				ConvertedAt: e.ConvertedAt,
+				Source:      e.Source,
+				Region:      e.Region,
+				Score:       e.Score,
+			})
+		}, nil
+	case FormatVCard:
+		return func(e db.Entity) error {
+			return writeVCard(out.buf, e)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func taskEncoder(out *writer, format string) (func(db.Task) error, error) {
+	switch format {
+	case FormatCSV:
+		if err := out.csv.Write(taskColumns); err != nil {
+			return nil, err
+		}
+		return func(t db.Task) error {
+			return out.csv.Write([]string{
+				t.ID, t.Name, t.Description, t.DueDate, t.AssignedTo.String, t.Status, t.EntityID.String, t.Recurrence,
+			})
+		}, nil
+	case FormatNDJSON:
+		enc := json.NewEncoder(out.buf)
+		return func(t db.Task) error {
+			return enc.Encode(taskRecord{
+				ID:          t.ID,
+				Name:        t.Name,
+				Description: t.Description,
+				DueDate:     t.DueDate,
+				AssignedTo:  t.AssignedTo.String,
+				Status:      t.Status,
+				EntityID:    t.EntityID.String,
+				Recurrence:  t.Recurrence,
+			})
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+type writer struct {
+	dst io.Writer
+	buf *bufio.Writer
+	csv *csv.Writer
+}
+
+func newWriter(w io.Writer) *writer {
+	buf := bufio.NewWriter(w)
+	return &writer{dst: w, buf: buf, csv: csv.NewWriter(buf)}
+}
+
+func (w *writer) flush() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := w.dst.(Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
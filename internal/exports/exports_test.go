@@ -0,0 +1,127 @@
+package exports
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+)
+
+func setupTest(t *testing.T) *sqlx.DB {
+	a := require.New(t)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	t.Cleanup(func() { dbc.Close() })
+
+	err = database.Migrate(context.Background(), dbc)
+	a.NoError(err)
+
+	_, err = dbc.Exec(`
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, created_at, converted_at) VALUES
+	('lead1', 'Jane', 'Doe', 'jane@example.com', '555-0100', 'new', '2026-01-01 09:00:00', ''),
+	('contact1', 'John', 'Smith, Jr', 'john@example.com', '555-0101', 'converted', '2026-01-02 09:00:00', '2026-02-01 09:00:00')
+	`)
+	a.NoError(err)
+
+	return dbc
+}
+
+func TestEntities_CSV(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc := setupTest(t)
+
+	// Test
+	var buf bytes.Buffer
+	err := Entities(db.WithWorkspace(context.Background(), db.DefaultWorkspaceID), &buf, dbc, db.NewQueries(), FormatCSV, db.EntityFilter{Kind: "lead"})
+	a.NoError(err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	a.Len(lines, 2)
+	a.Equal(strings.Join(entityColumns, ","), lines[0])
+	a.True(strings.HasPrefix(lines[1], "lead1,Jane,Doe,jane@example.com"))
+}
+
+func TestEntities_VCard(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc := setupTest(t)
+
+	// Test
+	var buf bytes.Buffer
+	err := Entities(db.WithWorkspace(context.Background(), db.DefaultWorkspaceID), &buf, dbc, db.NewQueries(), FormatVCard, db.EntityFilter{Kind: "contact"})
+	a.NoError(err)
+
+	vcard := buf.String()
+	a.Contains(vcard, "BEGIN:VCARD\r\nVERSION:4.0\r\n")
+	a.Contains(vcard, "FN:John Smith\\, Jr\r\n")
+	a.Contains(vcard, "N:Smith\\, Jr;John;;;\r\n")
+	a.Contains(vcard, "EMAIL:john@example.com\r\n")
+	a.NotContains(vcard, "jane@example.com")
+
+	err = Entities(db.WithWorkspace(context.Background(), db.DefaultWorkspaceID), &buf, dbc, db.NewQueries(), FormatVCard, db.EntityFilter{Kind: "lead"})
+	a.Error(err)
+}
+
+func TestFoldLine(t *testing.T) {
+	a := require.New(t)
+
+	line := "NOTE:" + strings.Repeat("é", 100)
+	folded := foldLine(line)
+
+	for _, part := range strings.Split(folded, "\r\n") {
+		a.LessOrEqual(len(part), lineLength)
+	}
+	a.Equal(line, strings.ReplaceAll(folded, "\r\n ", ""))
+}
+
+func TestCalendar(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc := setupTest(t)
+	_, err := dbc.Exec(`
+	INSERT INTO tasks (id, name, description, due_date, status, recurrence, series_id, occurrence) VALUES
+	('task1', 'Weekly check-in', 'Call, then log it', '2026-01-05 09:00:00', 'open', 'FREQ=WEEKLY;BYDAY=MO;COUNT=4', 'task1', 2),
+	('task2', 'Renewal', '', '2026-02-01', 'done', 'FREQ=MONTHLY;UNTIL=20261231T235959Z', 'task2', 1),
+	('task3', 'Someday', '', '', 'open', '', '', 1)
+	`)
+	a.NoError(err)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Test
+	var buf bytes.Buffer
+	err = Calendar(db.WithWorkspace(context.Background(), db.DefaultWorkspaceID), &buf, dbc, db.NewQueries(), db.TaskFilter{}, CalendarOptions{Name: "Tasks", Now: now})
+	a.NoError(err)
+
+	ics := buf.String()
+	a.True(strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	a.True(strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	a.Equal(2, strings.Count(ics, "BEGIN:VEVENT"))
+	a.Contains(ics, "DTSTAMP:20260101T120000Z\r\n")
+	a.Contains(ics, "DESCRIPTION:Call\\, then log it\r\n")
+	a.Contains(ics, "DTSTART:20260105T090000Z\r\nDTEND:20260105T093000Z\r\n")
+	// The second occurrence leaves three of four
+	a.Contains(ics, "RRULE:FREQ=WEEKLY;BYDAY=MO;COUNT=3\r\n")
+	a.Contains(ics, "DTSTART;VALUE=DATE:20260201\r\nDTEND;VALUE=DATE:20260202\r\n")
+	a.Contains(ics, "CATEGORIES:Done\r\n")
+	a.NotContains(ics, "FREQ=MONTHLY")
+	a.NotContains(ics, "Someday")
+
+	buf.Reset()
+	err = Calendar(db.WithWorkspace(context.Background(), db.DefaultWorkspaceID), &buf, dbc, db.NewQueries(), db.TaskFilter{}, CalendarOptions{Component: ComponentTodo, Now: now})
+	a.NoError(err)
+
+	ics = buf.String()
+	a.Equal(2, strings.Count(ics, "BEGIN:VTODO"))
+	a.Contains(ics, "DUE:20260105T090000Z\r\nSTATUS:NEEDS-ACTION\r\n")
+	a.Contains(ics, "DUE;VALUE=DATE:20260201\r\nSTATUS:COMPLETED\r\n")
+}
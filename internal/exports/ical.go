@@ -0,0 +1,186 @@
+package exports
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/recurrence"
+)
+
+// Tasks are published as events by default since not every calendar app
+// shows to-dos.
+const (
+	ComponentEvent = "vevent"
+	ComponentTodo  = "vtodo"
+)
+
+// eventDuration is the length given to the events of tasks due at a time.
+const eventDuration = 30 * time.Minute
+
+const icalTimeFormat = "20060102T150405Z"
+
+// CalendarOptions controls how tasks are written as an iCalendar object.
+type CalendarOptions struct {
+	// Name is shown by calendar apps as the name of the calendar
+	Name string
+	// Component is ComponentEvent or ComponentTodo
+	Component string
+	// Now is the DTSTAMP of every component
+	Now time.Time
+}
+
+// Calendar writes the tasks matching filter to w as an iCalendar (RFC 5545)
+// object. Tasks without a due date are left out. Open recurring tasks carry
+// their recurrence rule, so calendar apps show upcoming occurrences too.
+func Calendar(
+	ctx context.Context,
+	w io.Writer,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	filter db.TaskFilter,
+	opts CalendarOptions,
+) error {
+	if opts.Component == "" {
+		opts.Component = ComponentEvent
+	}
+	if opts.Component != ComponentEvent && opts.Component != ComponentTodo {
+		return fmt.Errorf("unsupported component %q", opts.Component)
+	}
+	stamp := opts.Now.UTC().Format(icalTimeFormat)
+
+	out := newWriter(w)
+	write := func(lines ...string) error {
+		for _, line := range lines {
+			if _, err := io.WriteString(out.buf, foldLine(line)+"\r\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	header := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//simplecrm//Tasks//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+	}
+	if opts.Name != "" {
+		header = append(header, "X-WR-CALNAME:"+textEscaper.Replace(opts.Name))
+	}
+	if err := write(header...); err != nil {
+		return err
+	}
+
+	n := 0
+	err := querier.IterateTasks(ctx, dbc, filter, func(task db.Task) error {
+		lines, ok := taskComponent(task, opts.Component, stamp)
+		if !ok {
+			return nil
+		}
+		if err := write(lines...); err != nil {
+			return err
+		}
+		n++
+		if n%flushEvery == 0 {
+			return out.flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := write("END:VCALENDAR"); err != nil {
+		return err
+	}
+	return out.flush()
+}
+
+// taskComponent returns the lines of the VEVENT or VTODO of task, reporting
+// false for tasks that cannot be placed on a calendar.
+func taskComponent(task db.Task, component, stamp string) ([]string, bool) {
+	due, layout, err := recurrence.ParseTime(task.DueDate)
+	if err != nil {
+		return nil, false
+	}
+	allDay := layout == time.DateOnly
+
+	formatTime := func(name string, t time.Time) string {
+		if allDay {
+			return name + ";VALUE=DATE:" + t.Format("20060102")
+		}
+		return name + ":" + t.UTC().Format(icalTimeFormat)
+	}
+
+	done := task.Status == "done"
+	name := "VEVENT"
+	if component == ComponentTodo {
+		name = "VTODO"
+	}
+
+	lines := []string{
+		"BEGIN:" + name,
+		"UID:" + task.ID,
+		"DTSTAMP:" + stamp,
+		"SUMMARY:" + textEscaper.Replace(task.Name),
+	}
+	if task.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+textEscaper.Replace(task.Description))
+	}
+
+	if component == ComponentTodo {
+		lines = append(lines, formatTime("DUE", due))
+		if done {
+			lines = append(lines, "STATUS:COMPLETED", "PERCENT-COMPLETE:100")
+		} else {
+			lines = append(lines, "STATUS:NEEDS-ACTION")
+		}
+	} else {
+		end := due.Add(eventDuration)
+		if allDay {
+			// All-day events end when the task is due by
+			end, _ = recurrence.DueTime(task.DueDate)
+		}
+		lines = append(lines, formatTime("DTSTART", due), formatTime("DTEND", end), "STATUS:CONFIRMED", "TRANSP:TRANSPARENT")
+		if done {
+			// Events have no completed status, so done tasks are marked in
+			// their categories instead
+			lines = append(lines, "CATEGORIES:Done")
+		}
+	}
+
+	if rrule, ok := taskRecurrence(task, allDay); ok && !done {
+		lines = append(lines, "RRULE:"+rrule)
+	}
+
+	return append(lines, "END:"+name), true
+}
+
+// taskRecurrence returns the rule of the series from task onwards. Completing
+// an occurrence creates the next one, so only the open occurrence of a series
+// carries it and COUNT only counts the occurrences left.
+func taskRecurrence(task db.Task, allDay bool) (string, bool) {
+	if task.Recurrence == "" {
+		return "", false
+	}
+
+	rule, err := recurrence.Parse(task.Recurrence)
+	if err != nil {
+		return "", false
+	}
+	if rule.Count > 0 {
+		rule.Count -= task.Occurrence - 1
+		if rule.Count <= 0 {
+			return "", false
+		}
+	}
+
+	if allDay {
+		return rule.DateString(), true
+	}
+	return rule.String(), true
+}
@@ -0,0 +1,81 @@
+package exports
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"simplecrm/internal/db"
+)
+
+// vCard and iCalendar lines longer than this many octets must be folded
+// (RFC 6350 3.2, RFC 5545 3.1).
+const lineLength = 75
+
+// textEscaper escapes TEXT values, which vCard and iCalendar share.
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	",", `\,`,
+	";", `\;`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+// writeVCard writes entity as a vCard 4.0 (RFC 6350) object.
+func writeVCard(w io.Writer, entity db.Entity) error {
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"UID:urn:uuid:" + entity.ID,
+		"FN:" + textEscaper.Replace(strings.TrimSpace(entity.FirstName+" "+entity.LastName)),
+		"N:" + textEscaper.Replace(entity.LastName) + ";" + textEscaper.Replace(entity.FirstName) + ";;;",
+	}
+	if entity.Email != "" {
+		lines = append(lines, "EMAIL:"+textEscaper.Replace(entity.Email))
+	}
+	if entity.Phone != "" {
+		lines = append(lines, "TEL;VALUE=text:"+textEscaper.Replace(entity.Phone))
+	}
+	if rev, err := time.Parse(db.TimeFormat, entity.CreatedAt); err == nil {
+		lines = append(lines, "REV:"+rev.UTC().Format("20060102T150405Z"))
+	}
+	lines = append(lines, "END:VCARD")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, foldLine(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// foldLine splits line into CRLF-separated chunks of at most lineLength
+// octets, each continuation starting with a space, without breaking UTF-8
+// sequences.
+func foldLine(line string) string {
+	if len(line) <= lineLength {
+		return line
+	}
+
+	var b strings.Builder
+	limit := lineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines lose one octet to the leading space
+		limit = lineLength - 1
+	}
+	b.WriteString(line)
+
+	return b.String()
+}
+
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
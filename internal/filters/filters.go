@@ -0,0 +1,222 @@
+// Package filters reads list filters from query strings, as given to list and
+// export endpoints or stored in segments.
+package filters
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"simplecrm/internal/customfields"
+	"simplecrm/internal/db"
+)
+
+// Entity reads the filters of entity listings and exports from the query
+// string. fields are the custom fields of kind, which can be filtered and
+// sorted on. The tag and not_tag parameters can be repeated. Callers keep
+// the entities the user making the request can see by setting VisibleTo.
+func Entity(q url.Values, kind string, fields []db.CustomField) (db.EntityFilter, error) {
+	limit, offset, err := Page(q)
+	if err != nil {
+		return db.EntityFilter{}, err
+	}
+
+	customFilters, customSort, err := parseCustomFieldQuery(q, fields)
+	if err != nil {
+		return db.EntityFilter{}, err
+	}
+
+	sort := q.Get("sort")
+	if customSort != nil {
+		sort = ""
+	} else if _, ok := db.EntitySorts[sort]; sort != "" && !ok {
+		return db.EntityFilter{}, fmt.Errorf("invalid sort %q", sort)
+	}
+
+	return db.EntityFilter{
+		Kind:         kind,
+		Status:       q.Get("status"),
+		AssignedTo:   q.Get("assigned_to"),
+		CreatedFrom:  q.Get("created_from"),
+		CreatedTo:    q.Get("created_to"),
+		Sort:         sort,
+		CustomFields: customFilters,
+		CustomSort:   customSort,
+		Tags:         q["tag"],
+		ExcludedTags: q["not_tag"],
+		TeamID:       q.Get("team_id"),
+		Limit:        limit,
+		Offset:       offset,
+	}, nil
+}
+
+// Task reads the filters of task listings and exports from the query string.
+// Tasks are sorted by due date unless sorted by one of their custom fields.
+func Task(q url.Values, fields []db.CustomField) (db.TaskFilter, error) {
+	limit, offset, err := Page(q)
+	if err != nil {
+		return db.TaskFilter{}, err
+	}
+
+	customFilters, customSort, err := parseCustomFieldQuery(q, fields)
+	if err != nil {
+		return db.TaskFilter{}, err
+	}
+	if sort := q.Get("sort"); sort != "" && customSort == nil {
+		return db.TaskFilter{}, fmt.Errorf("invalid sort %q", sort)
+	}
+
+	return db.TaskFilter{
+		Status:       q.Get("status"),
+		AssignedTo:   q.Get("assigned_to"),
+		DueFrom:      q.Get("due_from"),
+		DueTo:        q.Get("due_to"),
+		CustomFields: customFilters,
+		CustomSort:   customSort,
+		TeamID:       q.Get("team_id"),
+		Limit:        limit,
+		Offset:       offset,
+	}, nil
+}
+
+// Report reads the filters of reports from the query string. from and to
+// are dates or times, from inclusive and to exclusive. interval and group_by
+// default to the given values, group_by must be one of groups and "none"
+// turns either off.
+func Report(q url.Values, groups map[string]string, interval, groupBy string) (db.ReportFilter, error) {
+	for _, param := range []string{"from", "to"} {
+		if v := q.Get(param); v != "" && !validTime(v) {
+			return db.ReportFilter{}, fmt.Errorf("invalid %s %q", param, v)
+		}
+	}
+
+	if v := q.Get("interval"); v != "" {
+		interval = v
+	}
+	if _, ok := db.ReportIntervals[interval]; interval != "none" && !ok {
+		return db.ReportFilter{}, fmt.Errorf("invalid interval %q", interval)
+	}
+	if v := q.Get("group_by"); v != "" {
+		groupBy = v
+	}
+	if _, ok := groups[groupBy]; groupBy != "none" && !ok {
+		return db.ReportFilter{}, fmt.Errorf("invalid group_by %q", groupBy)
+	}
+
+	return db.ReportFilter{
+		From:     q.Get("from"),
+		To:       q.Get("to"),
+		Interval: interval,
+		GroupBy:  groupBy,
+		TeamID:   q.Get("team_id"),
+	}, nil
+}
+
+// validTime reports whether s is a date or a time as stored in the
+// database.
+func validTime(s string) bool {
+	for _, layout := range []string{time.DateTime, time.DateOnly} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// customFieldParam prefixes the query parameters and sort keys naming custom
+// fields, as in cf.budget.min=1000&sort=-cf.budget.
+const customFieldParam = "cf."
+
+// parseCustomFieldQuery reads custom field filters and sort from the query
+// string. Number and date fields can be bounded with the .min and .max
+// suffixes.
+func parseCustomFieldQuery(
+	q url.Values,
+	fields []db.CustomField,
+) ([]db.CustomFieldFilter, *db.CustomFieldSort, error) {
+	field := func(key string) (db.CustomField, bool) {
+		i := slices.IndexFunc(fields, func(f db.CustomField) bool { return f.Key == key })
+		if i < 0 {
+			return db.CustomField{}, false
+		}
+		return fields[i], true
+	}
+
+	params := make([]string, 0, len(q))
+	for param := range q {
+		if strings.HasPrefix(param, customFieldParam) {
+			params = append(params, param)
+		}
+	}
+	slices.Sort(params)
+
+	filters := []db.CustomFieldFilter{}
+	for _, param := range params {
+		key, op := strings.TrimPrefix(param, customFieldParam), db.CustomFieldEq
+		f, ok := field(key)
+		if !ok {
+			if base, suffix, found := strings.Cut(key, "."); found {
+				key, op = base, suffix
+				f, ok = field(key)
+			}
+		}
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown custom field %q", key)
+		}
+		if op != db.CustomFieldEq && op != db.CustomFieldMin && op != db.CustomFieldMax {
+			return nil, nil, fmt.Errorf("invalid custom field filter %q", param)
+		}
+		if op != db.CustomFieldEq && f.Type != customfields.TypeNumber && f.Type != customfields.TypeDate {
+			return nil, nil, fmt.Errorf("custom field %q cannot be filtered by range", key)
+		}
+
+		options, err := customfields.Options(f.Options)
+		if err != nil {
+			return nil, nil, fmt.Errorf("custom field %s: %w", f.ID, err)
+		}
+		value, err := customfields.Parse(f.Type, options, q.Get(param))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", param, err)
+		}
+
+		filters = append(filters, db.CustomFieldFilter{
+			FieldID: f.ID,
+			Numeric: customfields.Numeric(f.Type),
+			Op:      op,
+			Value:   value,
+		})
+	}
+
+	var sort *db.CustomFieldSort
+	if key, desc := q.Get("sort"), false; strings.HasPrefix(strings.TrimPrefix(key, "-"), customFieldParam) {
+		if strings.HasPrefix(key, "-") {
+			key, desc = key[1:], true
+		}
+		key = strings.TrimPrefix(key, customFieldParam)
+		f, ok := field(key)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown custom field %q", key)
+		}
+		sort = &db.CustomFieldSort{FieldID: f.ID, Numeric: customfields.Numeric(f.Type), Desc: desc}
+	}
+
+	return filters, sort, nil
+}
+
+// Page reads the limit and offset parameters.
+func Page(q url.Values) (limit, offset int, err error) {
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	return limit, offset, nil
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+)
+
+// CreateAssignmentRule stores a rule that routes matching new leads to its
+// members in turn.
+func CreateAssignmentRule(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createAssignmentRuleRequest, assignmentRuleResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createAssignmentRuleRequest) (*httpResponse[assignmentRuleResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		params := ops.CreateAssignmentRuleParams{
+			Name:     req.Name,
+			Priority: req.Priority,
+			Field:    req.Field,
+			Value:    req.Value,
+			Strategy: req.Strategy,
+		}
+		for _, m := range req.Members {
+			params.Members = append(params.Members, ops.AssignmentRuleMember{UserID: m.UserID, Weight: m.Weight})
+		}
+
+		rule, members, err := ops.CreateAssignmentRule(r.Context(), dbc, querier, params)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[assignmentRuleResponse]{
+			Data:       mapAssignmentRuleToResponse(rule, members),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+func ListAssignmentRules(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]assignmentRuleResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]assignmentRuleResponse], *httpError) {
+		rules, err := querier.ListAssignmentRules(r.Context(), dbc)
+		if err != nil {
+			slog.Error(err.Error())
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := []assignmentRuleResponse{}
+		for _, rule := range rules {
+			members, err := querier.ListAssignmentRuleMembers(r.Context(), dbc, rule.ID)
+			if err != nil {
+				slog.Error(err.Error())
+				return nil, &httpError{
+					Message:    err.Error(),
+					StatusCode: http.StatusInternalServerError,
+				}
+			}
+			resp = append(resp, mapAssignmentRuleToResponse(rule, members))
+		}
+
+		return &httpResponse[[]assignmentRuleResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleAssignmentCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[assignmentCommandRequest, map[string]string] {
+	return func(w http.ResponseWriter, r *http.Request, req assignmentCommandRequest) (*httpResponse[map[string]string], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		if err := querier.DeleteAssignmentRule(r.Context(), dbc, req.RuleID); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]string]{
+			Data:       map[string]string{"id": req.RuleID},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/storage"
+)
+
+// maxAttachmentUploadSize bounds upload requests as a whole. The store
+// enforces its own, lower, limit on the file itself.
+const maxAttachmentUploadSize = 64 << 20
+
+// Attachments are only available to the user making the request, named by
+// the X-User-ID header, when they can see the record they are attached to.
+
+// CreateAttachment accepts a multipart upload with a "file" part, an
+// "object_type" of lead, contact or task and the "record_id" to attach it to.
+func CreateAttachment(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	store storage.Store,
+) getHandlerFunc[attachmentResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[attachmentResponse], *httpError) {
+		objectType := r.FormValue("object_type")
+		recordID := r.FormValue("record_id")
+		if objectType == "" || recordID == "" {
+			return nil, &httpError{
+				Message:    "Missing object_type or record_id",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, &httpError{
+				Message:    "Missing file",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		defer file.Close()
+
+		userID := r.Header.Get(userIDHeader)
+		attachment, err := ops.AttachFile(r.Context(), dbc, querier, store, ops.AttachFileParams{
+			ObjectType: objectType,
+			RecordID:   recordID,
+			Filename:   header.Filename,
+			UploadedBy: userID,
+			ViewerID:   userID,
+		}, file)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[attachmentResponse]{
+			Data:       mapAttachmentToResponse(attachment),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+// ListAttachments lists the files attached to the lead, contact or task with
+// the id in the URL.
+func ListAttachments(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	objectType string,
+) getHandlerFunc[[]attachmentResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]attachmentResponse], *httpError) {
+		attachments, err := ops.ListAttachments(
+			r.Context(),
+			dbc,
+			querier,
+			objectType,
+			chi.URLParam(r, "id"),
+			r.Header.Get(userIDHeader),
+		)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := make([]attachmentResponse, 0, len(attachments))
+		for _, attachment := range attachments {
+			resp = append(resp, mapAttachmentToResponse(attachment))
+		}
+
+		return &httpResponse[[]attachmentResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// DownloadAttachment streams an attached file. It is always served as a
+// download, with the content type sniffed on upload, so that uploaded pages
+// cannot run in the CRM's origin.
+func DownloadAttachment(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	store storage.Store,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, err := ops.GetAttachment(r.Context(), dbc, querier, chi.URLParam(r, "id"), r.Header.Get(userIDHeader))
+		if err != nil {
+			httpErr := commandError(err)
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		content, err := store.Open(r.Context(), attachment.BlobKey)
+		if errors.Is(err, storage.ErrNotFound) {
+			slog.Error("Attachment content is missing", "attachment", attachment.ID, "blob", attachment.BlobKey)
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": attachment.Filename,
+		}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if _, err := io.Copy(w, content); err != nil {
+			slog.Error("Failed to stream attachment", "attachment", attachment.ID, "error", err)
+		}
+	}
+}
+
+func HandleAttachmentCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	store storage.Store,
+) handlerFunc[attachmentCommandRequest, attachmentResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req attachmentCommandRequest) (*httpResponse[attachmentResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		// delete is the only command
+		attachment, err := ops.DeleteAttachment(r.Context(), dbc, querier, store, req.AttachmentID, r.Header.Get(userIDHeader))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[attachmentResponse]{
+			Data:       mapAttachmentToResponse(attachment),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
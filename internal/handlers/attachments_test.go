@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/storage"
+)
+
+const testPDF = "%PDF-1.4 signed contract"
+
+// newAttachmentRequest returns a multipart upload of content as filename to
+// the record given by objectType and recordID, made by userID if set.
+func newAttachmentRequest(t *testing.T, userID, objectType, recordID, filename, content string) *http.Request {
+	a := require.New(t)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	a.NoError(mw.WriteField("object_type", objectType))
+	a.NoError(mw.WriteField("record_id", recordID))
+	part, err := mw.CreateFormFile("file", filename)
+	a.NoError(err)
+	_, err = part.Write([]byte(content))
+	a.NoError(err)
+	a.NoError(mw.Close())
+
+	req := httptest.NewRequest("POST", "/api/v1/attachment/create", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if userID != "" {
+		req.Header.Set(userIDHeader, userID)
+	}
+	return req
+}
+
+func TestAttachments(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	for _, id := range []string{"u1", "u2"} {
+		_, err := dbc.Exec(
+			"INSERT INTO users (id, first_name, last_name, email) VALUES (?, 'Test', 'User', ?)",
+			id, id+"@example.com",
+		)
+		a.NoError(err)
+	}
+
+	upload := func(userID, objectType, recordID, filename, content string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newAttachmentRequest(t, userID, objectType, recordID, filename, content))
+		return w
+	}
+	post := func(userID, url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		if userID != "" {
+			req.Header.Set(userIDHeader, userID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(userID, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		if userID != "" {
+			req.Header.Set(userIDHeader, userID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("", "/api/v1/lead/create", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "phone": "555-010-0100", "assigned_to": "u2"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var lead entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &lead))
+
+	w = post("", "/api/v1/task/create", `{"name": "Send proposal"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var task taskResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &task))
+
+	// Test
+	w = upload("u2", "lead", lead.ID, "contract.pdf", testPDF)
+	a.Equal(http.StatusCreated, w.Code)
+	var attachment attachmentResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &attachment))
+	a.Equal(lead.ID, attachment.EntityID)
+	a.Equal("contract.pdf", attachment.Filename)
+	// The type comes from the content, not from the name
+	a.Equal("application/pdf", attachment.ContentType)
+	a.Equal(int64(len(testPDF)), attachment.Size)
+	a.Equal("u2", attachment.UploadedBy)
+
+	w = upload("", "task", task.ID, "notes.txt", "Call back on Monday")
+	a.Equal(http.StatusCreated, w.Code)
+	var taskAttachment attachmentResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &taskAttachment))
+	a.Equal(task.ID, taskAttachment.TaskID)
+	a.Equal("text/plain; charset=utf-8", taskAttachment.ContentType)
+
+	w = upload("", "deal", lead.ID, "contract.pdf", testPDF)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = upload("", "contact", lead.ID, "contract.pdf", testPDF)
+	a.Equal(http.StatusNotFound, w.Code)
+	w = upload("", "task", "missing", "contract.pdf", testPDF)
+	a.Equal(http.StatusNotFound, w.Code)
+
+	w = get("", "/api/v1/query/lead/"+lead.ID+"/attachments")
+	a.Equal(http.StatusOK, w.Code)
+	var attachments []attachmentResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &attachments))
+	a.Len(attachments, 1)
+	a.Equal(attachment.ID, attachments[0].ID)
+
+	w = get("", "/api/v1/query/task/"+task.ID+"/attachments")
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &attachments))
+	a.Len(attachments, 1)
+	a.Equal(taskAttachment.ID, attachments[0].ID)
+
+	w = get("", "/api/v1/query/lead/"+lead.ID+"/history")
+	a.Equal(http.StatusOK, w.Code)
+	var history []entityHistoryResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &history))
+	a.Len(history, 1)
+	a.Equal("attachments", history[0].Action)
+	a.Equal("contract.pdf", history[0].Details["attached"])
+
+	w = get("u2", "/api/v1/attachment/"+attachment.ID+"/download")
+	a.Equal(http.StatusOK, w.Code)
+	a.Equal(testPDF, w.Body.String())
+	a.Equal("application/pdf", w.Header().Get("Content-Type"))
+	a.Equal(`attachment; filename=contract.pdf`, w.Header().Get("Content-Disposition"))
+	a.Equal("nosniff", w.Header().Get("X-Content-Type-Options"))
+
+	// Only users who can see the record can get at its files
+	w = get("u1", "/api/v1/attachment/"+attachment.ID+"/download")
+	a.Equal(http.StatusNotFound, w.Code)
+	w = get("u1", "/api/v1/query/lead/"+lead.ID+"/attachments")
+	a.Equal(http.StatusNotFound, w.Code)
+	w = upload("u1", "lead", lead.ID, "contract.pdf", testPDF)
+	a.Equal(http.StatusNotFound, w.Code)
+	w = post("u1", "/api/v1/attachment/command", `{"command": "delete", "attachment_id": "`+attachment.ID+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+
+	w = post("u2", "/api/v1/attachment/command", `{"command": "delete", "attachment_id": "`+attachment.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = get("u2", "/api/v1/attachment/"+attachment.ID+"/download")
+	a.Equal(http.StatusNotFound, w.Code)
+	w = get("", "/api/v1/query/lead/"+lead.ID+"/attachments")
+	a.Equal(http.StatusOK, w.Code)
+	a.JSONEq(`[]`, w.Body.String())
+}
+
+func TestAttachments_RemoveUnreferencedContent(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, _, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	querier := &db.Queries{}
+	store, err := storage.NewLocalStore(t.TempDir(), storage.DefaultMaxSize)
+	a.NoError(err)
+
+	r := chi.NewRouter()
+	r.Use(WorkspaceMiddleware(dbc, querier, db.DefaultWorkspaceID))
+	r.Post("/api/v1/attachment/create", MultipartFormMiddleware(
+		CreateAttachment(dbc, querier, store),
+		maxAttachmentUploadSize,
+	))
+	r.Post("/api/v1/attachment/command", JSONDecoderMiddleware(
+		HandleAttachmentCommand(dbc, querier, store),
+	))
+
+	_, err = dbc.Exec(`
+	INSERT INTO tasks (id, name, description, due_date, status) VALUES
+	('t1', 'Send proposal', '', '2026-02-01', 'open'),
+	('t2', 'Send contract', '', '2026-02-01', 'open');
+	`)
+	a.NoError(err)
+
+	hash := sha256.Sum256([]byte(testPDF))
+	key := hex.EncodeToString(hash[:])
+
+	// Test
+	ids := []string{}
+	for _, taskID := range []string{"t1", "t2"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newAttachmentRequest(t, "", "task", taskID, "contract.pdf", testPDF))
+		a.Equal(http.StatusCreated, w.Code)
+
+		var attachment attachmentResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &attachment))
+		ids = append(ids, attachment.ID)
+	}
+
+	for i, id := range ids {
+		req := httptest.NewRequest("POST", "/api/v1/attachment/command", strings.NewReader(`{"command": "delete", "attachment_id": "`+id+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		a.Equal(http.StatusOK, w.Code)
+
+		// The content stays until the last attachment sharing it is deleted
+		_, err := store.Open(t.Context(), key)
+		if i < len(ids)-1 {
+			a.NoError(err)
+		} else {
+			a.True(errors.Is(err, storage.ErrNotFound))
+		}
+	}
+}
+
+func TestAttachments_SlowUploadDoesNotBlockOthers(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, _, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	querier := &db.Queries{}
+	store, err := storage.NewLocalStore(t.TempDir(), storage.DefaultMaxSize)
+	a.NoError(err)
+
+	_, err = dbc.Exec(`
+	INSERT INTO tasks (id, name, description, due_date, status) VALUES
+	('t1', 'Send proposal', '', '2026-02-01', 'open');
+	`)
+	a.NoError(err)
+	ctx := db.WithWorkspace(t.Context(), db.DefaultWorkspaceID)
+	params := ops.AttachFileParams{ObjectType: ops.ObjectTypeTask, RecordID: "t1", Filename: "contract.pdf"}
+
+	// An upload whose content is still arriving
+	slow, upload := io.Pipe()
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := ops.AttachFile(ctx, dbc, querier, store, params, slow)
+		slowDone <- err
+	}()
+	_, err = upload.Write([]byte("%PDF-1.4 "))
+	a.NoError(err)
+
+	// Test
+	done := make(chan error, 1)
+	go func() {
+		_, err := ops.AttachFile(ctx, dbc, querier, store, params, strings.NewReader(testPDF))
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		a.NoError(err)
+	case <-time.After(time.Second):
+		a.Fail("attaching waited for another upload to finish")
+	}
+
+	_, err = upload.Write([]byte("proposal"))
+	a.NoError(err)
+	a.NoError(upload.Close())
+	a.NoError(<-slowDone)
+
+	attachments, err := querier.ListTaskAttachments(ctx, dbc, "t1")
+	a.NoError(err)
+	a.Len(attachments, 2)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/customfields"
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+)
+
+func CreateCustomField(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createCustomFieldRequest, customFieldResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createCustomFieldRequest) (*httpResponse[customFieldResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		field, err := ops.CreateCustomField(r.Context(), dbc, querier, ops.CreateCustomFieldParams(req))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[customFieldResponse]{
+			Data:       mapCustomFieldToResponse(field),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+// ListCustomFields lists the custom fields defined on the object type given
+// by the object_type parameter, or on every object type.
+func ListCustomFields(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]customFieldResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]customFieldResponse], *httpError) {
+		fields, err := querier.ListCustomFields(r.Context(), dbc, r.URL.Query().Get("object_type"))
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]customFieldResponse, 0, len(fields))
+		for _, field := range fields {
+			resp = append(resp, mapCustomFieldToResponse(field))
+		}
+
+		return &httpResponse[[]customFieldResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleCustomFieldCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[customFieldCommandRequest, map[string]string] {
+	return func(w http.ResponseWriter, r *http.Request, req customFieldCommandRequest) (*httpResponse[map[string]string], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		// delete is the only command
+		if err := querier.DeleteCustomField(r.Context(), dbc, req.FieldID); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]string]{
+			Data:       map[string]string{"id": req.FieldID},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// customFieldValues loads the custom field values of the given records,
+// grouped by record.
+func customFieldValues(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	recordIDs []string,
+) (map[string][]db.CustomFieldValue, error) {
+	values, err := querier.ListCustomFieldValues(ctx, dbc, recordIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byRecord := map[string][]db.CustomFieldValue{}
+	for _, value := range values {
+		byRecord[value.RecordID] = append(byRecord[value.RecordID], value)
+	}
+	return byRecord, nil
+}
+
+// attachEntityCustomFields fills in the custom field values of entities. A
+// converted lead keeps the values of its lead fields; where a lead and a
+// contact field share a key the one matching the entity's status is shown.
+func attachEntityCustomFields(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	entities []entityResponse,
+) error {
+	ids := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		ids = append(ids, entity.ID)
+	}
+
+	byRecord, err := customFieldValues(ctx, dbc, querier, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range entities {
+		objectType := ops.ObjectTypeLead
+		if entities[i].Status == ops.EntityStatusConverted {
+			objectType = ops.ObjectTypeContact
+		}
+
+		shown := map[string]string{}
+		for _, value := range byRecord[entities[i].ID] {
+			if previous, ok := shown[value.Key]; ok && previous == objectType {
+				continue
+			}
+			if entities[i].CustomFields == nil {
+				entities[i].CustomFields = map[string]any{}
+			}
+			entities[i].CustomFields[value.Key] = customfields.Decode(value.Type, value.Value)
+			shown[value.Key] = value.ObjectType
+		}
+	}
+
+	return nil
+}
+
+// attachTaskCustomFields fills in the custom field values of tasks.
+// Projected occurrences show the values of the task they were projected
+// from.
+func attachTaskCustomFields(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	tasks []taskResponse,
+) error {
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+
+	byRecord, err := customFieldValues(ctx, dbc, querier, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		for _, value := range byRecord[tasks[i].ID] {
+			if tasks[i].CustomFields == nil {
+				tasks[i].CustomFields = map[string]any{}
+			}
+			tasks[i].CustomFields[value.Key] = customfields.Decode(value.Type, value.Value)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/dedupe"
+	"simplecrm/internal/filters"
+)
+
+const defaultDuplicateScore = 0.5
+
+// ListDuplicates lists pairs of likely duplicate entities. The kind query
+// parameter restricts the search to leads or contacts and min_score sets the
+// lowest score returned.
+func ListDuplicates(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]duplicateResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]duplicateResponse], *httpError) {
+		q := r.URL.Query()
+
+		minScore := defaultDuplicateScore
+		if v := q.Get("min_score"); v != "" {
+			var err error
+			if minScore, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, &httpError{
+					Message:    "Invalid min_score",
+					StatusCode: http.StatusBadRequest,
+				}
+			}
+		}
+
+		limit, offset, err := filters.Page(q)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		filter := db.EntityFilter{Kind: q.Get("kind")}
+		candidates, err := dedupe.FindDuplicates(r.Context(), dbc, querier, filter, minScore)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		candidates = candidates[min(offset, len(candidates)):]
+		candidates = candidates[:min(pageSize(limit), len(candidates))]
+
+		resp := make([]duplicateResponse, 0, len(candidates))
+		for _, c := range candidates {
+			resp = append(resp, duplicateResponse{
+				Score:   c.Score,
+				Reasons: c.Reasons,
+				A:       mapEntityToResponse(c.A),
+				B:       mapEntityToResponse(c.B),
+			})
+		}
+
+		return &httpResponse[[]duplicateResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func GetEntityHistory(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]entityHistoryResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]entityHistoryResponse], *httpError) {
+		history, err := querier.ListEntityHistory(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]entityHistoryResponse, 0, len(history))
+		for _, h := range history {
+			details := map[string]any{}
+			if err := json.Unmarshal([]byte(h.Details), &details); err != nil {
+				slog.Error(err.Error())
+			}
+
+			resp = append(resp, entityHistoryResponse{
+				ID:        h.ID,
+				Action:    h.Action,
+				Details:   details,
+				CreatedAt: h.CreatedAt,
+			})
+		}
+
+		return &httpResponse[[]entityHistoryResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+	"simplecrm/internal/mail"
+)
+
+// ListOutboxEmails returns queued and delivered email, newest first,
+// optionally narrowed to a status.
+func ListOutboxEmails(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]outboxEmailResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]outboxEmailResponse], *httpError) {
+		q := r.URL.Query()
+		limit, offset, err := filters.Page(q)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		emails, err := querier.ListOutboxEmails(r.Context(), dbc, db.OutboxEmailFilter{
+			Status: q.Get("status"),
+			Limit:  pageSize(limit),
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]outboxEmailResponse, 0, len(emails))
+		for _, email := range emails {
+			resp = append(resp, mapOutboxEmailToResponse(email))
+		}
+
+		return &httpResponse[[]outboxEmailResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleEmailCommand(outbox *mail.Outbox) handlerFunc[emailCommandRequest, outboxEmailResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req emailCommandRequest) (*httpResponse[outboxEmailResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		email, err := outbox.Retry(r.Context(), req.EmailID)
+		if errors.Is(err, mail.ErrNotRetryable) {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[outboxEmailResponse]{
+			Data:       mapOutboxEmailToResponse(email),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
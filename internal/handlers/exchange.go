@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+)
+
+// CreateExchangeRates loads a batch of exchange rates, replacing those of
+// the same currency pairs and dates. Either every rate is loaded or none is.
+func CreateExchangeRates(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createExchangeRatesRequest, []exchangeRateResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createExchangeRatesRequest) (*httpResponse[[]exchangeRateResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		params := make([]ops.ExchangeRateParams, 0, len(req.Rates))
+		for _, rate := range req.Rates {
+			params = append(params, ops.ExchangeRateParams(rate))
+		}
+		rates, err := ops.SetExchangeRates(r.Context(), dbc, querier, params)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := make([]exchangeRateResponse, 0, len(rates))
+		for _, rate := range rates {
+			resp = append(resp, mapExchangeRateToResponse(rate))
+		}
+
+		return &httpResponse[[]exchangeRateResponse]{
+			Data:       resp,
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+// ListExchangeRates lists the exchange rates by currency pair, earliest
+// first, optionally those from or to currency and in effect by the date
+// on_or_before.
+func ListExchangeRates(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]exchangeRateResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]exchangeRateResponse], *httpError) {
+		rates, err := querier.ListExchangeRates(r.Context(), dbc, db.ExchangeRateFilter{
+			Currency:   r.URL.Query().Get("currency"),
+			OnOrBefore: r.URL.Query().Get("on_or_before"),
+		})
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := make([]exchangeRateResponse, 0, len(rates))
+		for _, rate := range rates {
+			resp = append(resp, mapExchangeRateToResponse(rate))
+		}
+
+		return &httpResponse[[]exchangeRateResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleExchangeRateCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[exchangeRateCommandRequest, map[string]any] {
+	return func(w http.ResponseWriter, r *http.Request, req exchangeRateCommandRequest) (*httpResponse[map[string]any], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		// delete is the only command
+		if err := querier.DeleteExchangeRate(r.Context(), dbc, req.From, req.To, req.EffectiveOn); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]any]{
+			Data:       map[string]any{"from": req.From, "to": req.To, "effective_on": req.EffectiveOn},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
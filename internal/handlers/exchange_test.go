@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExchangeRates(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	_, err := dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email) VALUES
+	('u1', 'Test', 'User', 'u1@example.com');
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, assigned_to, created_at, converted_at) VALUES
+	('e1', 'Jane', 'Doe', 'jane@example.com', '', 'new', 'u1', '2025-12-01 09:00:00', '');
+	INSERT INTO quotes (id, number, entity_id, title, currency, status, tax_rate, decided_at) VALUES
+	('q1', 1, 'e1', 'Q', 'EUR', 'accepted', 0, '2026-01-15 10:00:00'),
+	('q2', 2, 'e1', 'Q', 'EUR', 'accepted', 0, '2026-02-10 10:00:00'),
+	('q3', 3, 'e1', 'Q', 'GBP', 'declined', 0, '2026-01-20 10:00:00'),
+	('q4', 4, 'e1', 'Q', 'CHF', 'accepted', 0, '2026-01-25 10:00:00'),
+	('q5', 5, 'e1', 'Q', 'USD', 'accepted', 1000, '2026-01-05 10:00:00'),
+	('q6', 6, 'e1', 'Q', 'EUR', 'accepted', 0, '2025-12-20 10:00:00'),
+	('q7', 7, 'e1', 'Q', 'EUR', 'draft', 0, '');
+	INSERT INTO quote_lines (id, quote_id, position, description, quantity, unit_price, discount) VALUES
+	('l1', 'q1', 1, 'Item', 2, 5000, 0),
+	('l2', 'q2', 1, 'Item', 1, 10000, 0),
+	('l3', 'q3', 1, 'Item', 1, 8000, 0),
+	('l4', 'q4', 1, 'Item', 1, 9000, 0),
+	('l5', 'q5', 1, 'Item', 1, 500, 0),
+	('l6', 'q6', 1, 'Item', 1, 10000, 0),
+	('l7', 'q7', 1, 'Item', 1, 10000, 0);
+	`)
+	a.NoError(err)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string, resp any) int {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, "u1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code == http.StatusOK {
+			a.NoError(json.Unmarshal(w.Body.Bytes(), resp))
+		}
+		return w.Code
+	}
+
+	// Test
+	w := post("/api/v1/exchange-rate/create", `{"rates": [
+		{"from": "EUR", "to": "USD", "rate": "1.10", "effective_on": "2026-01-01"},
+		{"from": "EUR", "to": "USD", "rate": "1.15", "effective_on": "2026-02-01"},
+		{"from": "USD", "to": "GBP", "rate": "0.8", "effective_on": "2026-01-01"}
+	]}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var rates []exchangeRateResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &rates))
+	a.Len(rates, 3)
+
+	for _, pl := range []string{
+		`{"rates": []}`,
+		`{"rates": [{"from": "EUR", "to": "USD", "rate": "-1", "effective_on": "2026-01-01"}]}`,
+		`{"rates": [{"from": "EUR", "to": "USD", "rate": "1,1", "effective_on": "2026-01-01"}]}`,
+		`{"rates": [{"from": "EUR", "to": "EUR", "rate": "1", "effective_on": "2026-01-01"}]}`,
+		`{"rates": [{"from": "eur", "to": "USD", "rate": "1.1", "effective_on": "2026-01-01"}]}`,
+		`{"rates": [{"from": "EUR", "to": "USD", "rate": "1.1", "effective_on": "01/01/2026"}]}`,
+		// Nothing is loaded when any rate is invalid
+		`{"rates": [
+			{"from": "EUR", "to": "USD", "rate": "9", "effective_on": "2026-01-01"},
+			{"from": "EUR", "to": "USD", "rate": "0", "effective_on": "2026-03-01"}
+		]}`,
+	} {
+		w = post("/api/v1/exchange-rate/create", pl)
+		a.Equal(http.StatusBadRequest, w.Code, pl)
+	}
+
+	// Loading a rate again replaces it
+	w = post("/api/v1/exchange-rate/create", `{"rates": [{"from": "EUR", "to": "USD", "rate": "1.20", "effective_on": "2026-02-01"}]}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+
+	a.Equal(http.StatusOK, get("/api/v1/query/exchange-rates?currency=EUR", &rates))
+	a.Len(rates, 2)
+	a.Equal("1.10", rates[0].Rate)
+	a.Equal("1.20", rates[1].Rate)
+	a.Equal(http.StatusOK, get("/api/v1/query/exchange-rates?on_or_before=2026-01-31", &rates))
+	a.Len(rates, 2)
+
+	// Each quote is converted at the rate in effect on the day it was
+	// decided; those in currencies without a rate by then are counted
+	// apart. Declined quotes are summed apart from the accepted ones and
+	// drafts are left out.
+	var report []quoteReportResponse
+	a.Equal(http.StatusOK, get("/api/v1/query/report/quotes?from=2025-12-01", &report))
+	a.Equal([]quoteReportResponse{
+		{Period: "2025-12", Group: "accepted", Currency: "USD", Count: 1, Value: 0, Unconverted: 1},
+		// 11000 for q1 and 550 for q5 with its tax
+		{Period: "2026-01", Group: "accepted", Currency: "USD", Count: 3, Value: 11550, Unconverted: 1},
+		{Period: "2026-01", Group: "declined", Currency: "USD", Count: 1, DeclinedValue: 10000},
+		{Period: "2026-02", Group: "accepted", Currency: "USD", Count: 1, Value: 12000},
+	}, report)
+
+	// Value stays what was won when both are in a row
+	a.Equal(http.StatusOK, get("/api/v1/query/report/quotes?from=2026-01-01&to=2026-02-01&group_by=none&interval=none", &report))
+	a.Equal([]quoteReportResponse{
+		{Period: "", Group: "", Currency: "USD", Count: 4, Value: 11550, DeclinedValue: 10000, Unconverted: 1},
+	}, report)
+
+	a.Equal(http.StatusOK, get("/api/v1/query/report/quotes?from=2026-02-01&group_by=currency", &report))
+	a.Equal([]quoteReportResponse{
+		{Period: "2026-02", Group: "EUR", Currency: "USD", Count: 1, Value: 12000},
+	}, report)
+
+	w = post("/api/v1/exchange-rate/command", `{"command": "delete", "from": "EUR", "to": "USD", "effective_on": "2026-02-01"}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	w = post("/api/v1/exchange-rate/command", `{"command": "delete", "from": "EUR", "to": "USD", "effective_on": "2026-02-01"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+
+	// The earlier rate applies again
+	a.Equal(http.StatusOK, get("/api/v1/query/report/quotes?from=2026-02-01&group_by=none&interval=none", &report))
+	a.Equal([]quoteReportResponse{
+		{Period: "", Group: "", Currency: "USD", Count: 1, Value: 11000},
+	}, report)
+
+	// Rates into the old base currency are used inverted for the new one
+	_, err = dbc.Exec(`UPDATE workspaces SET base_currency = 'EUR' WHERE id = 'default'`)
+	a.NoError(err)
+	a.Equal(http.StatusOK, get("/api/v1/query/report/quotes?group_by=none&interval=none", &report))
+	a.Equal([]quoteReportResponse{
+		// 10000 each for q1, q2 and q6, and 550 USD is 500 EUR
+		{Period: "", Group: "", Currency: "EUR", Count: 6, Value: 30500, Unconverted: 2},
+	}, report)
+
+	a.Equal(http.StatusBadRequest, get("/api/v1/query/report/quotes?group_by=source", &report))
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/exports"
+	"simplecrm/internal/filters"
+	"simplecrm/internal/ops"
+)
+
+// Export streams leads, contacts or tasks matching the list filters in the
+// requested format (csv, ndjson, for contacts vcard or for tasks ics), only
+// those the user making the request can see.
+func Export(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		resource := chi.URLParam(r, "resource")
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exports.FormatCSV
+		}
+
+		contentType, ok := exports.ContentTypes[format]
+		if !ok {
+			http.Error(w, "Unsupported format", http.StatusBadRequest)
+			return
+		}
+
+		var export func() error
+		switch resource {
+		case "leads", "contacts":
+			kind := ops.ObjectTypeLead
+			if resource == "contacts" {
+				kind = ops.ObjectTypeContact
+			}
+
+			fields, err := querier.ListCustomFields(r.Context(), dbc, kind)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			filter, err := filters.Entity(r.URL.Query(), kind, fields)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.VisibleTo = viewerID
+			if format == exports.FormatVCard && kind != ops.ObjectTypeContact {
+				http.Error(w, "vCard export is only available for contacts", http.StatusBadRequest)
+				return
+			}
+			if format == exports.FormatICal {
+				http.Error(w, "iCalendar export is only available for tasks", http.StatusBadRequest)
+				return
+			}
+
+			export = func() error {
+				return exports.Entities(r.Context(), w, dbc, querier, format, filter)
+			}
+		case "tasks":
+			fields, err := querier.ListCustomFields(r.Context(), dbc, ops.ObjectTypeTask)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			filter, err := filters.Task(r.URL.Query(), fields)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.VisibleTo = viewerID
+			if format == exports.FormatVCard {
+				http.Error(w, "vCard export is only available for contacts", http.StatusBadRequest)
+				return
+			}
+
+			export = func() error {
+				return exports.Tasks(r.Context(), w, dbc, querier, format, filter)
+			}
+		default:
+			http.Error(w, "Unknown resource", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf(`attachment; filename="%s.%s"`, resource, exports.Extensions[format]),
+		)
+
+		// Headers are already sent, so a failure can only be logged
+		if err := export(); err != nil {
+			slog.Error("Export failed", "resource", resource, "error", err)
+		}
+	}
+}
+
+// CalendarFeed serves the tasks assigned to a user as an iCalendar feed that
+// calendar apps can subscribe to. The feed is authorized by the user's
+// calendar token rather than a session, since calendar apps cannot log in.
+// Tasks are events unless component=vtodo is given.
+func CalendarFeed(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := querier.GetUser(r.Context(), dbc, chi.URLParam(r, "id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Unknown users, bad tokens and disabled feeds look the same
+		token := r.URL.Query().Get("token")
+		if !user.Active || user.CalendarToken == "" ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(user.CalendarToken)) != 1 {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		component := r.URL.Query().Get("component")
+		if component != "" && component != exports.ComponentEvent && component != exports.ComponentTodo {
+			http.Error(w, "Unsupported component", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", exports.ContentTypes[exports.FormatICal])
+		w.Header().Set("Cache-Control", "no-store")
+
+		err = exports.Calendar(r.Context(), w, dbc, querier, db.TaskFilter{AssignedTo: user.ID}, exports.CalendarOptions{
+			Name:      "Tasks of " + strings.TrimSpace(user.FirstName+" "+user.LastName),
+			Component: component,
+			Now:       time.Now(),
+		})
+		if err != nil {
+			slog.Error("Calendar feed failed", "user", user.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+	"simplecrm/internal/ratelimit"
+)
+
+const maxFormSubmissionSize = 64 << 10
+
+func CreateLeadForm(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createLeadFormRequest, leadFormResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createLeadFormRequest) (*httpResponse[leadFormResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		form, err := ops.CreateLeadForm(r.Context(), dbc, querier, ops.CreateLeadFormParams(req))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[leadFormResponse]{
+			Data:       mapLeadFormToResponse(form),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+func ListLeadForms(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]leadFormResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]leadFormResponse], *httpError) {
+		forms, err := querier.ListLeadForms(r.Context(), dbc)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]leadFormResponse, 0, len(forms))
+		for _, form := range forms {
+			resp = append(resp, mapLeadFormToResponse(form))
+		}
+
+		return &httpResponse[[]leadFormResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleLeadFormCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[leadFormCommandRequest, map[string]string] {
+	return func(w http.ResponseWriter, r *http.Request, req leadFormCommandRequest) (*httpResponse[map[string]string], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		resp := map[string]string{"id": req.FormID}
+		var err error
+		switch req.Command {
+		case "enable", "disable":
+			_, err = querier.SetLeadFormEnabled(r.Context(), dbc, req.FormID, req.Command == "enable")
+		case "rotate_key":
+			var form db.LeadForm
+			form, err = ops.RotateLeadFormKey(r.Context(), dbc, querier, req.FormID)
+			resp["key"] = form.Key
+		case "delete":
+			err = querier.DeleteLeadForm(r.Context(), dbc, req.FormID)
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]string]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// SubmitLeadForm is the public endpoint web forms post to, as
+// application/x-www-form-urlencoded from a browser or as a JSON object of
+// strings from a script. Submissions are limited per client and form by
+// limiter, telling clients apart by the address proxies, if trusted, report
+// for them. Browsers are redirected to the form's redirect URL, if it has one;
+// everyone else gets 201 Created. Submissions caught by the honeypot get the
+// same response so bots cannot tell they were dropped.
+func SubmitLeadForm(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+	limiter *ratelimit.Limiter,
+	proxies TrustedProxies,
+	phoneRegion string,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+
+		form, err := querier.GetLeadFormByKey(r.Context(), dbc, chi.URLParam(r, "key"))
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !form.Enabled) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if ok, retryAfter := limiter.Allow(form.ID + " " + proxies.ClientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Too many submissions", http.StatusTooManyRequests)
+			return
+		}
+
+		values, browser, err := formValues(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Pages often pass campaign parameters on to the form's action URL
+		for _, field := range ops.UTMFields {
+			if values[field] == "" {
+				values[field] = r.URL.Query().Get(field)
+			}
+		}
+
+		_, err = ops.SubmitLeadForm(r.Context(), dbc, querier, form, values, phoneRegion, eventService)
+		if errors.Is(err, ops.ErrSpam) {
+			slog.Info("Dropped form submission caught by honeypot", "form", form.ID)
+		} else if err != nil {
+			httpErr := commandError(err)
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		if browser && form.RedirectURL != "" {
+			http.Redirect(w, r, form.RedirectURL, http.StatusSeeOther)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"status": "received"})
+	}
+}
+
+// FormPreflight answers CORS preflight requests for SubmitLeadForm, which
+// scripts on other origins send before posting JSON.
+func FormPreflight() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+idempotencyKeyHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// formValues reads a submission's fields, reporting whether it was posted by
+// a browser form.
+func formValues(w http.ResponseWriter, r *http.Request) (map[string]string, bool, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSubmissionSize)
+	values := map[string]string{}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, false, errors.New("Invalid form body")
+		}
+		for field := range r.PostForm {
+			values[field] = r.PostForm.Get(field)
+		}
+		return values, true, nil
+	case "application/json":
+		var raw map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			return nil, false, errors.New("Invalid JSON")
+		}
+		for field, value := range raw {
+			switch value.(type) {
+			case string, float64, bool:
+				values[field] = fmt.Sprint(value)
+			case nil:
+			default:
+				return nil, false, fmt.Errorf("%s must be a string", field)
+			}
+		}
+		return values, false, nil
+	default:
+		return nil, false, errors.New("Invalid Content-Type")
+	}
+}
+
+// TrustedProxies are the addresses of the reverse proxies in front of the
+// server, whose X-Forwarded-For and X-Real-IP headers are believed.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies reads a comma separated list of addresses and CIDR
+// ranges, such as 10.0.0.0/8,::1.
+func ParseTrustedProxies(list string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", item)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) trusted(address string) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client making r. Behind trusted
+// proxies it is the last address of X-Forwarded-For that is not one of
+// them, or else X-Real-IP; headers sent by anyone else are ignored, as
+// clients could make them up.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if !p.trusted(client) {
+		return client
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			if !p.trusted(hop) {
+				return hop
+			}
+			client = hop
+		}
+		return client
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	return client
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"log/slog"
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 
 	"simplecrm/internal/db"
+	"simplecrm/internal/normalize"
 	"simplecrm/internal/ops"
 	"simplecrm/internal/pubsub"
 )
@@ -24,13 +28,21 @@ func CreateUser(
 			}
 		}
 
+		email, err := normalize.Email(req.Email)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
 		user, err := ops.CreateUser(
 			r.Context(),
 			dbc,
 			querier,
 			req.FirstName,
 			req.LastName,
-			req.Email,
+			email,
 			userCreatedEventService,
 		)
 		if err != nil {
@@ -48,9 +60,49 @@ func CreateUser(
 	}
 }
 
-func HandleUserCommand() func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Notified"))
+func HandleUserCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[userCommandRequest, getUserResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req userCommandRequest) (*httpResponse[getUserResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		var (
+			user db.User
+			err  error
+		)
+		switch req.Command {
+		case "activate", "deactivate":
+			user, err = ops.SetUserActive(r.Context(), dbc, querier, req.UserID, req.Command == "activate", eventService)
+		case "rotate_calendar_token", "revoke_calendar_token":
+			user, err = ops.RotateCalendarToken(r.Context(), dbc, querier, req.UserID, req.Command == "revoke_calendar_token")
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := getUserResponse{
+			ID:        user.ID,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Email:     user.Email,
+			Active:    user.Active,
+			CreatedAt: user.CreatedAt,
+		}
+		if req.Command == "rotate_calendar_token" {
+			resp.CalendarToken = user.CalendarToken
+		}
+
+		return &httpResponse[getUserResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
 	}
 }
 
@@ -87,6 +139,7 @@ func GetUser(
 				FirstName: user.FirstName,
 				LastName:  user.LastName,
 				Email:     user.Email,
+				Active:    user.Active,
 				CreatedAt: user.CreatedAt,
 			},
 			StatusCode: 200,
@@ -96,9 +149,33 @@ func GetUser(
 
 // Task handlers
 
-func CreateTask() func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Created"))
+func CreateTask(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[createTaskRequest, taskResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createTaskRequest) (*httpResponse[taskResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		task, err := ops.CreateTask(r.Context(), dbc, querier, ops.CreateTaskParams(req), eventService)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := []taskResponse{mapTaskToResponse(task)}
+		if err := attachTaskCustomFields(r.Context(), dbc, querier, resp); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[taskResponse]{
+			Data:       resp[0],
+			StatusCode: http.StatusCreated,
+		}, nil
 	}
 }
 
@@ -108,15 +185,89 @@ func UpdateTask() func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleTaskCommand() func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Started"))
+func HandleTaskCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[taskCommandRequest, taskCommandResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req taskCommandRequest) (*httpResponse[taskCommandResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		var (
+			task db.Task
+			next *db.Task
+			err  error
+		)
+		switch req.Command {
+		case "complete":
+			task, next, err = ops.CompleteTask(r.Context(), dbc, querier, req.TaskID, eventService)
+		case "set_custom_fields":
+			task, err = ops.SetTaskCustomFields(r.Context(), dbc, querier, req.TaskID, req.CustomFields)
+		case "assign_team":
+			task, err = ops.AssignTaskTeam(r.Context(), dbc, querier, req.TaskID, req.TeamID, eventService)
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		tasks := []taskResponse{mapTaskToResponse(task)}
+		if next != nil {
+			tasks = append(tasks, mapTaskToResponse(*next))
+		}
+		if err := attachTaskCustomFields(r.Context(), dbc, querier, tasks); err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := taskCommandResponse{Task: tasks[0]}
+		if next != nil {
+			resp.Next = &tasks[1]
+		}
+
+		return &httpResponse[taskCommandResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
 	}
 }
 
-func GetTask() func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Task"))
+// GetTask returns a task. Tasks the user making the request cannot see
+// through their teams are not found.
+func GetTask(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[taskResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[taskResponse], *httpError) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		task, err := querier.GetTask(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, commandError(err)
+		}
+		ok, err := ops.CanSeeRecord(r.Context(), dbc, querier, viewerID, task.AssignedTo, task.TeamID)
+		if err != nil {
+			return nil, commandError(err)
+		}
+		if !ok {
+			return nil, commandError(sql.ErrNoRows)
+		}
+
+		resp := []taskResponse{mapTaskToResponse(task)}
+		if err := attachTaskCustomFields(r.Context(), dbc, querier, resp); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[taskResponse]{
+			Data:       resp[0],
+			StatusCode: http.StatusOK,
+		}, nil
 	}
 }
 
@@ -128,9 +279,59 @@ func GetLead() func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func CreateLead() func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Created"))
+func CreateLead(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+	phoneRegion string,
+) handlerFunc[createLeadRequest, entityResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createLeadRequest) (*httpResponse[entityResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		params, err := ops.CreateLeadParams(req).Normalize(phoneRegion)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		lead, err := ops.CreateLead(
+			r.Context(),
+			dbc,
+			querier,
+			params,
+			eventService,
+		)
+		var fieldError *ops.FieldError
+		if errors.As(err, &fieldError) {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := []entityResponse{mapEntityToResponse(lead)}
+		if err := attachEntityDetails(r.Context(), dbc, querier, resp); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[entityResponse]{
+			Data:       resp[0],
+			StatusCode: http.StatusCreated,
+		}, nil
 	}
 }
 
@@ -140,10 +341,12 @@ func UpdateLead() func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleLeadCommand() func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Assigned"))
-	}
+func HandleLeadCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[entityCommandRequest, entityResponse] {
+	return handleEntityCommand(dbc, querier, eventService)
 }
 
 // Contact handlers
@@ -160,9 +363,84 @@ func UpdateContact() func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func HandleContactCommand() func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Contacted"))
+func HandleContactCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[entityCommandRequest, entityResponse] {
+	return handleEntityCommand(dbc, querier, eventService)
+}
+
+// handleEntityCommand runs commands shared by leads and contacts.
+func handleEntityCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[entityCommandRequest, entityResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req entityCommandRequest) (*httpResponse[entityResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		var (
+			entity db.Entity
+			err    error
+		)
+		switch req.Command {
+		case "merge":
+			entity, err = ops.MergeEntities(r.Context(), dbc, querier, ops.MergeEntitiesParams{
+				SurvivorID:  req.SurvivorID,
+				DuplicateID: req.DuplicateID,
+				Fields:      req.Fields,
+			}, eventService)
+		case "assign":
+			entity, err = ops.AssignEntity(r.Context(), dbc, querier, req.EntityID, req.UserID, eventService)
+		case "assign_team":
+			entity, err = ops.AssignEntityTeam(r.Context(), dbc, querier, req.EntityID, req.TeamID, eventService)
+		case "change_status":
+			entity, err = ops.ChangeEntityStatus(r.Context(), dbc, querier, req.EntityID, req.Status, eventService)
+		case "set_custom_fields":
+			entity, err = ops.SetEntityCustomFields(r.Context(), dbc, querier, req.EntityID, req.CustomFields)
+		case "add_tags", "remove_tags":
+			entity, err = ops.TagEntity(r.Context(), dbc, querier, req.EntityID, req.Tags, req.Command == "remove_tags")
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := []entityResponse{mapEntityToResponse(entity)}
+		if err := attachEntityDetails(r.Context(), dbc, querier, resp); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[entityResponse]{
+			Data:       resp[0],
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func commandError(err error) *httpError {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return &httpError{
+			Message:    "Not found",
+			StatusCode: http.StatusNotFound,
+		}
+	case errors.Is(err, ops.ErrInvalidCommand):
+		return &httpError{
+			Message:    err.Error(),
+			StatusCode: http.StatusBadRequest,
+		}
+	default:
+		slog.Error(err.Error())
+		return &httpError{
+			Message:    err.Error(),
+			StatusCode: http.StatusInternalServerError,
+		}
 	}
 }
 
@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
@@ -16,30 +22,69 @@ import (
 
 	"simplecrm/database"
 	"simplecrm/internal/db"
+	"simplecrm/internal/imports"
+	"simplecrm/internal/mail"
+	"simplecrm/internal/notifications"
+	"simplecrm/internal/pubsub"
 	"simplecrm/internal/pubsub/mocks"
+	"simplecrm/internal/ratelimit"
+	"simplecrm/internal/reports"
+	"simplecrm/internal/scoring"
+	"simplecrm/internal/storage"
 )
 
+// setupTest mounts the routes with requests that name no user acting in the
+// default workspace, as a single-tenant deployment opts into.
 func setupTest(t *testing.T) (*sqlx.DB, *chi.Mux, mocks.MockUserCreatedEventServicer, func()) {
+	return setupTestWithDefaultWorkspace(t, db.DefaultWorkspaceID)
+}
+
+func setupTestWithDefaultWorkspace(t *testing.T, defaultWorkspace string) (*sqlx.DB, *chi.Mux, mocks.MockUserCreatedEventServicer, func()) {
 	a := require.New(t)
 	dbc, err := sqlx.Connect("sqlite3", ":memory:")
 	a.NoError(err)
+	// Every connection to :memory: opens a fresh database
+	dbc.SetMaxOpenConns(1)
 
-	_, err = dbc.Exec(string(database.Migrations))
+	err = database.Migrate(context.Background(), dbc)
 	a.NoError(err)
 
 	querier := &db.Queries{}
 	r := chi.NewRouter()
 	controller := gomock.NewController(t)
 	eventService := mocks.NewMockUserCreatedEventServicer(controller)
-	MountRoutes(r, dbc, querier, eventService)
+	events := pubsub.NewEventService()
+	importer := imports.NewImporter(dbc, querier, events, "US")
+	scorer := scoring.NewScorer(dbc, querier, events)
+	notifier := notifications.NewNotifier(dbc, querier, events)
+	outbox := mail.NewOutbox(dbc, querier, mail.LogSender{}, "crm@example.com")
+	reportScheduler := reports.NewScheduler(dbc, querier, outbox, time.Now)
+	formLimiter := ratelimit.New(3, time.Minute)
+	attachments, err := storage.NewLocalStore(t.TempDir(), storage.DefaultMaxSize)
+	a.NoError(err)
+	MountRoutes(r, dbc, querier, eventService, events, importer, scorer, notifier, reportScheduler, outbox, formLimiter, TrustedProxies{netip.MustParsePrefix("10.0.0.1/32")}, time.Hour, "US", defaultWorkspace, attachments)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	notifier.Start(ctx)
 
 	cleanup := func() {
+		cancel()
 		dbc.Close()
 	}
 
 	return dbc, r, *eventService, cleanup
 }
 
+// addViewer adds the user tests read records as, who sees those owned by
+// nobody.
+func addViewer(t *testing.T, dbc *sqlx.DB) string {
+	_, err := dbc.Exec(
+		"INSERT INTO users (id, first_name, last_name, email) VALUES ('viewer', 'Test', 'Viewer', 'viewer@example.com')",
+	)
+	require.NoError(t, err)
+	return "viewer"
+}
+
 func TestCreateUser(t *testing.T) {
 	// Setup
 	a := require.New(t)
@@ -226,3 +271,1387 @@ func TestGetUser(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateLead(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	// Test
+	url := "/api/v1/lead/create"
+	pl := `{"first_name": "Jane", "last_name": "Doe", "email": " Jane@Example.com", "phone": "(555) 010-0100"}`
+	req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusCreated, w.Code)
+
+	var lead entityResponse
+	err := json.Unmarshal(w.Body.Bytes(), &lead)
+	a.NoError(err)
+	a.Equal("new", lead.Status)
+	a.Equal("jane@example.com", lead.Email)
+	a.Equal("+15550100100", lead.Phone)
+
+	var count int
+	err = dbc.Get(&count, "SELECT COUNT(*) FROM entities WHERE id = ?", lead.ID)
+	a.NoError(err)
+	a.Equal(1, count)
+}
+
+func TestCreateLead_FailValidation(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	// Test
+	url := "/api/v1/lead/create"
+
+	tcs := []struct {
+		name string
+		pl   string
+	}{
+		{
+			name: "Invalid email",
+			pl:   `{"first_name": "Jane", "last_name": "Doe", "email": "jane", "phone": "555-010-0100"}`,
+		},
+		{
+			name: "Unparsable phone",
+			pl:   `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "phone": "555-0100"}`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", url, strings.NewReader(tc.pl))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+			a.Equal(http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestMergeLeads(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	_, err := dbc.Exec(`
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, created_at, converted_at) VALUES
+	('survivor', 'Jane', 'Doe', 'jane@example.com', '', 'new', '2026-01-01 09:00:00', ''),
+	('duplicate', 'Janet', 'Doe', 'jane@example.com', '555-0100', 'qualified', '2026-01-02 09:00:00', '');
+	INSERT INTO tasks (id, name, description, due_date, status, entity_id) VALUES
+	('task1', 'Call', 'Intro call', '2026-02-01', 'open', 'duplicate');
+	`)
+	a.NoError(err)
+
+	// Test
+	url := "/api/v1/lead/command"
+	pl := `{"command": "merge", "survivor_id": "survivor", "duplicate_id": "duplicate", "fields": {"status": "duplicate"}}`
+	req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusOK, w.Code)
+
+	var lead entityResponse
+	err = json.Unmarshal(w.Body.Bytes(), &lead)
+	a.NoError(err)
+	a.Equal("survivor", lead.ID)
+	a.Equal("Jane", lead.FirstName)
+	a.Equal("555-0100", lead.Phone)
+	a.Equal("qualified", lead.Status)
+
+	var entityID string
+	err = dbc.Get(&entityID, "SELECT entity_id FROM tasks WHERE id = 'task1'")
+	a.NoError(err)
+	a.Equal("survivor", entityID)
+
+	var count int
+	err = dbc.Get(&count, "SELECT COUNT(*) FROM entities WHERE id = 'duplicate'")
+	a.NoError(err)
+	a.Equal(0, count)
+
+	req = httptest.NewRequest("GET", "/api/v1/query/lead/survivor/history", nil)
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusOK, w.Code)
+
+	var history []entityHistoryResponse
+	err = json.Unmarshal(w.Body.Bytes(), &history)
+	a.NoError(err)
+	a.Len(history, 1)
+	a.Equal("merge", history[0].Action)
+	a.Equal("duplicate", history[0].Details["merged_id"])
+}
+
+func TestMergeLeads_NotFound(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	// Test
+	url := "/api/v1/lead/command"
+	pl := `{"command": "merge", "survivor_id": "missing", "duplicate_id": "other"}`
+	req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestCreateLead_AssignmentRules(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+	_, err := dbc.Exec(
+		"INSERT INTO users (id, first_name, last_name, email) VALUES ('u1', 'Ann', 'Lee', 'ann@example.com'), ('u2', 'Bob', 'Ray', 'bob@example.com')",
+	)
+	a.NoError(err)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/assignment/create", `{"name": "Web", "field": "source", "value": "web", "strategy": "round_robin", "members": [{"user_id": "u1"}, {"user_id": "u2"}]}`)
+	a.Equal(http.StatusCreated, w.Code)
+
+	// Test
+	assignees := []string{}
+	for _, phone := range []string{"555-010-0100", "555-010-0101"} {
+		w = post("/api/v1/lead/create", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "phone": "`+phone+`", "source": "web"}`)
+		a.Equal(http.StatusCreated, w.Code)
+
+		var lead entityResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &lead))
+		assignees = append(assignees, lead.AssignedTo)
+	}
+	a.ElementsMatch([]string{"u1", "u2"}, assignees)
+
+	// Leads not matching any rule stay unassigned
+	w = post("/api/v1/lead/create", `{"first_name": "Jim", "last_name": "Doe", "email": "jim@example.com", "phone": "555-010-0102", "source": "referral"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var lead entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &lead))
+	a.Empty(lead.AssignedTo)
+
+	// Deactivated users cannot be assigned
+	w = post("/api/v1/user/command", `{"command": "deactivate", "user_id": "u1"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = post("/api/v1/lead/command", `{"command": "assign", "entity_id": "`+lead.ID+`", "user_id": "u1"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/lead/create", `{"first_name": "Joe", "last_name": "Doe", "email": "joe@example.com", "phone": "555-010-0103", "assigned_to": "u1"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = post("/api/v1/lead/command", `{"command": "assign", "entity_id": "`+lead.ID+`", "user_id": "u2"}`)
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &lead))
+	a.Equal("u2", lead.AssignedTo)
+}
+
+func TestEntityScore(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+	viewerID := addViewer(t, dbc)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, viewerID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/scoring/create", `{"name": "Meeting", "kind": "activity", "field": "meeting", "points": 20}`)
+	a.Equal(http.StatusCreated, w.Code)
+	w = post("/api/v1/scoring/create", `{"name": "Bad", "kind": "attribute", "field": "shoe_size", "points": 1}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = post("/api/v1/lead/create", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "phone": "555-010-0100"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var lead entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &lead))
+
+	// Test
+	w = post("/api/v1/activity/create", `{"entity_id": "`+lead.ID+`", "kind": "meeting"}`)
+	a.Equal(http.StatusCreated, w.Code)
+
+	w = get("/api/v1/query/lead/" + lead.ID + "/score")
+	a.Equal(http.StatusOK, w.Code)
+	var score scoreResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &score))
+	a.Equal(20, score.Score)
+	a.Len(score.Items, 1)
+	a.Equal(1, score.Items[0].Matches)
+
+	w = get("/api/v1/query/lead/missing/score")
+	a.Equal(http.StatusNotFound, w.Code)
+
+	_, err := dbc.Exec("UPDATE entities SET score = 20 WHERE id = ?", lead.ID)
+	a.NoError(err)
+	w = post("/api/v1/lead/create", `{"first_name": "John", "last_name": "Roe", "email": "john@example.com", "phone": "555-010-0101"}`)
+	a.Equal(http.StatusCreated, w.Code)
+
+	w = get("/api/v1/query/leads?sort=-score")
+	a.Equal(http.StatusOK, w.Code)
+	var leads []entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &leads))
+	a.Len(leads, 2)
+	a.Equal(lead.ID, leads[0].ID)
+
+	w = get("/api/v1/query/leads?sort=shoe_size")
+	a.Equal(http.StatusBadRequest, w.Code)
+}
+
+func TestRecurringTasks(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+	viewerID := addViewer(t, dbc)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, viewerID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/task/create", `{"name": "Check-in", "recurrence": "FREQ=WEEKLY;BYDAY=MO"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/task/create", `{"name": "Check-in", "due_date": "2026-01-05 09:00:00", "recurrence": "FREQ=HOURLY"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = post("/api/v1/task/create", `{"name": "Check-in", "due_date": "2026-01-05 09:00:00", "recurrence": "freq=weekly;byday=mo;count=3"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var task taskResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &task))
+	a.Equal("FREQ=WEEKLY;BYDAY=MO;COUNT=3", task.Recurrence)
+	a.Equal(task.ID, task.SeriesID)
+	a.Equal(1, task.Occurrence)
+
+	// Test
+	w = get("/api/v1/query/tasks?expand=true&due_from=2026-01-01&due_to=2026-02-01")
+	a.Equal(http.StatusOK, w.Code)
+	var tasks []taskResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tasks))
+	a.Len(tasks, 3)
+	a.False(tasks[0].Projected)
+	a.True(tasks[1].Projected)
+	a.Equal("2026-01-12 09:00:00", tasks[1].DueDate)
+	a.Equal(3, tasks[2].Occurrence)
+
+	// Occurrences are numbered from the start of the series, not the range
+	w = get("/api/v1/query/tasks?expand=true&due_from=2026-01-13&due_to=2026-02-01")
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tasks))
+	a.Len(tasks, 1)
+	a.Equal("2026-01-19 09:00:00", tasks[0].DueDate)
+	a.Equal(3, tasks[0].Occurrence)
+
+	w = get("/api/v1/query/tasks?expand=true&due_from=2026-01-01")
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = post("/api/v1/task/command", `{"command": "complete", "task_id": "`+task.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	var completed taskCommandResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &completed))
+	a.Equal("done", completed.Task.Status)
+	a.NotEmpty(completed.Task.CompletedAt)
+	a.NotNil(completed.Next)
+	a.Empty(completed.Next.CompletedAt)
+	a.Equal("2026-01-12 09:00:00", completed.Next.DueDate)
+	a.Equal(task.ID, completed.Next.SeriesID)
+	a.Equal(2, completed.Next.Occurrence)
+
+	// The numbering projected from the next occurrence matches the original
+	w = get("/api/v1/query/tasks?expand=true&due_from=2026-01-13&due_to=2026-02-01")
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tasks))
+	a.Len(tasks, 1)
+	a.Equal(3, tasks[0].Occurrence)
+
+	w = post("/api/v1/task/command", `{"command": "complete", "task_id": "`+task.ID+`"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = post("/api/v1/task/command", `{"command": "complete", "task_id": "`+completed.Next.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &completed))
+	a.NotNil(completed.Next)
+	a.Equal(3, completed.Next.Occurrence)
+
+	// The third occurrence is the last one
+	w = post("/api/v1/task/command", `{"command": "complete", "task_id": "`+completed.Next.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	var last taskCommandResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &last))
+	a.Nil(last.Next)
+
+	w = get("/api/v1/query/task/" + last.Task.ID)
+	a.Equal(http.StatusOK, w.Code)
+	w = get("/api/v1/query/task/missing")
+	a.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestCalendarFeed(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, eventService, cleanup := setupTest(t)
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil)
+	defer cleanup()
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/user/create", `{"first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var user createUserResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &user))
+
+	w = post("/api/v1/task/create", `{"name": "Weekly check-in", "due_date": "2026-01-05 09:00:00", "assigned_to": "`+user.ID+`", "recurrence": "FREQ=WEEKLY"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	w = post("/api/v1/task/create", `{"name": "Someone else's", "due_date": "2026-01-05"}`)
+	a.Equal(http.StatusCreated, w.Code)
+
+	// Test
+	w = get("/api/v1/calendar/" + user.ID + ".ics")
+	a.Equal(http.StatusNotFound, w.Code)
+
+	w = post("/api/v1/user/command", `{"command": "rotate_calendar_token", "user_id": "`+user.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	var rotated getUserResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &rotated))
+	a.Len(rotated.CalendarToken, 64)
+
+	w = get("/api/v1/calendar/" + user.ID + ".ics?token=wrong")
+	a.Equal(http.StatusNotFound, w.Code)
+
+	w = get("/api/v1/calendar/" + user.ID + ".ics?token=" + rotated.CalendarToken)
+	a.Equal(http.StatusOK, w.Code)
+	a.Equal("text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	a.Contains(w.Body.String(), "SUMMARY:Weekly check-in\r\n")
+	a.Contains(w.Body.String(), "RRULE:FREQ=WEEKLY\r\n")
+	a.NotContains(w.Body.String(), "Someone else")
+
+	w = get("/api/v1/calendar/" + user.ID + ".ics?component=vtodo&token=" + rotated.CalendarToken)
+	a.Equal(http.StatusOK, w.Code)
+	a.Contains(w.Body.String(), "BEGIN:VTODO\r\n")
+
+	w = post("/api/v1/user/command", `{"command": "revoke_calendar_token", "user_id": "`+user.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = get("/api/v1/calendar/" + user.ID + ".ics?token=" + rotated.CalendarToken)
+	a.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestNotifications(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, eventService, cleanup := setupTest(t)
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	defer cleanup()
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	command := func(userID, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/notification/command", strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	list := func(userID, url string) []notificationResponse {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		a.Equal(http.StatusOK, w.Code)
+		var resp []notificationResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	w := post("/api/v1/user/create", `{"first_name": "Ann", "last_name": "Lee", "email": "ann@example.com"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var ann createUserResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &ann))
+	w = post("/api/v1/user/create", `{"first_name": "Bob", "last_name": "Ray", "email": "bob@example.com"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var bob createUserResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &bob))
+
+	w = post("/api/v1/lead/create", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "phone": "555-010-0100"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var lead entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &lead))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/v1/stream/notifications", nil)
+	a.NoError(err)
+	req.Header.Set(userIDHeader, ann.ID)
+	stream, err := server.Client().Do(req)
+	a.NoError(err)
+	defer stream.Body.Close()
+	a.Equal("text/event-stream", stream.Header.Get("Content-Type"))
+	lines := bufio.NewScanner(stream.Body)
+	a.True(lines.Scan())
+	a.Equal("event: unread", lines.Text())
+	a.True(lines.Scan())
+	a.Equal(`data: {"count":0}`, lines.Text())
+
+	// Test
+	w = post("/api/v1/lead/command", `{"command": "assign", "entity_id": "`+lead.ID+`", "user_id": "`+ann.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+
+	for lines.Scan() && !strings.HasPrefix(lines.Text(), "data: ") {
+	}
+	var live notificationResponse
+	a.NoError(json.Unmarshal([]byte(strings.TrimPrefix(lines.Text(), "data: ")), &live))
+	a.Equal("Lead assigned to you: Jane Doe", live.Title)
+
+	w = post("/api/v1/activity/create", `{"entity_id": "`+lead.ID+`", "kind": "note", "user_id": "`+bob.ID+`", "notes": "@ann she asked for a call, cc @bob"}`)
+	a.Equal(http.StatusCreated, w.Code)
+
+	a.Eventually(func() bool {
+		return len(list(ann.ID, "/api/v1/query/notifications")) == 2
+	}, time.Second, 10*time.Millisecond)
+	notes := list(ann.ID, "/api/v1/query/notifications")
+	a.Equal("Bob Ray mentioned you", notes[0].Title)
+	a.False(notes[0].Read)
+	// Authors are not notified of their own mentions
+	a.Empty(list(bob.ID, "/api/v1/query/notifications"))
+
+	w = command(ann.ID, `{"command": "mark_read", "notification_id": "`+live.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	var resp notificationCommandResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	a.Equal(notificationCommandResponse{Updated: 1, Unread: 1}, resp)
+	a.Len(list(ann.ID, "/api/v1/query/notifications?unread=true"), 1)
+
+	w = command(bob.ID, `{"command": "mark_read", "notification_id": "`+live.ID+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+
+	// Users cannot read or change each other's notifications
+	w = command(bob.ID, `{"command": "mark_all_read", "user_id": "`+ann.ID+`"}`)
+	a.Equal(http.StatusForbidden, w.Code)
+	req = httptest.NewRequest("GET", "/api/v1/query/notifications?user_id="+ann.ID, nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(userIDHeader, bob.ID)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusForbidden, w.Code)
+	req = httptest.NewRequest("GET", "/api/v1/stream/notifications?user_id="+ann.ID, nil)
+	req.Header.Set(userIDHeader, bob.ID)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusForbidden, w.Code)
+	w = command("", `{"command": "mark_all_read", "user_id": "`+ann.ID+`"}`)
+	a.Equal(http.StatusUnauthorized, w.Code)
+
+	w = command(ann.ID, `{"command": "mark_all_read"}`)
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	a.Equal(notificationCommandResponse{Updated: 1, Unread: 0}, resp)
+	a.Empty(list(ann.ID, "/api/v1/query/notifications?unread=true"))
+}
+
+func TestIngestEmails(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	_, err := dbc.Exec(`INSERT INTO users (id, first_name, last_name, email) VALUES ('u1', 'Ann', 'Lee', 'ann@example.com')`)
+	a.NoError(err)
+
+	post := func(url, contentType, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/api/v1/lead/create", "application/json", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "phone": "555-010-0100"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var lead entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &lead))
+
+	mbox := strings.Join([]string{
+		"From ann@example.com Mon Mar  4 10:30:00 2024",
+		"From: Ann Lee <ann@example.com>",
+		"To: Jane Doe <jane+crm@example.com>",
+		"Subject: Proposal",
+		"Date: Mon, 4 Mar 2024 10:30:00 +0000",
+		"Message-ID: <proposal@example.com>",
+		"Content-Type: multipart/mixed; boundary=b",
+		"",
+		"--b",
+		"Content-Type: text/plain",
+		"",
+		"Hi Jane, the proposal is attached.",
+		"--b",
+		"Content-Type: application/pdf",
+		"Content-Disposition: attachment; filename=proposal.pdf",
+		"",
+		"%PDF",
+		"--b--",
+		"",
+		"From nobody Mon Mar  4 11:00:00 2024",
+		"Subject: No sender",
+		"",
+		"Hi",
+		"",
+	}, "\n")
+
+	// Test
+	w = post("/api/v1/email/ingest", "application/mbox", mbox)
+	a.Equal(http.StatusOK, w.Code)
+	var resp ingestEmailsResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	a.Equal(1, resp.Ingested)
+	a.Equal(1, resp.Failed)
+	a.Len(resp.Emails, 2)
+	a.Equal([]string{lead.ID}, resp.Emails[0].EntityIDs)
+	a.NotEmpty(resp.Emails[1].Error)
+
+	w = get("/api/v1/query/lead/" + lead.ID + "/activities")
+	a.Equal(http.StatusOK, w.Code)
+	var activities []activityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &activities))
+	a.Len(activities, 1)
+	a.Equal("email", activities[0].Kind)
+	a.Equal("u1", activities[0].UserID)
+	a.Equal("2024-03-04 10:30:00", activities[0].OccurredAt)
+	a.Equal(resp.Emails[0].ID, activities[0].EmailID)
+	a.Contains(activities[0].Notes, "Proposal")
+
+	w = get("/api/v1/query/inbound-email/" + activities[0].EmailID)
+	a.Equal(http.StatusOK, w.Code)
+	var email inboundEmailResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &email))
+	a.Equal("proposal@example.com", email.MessageID)
+	a.Equal([]string{"jane+crm@example.com"}, email.To)
+	a.Equal("Hi Jane, the proposal is attached.", email.Body)
+	a.Len(email.Attachments, 1)
+	a.Equal("proposal.pdf", email.Attachments[0].Filename)
+
+	// The same message ingested again is recognized by its Message-ID
+	single, _, _ := strings.Cut(strings.SplitN(mbox, "\n", 2)[1], "\nFrom nobody")
+	w = post("/api/v1/email/ingest", "message/rfc822", single)
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	a.Equal(1, resp.Duplicates)
+	a.True(resp.Emails[0].Duplicate)
+	a.Equal(email.ID, resp.Emails[0].ID)
+
+	w = get("/api/v1/query/lead/" + lead.ID + "/activities")
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &activities))
+	a.Len(activities, 1)
+
+	w = post("/api/v1/email/ingest", "application/mbox", "Subject: not an mbox\n")
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/email/ingest", "application/json", "{}")
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = get("/api/v1/query/inbound-email/missing")
+	a.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestLeadForms(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+	viewerID := addViewer(t, dbc)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	submit := func(url, contentType, pl, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", contentType)
+		req.RemoteAddr = ip + ":41000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	leads := func() []entityResponse {
+		req := httptest.NewRequest("GET", "/api/v1/query/leads", nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, viewerID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp []entityResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	w := post("/api/v1/form/create", `{"name": "Bad", "mapping": {"status": "s"}}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/form/create", `{"name": "Bad", "redirect_url": "javascript:alert(1)"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/form/create", `{"name": "Bad", "honeypot": "email"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = post("/api/v1/form/create", `{
+		"name": "Landing page",
+		"mapping": {"first_name": "fname", "email": "your_email"},
+		"honeypot": "website",
+		"redirect_url": "https://example.com/thanks",
+		"source": "landing"
+	}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var form leadFormResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &form))
+	a.Len(form.Key, 32)
+	a.Equal("/api/v1/forms/"+form.Key+"/submit", form.SubmitPath)
+
+	// Test
+	w = submit(form.SubmitPath+"?utm_source=newsletter&utm_campaign=spring", "application/x-www-form-urlencoded",
+		"fname=Jane&last_name=Doe&your_email=Jane%40Example.com&phone=555-010-0100&utm_medium=email&website=", "192.0.2.1")
+	a.Equal(http.StatusSeeOther, w.Code)
+	a.Equal("https://example.com/thanks", w.Header().Get("Location"))
+
+	list := leads()
+	a.Len(list, 1)
+	a.Equal("jane@example.com", list[0].Email)
+	a.Equal("landing", list[0].Source)
+	a.Equal("newsletter", list[0].UTMSource)
+	a.Equal("email", list[0].UTMMedium)
+	a.Equal("spring", list[0].UTMCampaign)
+
+	w = submit(form.SubmitPath, "application/json",
+		`{"fname": "John", "last_name": "Roe", "your_email": "john@example.com", "phone": "555-010-0101", "source": "ads"}`, "192.0.2.1")
+	a.Equal(http.StatusCreated, w.Code)
+	a.Equal("*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = submit(form.SubmitPath, "application/json", `{"fname": "Jim", "last_name": "Poe", "phone": "555-010-0102"}`, "192.0.2.1")
+	a.Equal(http.StatusBadRequest, w.Code)
+	a.Contains(w.Body.String(), "your_email")
+
+	// Retried submissions are answered without creating the lead again
+	for range 2 {
+		req := httptest.NewRequest("POST", form.SubmitPath, strings.NewReader(
+			`{"fname": "Joan", "last_name": "Roe", "your_email": "joan@example.com", "phone": "555-010-0104"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotencyKeyHeader, "submission-1")
+		req.RemoteAddr = "192.0.2.4:41000"
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		a.Equal(http.StatusCreated, w.Code)
+	}
+	a.Equal("true", w.Header().Get("Idempotent-Replayed"))
+
+	// Each client may only submit so often
+	w = submit(form.SubmitPath, "application/json", `{}`, "192.0.2.1")
+	a.Equal(http.StatusTooManyRequests, w.Code)
+	a.NotEmpty(w.Header().Get("Retry-After"))
+
+	// Bots filling in the honeypot are answered as if they had succeeded
+	w = submit(form.SubmitPath, "application/x-www-form-urlencoded",
+		"fname=Spam&last_name=Bot&your_email=bot%40example.com&phone=555-010-0103&website=http%3A%2F%2Fspam", "192.0.2.2")
+	a.Equal(http.StatusSeeOther, w.Code)
+
+	list = leads()
+	a.Len(list, 3)
+	for _, lead := range list {
+		if lead.Email == "john@example.com" {
+			a.Equal("ads", lead.Source)
+		}
+	}
+
+	req := httptest.NewRequest("OPTIONS", form.SubmitPath, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusNoContent, w.Code)
+	a.Equal("Content-Type, Idempotency-Key", w.Header().Get("Access-Control-Allow-Headers"))
+
+	w = post("/api/v1/form/command", `{"command": "disable", "form_id": "`+form.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = submit(form.SubmitPath, "application/json", `{}`, "192.0.2.3")
+	a.Equal(http.StatusNotFound, w.Code)
+
+	w = post("/api/v1/form/command", `{"command": "enable", "form_id": "`+form.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = post("/api/v1/form/command", `{"command": "rotate_key", "form_id": "`+form.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	var rotated map[string]string
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &rotated))
+	a.NotEqual(form.Key, rotated["key"])
+	w = submit(form.SubmitPath, "application/json", `{}`, "192.0.2.3")
+	a.Equal(http.StatusNotFound, w.Code)
+
+	w = post("/api/v1/form/command", `{"command": "delete", "form_id": "`+form.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = post("/api/v1/form/command", `{"command": "delete", "form_id": "`+form.ID+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestCustomFields(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, eventService, cleanup := setupTest(t)
+	defer cleanup()
+	viewerID := addViewer(t, dbc)
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, viewerID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	leads := func(query string) []entityResponse {
+		w := get("/api/v1/query/leads?" + query)
+		a.Equal(http.StatusOK, w.Code, w.Body.String())
+		var resp []entityResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	w := post("/api/v1/user/create", `{"first_name": "Ann", "last_name": "Lee", "email": "ann@example.com"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var user createUserResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &user))
+
+	for _, pl := range []string{
+		`{"object_type": "lead", "key": "budget", "label": "Budget", "type": "number"}`,
+		`{"object_type": "lead", "key": "industry", "type": "enum", "options": ["saas", "retail"]}`,
+		`{"object_type": "lead", "key": "renewal_date", "type": "date"}`,
+		`{"object_type": "lead", "key": "champion", "type": "user"}`,
+		`{"object_type": "task", "key": "billable", "type": "bool"}`,
+	} {
+		w := post("/api/v1/custom-field/create", pl)
+		a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	}
+	w = post("/api/v1/custom-field/create", `{"object_type": "lead", "key": "budget", "type": "text"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/custom-field/create", `{"object_type": "lead", "key": "tier", "type": "enum"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/custom-field/create", `{"object_type": "account", "key": "size", "type": "number"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = get("/api/v1/query/custom-fields?object_type=lead")
+	var fields []customFieldResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &fields))
+	a.Len(fields, 4)
+	a.Equal([]string{"saas", "retail"}, fields[1].Options)
+
+	// Test
+	w = post("/api/v1/lead/create", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com",
+		"phone": "555-010-0100", "custom_fields": {"budget": "lots"}}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	a.Contains(w.Body.String(), "custom_fields.budget")
+	w = post("/api/v1/lead/create", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com",
+		"phone": "555-010-0100", "custom_fields": {"champion": "nobody"}}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	a.Empty(leads(""))
+
+	ids := map[string]string{}
+	for _, lead := range []struct{ name, fields string }{
+		{"Jane", `{"budget": 1500, "industry": "saas", "renewal_date": "2026-09-01", "champion": "` + user.ID + `"}`},
+		{"John", `{"budget": 900.5, "industry": "retail"}`},
+		{"Jim", `{"budget": 25000, "industry": "saas"}`},
+		{"Joe", `{}`},
+	} {
+		w := post("/api/v1/lead/create", `{"first_name": "`+lead.name+`", "last_name": "Doe", "email": "`+
+			strings.ToLower(lead.name)+`@example.com", "phone": "555-010-0100", "custom_fields": `+lead.fields+`}`)
+		a.Equal(http.StatusCreated, w.Code, w.Body.String())
+		var created entityResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &created))
+		ids[lead.name] = created.ID
+	}
+
+	list := leads("cf.industry=saas&sort=-cf.budget")
+	a.Len(list, 2)
+	a.Equal("Jim", list[0].FirstName)
+	a.Equal(25000.0, list[0].CustomFields["budget"])
+	a.Equal("Jane", list[1].FirstName)
+	a.Equal(user.ID, list[1].CustomFields["champion"])
+	a.Equal("2026-09-01", list[1].CustomFields["renewal_date"])
+
+	// Numbers compare as numbers, and records without a value sort last
+	list = leads("cf.budget.min=1000&sort=cf.budget")
+	a.Len(list, 2)
+	a.Equal("Jane", list[0].FirstName)
+	list = leads("sort=cf.budget")
+	a.Len(list, 4)
+	a.Equal([]string{"John", "Jane", "Jim", "Joe"},
+		[]string{list[0].FirstName, list[1].FirstName, list[2].FirstName, list[3].FirstName})
+
+	w = get("/api/v1/query/leads?cf.unknown=1")
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = get("/api/v1/query/leads?cf.industry.min=saas")
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = post("/api/v1/lead/command", `{"command": "set_custom_fields", "entity_id": "`+ids["John"]+`",
+		"custom_fields": {"budget": null, "industry": "saas"}}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	var updated entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &updated))
+	a.Equal(map[string]any{"industry": "saas"}, updated.CustomFields)
+	a.Len(leads("cf.industry=saas"), 3)
+
+	w = post("/api/v1/task/create", `{"name": "Call", "custom_fields": {"billable": "yes"}}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/task/create", `{"name": "Call", "custom_fields": {"billable": true}}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var task taskResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &task))
+	a.Equal(map[string]any{"billable": true}, task.CustomFields)
+
+	w = get("/api/v1/query/tasks?cf.billable=false")
+	var tasks []taskResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tasks))
+	a.Empty(tasks)
+
+	// Deleting a field deletes its values
+	w = post("/api/v1/custom-field/command", `{"command": "delete", "field_id": "`+fields[0].ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	list = leads("cf.industry=saas")
+	a.NotContains(list[0].CustomFields, "budget")
+	w = post("/api/v1/custom-field/command", `{"command": "delete", "field_id": "`+fields[0].ID+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestTagsAndSegments(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+	viewerID := addViewer(t, dbc)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, viewerID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	names := func(w *httptest.ResponseRecorder) []string {
+		a.Equal(http.StatusOK, w.Code, w.Body.String())
+		var resp []entityResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+		names := []string{}
+		for _, entity := range resp {
+			names = append(names, entity.FirstName)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	ids := map[string]string{}
+	for n, name := range []string{"Ann", "Bob", "Cat"} {
+		w := post("/api/v1/lead/create", fmt.Sprintf(`{"first_name": %q, "last_name": "Doe",
+			"email": "%s@example.com", "phone": "555-010-010%d", "status": "qualified"}`, name, strings.ToLower(name), n))
+		a.Equal(http.StatusCreated, w.Code, w.Body.String())
+		var created entityResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &created))
+		ids[name] = created.ID
+	}
+
+	// Test
+	w := post("/api/v1/lead/command", `{"command": "add_tags", "entity_id": "`+ids["Ann"]+`", "tags": ["VIP", "conference-2026"]}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	var tagged entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tagged))
+	a.Equal([]string{"conference-2026", "VIP"}, tagged.Tags)
+
+	// Tags are matched regardless of case
+	w = post("/api/v1/lead/command", `{"command": "add_tags", "entity_id": "`+ids["Bob"]+`", "tags": ["vip"]}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tagged))
+	a.Equal([]string{"VIP"}, tagged.Tags)
+	w = post("/api/v1/lead/command", `{"command": "add_tags", "entity_id": "`+ids["Cat"]+`", "tags": ["conference-2026", " "]}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/lead/command", `{"command": "add_tags", "entity_id": "`+ids["Cat"]+`", "tags": ["conference-2026"]}`)
+	a.Equal(http.StatusOK, w.Code)
+
+	a.Equal([]string{"Ann", "Bob"}, names(get("/api/v1/query/leads?tag=vip")))
+	a.Equal([]string{"Ann"}, names(get("/api/v1/query/leads?tag=VIP&tag=conference-2026")))
+	a.Equal([]string{"Cat"}, names(get("/api/v1/query/leads?not_tag=vip")))
+
+	w = get("/api/v1/query/tags")
+	var tags []tagResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tags))
+	a.Len(tags, 2)
+	a.Equal("conference-2026", tags[0].Name)
+	a.Equal(2, tags[0].Entities)
+
+	w = post("/api/v1/segment/create", `{"name": "Bad", "object_type": "lead", "filter": "cf.unknown=1"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/segment/create", `{"name": "Bad", "object_type": "lead", "filter": "limit=5"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/segment/create", `{"name": "Hot conference leads", "object_type": "lead",
+		"filter": "status=qualified&tag=conference-2026"}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var segment segmentResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &segment))
+	w = post("/api/v1/segment/create", `{"name": "Hot conference leads", "object_type": "lead", "filter": ""}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	a.Equal([]string{"Ann", "Cat"}, names(get("/api/v1/query/segment/"+segment.ID+"/entities")))
+
+	// Segments are evaluated when read
+	w = post("/api/v1/lead/command", `{"command": "remove_tags", "entity_id": "`+ids["Cat"]+`", "tags": ["conference-2026"]}`)
+	a.Equal(http.StatusOK, w.Code)
+	tagged = entityResponse{}
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tagged))
+	a.Empty(tagged.Tags)
+	a.Equal([]string{"Ann"}, names(get("/api/v1/query/segment/"+segment.ID+"/entities")))
+
+	w = get("/api/v1/export/segment/" + segment.ID + "?format=ndjson")
+	a.Equal(http.StatusOK, w.Code)
+	a.Equal(1, strings.Count(w.Body.String(), "\n"))
+	a.Contains(w.Body.String(), "ann@example.com")
+	w = get("/api/v1/export/segment/" + segment.ID + "?format=vcard")
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	// Merging keeps the duplicate's tags
+	w = post("/api/v1/lead/command", `{"command": "merge", "survivor_id": "`+ids["Cat"]+`", "duplicate_id": "`+ids["Ann"]+`"}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tagged))
+	a.Equal([]string{"conference-2026", "VIP"}, tagged.Tags)
+
+	w = post("/api/v1/tag/command", `{"command": "delete", "tag_id": "`+tags[1].ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	a.Empty(names(get("/api/v1/query/leads?tag=vip")))
+
+	w = post("/api/v1/segment/command", `{"command": "delete", "segment_id": "`+segment.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = get("/api/v1/query/segment/" + segment.ID + "/entities")
+	a.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestSavedViews(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, eventService, cleanup := setupTest(t)
+	defer cleanup()
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(userID, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	command := func(userID, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/view/command", strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	users := map[string]string{}
+	for _, name := range []string{"Ann", "Bob"} {
+		w := post("/api/v1/user/create", `{"first_name": "`+name+`", "last_name": "Lee", "email": "`+
+			strings.ToLower(name)+`@example.com"}`)
+		a.Equal(http.StatusCreated, w.Code)
+		var user createUserResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &user))
+		users[name] = user.ID
+	}
+
+	w := post("/api/v1/custom-field/create", `{"object_type": "lead", "key": "budget", "type": "number"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	for n, lead := range []struct{ name, status, budget string }{
+		{"Jane", "qualified", "5000"},
+		{"John", "qualified", "900"},
+		{"Jim", "new", "25000"},
+	} {
+		w := post("/api/v1/lead/create", fmt.Sprintf(`{"first_name": %q, "last_name": "Doe", "email": "%s@example.com",
+			"phone": "555-010-010%d", "status": %q, "custom_fields": {"budget": %s}}`,
+			lead.name, strings.ToLower(lead.name), n, lead.status, lead.budget))
+		a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w = post("/api/v1/view/create", `{"owner_id": "`+users["Ann"]+`", "name": "Bad", "resource": "leads", "columns": ["password"]}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/view/create", `{"owner_id": "`+users["Ann"]+`", "name": "Bad", "resource": "leads", "sort": "cf.size"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/view/create", `{"owner_id": "nobody", "name": "Bad", "resource": "leads"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	w = post("/api/v1/view/create", `{"owner_id": "`+users["Ann"]+`", "name": "Qualified by budget", "resource": "leads",
+		"filter": "status=qualified", "sort": "-cf.budget", "columns": ["first_name", "email", "cf.budget"]}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var private savedViewResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &private))
+	w = post("/api/v1/view/create", `{"owner_id": "`+users["Ann"]+`", "name": "Everyone", "resource": "leads", "shared": true}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var shared savedViewResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &shared))
+
+	// Test
+	w = get(users["Ann"], "/api/v1/query/view/"+private.ID+"?limit=1")
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	var result savedViewResultResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	a.Equal([]string{"first_name", "email", "cf.budget"}, result.Columns)
+	a.Equal([]map[string]any{{"first_name": "Jane", "email": "jane@example.com", "cf.budget": 5000.0}}, result.Rows)
+
+	w = get(users["Ann"], "/api/v1/query/view/"+private.ID+"?limit=1&offset=1")
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	a.Equal("John", result.Rows[0]["first_name"])
+
+	// Views that are not shared are only visible to their owner
+	w = get(users["Bob"], "/api/v1/query/view/"+private.ID)
+	a.Equal(http.StatusNotFound, w.Code)
+	w = get(users["Bob"], "/api/v1/query/view/"+shared.ID)
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	a.Len(result.Rows, 3)
+	a.Contains(result.Rows[0], "phone")
+
+	// Users act as themselves, whoever a user_id names
+	w = get(users["Bob"], "/api/v1/query/view/"+private.ID+"?user_id="+users["Ann"])
+	a.Equal(http.StatusForbidden, w.Code)
+	w = command(users["Bob"], `{"command": "delete", "user_id": "`+users["Ann"]+`", "view_id": "`+private.ID+`"}`)
+	a.Equal(http.StatusForbidden, w.Code)
+	w = get("", "/api/v1/query/views")
+	a.Equal(http.StatusUnauthorized, w.Code)
+
+	w = command(users["Bob"], `{"command": "set_default", "view_id": "`+private.ID+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+	w = command(users["Bob"], `{"command": "set_default", "view_id": "`+shared.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+
+	w = get(users["Bob"], "/api/v1/query/views?resource=leads")
+	var views []savedViewResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &views))
+	a.Len(views, 1)
+	a.True(views[0].Default)
+	w = get(users["Ann"], "/api/v1/query/views")
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &views))
+	a.Len(views, 2)
+	a.False(views[0].Default)
+
+	w = command(users["Bob"], `{"command": "clear_default", "resource": "leads"}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	w = get(users["Bob"], "/api/v1/query/views?resource=leads")
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &views))
+	a.Len(views, 1)
+	a.False(views[0].Default)
+
+	w = command(users["Bob"], `{"command": "delete", "view_id": "`+shared.ID+`"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = command(users["Ann"], `{"command": "delete", "view_id": "`+shared.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = get(users["Bob"], "/api/v1/query/views")
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &views))
+	a.Empty(views)
+}
+
+func TestTeams(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, eventService, cleanup := setupTest(t)
+	defer cleanup()
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(4)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(userID, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		if userID != "" {
+			req.Header.Set(userIDHeader, userID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	users := map[string]string{}
+	for _, name := range []string{"Mia", "Ann", "Bob", "Zed"} {
+		w := post("/api/v1/user/create", `{"first_name": "`+name+`", "last_name": "Lee", "email": "`+
+			strings.ToLower(name)+`@example.com"}`)
+		a.Equal(http.StatusCreated, w.Code)
+		var user createUserResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &user))
+		users[name] = user.ID
+	}
+
+	teams := map[string]string{}
+	for _, team := range []struct{ name, parent string }{{"Sales", ""}, {"East", "Sales"}, {"West", "Sales"}} {
+		w := post("/api/v1/team/create", `{"name": "`+team.name+`", "parent_id": "`+teams[team.parent]+`"}`)
+		a.Equal(http.StatusCreated, w.Code, w.Body.String())
+		var created teamResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &created))
+		teams[team.name] = created.ID
+	}
+	w := post("/api/v1/team/create", `{"name": "Sales"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/team/create", `{"name": "North", "parent_id": "nope"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	for _, member := range []struct{ team, user, role string }{
+		{"Sales", "Mia", "manager"},
+		{"East", "Ann", ""},
+		{"West", "Bob", "member"},
+	} {
+		w := post("/api/v1/team/command", `{"command": "add_member", "team_id": "`+teams[member.team]+
+			`", "user_id": "`+users[member.user]+`", "role": "`+member.role+`"}`)
+		a.Equal(http.StatusOK, w.Code, w.Body.String())
+	}
+	w = post("/api/v1/team/command", `{"command": "add_member", "team_id": "`+teams["East"]+
+		`", "user_id": "`+users["Zed"]+`", "role": "owner"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	leads := map[string]string{}
+	for n, lead := range []struct{ name, owner string }{
+		{"Jane", `"assigned_to": "` + users["Ann"] + `"`},
+		{"John", `"assigned_to": "` + users["Bob"] + `"`},
+		{"Jim", `"team_id": "` + teams["West"] + `"`},
+		{"Joe", `"source": "web"`},
+		{"Jill", `"assigned_to": "` + users["Zed"] + `"`},
+	} {
+		w := post("/api/v1/lead/create", fmt.Sprintf(`{"first_name": %q, "last_name": "Doe", "email": "%s@example.com",
+			"phone": "555-010-010%d", %s}`, lead.name, strings.ToLower(lead.name), n, lead.owner))
+		a.Equal(http.StatusCreated, w.Code, w.Body.String())
+		var created entityResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &created))
+		leads[lead.name] = created.ID
+	}
+	w = post("/api/v1/lead/create", `{"first_name": "Bad", "last_name": "Doe", "email": "bad@example.com",
+		"phone": "555-010-0109", "team_id": "nope"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	visibleTo := func(user string) []string {
+		w := get(users[user], "/api/v1/query/leads")
+		a.Equal(http.StatusOK, w.Code, w.Body.String())
+		var entities []entityResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &entities))
+		names := []string{}
+		for _, entity := range entities {
+			names = append(names, entity.FirstName)
+		}
+		return names
+	}
+
+	// Test
+	a.ElementsMatch([]string{"Jane", "Joe"}, visibleTo("Ann"))
+	a.ElementsMatch([]string{"John", "Jim", "Joe"}, visibleTo("Bob"))
+	// Managers of Sales see the records of its sub-teams and their members
+	a.ElementsMatch([]string{"Jane", "John", "Jim", "Joe"}, visibleTo("Mia"))
+	a.ElementsMatch([]string{"Joe", "Jill"}, visibleTo("Zed"))
+
+	w = post("/api/v1/lead/command", `{"command": "assign_team", "entity_id": "`+leads["Jill"]+`", "team_id": "`+teams["East"]+`"}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	var assigned entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &assigned))
+	a.Equal(teams["East"], assigned.TeamID)
+	a.Equal(users["Zed"], assigned.AssignedTo)
+	a.ElementsMatch([]string{"Jane", "Joe", "Jill"}, visibleTo("Ann"))
+
+	w = get(users["Mia"], "/api/v1/query/leads?team_id="+teams["East"])
+	var entities []entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &entities))
+	a.Len(entities, 1)
+
+	w = post("/api/v1/task/create", `{"name": "Call Jim", "team_id": "`+teams["West"]+`", "entity_id": "`+leads["Jim"]+`"}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var task taskResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &task))
+	a.Equal(teams["West"], task.TeamID)
+	w = get(users["Ann"], "/api/v1/query/task/"+task.ID)
+	a.Equal(http.StatusNotFound, w.Code)
+	w = get(users["Mia"], "/api/v1/query/task/"+task.ID)
+	a.Equal(http.StatusOK, w.Code)
+	w = get("", "/api/v1/query/task/"+task.ID)
+	a.Equal(http.StatusUnauthorized, w.Code)
+	w = get(users["Bob"], "/api/v1/query/tasks")
+	var tasks []taskResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &tasks))
+	a.Len(tasks, 1)
+
+	w = post("/api/v1/task/command", `{"command": "assign_team", "task_id": "`+task.ID+`", "team_id": "`+teams["East"]+`"}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	w = get(users["Ann"], "/api/v1/query/task/"+task.ID)
+	a.Equal(http.StatusOK, w.Code)
+
+	// Views can be shared with a team, and run by its members and managers
+	w = post("/api/v1/view/create", `{"owner_id": "`+users["Ann"]+`", "name": "West", "resource": "leads", "team_id": "`+
+		teams["West"]+`"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("/api/v1/view/create", `{"owner_id": "`+users["Bob"]+`", "name": "West", "resource": "leads", "team_id": "`+
+		teams["West"]+`"}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var view savedViewResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &view))
+	w = get(users["Ann"], "/api/v1/query/view/"+view.ID)
+	a.Equal(http.StatusNotFound, w.Code)
+	w = get(users["Mia"], "/api/v1/query/view/"+view.ID)
+	a.Equal(http.StatusOK, w.Code)
+	var result savedViewResultResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	a.Len(result.Rows, 5)
+	w = get(users["Bob"], "/api/v1/query/view/"+view.ID)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &result))
+	a.Len(result.Rows, 3)
+
+	w = get("", "/api/v1/query/teams")
+	var listed []teamResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &listed))
+	a.Len(listed, 3)
+	a.Equal("East", listed[0].Name)
+	a.Equal(teams["Sales"], listed[0].ParentID)
+	a.Equal([]teamMemberResponse{{UserID: users["Ann"], Role: "member", CreatedAt: listed[0].Members[0].CreatedAt}}, listed[0].Members)
+
+	// Deleting a team leaves its records to their assignees
+	w = post("/api/v1/team/command", `{"command": "delete", "team_id": "`+teams["West"]+`"}`)
+	a.Equal(http.StatusOK, w.Code, w.Body.String())
+	a.ElementsMatch([]string{"Jane", "Jim", "Joe", "Jill"}, visibleTo("Ann"))
+	w = post("/api/v1/team/command", `{"command": "delete", "team_id": "`+teams["West"]+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+
+	w = post("/api/v1/team/command", `{"command": "remove_member", "team_id": "`+teams["Sales"]+`", "user_id": "`+users["Mia"]+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	a.ElementsMatch([]string{"Jim", "Joe"}, visibleTo("Mia"))
+}
+
+func TestLeadForms_ClientsBehindProxy(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	req := httptest.NewRequest("POST", "/api/v1/form/create", strings.NewReader(`{"name": "Landing page"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusCreated, w.Code)
+	var form leadFormResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &form))
+
+	// 10.0.0.1 is the trusted proxy of setupTest
+	submit := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest("POST", form.SubmitPath, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		req.RemoteAddr = remoteAddr + ":41000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Test
+	for range 3 {
+		a.NotEqual(http.StatusTooManyRequests, submit("10.0.0.1", "198.51.100.1"))
+	}
+	a.Equal(http.StatusTooManyRequests, submit("10.0.0.1", "198.51.100.1"))
+
+	// Another visitor behind the same proxy has a limit of their own, and
+	// addresses a client puts in front of it are not believed
+	a.NotEqual(http.StatusTooManyRequests, submit("10.0.0.1", "198.51.100.2"))
+	a.Equal(http.StatusTooManyRequests, submit("10.0.0.1", "203.0.113.9, 198.51.100.1"))
+
+	// Clients that are not the proxy cannot pick their address
+	for range 3 {
+		a.NotEqual(http.StatusTooManyRequests, submit("192.0.2.1", "198.51.100.3"))
+	}
+	a.Equal(http.StatusTooManyRequests, submit("192.0.2.1", "198.51.100.4"))
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotentBodySize bounds keyed requests to the authenticated routes.
+// It is the largest limit of any of them, that of attachment uploads.
+const maxIdempotentBodySize = maxAttachmentUploadSize
+
+// IdempotencyMiddleware makes POST requests carrying an Idempotency-Key header
+// safe to retry. The first request with a key is processed normally and its
+// response stored; repeats with the same body replay that response, repeats
+// with a different body are rejected. Keys expire after ttl.
+//
+// The body is read in full to hash it, before the route gets to apply its
+// own limit, so keyed requests with bodies over maxBytes are refused.
+func IdempotencyMiddleware(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	ttl time.Duration,
+	maxBytes int64,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := sha256.Sum256(body)
+			requestHash := hex.EncodeToString(hash[:])
+			now := time.Now().UTC()
+
+			reserved, err := querier.ReserveIdempotencyKey(r.Context(), dbc, db.ReserveIdempotencyKeyParams{
+				Key:         key,
+				Method:      r.Method,
+				Path:        r.URL.Path,
+				RequestHash: requestHash,
+				CreatedAt:   now.Format(db.TimeFormat),
+				ExpiresAt:   now.Add(ttl).Format(db.TimeFormat),
+			})
+			if err != nil {
+				slog.Error(err.Error())
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if !reserved {
+				replayIdempotentResponse(w, r, dbc, querier, key, requestHash)
+				return
+			}
+
+			release := func() {
+				if err := querier.DeleteIdempotencyKey(r.Context(), dbc, key, r.Method, r.URL.Path); err != nil {
+					slog.Error(err.Error())
+				}
+			}
+
+			// A handler that panics leaves nothing to replay, so the key is
+			// released rather than left reserved until it expires
+			defer func() {
+				if p := recover(); p != nil {
+					release()
+					panic(p)
+				}
+			}()
+
+			rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// Server errors are not stored so the client can retry with the same key
+			if rec.statusCode >= http.StatusInternalServerError {
+				release()
+				return
+			}
+
+			err = querier.CompleteIdempotencyKey(r.Context(), dbc, db.CompleteIdempotencyKeyParams{
+				Key:          key,
+				Method:       r.Method,
+				Path:         r.URL.Path,
+				StatusCode:   rec.statusCode,
+				ContentType:  rec.Header().Get("Content-Type"),
+				ResponseBody: rec.body.Bytes(),
+			})
+			if err != nil {
+				slog.Error(err.Error())
+			}
+		})
+	}
+}
+
+func replayIdempotentResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	key, requestHash string,
+) {
+	stored, err := querier.GetIdempotencyKey(r.Context(), dbc, key, r.Method, r.URL.Path)
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if stored.RequestHash != requestHash {
+		http.Error(w, "Idempotency-Key was already used with a different request body", http.StatusUnprocessableEntity)
+		return
+	}
+
+	if stored.StatusCode == 0 {
+		http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+		return
+	}
+
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.ResponseBody)
+}
+
+// responseRecorder passes writes through to the client while keeping a copy
+// of the status code and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	if !rec.wroteHeader {
+		rec.statusCode = statusCode
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simplecrm/internal/db"
+)
+
+func TestIdempotency_ReplaysOriginalResponse(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, eventService, cleanup := setupTest(t)
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	defer cleanup()
+
+	// Test
+	url := "/api/v1/user/create"
+	pl := `{"first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`
+
+	var bodies []string
+	for range 2 {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+		a.Equal(http.StatusCreated, w.Code)
+		bodies = append(bodies, w.Body.String())
+	}
+
+	a.Equal(bodies[0], bodies[1])
+}
+
+func TestIdempotency_RejectsDifferentBody(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, eventService, cleanup := setupTest(t)
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	defer cleanup()
+
+	// Test
+	url := "/api/v1/user/create"
+	pls := []string{
+		`{"first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`,
+		`{"first_name": "Jane", "last_name": "Doe", "email": "jane.doe@example.com"}`,
+	}
+	codes := []int{http.StatusCreated, http.StatusUnprocessableEntity}
+
+	for i, pl := range pls {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+		a.Equal(codes[i], w.Code)
+	}
+}
+
+func TestIdempotency_DoesNotStoreServerErrors(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	_, err := dbc.Exec(
+		"INSERT INTO users (id, first_name, last_name, email) VALUES ('testid', 'John', 'Doe', 'john.doe@example.com')",
+	)
+	a.NoError(err)
+
+	// Test
+	url := "/api/v1/user/create"
+	pl := `{"first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`
+	req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusInternalServerError, w.Code)
+
+	var count int
+	err = dbc.Get(&count, "SELECT COUNT(*) FROM idempotency_keys")
+	a.NoError(err)
+	a.Equal(0, count)
+}
+
+func TestIdempotency_ReleasesKeyWhenHandlerPanics(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, _, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	panics := true
+	handler := IdempotencyMiddleware(dbc, &db.Queries{}, time.Hour, 1<<10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if panics {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/user/create", strings.NewReader(`{}`))
+		req = req.WithContext(db.WithWorkspace(req.Context(), db.DefaultWorkspaceID))
+		req.Header.Set(idempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test
+	a.PanicsWithValue("boom", func() { serve() })
+
+	var count int
+	err := dbc.Get(&count, "SELECT COUNT(*) FROM idempotency_keys")
+	a.NoError(err)
+	a.Equal(0, count)
+
+	// The retry is processed rather than rejected as still in progress
+	panics = false
+	a.Equal(http.StatusCreated, serve().Code)
+}
+
+func TestIdempotency_ReservesExpiredKeyAgain(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, _, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	// A reservation left behind by a request that never completed
+	_, err := dbc.Exec(
+		"INSERT INTO idempotency_keys (key, method, path, request_hash, created_at, expires_at) VALUES ('key-1', 'POST', '/api/v1/user/create', 'stale', ?, ?)",
+		time.Now().UTC().Add(-2*time.Hour).Format(db.TimeFormat),
+		time.Now().UTC().Add(-time.Hour).Format(db.TimeFormat),
+	)
+	a.NoError(err)
+
+	handler := IdempotencyMiddleware(dbc, &db.Queries{}, time.Hour, 1<<10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	// Test
+	req := httptest.NewRequest("POST", "/api/v1/user/create", strings.NewReader(`{}`))
+	req = req.WithContext(db.WithWorkspace(req.Context(), db.DefaultWorkspaceID))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	a.Equal(http.StatusCreated, w.Code)
+
+	var statusCode int
+	err = dbc.Get(&statusCode, "SELECT status_code FROM idempotency_keys WHERE key = 'key-1'")
+	a.NoError(err)
+	a.Equal(http.StatusCreated, statusCode)
+}
+
+func TestIdempotency_RejectsOversizedBody(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, _, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	called := false
+	handler := IdempotencyMiddleware(dbc, &db.Queries{}, time.Hour, 1<<10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	// Test
+	req := httptest.NewRequest("POST", "/api/v1/attachment/create", strings.NewReader(strings.Repeat("x", 1<<10+1)))
+	req = req.WithContext(db.WithWorkspace(req.Context(), db.DefaultWorkspaceID))
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	a.Equal(http.StatusRequestEntityTooLarge, w.Code)
+	a.False(called)
+
+	var count int
+	err := dbc.Get(&count, "SELECT COUNT(*) FROM idempotency_keys")
+	a.NoError(err)
+	a.Zero(count)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/imports"
+)
+
+const maxImportSize = 32 << 20
+
+// CreateImport accepts a multipart upload with a "file" CSV part, an
+// "object_type" of lead or contact and an optional JSON "mapping" of entity
+// fields to CSV headers, and queues it for background processing.
+func CreateImport(importer *imports.Importer) getHandlerFunc[importJobResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[importJobResponse], *httpError) {
+		mapping, err := imports.ParseMapping(r.FormValue("mapping"))
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, &httpError{
+				Message:    "Missing file",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		defer file.Close()
+
+		source, err := io.ReadAll(file)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		objectType := r.FormValue("object_type")
+		if objectType == "" {
+			return nil, &httpError{
+				Message:    "Missing object_type",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		job, err := importer.Enqueue(r.Context(), objectType, mapping, source)
+		if errors.Is(err, imports.ErrQueueFull) {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusServiceUnavailable,
+			}
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		return &httpResponse[importJobResponse]{
+			Data:       mapImportJobToResponse(job),
+			StatusCode: http.StatusAccepted,
+		}, nil
+	}
+}
+
+func GetImport(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[importJobResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[importJobResponse], *httpError) {
+		job, err := querier.GetImportJob(r.Context(), dbc, chi.URLParam(r, "id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &httpError{
+				Message:    "Import not found",
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		return &httpResponse[importJobResponse]{
+			Data:       mapImportJobToResponse(job),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func GetImportErrors(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]importErrorResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]importErrorResponse], *httpError) {
+		importErrors, err := querier.ListImportErrors(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]importErrorResponse, 0, len(importErrors))
+		for _, importError := range importErrors {
+			row := map[string]string{}
+			if err := json.Unmarshal([]byte(importError.RowData), &row); err != nil {
+				slog.Error(err.Error())
+			}
+
+			resp = append(resp, importErrorResponse{
+				RowNumber: importError.RowNumber,
+				Field:     importError.Field,
+				Message:   importError.Message,
+				Row:       row,
+			})
+		}
+
+		return &httpResponse[[]importErrorResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/inbound"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+)
+
+const (
+	mediaTypeRFC822 = "message/rfc822"
+	mediaTypeMbox   = "application/mbox"
+)
+
+// IngestEmails logs a single raw message (message/rfc822) or every message
+// of an mbox file (application/mbox) on the timelines of the leads and
+// contacts they were exchanged with. A message that cannot be parsed is
+// reported without failing the rest of the upload.
+func IngestEmails(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) func(w http.ResponseWriter, r *http.Request, body []byte) (*httpResponse[ingestEmailsResponse], *httpError) {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) (*httpResponse[ingestEmailsResponse], *httpError) {
+		resp := ingestEmailsResponse{Emails: []ingestedEmailResponse{}}
+
+		ingest := func(raw []byte) error {
+			result, err := ops.IngestEmail(r.Context(), dbc, querier, raw, eventService)
+			if errors.Is(err, ops.ErrInvalidCommand) {
+				resp.Failed++
+				resp.Emails = append(resp.Emails, ingestedEmailResponse{Error: err.Error()})
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			email := ingestedEmailResponse{
+				ID:        result.Email.ID,
+				MessageID: result.Email.MessageID,
+				Subject:   result.Email.Subject,
+				Duplicate: result.Duplicate,
+			}
+			for _, activity := range result.Activities {
+				email.EntityIDs = append(email.EntityIDs, activity.EntityID)
+			}
+			if result.Duplicate {
+				resp.Duplicates++
+			} else {
+				resp.Ingested++
+			}
+			resp.Emails = append(resp.Emails, email)
+			return nil
+		}
+
+		var err error
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == mediaTypeMbox {
+			if !inbound.IsMbox(body) {
+				return nil, &httpError{
+					Message:    "Invalid mbox file",
+					StatusCode: http.StatusBadRequest,
+				}
+			}
+			err = inbound.SplitMbox(bytes.NewReader(body), ingest)
+		} else {
+			err = ingest(body)
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[ingestEmailsResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func GetInboundEmail(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[inboundEmailResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[inboundEmailResponse], *httpError) {
+		email, err := querier.GetInboundEmail(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		attachments, err := querier.ListInboundEmailAttachments(r.Context(), dbc, email.ID)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[inboundEmailResponse]{
+			Data:       mapInboundEmailToResponse(email, attachments),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/recurrence"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	return min(limit, maxPageSize)
+}
+
+func ListEntities(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	kind string,
+) getHandlerFunc[[]entityResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]entityResponse], *httpError) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		fields, err := querier.ListCustomFields(r.Context(), dbc, kind)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		filter, err := filters.Entity(r.URL.Query(), kind, fields)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		filter.VisibleTo = viewerID
+		filter.Limit = pageSize(filter.Limit)
+
+		entities, err := querier.ListEntities(r.Context(), dbc, filter)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]entityResponse, 0, len(entities))
+		for _, entity := range entities {
+			resp = append(resp, mapEntityToResponse(entity))
+		}
+		if err := attachEntityDetails(r.Context(), dbc, querier, resp); err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		return &httpResponse[[]entityResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func ListTasks(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]taskResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]taskResponse], *httpError) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		fields, err := querier.ListCustomFields(r.Context(), dbc, ops.ObjectTypeTask)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		filter, err := filters.Task(r.URL.Query(), fields)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		filter.VisibleTo = viewerID
+		filter.Limit = pageSize(filter.Limit)
+
+		if r.URL.Query().Get("expand") == "true" {
+			return expandTasks(r, dbc, querier, filter)
+		}
+
+		tasks, err := querier.ListTasks(r.Context(), dbc, filter)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]taskResponse, 0, len(tasks))
+		for _, task := range tasks {
+			resp = append(resp, mapTaskToResponse(task))
+		}
+		if err := attachTaskCustomFields(r.Context(), dbc, querier, resp); err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		return &httpResponse[[]taskResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// maxExpandRange bounds the date range recurring tasks are expanded over.
+const maxExpandRange = 366 * 24 * time.Hour
+
+// expandTasks lists the tasks due within [due_from, due_to) together with the
+// upcoming occurrences of recurring tasks in that range.
+func expandTasks(
+	r *http.Request,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	filter db.TaskFilter,
+) (*httpResponse[[]taskResponse], *httpError) {
+	from, _, err := recurrence.ParseTime(filter.DueFrom)
+	if err != nil {
+		return nil, &httpError{
+			Message:    "expand requires due_from and due_to dates",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	to, _, err := recurrence.ParseTime(filter.DueTo)
+	if err != nil {
+		return nil, &httpError{
+			Message:    "expand requires due_from and due_to dates",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	if !to.After(from) || to.Sub(from) > maxExpandRange {
+		return nil, &httpError{
+			Message:    "due_to must be after due_from and at most 366 days later",
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	limit, offset := filter.Limit, filter.Offset
+	occurrences, err := ops.ExpandTasks(r.Context(), dbc, querier, filter, from, to, offset+limit)
+	if err != nil {
+		return nil, &httpError{
+			Message:    err.Error(),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+
+	resp := make([]taskResponse, 0, limit)
+	for n, occurrence := range occurrences {
+		if n >= offset {
+			resp = append(resp, mapTaskOccurrenceToResponse(occurrence))
+		}
+	}
+	if err := attachTaskCustomFields(r.Context(), dbc, querier, resp); err != nil {
+		return nil, &httpError{
+			Message:    err.Error(),
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+
+	return &httpResponse[[]taskResponse]{
+		Data:       resp,
+		StatusCode: http.StatusOK,
+	}, nil
+}
+
+// attachEntityDetails fills in what entity responses carry besides the
+// entities' own columns: custom field values and tags.
+func attachEntityDetails(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	entities []entityResponse,
+) error {
+	if err := attachEntityCustomFields(ctx, dbc, querier, entities); err != nil {
+		return err
+	}
+	return attachEntityTags(ctx, dbc, querier, entities)
+}
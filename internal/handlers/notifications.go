@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+	"simplecrm/internal/notifications"
+)
+
+// streamKeepAlive is how often an idle notification stream sends a comment,
+// so that proxies do not close it.
+const streamKeepAlive = 30 * time.Second
+
+// ListNotifications returns the notifications of the user making the
+// request, newest first, or only the unread ones with unread=true.
+func ListNotifications(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]notificationResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]notificationResponse], *httpError) {
+		q := r.URL.Query()
+		userID, httpErr := ownUser(r, q.Get("user_id"))
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		limit, offset, err := filters.Page(q)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		list, err := querier.ListNotifications(r.Context(), dbc, db.NotificationFilter{
+			UserID: userID,
+			Unread: q.Get("unread") == "true",
+			Limit:  pageSize(limit),
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]notificationResponse, 0, len(list))
+		for _, notification := range list {
+			resp = append(resp, mapNotificationToResponse(notification))
+		}
+
+		return &httpResponse[[]notificationResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleNotificationCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[notificationCommandRequest, notificationCommandResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req notificationCommandRequest) (*httpResponse[notificationCommandResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		userID, httpErr := ownUser(r, req.UserID)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		now := time.Now().UTC().Format(db.TimeFormat)
+
+		var resp notificationCommandResponse
+		switch req.Command {
+		case "mark_read":
+			notification, err := querier.MarkNotificationRead(r.Context(), dbc, userID, req.NotificationID, now)
+			if err != nil {
+				return nil, commandError(err)
+			}
+			if notification.ReadAt == now {
+				resp.Updated = 1
+			}
+		case "mark_all_read":
+			n, err := querier.MarkAllNotificationsRead(r.Context(), dbc, userID, now)
+			if err != nil {
+				return nil, commandError(err)
+			}
+			resp.Updated = n
+		}
+
+		unread, err := querier.CountUnreadNotifications(r.Context(), dbc, userID)
+		if err != nil {
+			return nil, commandError(err)
+		}
+		resp.Unread = unread
+
+		return &httpResponse[notificationCommandResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// NotificationStream delivers the notifications of the user making the
+// request as server-sent events while the connection is open. It starts with
+// an unread event carrying the unread count, followed by a notification
+// event for every new notification.
+func NotificationStream(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	notifier *notifications.Notifier,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The workspace middleware has checked that the user exists
+		userID, httpErr := ownUser(r, r.URL.Query().Get("user_id"))
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// Subscribing before counting means no notification falls in between
+		received, unsubscribe := notifier.Subscribe(userID)
+		defer unsubscribe()
+
+		unread, err := querier.CountUnreadNotifications(r.Context(), dbc, userID)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		if err := writeEvent(w, "", "unread", map[string]int{"count": unread}); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case notification := <-received:
+				if err := writeEvent(w, notification.ID, "notification", mapNotificationToResponse(notification)); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, id, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+	"simplecrm/internal/money"
+	"simplecrm/internal/ops"
+)
+
+func CreateProduct(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createProductRequest, productResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createProductRequest) (*httpResponse[productResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		product, prices, err := ops.CreateProduct(r.Context(), dbc, querier, ops.CreateProductParams(req))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[productResponse]{
+			Data:       mapProductToResponse(product, prices),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+// ListProducts lists the catalog with each product's prices. Products no
+// longer sold are left out unless include_inactive is true.
+func ListProducts(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]productResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]productResponse], *httpError) {
+		includeInactive := r.URL.Query().Get("include_inactive") == "true"
+		products, err := querier.ListProducts(r.Context(), dbc, includeInactive)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		ids := make([]string, 0, len(products))
+		for _, product := range products {
+			ids = append(ids, product.ID)
+		}
+		prices, err := querier.ListProductPrices(r.Context(), dbc, ids)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := make([]productResponse, 0, len(products))
+		for _, product := range products {
+			resp = append(resp, mapProductToResponse(product, prices))
+		}
+
+		return &httpResponse[[]productResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// HandleProductCommand sets or removes a product's price in a currency, or
+// takes it off or back on sale.
+func HandleProductCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[productCommandRequest, productResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req productCommandRequest) (*httpResponse[productResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		var err error
+		switch req.Command {
+		case "set_price":
+			_, err = ops.SetProductPrice(r.Context(), dbc, querier, req.ProductID, req.Currency, *req.UnitPrice)
+		case "remove_price":
+			err = querier.DeleteProductPrice(r.Context(), dbc, req.ProductID, req.Currency)
+		case "set_active":
+			_, err = querier.SetProductActive(r.Context(), dbc, req.ProductID, *req.Active)
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		product, err := querier.GetProduct(r.Context(), dbc, req.ProductID)
+		if err != nil {
+			return nil, commandError(err)
+		}
+		prices, err := querier.ListProductPrices(r.Context(), dbc, []string{product.ID})
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[productResponse]{
+			Data:       mapProductToResponse(product, prices),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// Quotes are only available to the user making the request, named by the
+// X-User-ID header, when they can see the lead or contact the quote is made
+// to.
+
+func CreateQuote(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createQuoteRequest, quoteResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createQuoteRequest) (*httpResponse[quoteResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		userID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		quote, err := ops.CreateQuote(r.Context(), dbc, querier, ops.CreateQuoteParams{
+			EntityID:   req.EntityID,
+			Title:      req.Title,
+			Currency:   req.Currency,
+			TaxRate:    req.TaxRate,
+			ValidUntil: req.ValidUntil,
+			Notes:      req.Notes,
+			CreatedBy:  userID,
+			ViewerID:   userID,
+		})
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[quoteResponse]{
+			Data:       mapQuoteToResponse(ops.PriceQuote(quote, nil)),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+// ListQuotes lists quotes a page at a time, latest first, optionally those
+// to the lead or contact given by entity_id or with a status.
+func ListQuotes(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]quoteResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]quoteResponse], *httpError) {
+		userID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		q := r.URL.Query()
+		limit, offset, err := filters.Page(q)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		if status := q.Get("status"); status != "" && !slices.Contains(ops.QuoteStatuses, status) {
+			return nil, &httpError{
+				Message:    "Unknown status " + strconv.Quote(status),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		quotes, err := querier.ListQuotes(r.Context(), dbc, db.QuoteFilter{
+			EntityID:  q.Get("entity_id"),
+			Status:    q.Get("status"),
+			VisibleTo: userID,
+			Limit:     pageSize(limit),
+			Offset:    offset,
+		})
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		ids := make([]string, 0, len(quotes))
+		for _, quote := range quotes {
+			ids = append(ids, quote.ID)
+		}
+		lines, err := querier.ListQuoteLines(r.Context(), dbc, ids)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := make([]quoteResponse, 0, len(quotes))
+		for _, quote := range quotes {
+			quoteLines := slices.DeleteFunc(slices.Clone(lines), func(line db.QuoteLine) bool {
+				return line.QuoteID != quote.ID
+			})
+			resp = append(resp, mapQuoteToResponse(ops.PriceQuote(quote, quoteLines)))
+		}
+
+		return &httpResponse[[]quoteResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// GetQuote returns a quote with its lines and totals.
+func GetQuote(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[quoteResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[quoteResponse], *httpError) {
+		userID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		quote, err := ops.GetQuote(r.Context(), dbc, querier, chi.URLParam(r, "id"), userID)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[quoteResponse]{
+			Data:       mapQuoteToResponse(quote),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// HandleQuoteCommand edits a draft quote and its lines, or moves a quote to
+// another status.
+func HandleQuoteCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[quoteCommandRequest, quoteResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req quoteCommandRequest) (*httpResponse[quoteResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		if (req.Command == "update_line" || req.Command == "remove_line") && req.LineID == "" {
+			return nil, &httpError{
+				Message:    "Missing line_id",
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		userID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		var (
+			quote ops.PricedQuote
+			err   error
+		)
+		switch req.Command {
+		case "update":
+			quote, err = ops.UpdateQuote(r.Context(), dbc, querier, ops.UpdateQuoteParams{
+				ID:         req.QuoteID,
+				Title:      req.Title,
+				TaxRate:    req.TaxRate,
+				ValidUntil: req.ValidUntil,
+				Notes:      req.Notes,
+				ViewerID:   userID,
+			})
+		case "add_line":
+			quote, err = ops.AddQuoteLine(r.Context(), dbc, querier, ops.AddQuoteLineParams{
+				QuoteID:     req.QuoteID,
+				ProductID:   req.ProductID,
+				Description: req.Description,
+				UnitPrice:   req.UnitPrice,
+				Quantity:    req.Quantity,
+				Discount:    req.Discount,
+				ViewerID:    userID,
+			})
+		case "update_line":
+			quote, err = ops.UpdateQuoteLine(r.Context(), dbc, querier, ops.UpdateQuoteLineParams{
+				QuoteID:   req.QuoteID,
+				LineID:    req.LineID,
+				UnitPrice: *req.UnitPrice,
+				Quantity:  req.Quantity,
+				Discount:  req.Discount,
+				ViewerID:  userID,
+			})
+		case "remove_line":
+			quote, err = ops.RemoveQuoteLine(r.Context(), dbc, querier, req.QuoteID, req.LineID, userID)
+		case "set_status":
+			quote, err = ops.SetQuoteStatus(r.Context(), dbc, querier, req.QuoteID, req.Status, userID)
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[quoteResponse]{
+			Data:       mapQuoteToResponse(quote),
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+var quoteFuncs = template.FuncMap{
+	"money": money.Format,
+	"percent": func(bp int64) string {
+		return strconv.FormatFloat(float64(bp)/100, 'f', -1, 64) + "%"
+	},
+}
+
+// quotePage renders a quote as a standalone page for printing or saving
+// from the browser.
+var quotePage = template.Must(template.New("quote").Funcs(quoteFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Quote {{.Quote.Number}}: {{.Quote.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ccc; padding: 0.4em; text-align: left; }
+.amount { text-align: right; white-space: nowrap; }
+</style>
+</head>
+<body>
+<h1>Quote {{.Quote.Number}}: {{.Quote.Title}}</h1>
+<p>
+For {{.Entity.FirstName}} {{.Entity.LastName}}{{with .Entity.Email}} &lt;{{.}}&gt;{{end}}<br>
+Status: {{.Quote.Status}}<br>
+Date: {{.Date}}{{with .Quote.ValidUntil}}<br>
+Valid until: {{.}}{{end}}
+</p>
+<table>
+<thead>
+<tr><th>SKU</th><th>Description</th><th class="amount">Quantity</th><th class="amount">Unit price</th><th class="amount">Discount</th><th class="amount">Amount</th></tr>
+</thead>
+<tbody>
+{{- $currency := .Quote.Currency}}
+{{- range .Quote.Lines}}
+<tr><td>{{.SKU}}</td><td>{{.Description}}</td><td class="amount">{{.Quantity}}</td><td class="amount">{{money .UnitPrice $currency}}</td><td class="amount">{{if .Discount}}{{percent .Discount}}{{end}}</td><td class="amount">{{money .Net $currency}}</td></tr>
+{{- end}}
+</tbody>
+<tfoot>
+<tr><td colspan="5" class="amount">Subtotal</td><td class="amount">{{money .Quote.Subtotal $currency}}</td></tr>
+<tr><td colspan="5" class="amount">Tax ({{percent .Quote.TaxRate}})</td><td class="amount">{{money .Quote.Tax $currency}}</td></tr>
+<tr><th colspan="5" class="amount">Total</th><th class="amount">{{money .Quote.Total $currency}}</th></tr>
+</tfoot>
+</table>
+{{with .Quote.Notes}}<p>{{.}}</p>{{end}}
+</body>
+</html>
+`))
+
+// PrintQuote renders a quote as a plain HTML page, with amounts in the major
+// unit of its currency, for printing from the browser.
+func PrintQuote(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, httpErr := viewer(r)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		quote, err := ops.GetQuote(r.Context(), dbc, querier, chi.URLParam(r, "id"), userID)
+		if err != nil {
+			httpErr := commandError(err)
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+		entity, err := querier.GetEntity(r.Context(), dbc, quote.EntityID)
+		if err != nil {
+			httpErr := commandError(err)
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		// Quotes are dated when sent, drafts when last changed
+		date := quote.UpdatedAt
+		if quote.SentAt != "" {
+			date = quote.SentAt
+		}
+		if len(date) > 10 {
+			date = date[:10]
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = quotePage.Execute(w, map[string]any{
+			"Quote":  quote,
+			"Entity": entity,
+			"Date":   date,
+		})
+		if err != nil {
+			slog.Error("Failed to render quote", "quote", quote.ID, "error", err)
+		}
+	}
+}
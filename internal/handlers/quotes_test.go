@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotes(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	_, err := dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email) VALUES
+	('u1', 'Test', 'User', 'u1@example.com'),
+	('u2', 'Test', 'User', 'u2@example.com');
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, assigned_to, created_at, converted_at) VALUES
+	('e1', 'Jane', 'Doe', 'jane@example.com', '', 'new', 'u1', '2026-01-05 09:00:00', '');
+	`)
+	a.NoError(err)
+
+	post := func(userID, url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		if userID != "" {
+			req.Header.Set(userIDHeader, userID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(userID, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		if userID != "" {
+			req.Header.Set(userIDHeader, userID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	command := func(userID, pl string) quoteResponse {
+		t.Helper()
+		w := post(userID, "/api/v1/quote/command", pl)
+		a.Equal(http.StatusOK, w.Code, w.Body.String())
+		var quote quoteResponse
+		a.NoError(json.Unmarshal(w.Body.Bytes(), &quote))
+		return quote
+	}
+
+	// Test
+	w := post("", "/api/v1/product/create", `{
+		"sku": "SUP-1",
+		"name": "Support plan",
+		"prices": {"USD": 12500, "EUR": 11000}
+	}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var support productResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &support))
+	a.Equal(map[string]int64{"USD": 12500, "EUR": 11000}, support.Prices)
+
+	w = post("", "/api/v1/product/create", `{"sku": "INST", "name": "Installation", "prices": {"EUR": 5000}}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var install productResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &install))
+
+	for _, pl := range []string{
+		// SKUs are unique regardless of case
+		`{"sku": "sup-1", "name": "Other"}`,
+		`{"sku": "A B", "name": "Other"}`,
+		`{"sku": "OTHER", "name": "Other", "prices": {"usd": 100}}`,
+		`{"sku": "OTHER", "name": "Other", "prices": {"USD": -1}}`,
+	} {
+		w = post("", "/api/v1/product/create", pl)
+		a.Equal(http.StatusBadRequest, w.Code, pl)
+	}
+
+	w = post("", "/api/v1/product/command", `{"command": "set_price", "product_id": "`+support.ID+`", "currency": "EUR", "unit_price": 11500}`)
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &support))
+	a.Equal(int64(11500), support.Prices["EUR"])
+
+	w = post("u2", "/api/v1/quote/create", `{"entity_id": "e1", "title": "Support", "currency": "USD"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+	w = post("u1", "/api/v1/quote/create", `{"entity_id": "e1", "title": "Support", "currency": "USD", "tax_rate": 8.255}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("", "/api/v1/quote/create", `{"entity_id": "e1", "title": "Support", "currency": "USD"}`)
+	a.Equal(http.StatusUnauthorized, w.Code)
+
+	w = post("u1", "/api/v1/quote/create", `{
+		"entity_id": "e1",
+		"title": "Support & installation",
+		"currency": "USD",
+		"tax_rate": 8.25,
+		"valid_until": "2999-12-31"
+	}`)
+	a.Equal(http.StatusCreated, w.Code, w.Body.String())
+	var quote quoteResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &quote))
+	a.Equal(int64(1), quote.Number)
+	a.Equal("draft", quote.Status)
+	a.Equal("u1", quote.CreatedBy)
+	a.Empty(quote.Lines)
+
+	// Quotes without lines cannot be sent
+	w = post("u1", "/api/v1/quote/command", `{"command": "set_status", "quote_id": "`+quote.ID+`", "status": "sent"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	w = post("u1", "/api/v1/quote/command", `{"command": "remove_line", "quote_id": "`+quote.ID+`"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	quote = command("u1", `{"command": "add_line", "quote_id": "`+quote.ID+`", "product_id": "`+support.ID+`", "quantity": 3, "discount": 10}`)
+	// The product has no USD price
+	w = post("u1", "/api/v1/quote/command", `{"command": "add_line", "quote_id": "`+quote.ID+`", "product_id": "`+install.ID+`", "quantity": 1}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+	quote = command("u1", `{"command": "add_line", "quote_id": "`+quote.ID+`", "description": "Travel", "unit_price": 3333, "quantity": 1}`)
+
+	a.Len(quote.Lines, 2)
+	a.Equal(quoteLineResponse{
+		ID:             quote.Lines[0].ID,
+		Position:       1,
+		ProductID:      support.ID,
+		SKU:            "SUP-1",
+		Description:    "Support plan",
+		Quantity:       3,
+		UnitPrice:      12500,
+		Discount:       10,
+		Gross:          37500,
+		DiscountAmount: 3750,
+		Net:            33750,
+	}, quote.Lines[0])
+	a.Equal(2, quote.Lines[1].Position)
+	a.Equal(int64(37083), quote.Subtotal)
+	// 8.25% of 370.83 is 30.593475
+	a.Equal(int64(3059), quote.Tax)
+	a.Equal(int64(40142), quote.Total)
+
+	quote = command("u1", `{"command": "update_line", "quote_id": "`+quote.ID+`", "line_id": "`+quote.Lines[1].ID+`", "unit_price": 3333, "quantity": 2}`)
+	a.Equal(int64(6666), quote.Lines[1].Net)
+	quote = command("u1", `{"command": "remove_line", "quote_id": "`+quote.ID+`", "line_id": "`+quote.Lines[1].ID+`"}`)
+	a.Len(quote.Lines, 1)
+	a.Equal(int64(33750+2784), quote.Total)
+
+	quote = command("u1", `{"command": "set_status", "quote_id": "`+quote.ID+`", "status": "sent"}`)
+	a.Equal("sent", quote.Status)
+	a.NotEmpty(quote.SentAt)
+
+	// Sent quotes cannot be edited
+	w = post("u1", "/api/v1/quote/command", `{"command": "update", "quote_id": "`+quote.ID+`", "title": "Changed"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	// Catalog changes leave existing lines as they were
+	w = post("", "/api/v1/product/command", `{"command": "set_price", "product_id": "`+support.ID+`", "currency": "USD", "unit_price": 99900}`)
+	a.Equal(http.StatusOK, w.Code)
+
+	w = get("u1", "/api/v1/quote/"+quote.ID+"/print")
+	a.Equal(http.StatusOK, w.Code)
+	a.Contains(w.Header().Get("Content-Type"), "text/html")
+	page := w.Body.String()
+	a.Contains(page, "Quote 1: Support &amp; installation")
+	a.Contains(page, "Jane Doe &lt;jane@example.com&gt;")
+	a.Contains(page, "<td class=\"amount\">125.00 USD</td>")
+	a.Contains(page, "Tax (8.25%)")
+	a.Contains(page, "365.34 USD")
+
+	w = get("u2", "/api/v1/quote/"+quote.ID+"/print")
+	a.Equal(http.StatusNotFound, w.Code)
+	w = get("", "/api/v1/quote/"+quote.ID+"/print")
+	a.Equal(http.StatusUnauthorized, w.Code)
+	w = get("u2", "/api/v1/query/quote/"+quote.ID)
+	a.Equal(http.StatusNotFound, w.Code)
+
+	quote = command("u1", `{"command": "set_status", "quote_id": "`+quote.ID+`", "status": "accepted"}`)
+	a.Equal("accepted", quote.Status)
+	a.NotEmpty(quote.DecidedAt)
+	w = post("u1", "/api/v1/quote/command", `{"command": "set_status", "quote_id": "`+quote.ID+`", "status": "declined"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	var quotes []quoteResponse
+	w = get("u1", "/api/v1/query/quotes?entity_id=e1&status=accepted")
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &quotes))
+	a.Len(quotes, 1)
+	a.Equal(quote.Total, quotes[0].Total)
+
+	w = get("u2", "/api/v1/query/quotes")
+	a.Equal(http.StatusOK, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &quotes))
+	a.Empty(quotes)
+	w = get("u1", "/api/v1/query/quotes?status=lost")
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	var history int
+	a.NoError(dbc.Get(&history, "SELECT COUNT(*) FROM entity_history WHERE entity_id = 'e1' AND action = 'quotes'"))
+	a.Equal(3, history)
+
+	// Inactive products cannot be quoted
+	w = post("", "/api/v1/product/command", `{"command": "set_active", "product_id": "`+support.ID+`", "active": false}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = get("", "/api/v1/query/products")
+	var products []productResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &products))
+	a.Len(products, 1)
+
+	w = post("u1", "/api/v1/quote/create", `{"entity_id": "e1", "title": "Renewal", "currency": "EUR"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &quote))
+	a.Equal(int64(2), quote.Number)
+	w = post("u1", "/api/v1/quote/command", `{"command": "add_line", "quote_id": "`+quote.ID+`", "product_id": "`+support.ID+`", "quantity": 1}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+}
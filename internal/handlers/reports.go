@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/reports"
+)
+
+// Reports are split into periods by the interval parameter, day, week, month
+// or year, and into groups by the group_by parameter. See filters.Report for
+// the other parameters. They only count the records the user making the
+// request can see.
+
+// LeadReport counts the leads created in each period and group, by default
+// each month and status.
+func LeadReport(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]leadReportResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]leadReportResponse], *httpError) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		filter, err := filters.Report(r.URL.Query(), db.EntityReportGroups, "month", "status")
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		filter.VisibleTo = viewerID
+
+		counts, err := querier.CountEntitiesCreated(r.Context(), dbc, filter)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]leadReportResponse, 0, len(counts))
+		for _, count := range counts {
+			resp = append(resp, mapEntityCountToResponse(count))
+		}
+
+		return &httpResponse[[]leadReportResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// ConversionReport reports the conversion rate and median time to convert
+// of the leads created in each period and group, by default each month.
+func ConversionReport(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]conversionReportResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]conversionReportResponse], *httpError) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		filter, err := filters.Report(r.URL.Query(), db.EntityReportGroups, "month", "none")
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		filter.VisibleTo = viewerID
+
+		stats, err := querier.GetConversionStats(r.Context(), dbc, filter)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]conversionReportResponse, 0, len(stats))
+		for _, s := range stats {
+			resp = append(resp, mapConversionStatsToResponse(s))
+		}
+
+		return &httpResponse[[]conversionReportResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// TaskReport counts the open and overdue tasks due in each period and group,
+// and the tasks completed in it, by default per assigned user over the
+// whole range.
+func TaskReport(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]taskReportResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]taskReportResponse], *httpError) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		filter, err := filters.Report(r.URL.Query(), db.TaskReportGroups, "none", "assigned_to")
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		filter.VisibleTo = viewerID
+
+		now := time.Now().UTC().Format(db.TimeFormat)
+		stats, err := querier.GetTaskStats(r.Context(), dbc, filter, now)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]taskReportResponse, 0, len(stats))
+		for _, s := range stats {
+			resp = append(resp, mapTaskStatsToResponse(s))
+		}
+
+		return &httpResponse[[]taskReportResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// QuoteReport sums the value of the quotes accepted or declined in each
+// period and group, by default each month and status. Values are converted
+// into the workspace's base currency at the rate in effect on the day each
+// quote was decided.
+func QuoteReport(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]quoteReportResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]quoteReportResponse], *httpError) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		filter, err := filters.Report(r.URL.Query(), db.QuoteReportGroups, "month", "status")
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		filter.VisibleTo = viewerID
+
+		rows, err := reports.QuoteValues(r.Context(), dbc, querier, filter)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]quoteReportResponse, 0, len(rows))
+		for _, row := range rows {
+			resp = append(resp, mapQuoteRowToResponse(row))
+		}
+
+		return &httpResponse[[]quoteReportResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func CreateScheduledReport(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createScheduledReportRequest, scheduledReportResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createScheduledReportRequest) (*httpResponse[scheduledReportResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		report, err := ops.CreateScheduledReport(r.Context(), dbc, querier, ops.CreateScheduledReportParams(req))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[scheduledReportResponse]{
+			Data:       mapScheduledReportToResponse(report),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+func ListScheduledReports(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]scheduledReportResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]scheduledReportResponse], *httpError) {
+		scheduled, err := querier.ListScheduledReports(r.Context(), dbc)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]scheduledReportResponse, 0, len(scheduled))
+		for _, report := range scheduled {
+			resp = append(resp, mapScheduledReportToResponse(report))
+		}
+
+		return &httpResponse[[]scheduledReportResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// ListReportSnapshots lists the results of a scheduled report's runs a page
+// at a time, latest first.
+func ListReportSnapshots(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]reportSnapshotResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]reportSnapshotResponse], *httpError) {
+		limit, offset, err := filters.Page(r.URL.Query())
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		report, err := querier.GetScheduledReport(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		snapshots, err := querier.ListReportSnapshots(r.Context(), dbc, report.ID, pageSize(limit), offset)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := make([]reportSnapshotResponse, 0, len(snapshots))
+		for _, snapshot := range snapshots {
+			resp = append(resp, mapReportSnapshotToResponse(snapshot))
+		}
+
+		return &httpResponse[[]reportSnapshotResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// HandleScheduledReportCommand deletes a scheduled report or runs it now, on
+// the range ending now, which keeps its schedule as it was.
+func HandleScheduledReportCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	scheduler *reports.Scheduler,
+) handlerFunc[scheduledReportCommandRequest, map[string]any] {
+	return func(w http.ResponseWriter, r *http.Request, req scheduledReportCommandRequest) (*httpResponse[map[string]any], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		switch req.Command {
+		case "run":
+			report, err := querier.GetScheduledReport(r.Context(), dbc, req.ReportID)
+			if err != nil {
+				return nil, commandError(err)
+			}
+			snapshot, err := scheduler.RunReport(r.Context(), report, time.Now().UTC())
+			if err != nil {
+				return nil, commandError(err)
+			}
+			return &httpResponse[map[string]any]{
+				Data:       map[string]any{"id": req.ReportID, "snapshot": mapReportSnapshotToResponse(snapshot)},
+				StatusCode: http.StatusOK,
+			}, nil
+		case "delete":
+			if err := ops.DeleteScheduledReport(r.Context(), dbc, querier, req.ReportID); err != nil {
+				return nil, commandError(err)
+			}
+		}
+
+		return &httpResponse[map[string]any]{
+			Data:       map[string]any{"id": req.ReportID},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
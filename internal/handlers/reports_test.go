@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/internal/db"
+)
+
+func TestReports(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	_, err := dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email) VALUES
+	('u1', 'Test', 'User', 'u1@example.com'),
+	('u2', 'Test', 'User', 'u2@example.com'),
+	('m1', 'Test', 'Manager', 'm1@example.com');
+	INSERT INTO teams (id, name) VALUES ('sales', 'Sales');
+	INSERT INTO team_members (team_id, user_id, role) VALUES
+	('sales', 'm1', 'manager'),
+	('sales', 'u1', 'member'),
+	('sales', 'u2', 'member');
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, source, assigned_to, created_at, converted_at) VALUES
+	('e1', 'Jane', 'Doe', 'e1@example.com', '', 'converted', 'web', 'u1', '2026-01-05 09:00:00', '2026-01-07 09:00:00'),
+	('e2', 'John', 'Doe', 'e2@example.com', '', 'converted', 'web', 'u1', '2026-01-10 09:00:00', '2026-01-14 09:00:00'),
+	('e3', 'Joan', 'Doe', 'e3@example.com', '', 'converted', 'referral', 'u1', '2026-01-20 09:00:00', '2026-01-30 09:00:00'),
+	('e4', 'Jim', 'Doe', 'e4@example.com', '', 'new', 'web', 'u1', '2026-01-25 09:00:00', ''),
+	('e5', 'Jill', 'Doe', 'e5@example.com', '', 'new', 'web', 'u2', '2026-02-03 09:00:00', ''),
+	('e6', 'Jack', 'Doe', 'e6@example.com', '', 'new', 'web', 'u1', '2025-12-31 09:00:00', '');
+	INSERT INTO tasks (id, name, description, due_date, status, assigned_to, completed_at) VALUES
+	('t1', 'Call', '', '2026-01-05', 'done', 'u1', '2026-02-02 10:00:00'),
+	('t2', 'Call', '', '2026-01-06', 'open', 'u1', ''),
+	('t3', 'Call', '', '2999-01-01', 'open', 'u1', ''),
+	('t4', 'Call', '', '2026-01-07', 'open', 'u2', '');
+	`)
+	a.NoError(err)
+
+	// Reports count what the user making the request can see, which for
+	// the manager of both users is everything
+	get := func(userID, url string, resp any) int {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code == http.StatusOK {
+			a.NoError(json.Unmarshal(w.Body.Bytes(), resp))
+		}
+		return w.Code
+	}
+
+	// Test
+	var leads []leadReportResponse
+	a.Equal(http.StatusOK, get("m1", "/api/v1/query/report/leads?from=2026-01-01&to=2026-03-01&group_by=source", &leads))
+	a.Equal([]leadReportResponse{
+		{Period: "2026-01", Group: "referral", Count: 1},
+		{Period: "2026-01", Group: "web", Count: 3},
+		{Period: "2026-02", Group: "web", Count: 1},
+	}, leads)
+
+	a.Equal(http.StatusOK, get("m1", "/api/v1/query/report/leads?from=2026-01-01&interval=week&group_by=none", &leads))
+	a.Equal([]leadReportResponse{
+		{Period: "2026-01-05", Group: "", Count: 2},
+		{Period: "2026-01-19", Group: "", Count: 2},
+		{Period: "2026-02-02", Group: "", Count: 1},
+	}, leads)
+
+	var conversion []conversionReportResponse
+	a.Equal(http.StatusOK, get("m1", "/api/v1/query/report/conversion?from=2026-01-01&to=2026-02-01", &conversion))
+	a.Len(conversion, 1)
+	a.Equal("2026-01", conversion[0].Period)
+	a.Equal(4, conversion[0].Created)
+	a.Equal(3, conversion[0].Converted)
+	a.Equal(0.75, conversion[0].ConversionRate)
+	a.NotNil(conversion[0].MedianDaysToConvert)
+	a.InDelta(4.0, *conversion[0].MedianDaysToConvert, 0.001)
+
+	// An even number of conversions has the mean of the middle two as median
+	a.Equal(http.StatusOK, get("m1", "/api/v1/query/report/conversion?from=2026-01-01&to=2026-01-15&interval=none", &conversion))
+	a.Len(conversion, 1)
+	a.InDelta(3.0, *conversion[0].MedianDaysToConvert, 0.001)
+
+	a.Equal(http.StatusOK, get("m1", "/api/v1/query/report/conversion?from=2026-02-01&interval=none", &conversion))
+	a.Len(conversion, 1)
+	a.Zero(conversion[0].ConversionRate)
+	a.Nil(conversion[0].MedianDaysToConvert)
+
+	var tasks []taskReportResponse
+	a.Equal(http.StatusOK, get("m1", "/api/v1/query/report/tasks", &tasks))
+	a.Equal([]taskReportResponse{
+		{Period: "", Group: "u1", Total: 3, Completed: 1, Open: 2, Overdue: 1},
+		{Period: "", Group: "u2", Total: 1, Completed: 0, Open: 1, Overdue: 1},
+	}, tasks)
+
+	// Completions are counted when tasks were completed, whenever they were
+	// due
+	a.Equal(http.StatusOK, get("u1", "/api/v1/query/report/tasks?to=2026-01-07", &tasks))
+	a.Equal([]taskReportResponse{
+		{Period: "", Group: "u1", Total: 2, Completed: 0, Open: 1, Overdue: 1},
+	}, tasks)
+	a.Equal(http.StatusOK, get("m1", "/api/v1/query/report/tasks?from=2026-01-01&interval=month&group_by=none", &tasks))
+	a.Equal([]taskReportResponse{
+		{Period: "2026-01", Group: "", Total: 3, Completed: 0, Open: 2, Overdue: 2},
+		{Period: "2026-02", Group: "", Total: 0, Completed: 1, Open: 0, Overdue: 0},
+		{Period: "2999-01", Group: "", Total: 1, Completed: 0, Open: 1, Overdue: 0},
+	}, tasks)
+
+	// Tasks due on a plain date are overdue once that day is over, as for
+	// reminders
+	now := time.Now().UTC()
+	_, err = dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email) VALUES ('u3', 'Test', 'User', 'u3@example.com');
+	INSERT INTO tasks (id, name, description, due_date, status, assigned_to) VALUES
+	('t5', 'Call', '', ?, 'open', 'u3'),
+	('t6', 'Call', '', ?, 'open', 'u3'),
+	('t7', 'Call', '', ?, 'open', 'u3');
+	`,
+		now.Format(time.DateOnly),
+		now.AddDate(0, 0, -1).Format(time.DateOnly),
+		now.Add(-time.Minute).Format(db.TimeFormat),
+	)
+	a.NoError(err)
+	a.Equal(http.StatusOK, get("u3", "/api/v1/query/report/tasks", &tasks))
+	a.Equal([]taskReportResponse{
+		{Period: "", Group: "u3", Total: 3, Completed: 0, Open: 3, Overdue: 2},
+	}, tasks)
+
+	a.Equal(http.StatusUnauthorized, get("", "/api/v1/query/report/leads", nil))
+	a.Equal(http.StatusBadRequest, get("m1", "/api/v1/query/report/leads?group_by=email", nil))
+	a.Equal(http.StatusBadRequest, get("m1", "/api/v1/query/report/leads?interval=quarter", nil))
+	a.Equal(http.StatusBadRequest, get("m1", "/api/v1/query/report/tasks?group_by=status", nil))
+	a.Equal(http.StatusBadRequest, get("m1", "/api/v1/query/report/conversion?from=yesterday", nil))
+}
+
+func TestScheduledReports(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	_, err := dbc.Exec(`
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, source, created_at, converted_at) VALUES
+	('e1', 'Jane', 'Doe', 'e1@example.com', '', 'new', 'web', datetime('now', '-1 day'), '');
+	`)
+	a.NoError(err)
+
+	post := func(url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Test
+	w := post("/api/v1/scheduled-report/create", `{
+		"name": "Weekly digest",
+		"metrics": ["leads", "conversion"],
+		"recipients": ["sales@example.com"],
+		"schedule": "0 8 * * MON",
+		"timezone": "Europe/Berlin",
+		"filter": "group_by=source"
+	}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var report scheduledReportResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	a.Equal([]string{"leads", "conversion"}, report.Metrics)
+	a.Equal("week", report.Period)
+	next, err := time.Parse(db.TimeFormat, report.NextRunAt)
+	a.NoError(err)
+	a.True(next.After(time.Now().UTC()))
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	a.NoError(err)
+	a.Equal(time.Monday, next.In(berlin).Weekday())
+	a.Equal(8, next.In(berlin).Hour())
+
+	for _, pl := range []string{
+		// Same name
+		`{"name": "Weekly digest", "metrics": ["leads"], "schedule": "@weekly"}`,
+		`{"name": "Digest", "metrics": ["deals"], "schedule": "@weekly"}`,
+		`{"name": "Digest", "metrics": ["leads", "leads"], "schedule": "@weekly"}`,
+		`{"name": "Digest", "metrics": ["leads"], "schedule": "every monday"}`,
+		`{"name": "Digest", "metrics": ["leads"], "schedule": "0 0 30 2 *"}`,
+		`{"name": "Digest", "metrics": ["leads"], "schedule": "@weekly", "timezone": "Mars/Olympus"}`,
+		`{"name": "Digest", "metrics": ["leads"], "schedule": "@weekly", "recipients": ["sales"]}`,
+		`{"name": "Digest", "metrics": ["leads"], "schedule": "@weekly", "period": "year"}`,
+		`{"name": "Digest", "metrics": ["leads"], "schedule": "@weekly", "filter": "from=2026-01-01"}`,
+		// Tasks cannot be grouped by source
+		`{"name": "Digest", "metrics": ["leads", "tasks"], "schedule": "@weekly", "filter": "group_by=source"}`,
+	} {
+		w = post("/api/v1/scheduled-report/create", pl)
+		a.Equal(http.StatusBadRequest, w.Code, pl)
+	}
+
+	w = get("/api/v1/query/scheduled-reports")
+	a.Equal(http.StatusOK, w.Code)
+	var reports []scheduledReportResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &reports))
+	a.Len(reports, 1)
+
+	w = post("/api/v1/scheduled-report/command", `{"command": "run", "report_id": "`+report.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+
+	w = get("/api/v1/query/scheduled-report/" + report.ID + "/snapshots")
+	a.Equal(http.StatusOK, w.Code)
+	var snapshots []reportSnapshotResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &snapshots))
+	a.Len(snapshots, 1)
+	var result struct {
+		Leads      []leadReportResponse       `json:"leads"`
+		Conversion []conversionReportResponse `json:"conversion"`
+		Tasks      []taskReportResponse       `json:"tasks"`
+	}
+	a.NoError(json.Unmarshal(snapshots[0].Result, &result))
+	a.Equal([]leadReportResponse{{Group: "web", Count: 1}}, result.Leads)
+	a.Len(result.Conversion, 1)
+	a.Nil(result.Tasks)
+
+	// Running on demand leaves the schedule as it was
+	w = get("/api/v1/query/scheduled-reports")
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &reports))
+	a.Equal(report.NextRunAt, reports[0].NextRunAt)
+
+	var queued int
+	a.NoError(dbc.Get(&queued, "SELECT COUNT(*) FROM email_outbox WHERE to_addresses = 'sales@example.com'"))
+	a.Equal(1, queued)
+
+	w = post("/api/v1/scheduled-report/command", `{"command": "delete", "report_id": "`+report.ID+`"}`)
+	a.Equal(http.StatusOK, w.Code)
+	w = get("/api/v1/query/scheduled-report/" + report.ID + "/snapshots")
+	a.Equal(http.StatusNotFound, w.Code)
+	w = post("/api/v1/scheduled-report/command", `{"command": "run", "report_id": "`+report.ID+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+}
@@ -2,13 +2,25 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 
 	"simplecrm/internal/db"
+	"simplecrm/internal/imports"
+	"simplecrm/internal/mail"
+	"simplecrm/internal/notifications"
+	"simplecrm/internal/ops"
 	"simplecrm/internal/pubsub"
+	"simplecrm/internal/ratelimit"
+	"simplecrm/internal/reports"
+	"simplecrm/internal/scoring"
+	"simplecrm/internal/storage"
 )
 
 type httpError struct {
@@ -76,44 +88,430 @@ func JSONDecoderMiddlewareGet[Resp any](
 	}
 }
 
+// MultipartFormMiddleware parses a multipart/form-data body of at most
+// maxBytes before calling handler and encodes its response as JSON.
+func MultipartFormMiddleware[Resp any](
+	handler getHandlerFunc[Resp],
+	maxBytes int64,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "multipart/form-data" {
+			http.Error(w, "Invalid Content-Type", http.StatusBadRequest)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		if err := r.ParseMultipartForm(maxBytes); err != nil {
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+
+		resp, err := handler(w, r)
+		if err != nil {
+			http.Error(w, err.Message, err.StatusCode)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.StatusCode)
+		json.NewEncoder(w).Encode(resp.Data)
+	}
+}
+
+// RawBodyMiddleware reads a body of at most maxBytes with one of mediaTypes
+// and passes it to handler, encoding its response as JSON.
+func RawBodyMiddleware[Resp any](
+	handler func(w http.ResponseWriter, r *http.Request, body []byte) (*httpResponse[Resp], *httpError),
+	maxBytes int64,
+	mediaTypes ...string,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if !slices.Contains(mediaTypes, mediaType) {
+			http.Error(w, "Invalid Content-Type", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		resp, httpErr := handler(w, r, body)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.StatusCode)
+		json.NewEncoder(w).Encode(resp.Data)
+	}
+}
+
 func MountRoutes(
 	r chi.Router,
 	dbc *sqlx.DB,
 	querier db.Querier,
 	userCreatedEventService pubsub.UserCreatedEventServicer,
+	eventService pubsub.EventServicer,
+	importer *imports.Importer,
+	scorer *scoring.Scorer,
+	notifier *notifications.Notifier,
+	reportScheduler *reports.Scheduler,
+	outbox *mail.Outbox,
+	formLimiter *ratelimit.Limiter,
+	trustedProxies TrustedProxies,
+	idempotencyTTL time.Duration,
+	phoneRegion string,
+	defaultWorkspace string,
+	attachments storage.Store,
 ) {
-	r.Route("/api/v1/query", func(r chi.Router) {
-		r.Get("/user", JSONDecoderMiddlewareGet(
-			GetUser(dbc, querier),
-		))
-		r.Get("/lead/{id}", GetLead())
-		r.Get("/contact/{id}", GetContact())
-		r.Get("/task/{id}", GetTask())
-	})
+	r.Group(func(r chi.Router) {
+		r.Use(WorkspaceMiddleware(dbc, querier, defaultWorkspace))
 
-	r.Route("/api/v1/user", func(r chi.Router) {
-		r.Post("/create", JSONDecoderMiddleware(
-			CreateUser(dbc, querier, userCreatedEventService),
-		))
-		r.Post("/update/{id}", UpdateUser())
-		r.Post("/command", HandleUserCommand())
-	})
+		r.Route("/api/v1/query", func(r chi.Router) {
+			r.Get("/user", JSONDecoderMiddlewareGet(
+				GetUser(dbc, querier),
+			))
+			r.Get("/lead/{id}", GetLead())
+			r.Get("/lead/{id}/history", JSONDecoderMiddlewareGet(
+				GetEntityHistory(dbc, querier),
+			))
+			r.Get("/lead/{id}/score", JSONDecoderMiddlewareGet(
+				GetEntityScore(scorer),
+			))
+			r.Get("/lead/{id}/activities", JSONDecoderMiddlewareGet(
+				ListActivities(dbc, querier),
+			))
+			r.Get("/lead/{id}/attachments", JSONDecoderMiddlewareGet(
+				ListAttachments(dbc, querier, ops.ObjectTypeLead),
+			))
+			r.Get("/contact/{id}", GetContact())
+			r.Get("/contact/{id}/history", JSONDecoderMiddlewareGet(
+				GetEntityHistory(dbc, querier),
+			))
+			r.Get("/contact/{id}/score", JSONDecoderMiddlewareGet(
+				GetEntityScore(scorer),
+			))
+			r.Get("/contact/{id}/activities", JSONDecoderMiddlewareGet(
+				ListActivities(dbc, querier),
+			))
+			r.Get("/contact/{id}/attachments", JSONDecoderMiddlewareGet(
+				ListAttachments(dbc, querier, ops.ObjectTypeContact),
+			))
+			r.Get("/duplicates", JSONDecoderMiddlewareGet(
+				ListDuplicates(dbc, querier),
+			))
+			r.Get("/task/{id}", JSONDecoderMiddlewareGet(
+				GetTask(dbc, querier),
+			))
+			r.Get("/task/{id}/attachments", JSONDecoderMiddlewareGet(
+				ListAttachments(dbc, querier, ops.ObjectTypeTask),
+			))
+			r.Get("/leads", JSONDecoderMiddlewareGet(
+				ListEntities(dbc, querier, ops.ObjectTypeLead),
+			))
+			r.Get("/contacts", JSONDecoderMiddlewareGet(
+				ListEntities(dbc, querier, ops.ObjectTypeContact),
+			))
+			r.Get("/tasks", JSONDecoderMiddlewareGet(
+				ListTasks(dbc, querier),
+			))
+			r.Get("/import/{id}", JSONDecoderMiddlewareGet(
+				GetImport(dbc, querier),
+			))
+			r.Get("/import/{id}/errors", JSONDecoderMiddlewareGet(
+				GetImportErrors(dbc, querier),
+			))
+			r.Get("/assignment-rules", JSONDecoderMiddlewareGet(
+				ListAssignmentRules(dbc, querier),
+			))
+			r.Get("/scoring-rules", JSONDecoderMiddlewareGet(
+				ListScoringRules(dbc, querier),
+			))
+			r.Get("/workflows", JSONDecoderMiddlewareGet(
+				ListWorkflows(dbc, querier),
+			))
+			r.Get("/workflow/{id}/runs", JSONDecoderMiddlewareGet(
+				ListWorkflowRuns(dbc, querier),
+			))
+			r.Get("/notifications", JSONDecoderMiddlewareGet(
+				ListNotifications(dbc, querier),
+			))
+			r.Get("/emails", JSONDecoderMiddlewareGet(
+				ListOutboxEmails(dbc, querier),
+			))
+			r.Get("/inbound-email/{id}", JSONDecoderMiddlewareGet(
+				GetInboundEmail(dbc, querier),
+			))
+			r.Get("/forms", JSONDecoderMiddlewareGet(
+				ListLeadForms(dbc, querier),
+			))
+			r.Get("/custom-fields", JSONDecoderMiddlewareGet(
+				ListCustomFields(dbc, querier),
+			))
+			r.Get("/tags", JSONDecoderMiddlewareGet(
+				ListTags(dbc, querier),
+			))
+			r.Get("/segments", JSONDecoderMiddlewareGet(
+				ListSegments(dbc, querier),
+			))
+			r.Get("/segment/{id}/entities", JSONDecoderMiddlewareGet(
+				ListSegmentEntities(dbc, querier),
+			))
+			r.Get("/views", JSONDecoderMiddlewareGet(
+				ListSavedViews(dbc, querier),
+			))
+			r.Get("/view/{id}", JSONDecoderMiddlewareGet(
+				RunSavedView(dbc, querier),
+			))
+			r.Get("/teams", JSONDecoderMiddlewareGet(
+				ListTeams(dbc, querier),
+			))
+			r.Get("/report/leads", JSONDecoderMiddlewareGet(
+				LeadReport(dbc, querier),
+			))
+			r.Get("/report/conversion", JSONDecoderMiddlewareGet(
+				ConversionReport(dbc, querier),
+			))
+			r.Get("/report/tasks", JSONDecoderMiddlewareGet(
+				TaskReport(dbc, querier),
+			))
+			r.Get("/report/quotes", JSONDecoderMiddlewareGet(
+				QuoteReport(dbc, querier),
+			))
+			r.Get("/scheduled-reports", JSONDecoderMiddlewareGet(
+				ListScheduledReports(dbc, querier),
+			))
+			r.Get("/scheduled-report/{id}/snapshots", JSONDecoderMiddlewareGet(
+				ListReportSnapshots(dbc, querier),
+			))
+			r.Get("/products", JSONDecoderMiddlewareGet(
+				ListProducts(dbc, querier),
+			))
+			r.Get("/quotes", JSONDecoderMiddlewareGet(
+				ListQuotes(dbc, querier),
+			))
+			r.Get("/quote/{id}", JSONDecoderMiddlewareGet(
+				GetQuote(dbc, querier),
+			))
+			r.Get("/exchange-rates", JSONDecoderMiddlewareGet(
+				ListExchangeRates(dbc, querier),
+			))
+		})
 
-	r.Route("/api/v1/lead", func(r chi.Router) {
-		r.Post("/create", CreateLead())
-		r.Patch("/update/{id}", UpdateLead())
-		r.Post("/command", HandleLeadCommand())
-	})
+		r.Get("/api/v1/export/{resource}", Export(dbc, querier))
+		r.Get("/api/v1/export/segment/{id}", ExportSegment(dbc, querier))
+		r.Get("/api/v1/stream/notifications", NotificationStream(dbc, querier, notifier))
+		r.Get("/api/v1/attachment/{id}/download", DownloadAttachment(dbc, querier, attachments))
+		r.Get("/api/v1/quote/{id}/print", PrintQuote(dbc, querier))
 
-	r.Route("/api/v1/contact", func(r chi.Router) {
-		r.Post("/create", CreateContact())
-		r.Patch("/update/{id}", UpdateContact())
-		r.Post("/command", HandleContactCommand())
-	})
+		r.Group(func(r chi.Router) {
+			r.Use(IdempotencyMiddleware(dbc, querier, idempotencyTTL, maxIdempotentBodySize))
+
+			r.Route("/api/v1/user", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateUser(dbc, querier, userCreatedEventService),
+				))
+				r.Post("/update/{id}", UpdateUser())
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleUserCommand(dbc, querier, eventService),
+				))
+			})
+
+			r.Route("/api/v1/lead", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateLead(dbc, querier, eventService, phoneRegion),
+				))
+				r.Patch("/update/{id}", UpdateLead())
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleLeadCommand(dbc, querier, eventService),
+				))
+			})
+
+			r.Route("/api/v1/contact", func(r chi.Router) {
+				r.Post("/create", CreateContact())
+				r.Patch("/update/{id}", UpdateContact())
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleContactCommand(dbc, querier, eventService),
+				))
+			})
+
+			r.Route("/api/v1/task", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateTask(dbc, querier, eventService),
+				))
+				r.Patch("/update/{id}", UpdateTask())
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleTaskCommand(dbc, querier, eventService),
+				))
+			})
+
+			r.Route("/api/v1/assignment", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateAssignmentRule(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleAssignmentCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/scoring", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateScoringRule(dbc, querier, eventService),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleScoringCommand(dbc, querier, eventService),
+				))
+			})
 
-	r.Route("/api/v1/task", func(r chi.Router) {
-		r.Post("/create", CreateTask())
-		r.Patch("/update/{id}", UpdateTask())
-		r.Post("/command", HandleTaskCommand())
+			r.Route("/api/v1/workflow", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateWorkflow(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleWorkflowCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/form", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateLeadForm(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleLeadFormCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/custom-field", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateCustomField(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleCustomFieldCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/tag", func(r chi.Router) {
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleTagCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/segment", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateSegment(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleSegmentCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/view", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateSavedView(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleSavedViewCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/scheduled-report", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateScheduledReport(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleScheduledReportCommand(dbc, querier, reportScheduler),
+				))
+			})
+
+			r.Route("/api/v1/product", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateProduct(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleProductCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/quote", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateQuote(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleQuoteCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/exchange-rate", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateExchangeRates(dbc, querier),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleExchangeRateCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/team", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					CreateTeam(dbc, querier, eventService),
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleTeamCommand(dbc, querier, eventService),
+				))
+			})
+
+			r.Route("/api/v1/notification", func(r chi.Router) {
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleNotificationCommand(dbc, querier),
+				))
+			})
+
+			r.Route("/api/v1/email", func(r chi.Router) {
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleEmailCommand(outbox),
+				))
+				r.Post("/ingest", RawBodyMiddleware(
+					IngestEmails(dbc, querier, eventService),
+					maxImportSize,
+					mediaTypeRFC822, mediaTypeMbox,
+				))
+			})
+
+			r.Route("/api/v1/activity", func(r chi.Router) {
+				r.Post("/create", JSONDecoderMiddleware(
+					LogActivity(dbc, querier, eventService),
+				))
+			})
+
+			r.Route("/api/v1/attachment", func(r chi.Router) {
+				r.Post("/create", MultipartFormMiddleware(
+					CreateAttachment(dbc, querier, attachments),
+					maxAttachmentUploadSize,
+				))
+				r.Post("/command", JSONDecoderMiddleware(
+					HandleAttachmentCommand(dbc, querier, attachments),
+				))
+			})
+
+			r.Route("/api/v1/import", func(r chi.Router) {
+				r.Post("/create", MultipartFormMiddleware(
+					CreateImport(importer),
+					maxImportSize,
+				))
+			})
+		})
 	})
+
+	// Public routes, acting in the workspace of the calendar's user or the form
+	r.With(publicWorkspaceMiddleware(dbc, "id", querier.GetUserWorkspaceID)).
+		Get("/api/v1/calendar/{id}.ics", CalendarFeed(dbc, querier))
+	r.With(
+		publicWorkspaceMiddleware(dbc, "key", querier.GetLeadFormWorkspaceID),
+		IdempotencyMiddleware(dbc, querier, idempotencyTTL, maxFormSubmissionSize),
+	).Post("/api/v1/forms/{key}/submit", SubmitLeadForm(dbc, querier, eventService, formLimiter, trustedProxies, phoneRegion))
+	r.Options("/api/v1/forms/{key}/submit", FormPreflight())
 }
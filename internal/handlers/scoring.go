@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+	"simplecrm/internal/scoring"
+)
+
+func LogActivity(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[logActivityRequest, activityResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req logActivityRequest) (*httpResponse[activityResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		activity, err := ops.LogActivity(r.Context(), dbc, querier, ops.LogActivityParams{
+			EntityID:   req.EntityID,
+			Kind:       req.Kind,
+			UserID:     req.UserID,
+			Notes:      req.Notes,
+			OccurredAt: req.OccurredAt,
+		}, eventService)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[activityResponse]{
+			Data:       mapActivityToResponse(activity),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+func ListActivities(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]activityResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]activityResponse], *httpError) {
+		activities, err := querier.ListActivities(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]activityResponse, 0, len(activities))
+		for _, activity := range activities {
+			resp = append(resp, mapActivityToResponse(activity))
+		}
+
+		return &httpResponse[[]activityResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// GetEntityScore explains how an entity's score is made up, computed with
+// the current rules.
+func GetEntityScore(scorer *scoring.Scorer) getHandlerFunc[scoreResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[scoreResponse], *httpError) {
+		entity, explanation, err := scorer.Explain(r.Context(), chi.URLParam(r, "id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &httpError{
+				Message:    "Not found",
+				StatusCode: http.StatusNotFound,
+			}
+		}
+		if err != nil {
+			slog.Error(err.Error())
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := scoreResponse{
+			EntityID:    entity.ID,
+			Score:       explanation.Score,
+			StoredScore: entity.Score,
+			ScoredAt:    entity.ScoredAt,
+			ComputedAt:  explanation.ComputedAt.Format(db.TimeFormat),
+			Items:       make([]scoreItemResponse, 0, len(explanation.Items)),
+		}
+		for _, item := range explanation.Items {
+			resp.Items = append(resp.Items, scoreItemResponse{
+				RuleID:       item.RuleID,
+				Name:         item.Name,
+				Kind:         item.Kind,
+				Field:        item.Field,
+				Points:       item.Points,
+				Matches:      item.Matches,
+				Contribution: item.Contribution,
+			})
+		}
+
+		return &httpResponse[scoreResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func CreateScoringRule(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[createScoringRuleRequest, scoringRuleResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createScoringRuleRequest) (*httpResponse[scoringRuleResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		rule, err := ops.CreateScoringRule(r.Context(), dbc, querier, ops.CreateScoringRuleParams{
+			Name:         req.Name,
+			Kind:         req.Kind,
+			Field:        req.Field,
+			Value:        req.Value,
+			Points:       req.Points,
+			HalfLifeDays: req.HalfLifeDays,
+		}, eventService)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[scoringRuleResponse]{
+			Data:       mapScoringRuleToResponse(rule),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+func ListScoringRules(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]scoringRuleResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]scoringRuleResponse], *httpError) {
+		rules, err := querier.ListScoringRules(r.Context(), dbc)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]scoringRuleResponse, 0, len(rules))
+		for _, rule := range rules {
+			resp = append(resp, mapScoringRuleToResponse(rule))
+		}
+
+		return &httpResponse[[]scoringRuleResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleScoringCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[scoringCommandRequest, map[string]string] {
+	return func(w http.ResponseWriter, r *http.Request, req scoringCommandRequest) (*httpResponse[map[string]string], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		if err := ops.DeleteScoringRule(r.Context(), dbc, querier, req.RuleID, eventService); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]string]{
+			Data:       map[string]string{"id": req.RuleID},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
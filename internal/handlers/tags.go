@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/exports"
+	"simplecrm/internal/filters"
+	"simplecrm/internal/ops"
+)
+
+func ListTags(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]tagResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]tagResponse], *httpError) {
+		tags, err := querier.ListTags(r.Context(), dbc)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]tagResponse, 0, len(tags))
+		for _, tag := range tags {
+			resp = append(resp, tagResponse{
+				ID:        tag.ID,
+				Name:      tag.Name,
+				Entities:  tag.Entities,
+				CreatedAt: tag.CreatedAt,
+			})
+		}
+
+		return &httpResponse[[]tagResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleTagCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[tagCommandRequest, map[string]string] {
+	return func(w http.ResponseWriter, r *http.Request, req tagCommandRequest) (*httpResponse[map[string]string], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		// delete is the only command
+		if err := querier.DeleteTag(r.Context(), dbc, req.TagID); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]string]{
+			Data:       map[string]string{"id": req.TagID},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// attachEntityTags fills in the tags of entities.
+func attachEntityTags(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	entities []entityResponse,
+) error {
+	ids := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		ids = append(ids, entity.ID)
+	}
+
+	tags, err := querier.ListEntityTags(ctx, dbc, ids)
+	if err != nil {
+		return err
+	}
+
+	byEntity := map[string][]string{}
+	for _, tag := range tags {
+		byEntity[tag.EntityID] = append(byEntity[tag.EntityID], tag.Name)
+	}
+	for i := range entities {
+		entities[i].Tags = byEntity[entities[i].ID]
+	}
+
+	return nil
+}
+
+// Segment handlers
+
+func CreateSegment(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createSegmentRequest, segmentResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createSegmentRequest) (*httpResponse[segmentResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		segment, err := ops.CreateSegment(r.Context(), dbc, querier, ops.CreateSegmentParams(req))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[segmentResponse]{
+			Data:       mapSegmentToResponse(segment),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+func ListSegments(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]segmentResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]segmentResponse], *httpError) {
+		segments, err := querier.ListSegments(r.Context(), dbc)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]segmentResponse, 0, len(segments))
+		for _, segment := range segments {
+			resp = append(resp, mapSegmentToResponse(segment))
+		}
+
+		return &httpResponse[[]segmentResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleSegmentCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[segmentCommandRequest, map[string]string] {
+	return func(w http.ResponseWriter, r *http.Request, req segmentCommandRequest) (*httpResponse[map[string]string], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		// delete is the only command
+		if err := querier.DeleteSegment(r.Context(), dbc, req.SegmentID); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]string]{
+			Data:       map[string]string{"id": req.SegmentID},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// ListSegmentEntities evaluates a segment, listing the leads or contacts
+// currently matching its filter a page at a time, only those the user
+// making the request can see.
+func ListSegmentEntities(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]entityResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]entityResponse], *httpError) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		limit, offset, err := filters.Page(r.URL.Query())
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		segment, err := querier.GetSegment(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		filter, err := ops.SegmentFilter(r.Context(), dbc, querier, segment)
+		if err != nil {
+			return nil, commandError(err)
+		}
+		filter.VisibleTo = viewerID
+		filter.Limit, filter.Offset = pageSize(limit), offset
+
+		entities, err := querier.ListEntities(r.Context(), dbc, filter)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := make([]entityResponse, 0, len(entities))
+		for _, entity := range entities {
+			resp = append(resp, mapEntityToResponse(entity))
+		}
+		if err := attachEntityDetails(r.Context(), dbc, querier, resp); err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[[]entityResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// ExportSegment streams the leads or contacts currently in a segment in the
+// requested format (csv, ndjson or, for contacts, vcard), only those the
+// user making the request can see.
+func ExportSegment(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		viewerID, httpErr := viewer(r)
+		if httpErr != nil {
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		segment, err := querier.GetSegment(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			httpErr := commandError(err)
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = exports.FormatCSV
+		}
+		contentType, ok := exports.ContentTypes[format]
+		if !ok || format == exports.FormatICal {
+			http.Error(w, "Unsupported format", http.StatusBadRequest)
+			return
+		}
+		if format == exports.FormatVCard && segment.ObjectType != ops.ObjectTypeContact {
+			http.Error(w, "vCard export is only available for contacts", http.StatusBadRequest)
+			return
+		}
+
+		filter, err := ops.SegmentFilter(r.Context(), dbc, querier, segment)
+		if err != nil {
+			httpErr := commandError(err)
+			http.Error(w, httpErr.Message, httpErr.StatusCode)
+			return
+		}
+		filter.VisibleTo = viewerID
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf(`attachment; filename="segment-%s.%s"`, segment.ID, exports.Extensions[format]),
+		)
+
+		// Headers are already sent, so a failure can only be logged
+		if err := exports.Entities(r.Context(), w, dbc, querier, format, filter); err != nil {
+			slog.Error("Export failed", "segment", segment.ID, "error", err)
+		}
+	}
+}
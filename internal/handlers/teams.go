@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+)
+
+func CreateTeam(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[createTeamRequest, teamResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createTeamRequest) (*httpResponse[teamResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		team, err := ops.CreateTeam(r.Context(), dbc, querier, ops.CreateTeamParams(req), eventService)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[teamResponse]{
+			Data:       mapTeamToResponse(team, nil),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+// ListTeams lists every team with its members, by name.
+func ListTeams(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]teamResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]teamResponse], *httpError) {
+		teams, err := querier.ListTeams(r.Context(), dbc)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp, err := teamResponses(r.Context(), dbc, querier, teams)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[[]teamResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleTeamCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+) handlerFunc[teamCommandRequest, teamResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req teamCommandRequest) (*httpResponse[teamResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		var (
+			team db.Team
+			err  error
+		)
+		switch req.Command {
+		case "add_member":
+			team, err = ops.SetTeamMember(r.Context(), dbc, querier, req.TeamID, req.UserID, req.Role, eventService)
+		case "remove_member":
+			team, err = ops.RemoveTeamMember(r.Context(), dbc, querier, req.TeamID, req.UserID, eventService)
+		case "delete":
+			team, err = ops.DeleteTeam(r.Context(), dbc, querier, req.TeamID, eventService)
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp, err := teamResponses(r.Context(), dbc, querier, []db.Team{team})
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[teamResponse]{
+			Data:       resp[0],
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// teamResponses maps teams to responses listing their members.
+func teamResponses(ctx context.Context, dbc *sqlx.DB, querier db.Querier, teams []db.Team) ([]teamResponse, error) {
+	ids := make([]string, 0, len(teams))
+	for _, team := range teams {
+		ids = append(ids, team.ID)
+	}
+
+	members, err := querier.ListTeamMembers(ctx, dbc, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]teamResponse, 0, len(teams))
+	for _, team := range teams {
+		resp = append(resp, mapTeamToResponse(team, members))
+	}
+	return resp, nil
+}
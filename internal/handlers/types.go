@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 
 	"simplecrm/internal/db"
+	"simplecrm/internal/mail"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/reports"
 )
 
 type Validatable interface {
@@ -39,7 +45,25 @@ type getUserResponse struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
 	Email     string `json:"email"`
+	Active    bool   `json:"active"`
 	CreatedAt string `json:"created_at"`
+	// CalendarToken is only returned by rotate_calendar_token
+	CalendarToken string `json:"calendar_token,omitempty"`
+}
+
+type userCommandRequest struct {
+	Command string `json:"command" validate:"required,oneof=activate deactivate rotate_calendar_token revoke_calendar_token"`
+	UserID  string `json:"user_id" validate:"required"`
+}
+
+func (r userCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
 }
 
 func mapUserToResponse(user db.User) createUserResponse {
@@ -51,3 +75,1485 @@ func mapUserToResponse(user db.User) createUserResponse {
 		CreatedAt: user.CreatedAt,
 	}
 }
+
+type createLeadRequest struct {
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Email      string `json:"email"`
+	Phone      string `json:"phone"`
+	Status     string `json:"status"`
+	AssignedTo string `json:"assigned_to"`
+	TeamID     string `json:"team_id"`
+	Source     string `json:"source"`
+	Region     string `json:"region"`
+
+	UTMSource   string `json:"utm_source"`
+	UTMMedium   string `json:"utm_medium"`
+	UTMCampaign string `json:"utm_campaign"`
+	UTMTerm     string `json:"utm_term"`
+	UTMContent  string `json:"utm_content"`
+
+	CustomFields map[string]any `json:"custom_fields"`
+}
+
+// Validate applies the same rules as every other lead write path, such as
+// CSV imports.
+func (r createLeadRequest) Validate() validator.ValidationErrors {
+	return ops.CreateLeadParams(r).Validate()
+}
+
+type entityResponse struct {
+	ID          string `json:"id"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Email       string `json:"email"`
+	Phone       string `json:"phone"`
+	Status      string `json:"status"`
+	AssignedTo  string `json:"assigned_to,omitempty"`
+	TeamID      string `json:"team_id,omitempty"`
+	CreatedAt   string `json:"created_at"`
+	ConvertedAt string `json:"converted_at,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Score       int    `json:"score"`
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty"`
+	// CustomFields holds the record's custom field values by key
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+}
+
+func mapEntityToResponse(entity db.Entity) entityResponse {
+	return entityResponse{
+		ID:          entity.ID,
+		FirstName:   entity.FirstName,
+		LastName:    entity.LastName,
+		Email:       entity.Email,
+		Phone:       entity.Phone,
+		Status:      entity.Status,
+		AssignedTo:  entity.AssignedTo.String,
+		TeamID:      entity.TeamID.String,
+		CreatedAt:   entity.CreatedAt,
+		ConvertedAt: entity.ConvertedAt,
+		Source:      entity.Source,
+		Region:      entity.Region,
+		Score:       entity.Score,
+		UTMSource:   entity.UTMSource,
+		UTMMedium:   entity.UTMMedium,
+		UTMCampaign: entity.UTMCampaign,
+		UTMTerm:     entity.UTMTerm,
+		UTMContent:  entity.UTMContent,
+	}
+}
+
+type importJobResponse struct {
+	ID            string `json:"id"`
+	ObjectType    string `json:"object_type"`
+	Status        string `json:"status"`
+	TotalRows     int    `json:"total_rows"`
+	ProcessedRows int    `json:"processed_rows"`
+	SucceededRows int    `json:"succeeded_rows"`
+	FailedRows    int    `json:"failed_rows"`
+	Error         string `json:"error,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	StartedAt     string `json:"started_at,omitempty"`
+	FinishedAt    string `json:"finished_at,omitempty"`
+}
+
+func mapImportJobToResponse(job db.ImportJob) importJobResponse {
+	return importJobResponse{
+		ID:            job.ID,
+		ObjectType:    job.ObjectType,
+		Status:        job.Status,
+		TotalRows:     job.TotalRows,
+		ProcessedRows: job.ProcessedRows,
+		SucceededRows: job.SucceededRows,
+		FailedRows:    job.FailedRows,
+		Error:         job.Error,
+		CreatedAt:     job.CreatedAt,
+		StartedAt:     job.StartedAt.String,
+		FinishedAt:    job.FinishedAt.String,
+	}
+}
+
+type importErrorResponse struct {
+	RowNumber int               `json:"row_number"`
+	Field     string            `json:"field,omitempty"`
+	Message   string            `json:"message"`
+	Row       map[string]string `json:"row"`
+}
+
+type taskResponse struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	AssignedTo  string `json:"assigned_to,omitempty"`
+	TeamID      string `json:"team_id,omitempty"`
+	Status      string `json:"status"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	EntityID    string `json:"entity_id,omitempty"`
+	Recurrence  string `json:"recurrence,omitempty"`
+	SeriesID    string `json:"series_id,omitempty"`
+	Occurrence  int    `json:"occurrence"`
+	// Projected is set on occurrences of a recurring task that do not exist
+	// yet, which share the ID of the task they were projected from
+	Projected bool `json:"projected,omitempty"`
+	// CustomFields holds the task's custom field values by key
+	CustomFields map[string]any `json:"custom_fields,omitempty"`
+}
+
+func mapTaskToResponse(task db.Task) taskResponse {
+	return taskResponse{
+		ID:          task.ID,
+		Name:        task.Name,
+		Description: task.Description,
+		DueDate:     task.DueDate,
+		AssignedTo:  task.AssignedTo.String,
+		TeamID:      task.TeamID.String,
+		Status:      task.Status,
+		CompletedAt: task.CompletedAt,
+		EntityID:    task.EntityID.String,
+		Recurrence:  task.Recurrence,
+		SeriesID:    task.SeriesID,
+		Occurrence:  task.Occurrence,
+	}
+}
+
+func mapTaskOccurrenceToResponse(occurrence ops.TaskOccurrence) taskResponse {
+	resp := mapTaskToResponse(occurrence.Task)
+	resp.DueDate = occurrence.DueDate
+	resp.Occurrence = occurrence.Occurrence
+	resp.Projected = occurrence.Projected
+	if occurrence.Projected {
+		resp.Status = ops.TaskStatusOpen
+		resp.CompletedAt = ""
+	}
+	return resp
+}
+
+type createTaskRequest struct {
+	Name        string `json:"name"        validate:"required"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	AssignedTo  string `json:"assigned_to"`
+	TeamID      string `json:"team_id"`
+	EntityID    string `json:"entity_id"`
+	Recurrence  string `json:"recurrence"`
+
+	CustomFields map[string]any `json:"custom_fields"`
+}
+
+func (r createTaskRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type taskCommandRequest struct {
+	Command      string         `json:"command"       validate:"required,oneof=complete set_custom_fields assign_team"`
+	TaskID       string         `json:"task_id"       validate:"required"`
+	CustomFields map[string]any `json:"custom_fields" validate:"required_if=Command set_custom_fields"`
+	// TeamID is the team assign_team hands the task to, none if empty
+	TeamID string `json:"team_id"`
+}
+
+func (r taskCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type taskCommandResponse struct {
+	Task taskResponse `json:"task"`
+	// Next is the occurrence created by completing a recurring task
+	Next *taskResponse `json:"next,omitempty"`
+}
+
+type entityCommandRequest struct {
+	Command      string            `json:"command"       validate:"required,oneof=merge assign assign_team change_status set_custom_fields add_tags remove_tags"`
+	SurvivorID   string            `json:"survivor_id"   validate:"required_if=Command merge"`
+	DuplicateID  string            `json:"duplicate_id"  validate:"required_if=Command merge"`
+	Fields       map[string]string `json:"fields"`
+	EntityID     string            `json:"entity_id"     validate:"required_unless=Command merge"`
+	UserID       string            `json:"user_id"`
+	TeamID       string            `json:"team_id"`
+	Status       string            `json:"status"        validate:"required_if=Command change_status"`
+	CustomFields map[string]any    `json:"custom_fields" validate:"required_if=Command set_custom_fields"`
+	Tags         []string          `json:"tags"          validate:"required_if=Command add_tags,required_if=Command remove_tags"`
+}
+
+func (r entityCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type duplicateResponse struct {
+	Score   float64        `json:"score"`
+	Reasons []string       `json:"reasons"`
+	A       entityResponse `json:"a"`
+	B       entityResponse `json:"b"`
+}
+
+type entityHistoryResponse struct {
+	ID        int64          `json:"id"`
+	Action    string         `json:"action"`
+	Details   map[string]any `json:"details"`
+	CreatedAt string         `json:"created_at"`
+}
+
+type assignmentRuleMemberRequest struct {
+	UserID string `json:"user_id" validate:"required"`
+	Weight int    `json:"weight"  validate:"gte=0"`
+}
+
+type createAssignmentRuleRequest struct {
+	Name     string                        `json:"name"     validate:"required"`
+	Priority int                           `json:"priority"`
+	Field    string                        `json:"field"    validate:"omitempty,oneof=email_domain source region status"`
+	Value    string                        `json:"value"    validate:"required_with=Field"`
+	Strategy string                        `json:"strategy" validate:"required,oneof=round_robin weighted"`
+	Members  []assignmentRuleMemberRequest `json:"members"  validate:"required,min=1,dive"`
+}
+
+func (r createAssignmentRuleRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type assignmentCommandRequest struct {
+	Command string `json:"command" validate:"required,oneof=delete"`
+	RuleID  string `json:"rule_id" validate:"required"`
+}
+
+func (r assignmentCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type assignmentRuleMemberResponse struct {
+	UserID string `json:"user_id"`
+	Weight int    `json:"weight"`
+	Active bool   `json:"active"`
+}
+
+type assignmentRuleResponse struct {
+	ID        string                         `json:"id"`
+	Name      string                         `json:"name"`
+	Priority  int                            `json:"priority"`
+	Field     string                         `json:"field,omitempty"`
+	Value     string                         `json:"value,omitempty"`
+	Strategy  string                         `json:"strategy"`
+	Members   []assignmentRuleMemberResponse `json:"members"`
+	CreatedAt string                         `json:"created_at"`
+}
+
+func mapAssignmentRuleToResponse(rule db.AssignmentRule, members []db.AssignmentRuleMember) assignmentRuleResponse {
+	resp := assignmentRuleResponse{
+		ID:        rule.ID,
+		Name:      rule.Name,
+		Priority:  rule.Priority,
+		Field:     rule.Field,
+		Value:     rule.Value,
+		Strategy:  rule.Strategy,
+		Members:   []assignmentRuleMemberResponse{},
+		CreatedAt: rule.CreatedAt,
+	}
+	for _, m := range members {
+		resp.Members = append(resp.Members, assignmentRuleMemberResponse{
+			UserID: m.UserID,
+			Weight: m.Weight,
+			Active: m.Active,
+		})
+	}
+	return resp
+}
+
+type logActivityRequest struct {
+	EntityID   string `json:"entity_id"   validate:"required"`
+	Kind       string `json:"kind"        validate:"required,oneof=call meeting email note"`
+	UserID     string `json:"user_id"`
+	Notes      string `json:"notes"`
+	OccurredAt string `json:"occurred_at"`
+}
+
+func (r logActivityRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type activityResponse struct {
+	ID         string `json:"id"`
+	EntityID   string `json:"entity_id"`
+	Kind       string `json:"kind"`
+	UserID     string `json:"user_id,omitempty"`
+	Notes      string `json:"notes"`
+	OccurredAt string `json:"occurred_at"`
+	CreatedAt  string `json:"created_at"`
+	EmailID    string `json:"email_id,omitempty"`
+}
+
+func mapActivityToResponse(activity db.Activity) activityResponse {
+	return activityResponse{
+		ID:         activity.ID,
+		EntityID:   activity.EntityID,
+		Kind:       activity.Kind,
+		UserID:     activity.UserID.String,
+		Notes:      activity.Notes,
+		OccurredAt: activity.OccurredAt,
+		CreatedAt:  activity.CreatedAt,
+		EmailID:    activity.EmailID,
+	}
+}
+
+type createScoringRuleRequest struct {
+	Name         string `json:"name"           validate:"required"`
+	Kind         string `json:"kind"           validate:"required,oneof=attribute activity"`
+	Field        string `json:"field"          validate:"required_if=Kind attribute"`
+	Value        string `json:"value"`
+	Points       int    `json:"points"         validate:"required"`
+	HalfLifeDays int    `json:"half_life_days" validate:"gte=0"`
+}
+
+func (r createScoringRuleRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type scoringCommandRequest struct {
+	Command string `json:"command" validate:"required,oneof=delete"`
+	RuleID  string `json:"rule_id" validate:"required"`
+}
+
+func (r scoringCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type scoringRuleResponse struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Kind         string `json:"kind"`
+	Field        string `json:"field,omitempty"`
+	Value        string `json:"value,omitempty"`
+	Points       int    `json:"points"`
+	HalfLifeDays int    `json:"half_life_days"`
+	CreatedAt    string `json:"created_at"`
+}
+
+func mapScoringRuleToResponse(rule db.ScoringRule) scoringRuleResponse {
+	return scoringRuleResponse{
+		ID:           rule.ID,
+		Name:         rule.Name,
+		Kind:         rule.Kind,
+		Field:        rule.Field,
+		Value:        rule.Value,
+		Points:       rule.Points,
+		HalfLifeDays: rule.HalfLifeDays,
+		CreatedAt:    rule.CreatedAt,
+	}
+}
+
+type scoreItemResponse struct {
+	RuleID       string  `json:"rule_id"`
+	Name         string  `json:"name"`
+	Kind         string  `json:"kind"`
+	Field        string  `json:"field,omitempty"`
+	Points       int     `json:"points"`
+	Matches      int     `json:"matches"`
+	Contribution float64 `json:"contribution"`
+}
+
+type scoreResponse struct {
+	EntityID string `json:"entity_id"`
+	// Score is computed now, StoredScore is what listings currently sort by
+	Score       int                 `json:"score"`
+	StoredScore int                 `json:"stored_score"`
+	ScoredAt    string              `json:"scored_at,omitempty"`
+	ComputedAt  string              `json:"computed_at"`
+	Items       []scoreItemResponse `json:"items"`
+}
+
+type workflowActionRequest struct {
+	Type   string            `json:"type"   validate:"required"`
+	Params map[string]string `json:"params"`
+}
+
+type createWorkflowRequest struct {
+	Name      string                  `json:"name"      validate:"required"`
+	Trigger   string                  `json:"trigger"   validate:"required"`
+	Condition string                  `json:"condition"`
+	Actions   []workflowActionRequest `json:"actions"   validate:"required,min=1,dive"`
+}
+
+func (r createWorkflowRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type workflowCommandRequest struct {
+	Command    string `json:"command"     validate:"required,oneof=enable disable delete"`
+	WorkflowID string `json:"workflow_id" validate:"required"`
+}
+
+func (r workflowCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type workflowResponse struct {
+	ID        string                  `json:"id"`
+	Name      string                  `json:"name"`
+	Trigger   string                  `json:"trigger"`
+	Condition string                  `json:"condition,omitempty"`
+	Actions   []workflowActionRequest `json:"actions"`
+	Enabled   bool                    `json:"enabled"`
+	CreatedAt string                  `json:"created_at"`
+}
+
+func mapWorkflowToResponse(workflow db.Workflow) workflowResponse {
+	resp := workflowResponse{
+		ID:        workflow.ID,
+		Name:      workflow.Name,
+		Trigger:   workflow.Trigger,
+		Condition: workflow.Condition,
+		Actions:   []workflowActionRequest{},
+		Enabled:   workflow.Enabled,
+		CreatedAt: workflow.CreatedAt,
+	}
+	// Actions were validated when stored
+	_ = json.Unmarshal([]byte(workflow.Actions), &resp.Actions)
+	return resp
+}
+
+type workflowRunResponse struct {
+	ID         int64  `json:"id"`
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	Log        []any  `json:"log"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+}
+
+func mapWorkflowRunToResponse(run db.WorkflowRun) workflowRunResponse {
+	resp := workflowRunResponse{
+		ID:         run.ID,
+		EventID:    run.EventID,
+		EventType:  run.EventType,
+		Status:     run.Status,
+		Error:      run.Error,
+		Log:        []any{},
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+	}
+	_ = json.Unmarshal([]byte(run.Log), &resp.Log)
+	return resp
+}
+
+type notificationResponse struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	Kind      string `json:"kind"`
+	Title     string `json:"title"`
+	Body      string `json:"body,omitempty"`
+	EntityID  string `json:"entity_id,omitempty"`
+	TaskID    string `json:"task_id,omitempty"`
+	Read      bool   `json:"read"`
+	ReadAt    string `json:"read_at,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+func mapNotificationToResponse(notification db.Notification) notificationResponse {
+	return notificationResponse{
+		ID:        notification.ID,
+		UserID:    notification.UserID,
+		Kind:      notification.Kind,
+		Title:     notification.Title,
+		Body:      notification.Body,
+		EntityID:  notification.EntityID,
+		TaskID:    notification.TaskID,
+		Read:      notification.ReadAt != "",
+		ReadAt:    notification.ReadAt,
+		CreatedAt: notification.CreatedAt,
+	}
+}
+
+type notificationCommandRequest struct {
+	Command string `json:"command"         validate:"required,oneof=mark_read mark_all_read"`
+	// UserID is optional and must be the user making the request
+	UserID         string `json:"user_id"`
+	NotificationID string `json:"notification_id" validate:"required_if=Command mark_read"`
+}
+
+func (r notificationCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type notificationCommandResponse struct {
+	// Updated counts the notifications that were unread until now
+	Updated int64 `json:"updated"`
+	Unread  int   `json:"unread"`
+}
+
+type outboxEmailResponse struct {
+	ID            string   `json:"id"`
+	From          string   `json:"from"`
+	To            []string `json:"to"`
+	Subject       string   `json:"subject"`
+	Status        string   `json:"status"`
+	Attempts      int      `json:"attempts"`
+	LastError     string   `json:"last_error,omitempty"`
+	NextAttemptAt string   `json:"next_attempt_at,omitempty"`
+	CreatedAt     string   `json:"created_at"`
+	SentAt        string   `json:"sent_at,omitempty"`
+}
+
+func mapOutboxEmailToResponse(email db.OutboxEmail) outboxEmailResponse {
+	resp := outboxEmailResponse{
+		ID:        email.ID,
+		From:      email.FromAddress,
+		To:        strings.Split(email.ToAddresses, ","),
+		Subject:   email.Subject,
+		Status:    email.Status,
+		Attempts:  email.Attempts,
+		LastError: email.LastError,
+		CreatedAt: email.CreatedAt,
+		SentAt:    email.SentAt,
+	}
+	if email.Status == mail.StatusPending {
+		resp.NextAttemptAt = email.NextAttemptAt
+	}
+	return resp
+}
+
+type emailCommandRequest struct {
+	Command string `json:"command"  validate:"required,oneof=retry"`
+	EmailID string `json:"email_id" validate:"required"`
+}
+
+func (r emailCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type ingestEmailsResponse struct {
+	Ingested   int                     `json:"ingested"`
+	Duplicates int                     `json:"duplicates"`
+	Failed     int                     `json:"failed"`
+	Emails     []ingestedEmailResponse `json:"emails"`
+}
+
+// ingestedEmailResponse reports the outcome for one message, in the order
+// they appeared in the upload.
+type ingestedEmailResponse struct {
+	ID        string   `json:"id,omitempty"`
+	MessageID string   `json:"message_id,omitempty"`
+	Subject   string   `json:"subject,omitempty"`
+	Duplicate bool     `json:"duplicate,omitempty"`
+	EntityIDs []string `json:"entity_ids,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type inboundEmailResponse struct {
+	ID          string                      `json:"id"`
+	MessageID   string                      `json:"message_id"`
+	From        string                      `json:"from"`
+	To          []string                    `json:"to"`
+	Cc          []string                    `json:"cc"`
+	Subject     string                      `json:"subject"`
+	Body        string                      `json:"body"`
+	SentAt      string                      `json:"sent_at"`
+	CreatedAt   string                      `json:"created_at"`
+	Attachments []inboundAttachmentResponse `json:"attachments"`
+}
+
+type inboundAttachmentResponse struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+func mapInboundEmailToResponse(email db.InboundEmail, attachments []db.InboundEmailAttachment) inboundEmailResponse {
+	resp := inboundEmailResponse{
+		ID:          email.ID,
+		MessageID:   email.MessageID,
+		From:        email.FromAddress,
+		To:          splitAddresses(email.ToAddresses),
+		Cc:          splitAddresses(email.CcAddresses),
+		Subject:     email.Subject,
+		Body:        email.Body,
+		SentAt:      email.SentAt,
+		CreatedAt:   email.CreatedAt,
+		Attachments: make([]inboundAttachmentResponse, 0, len(attachments)),
+	}
+	for _, attachment := range attachments {
+		resp.Attachments = append(resp.Attachments, inboundAttachmentResponse{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Size:        attachment.Size,
+		})
+	}
+	return resp
+}
+
+func splitAddresses(addresses string) []string {
+	if addresses == "" {
+		return []string{}
+	}
+	return strings.Split(addresses, ",")
+}
+
+type createLeadFormRequest struct {
+	Name        string            `json:"name"         validate:"required"`
+	Mapping     map[string]string `json:"mapping"`
+	Honeypot    string            `json:"honeypot"`
+	RedirectURL string            `json:"redirect_url"`
+	Source      string            `json:"source"`
+}
+
+func (r createLeadFormRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type leadFormCommandRequest struct {
+	Command string `json:"command" validate:"required,oneof=enable disable rotate_key delete"`
+	FormID  string `json:"form_id" validate:"required"`
+}
+
+func (r leadFormCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type leadFormResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// SubmitPath is where the form posts its submissions
+	SubmitPath  string            `json:"submit_path"`
+	Mapping     map[string]string `json:"mapping"`
+	Honeypot    string            `json:"honeypot,omitempty"`
+	RedirectURL string            `json:"redirect_url,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	CreatedAt   string            `json:"created_at"`
+}
+
+func mapLeadFormToResponse(form db.LeadForm) leadFormResponse {
+	resp := leadFormResponse{
+		ID:          form.ID,
+		Name:        form.Name,
+		Key:         form.Key,
+		SubmitPath:  "/api/v1/forms/" + form.Key + "/submit",
+		Mapping:     map[string]string{},
+		Honeypot:    form.Honeypot,
+		RedirectURL: form.RedirectURL,
+		Source:      form.Source,
+		Enabled:     form.Enabled,
+		CreatedAt:   form.CreatedAt,
+	}
+	_ = json.Unmarshal([]byte(form.Mapping), &resp.Mapping)
+	return resp
+}
+
+type createCustomFieldRequest struct {
+	ObjectType string   `json:"object_type" validate:"required"`
+	Key        string   `json:"key"         validate:"required"`
+	Label      string   `json:"label"`
+	Type       string   `json:"type"        validate:"required"`
+	Options    []string `json:"options"`
+}
+
+func (r createCustomFieldRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type customFieldCommandRequest struct {
+	Command string `json:"command"  validate:"required,oneof=delete"`
+	FieldID string `json:"field_id" validate:"required"`
+}
+
+func (r customFieldCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type customFieldResponse struct {
+	ID         string   `json:"id"`
+	ObjectType string   `json:"object_type"`
+	Key        string   `json:"key"`
+	Label      string   `json:"label"`
+	Type       string   `json:"type"`
+	Options    []string `json:"options,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+func mapCustomFieldToResponse(field db.CustomField) customFieldResponse {
+	resp := customFieldResponse{
+		ID:         field.ID,
+		ObjectType: field.ObjectType,
+		Key:        field.Key,
+		Label:      field.Label,
+		Type:       field.Type,
+		CreatedAt:  field.CreatedAt,
+	}
+	// Options are validated when the field is created
+	json.Unmarshal([]byte(field.Options), &resp.Options)
+	return resp
+}
+
+type tagResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Entities is the number of leads and contacts carrying the tag
+	Entities  int    `json:"entities"`
+	CreatedAt string `json:"created_at"`
+}
+
+type tagCommandRequest struct {
+	Command string `json:"command" validate:"required,oneof=delete"`
+	TagID   string `json:"tag_id"  validate:"required"`
+}
+
+func (r tagCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type createSegmentRequest struct {
+	Name       string `json:"name"        validate:"required"`
+	ObjectType string `json:"object_type" validate:"required"`
+	Filter     string `json:"filter"`
+}
+
+func (r createSegmentRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type segmentCommandRequest struct {
+	Command   string `json:"command"    validate:"required,oneof=delete"`
+	SegmentID string `json:"segment_id" validate:"required"`
+}
+
+func (r segmentCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type segmentResponse struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ObjectType string `json:"object_type"`
+	Filter     string `json:"filter"`
+	CreatedAt  string `json:"created_at"`
+}
+
+func mapSegmentToResponse(segment db.Segment) segmentResponse {
+	return segmentResponse{
+		ID:         segment.ID,
+		Name:       segment.Name,
+		ObjectType: segment.ObjectType,
+		Filter:     segment.Filter,
+		CreatedAt:  segment.CreatedAt,
+	}
+}
+
+type createSavedViewRequest struct {
+	OwnerID  string   `json:"owner_id" validate:"required"`
+	Name     string   `json:"name"     validate:"required"`
+	Resource string   `json:"resource" validate:"required"`
+	Filter   string   `json:"filter"`
+	Sort     string   `json:"sort"`
+	Columns  []string `json:"columns"`
+	Shared   bool     `json:"shared"`
+	TeamID   string   `json:"team_id"`
+}
+
+func (r createSavedViewRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type savedViewCommandRequest struct {
+	Command string `json:"command"  validate:"required,oneof=delete set_default clear_default"`
+	// UserID is optional and must be the user making the request
+	UserID string `json:"user_id"`
+	ViewID string `json:"view_id"  validate:"required_unless=Command clear_default"`
+	// Resource is the list whose default clear_default removes
+	Resource string `json:"resource" validate:"required_if=Command clear_default"`
+}
+
+func (r savedViewCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type savedViewResponse struct {
+	ID       string   `json:"id"`
+	OwnerID  string   `json:"owner_id"`
+	Name     string   `json:"name"`
+	Resource string   `json:"resource"`
+	Filter   string   `json:"filter"`
+	Sort     string   `json:"sort,omitempty"`
+	Columns  []string `json:"columns"`
+	Shared   bool     `json:"shared"`
+	TeamID   string   `json:"team_id,omitempty"`
+	// Default is set on the view the requesting user opens the list with
+	Default   bool   `json:"default"`
+	CreatedAt string `json:"created_at"`
+}
+
+func mapSavedViewToResponse(view db.SavedView) savedViewResponse {
+	resp := savedViewResponse{
+		ID:        view.ID,
+		OwnerID:   view.OwnerID,
+		Name:      view.Name,
+		Resource:  view.Resource,
+		Filter:    view.Filter,
+		Sort:      view.Sort,
+		Columns:   []string{},
+		Shared:    view.Shared,
+		TeamID:    view.TeamID.String,
+		CreatedAt: view.CreatedAt,
+	}
+	// Columns are validated when the view is created
+	json.Unmarshal([]byte(view.Columns), &resp.Columns)
+	return resp
+}
+
+// savedViewResultResponse is a page of the records a saved view lists,
+// reduced to its columns.
+type savedViewResultResponse struct {
+	View    savedViewResponse `json:"view"`
+	Columns []string          `json:"columns"`
+	Rows    []map[string]any  `json:"rows"`
+}
+
+type createTeamRequest struct {
+	Name     string `json:"name"      validate:"required"`
+	ParentID string `json:"parent_id"`
+}
+
+func (r createTeamRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type teamCommandRequest struct {
+	Command string `json:"command" validate:"required,oneof=add_member remove_member delete"`
+	TeamID  string `json:"team_id" validate:"required"`
+	UserID  string `json:"user_id" validate:"required_unless=Command delete"`
+	// Role is member, the default, or manager
+	Role string `json:"role"`
+}
+
+func (r teamCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type teamMemberResponse struct {
+	UserID    string `json:"user_id"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+type teamResponse struct {
+	ID        string               `json:"id"`
+	Name      string               `json:"name"`
+	ParentID  string               `json:"parent_id,omitempty"`
+	Members   []teamMemberResponse `json:"members"`
+	CreatedAt string               `json:"created_at"`
+}
+
+func mapTeamToResponse(team db.Team, members []db.TeamMember) teamResponse {
+	resp := teamResponse{
+		ID:        team.ID,
+		Name:      team.Name,
+		ParentID:  team.ParentID.String,
+		Members:   []teamMemberResponse{},
+		CreatedAt: team.CreatedAt,
+	}
+	for _, member := range members {
+		if member.TeamID == team.ID {
+			resp.Members = append(resp.Members, teamMemberResponse{
+				UserID:    member.UserID,
+				Role:      member.Role,
+				CreatedAt: member.CreatedAt,
+			})
+		}
+	}
+	return resp
+}
+
+type attachmentCommandRequest struct {
+	Command      string `json:"command"       validate:"required,oneof=delete"`
+	AttachmentID string `json:"attachment_id" validate:"required"`
+}
+
+func (r attachmentCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type attachmentResponse struct {
+	ID          string `json:"id"`
+	EntityID    string `json:"entity_id,omitempty"`
+	TaskID      string `json:"task_id,omitempty"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	UploadedBy  string `json:"uploaded_by,omitempty"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func mapAttachmentToResponse(attachment db.Attachment) attachmentResponse {
+	return attachmentResponse{
+		ID:          attachment.ID,
+		EntityID:    attachment.EntityID,
+		TaskID:      attachment.TaskID,
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		UploadedBy:  attachment.UploadedBy,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
+
+type leadReportResponse struct {
+	Period string `json:"period"`
+	Group  string `json:"group"`
+	Count  int    `json:"count"`
+}
+
+func mapEntityCountToResponse(count db.EntityCount) leadReportResponse {
+	return leadReportResponse{
+		Period: count.Period,
+		Group:  count.Group,
+		Count:  count.Count,
+	}
+}
+
+type conversionReportResponse struct {
+	Period    string `json:"period"`
+	Group     string `json:"group"`
+	Created   int    `json:"created"`
+	Converted int    `json:"converted"`
+	// ConversionRate is the share of the leads created that converted
+	ConversionRate      float64  `json:"conversion_rate"`
+	MedianDaysToConvert *float64 `json:"median_days_to_convert"`
+}
+
+func mapConversionStatsToResponse(stats db.ConversionStats) conversionReportResponse {
+	row := conversionReportResponse{
+		Period:    stats.Period,
+		Group:     stats.Group,
+		Created:   stats.Created,
+		Converted: stats.Converted,
+	}
+	if stats.Created > 0 {
+		row.ConversionRate = float64(stats.Converted) / float64(stats.Created)
+	}
+	if stats.MedianDaysToConvert.Valid {
+		row.MedianDaysToConvert = &stats.MedianDaysToConvert.Float64
+	}
+	return row
+}
+
+type taskReportResponse struct {
+	Period    string `json:"period"`
+	Group     string `json:"group"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+	Open      int    `json:"open"`
+	Overdue   int    `json:"overdue"`
+}
+
+func mapTaskStatsToResponse(stats db.TaskStats) taskReportResponse {
+	return taskReportResponse{
+		Period:    stats.Period,
+		Group:     stats.Group,
+		Total:     stats.Total,
+		Completed: stats.Completed,
+		Open:      stats.Open,
+		Overdue:   stats.Overdue,
+	}
+}
+
+type quoteReportResponse struct {
+	Period        string `json:"period"`
+	Group         string `json:"group"`
+	Currency      string `json:"currency"`
+	Count         int    `json:"count"`
+	Value         int64  `json:"value"`
+	DeclinedValue int64  `json:"declined_value"`
+	Unconverted   int    `json:"unconverted"`
+}
+
+func mapQuoteRowToResponse(row reports.QuoteRow) quoteReportResponse {
+	return quoteReportResponse(row)
+}
+
+type createScheduledReportRequest struct {
+	Name       string   `json:"name"       validate:"required"`
+	Metrics    []string `json:"metrics"    validate:"required,min=1"`
+	Recipients []string `json:"recipients" validate:"dive,email"`
+	Schedule   string   `json:"schedule"   validate:"required"`
+	Timezone   string   `json:"timezone"`
+	Period     string   `json:"period"     validate:"omitempty,oneof=day week month"`
+	Filter     string   `json:"filter"`
+}
+
+func (r createScheduledReportRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type scheduledReportCommandRequest struct {
+	Command  string `json:"command"   validate:"required,oneof=run delete"`
+	ReportID string `json:"report_id" validate:"required"`
+}
+
+func (r scheduledReportCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type scheduledReportResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Metrics    []string `json:"metrics"`
+	Recipients []string `json:"recipients"`
+	Schedule   string   `json:"schedule"`
+	Timezone   string   `json:"timezone"`
+	Period     string   `json:"period"`
+	Filter     string   `json:"filter"`
+	NextRunAt  string   `json:"next_run_at"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+func mapScheduledReportToResponse(report db.ScheduledReport) scheduledReportResponse {
+	resp := scheduledReportResponse{
+		ID:         report.ID,
+		Name:       report.Name,
+		Metrics:    []string{},
+		Recipients: []string{},
+		Schedule:   report.Schedule,
+		Timezone:   report.Timezone,
+		Period:     report.Period,
+		Filter:     report.Filter,
+		NextRunAt:  report.NextRunAt,
+		CreatedAt:  report.CreatedAt,
+	}
+	// Both are validated when the report is created
+	json.Unmarshal([]byte(report.Metrics), &resp.Metrics)
+	json.Unmarshal([]byte(report.Recipients), &resp.Recipients)
+	return resp
+}
+
+type reportSnapshotResponse struct {
+	ID        string `json:"id"`
+	ReportID  string `json:"report_id"`
+	RangeFrom string `json:"range_from"`
+	RangeTo   string `json:"range_to"`
+	// Result holds the rows of each metric, as the report endpoints return
+	// them
+	Result json.RawMessage `json:"result"`
+	RanAt  string          `json:"ran_at"`
+}
+
+func mapReportSnapshotToResponse(snapshot db.ReportSnapshot) reportSnapshotResponse {
+	return reportSnapshotResponse{
+		ID:        snapshot.ID,
+		ReportID:  snapshot.ReportID,
+		RangeFrom: snapshot.RangeFrom,
+		RangeTo:   snapshot.RangeTo,
+		Result:    json.RawMessage(snapshot.Result),
+		RanAt:     snapshot.RanAt,
+	}
+}
+
+type createProductRequest struct {
+	SKU         string `json:"sku"  validate:"required"`
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	// Prices maps currency codes to unit prices in each currency's minor unit
+	Prices map[string]int64 `json:"prices"`
+}
+
+func (r createProductRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type productCommandRequest struct {
+	Command   string `json:"command"    validate:"required,oneof=set_price remove_price set_active"`
+	ProductID string `json:"product_id" validate:"required"`
+	Currency  string `json:"currency"   validate:"required_unless=Command set_active"`
+	UnitPrice *int64 `json:"unit_price" validate:"required_if=Command set_price"`
+	Active    *bool  `json:"active"     validate:"required_if=Command set_active"`
+}
+
+func (r productCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type productResponse struct {
+	ID          string `json:"id"`
+	SKU         string `json:"sku"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Active      bool   `json:"active"`
+	// Prices maps currency codes to unit prices in each currency's minor unit
+	Prices    map[string]int64 `json:"prices"`
+	CreatedAt string           `json:"created_at"`
+}
+
+func mapProductToResponse(product db.Product, prices []db.ProductPrice) productResponse {
+	resp := productResponse{
+		ID:          product.ID,
+		SKU:         product.SKU,
+		Name:        product.Name,
+		Description: product.Description,
+		Active:      product.Active,
+		Prices:      map[string]int64{},
+		CreatedAt:   product.CreatedAt,
+	}
+	for _, price := range prices {
+		if price.ProductID == product.ID {
+			resp.Prices[price.Currency] = price.UnitPrice
+		}
+	}
+	return resp
+}
+
+type createQuoteRequest struct {
+	EntityID string `json:"entity_id" validate:"required"`
+	Title    string `json:"title"     validate:"required"`
+	Currency string `json:"currency"  validate:"required"`
+	// TaxRate is a percentage, such as 8.25
+	TaxRate    float64 `json:"tax_rate"`
+	ValidUntil string  `json:"valid_until"`
+	Notes      string  `json:"notes"`
+}
+
+func (r createQuoteRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type quoteCommandRequest struct {
+	Command string `json:"command"  validate:"required,oneof=update add_line update_line remove_line set_status"`
+	QuoteID string `json:"quote_id" validate:"required"`
+	// Title, TaxRate, ValidUntil and Notes replace the quote's on update
+	Title      string  `json:"title"       validate:"required_if=Command update"`
+	TaxRate    float64 `json:"tax_rate"`
+	ValidUntil string  `json:"valid_until"`
+	Notes      string  `json:"notes"`
+	// LineID is required by update_line and remove_line
+	LineID    string `json:"line_id"`
+	ProductID string `json:"product_id"`
+	// Description and UnitPrice default to the product's on add_line
+	Description string `json:"description"`
+	UnitPrice   *int64 `json:"unit_price" validate:"required_if=Command update_line"`
+	Quantity    int64  `json:"quantity"`
+	// Discount is a percentage of the line's price
+	Discount float64 `json:"discount"`
+	Status   string  `json:"status"     validate:"required_if=Command set_status"`
+}
+
+func (r quoteCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+// Amounts in quote responses are in the minor unit of the quote's currency
+// and rates are percentages.
+
+type quoteLineResponse struct {
+	ID             string  `json:"id"`
+	Position       int     `json:"position"`
+	ProductID      string  `json:"product_id,omitempty"`
+	SKU            string  `json:"sku"`
+	Description    string  `json:"description"`
+	Quantity       int64   `json:"quantity"`
+	UnitPrice      int64   `json:"unit_price"`
+	Discount       float64 `json:"discount"`
+	Gross          int64   `json:"gross"`
+	DiscountAmount int64   `json:"discount_amount"`
+	Net            int64   `json:"net"`
+}
+
+type quoteResponse struct {
+	ID         string              `json:"id"`
+	Number     int64               `json:"number"`
+	EntityID   string              `json:"entity_id"`
+	Title      string              `json:"title"`
+	Currency   string              `json:"currency"`
+	Status     string              `json:"status"`
+	TaxRate    float64             `json:"tax_rate"`
+	ValidUntil string              `json:"valid_until,omitempty"`
+	Notes      string              `json:"notes"`
+	Lines      []quoteLineResponse `json:"lines"`
+	Subtotal   int64               `json:"subtotal"`
+	Tax        int64               `json:"tax"`
+	Total      int64               `json:"total"`
+	CreatedBy  string              `json:"created_by,omitempty"`
+	CreatedAt  string              `json:"created_at"`
+	UpdatedAt  string              `json:"updated_at"`
+	SentAt     string              `json:"sent_at,omitempty"`
+	DecidedAt  string              `json:"decided_at,omitempty"`
+}
+
+func mapQuoteToResponse(quote ops.PricedQuote) quoteResponse {
+	resp := quoteResponse{
+		ID:         quote.ID,
+		Number:     quote.Number,
+		EntityID:   quote.EntityID,
+		Title:      quote.Title,
+		Currency:   quote.Currency,
+		Status:     quote.Status,
+		TaxRate:    float64(quote.TaxRate) / 100,
+		ValidUntil: quote.ValidUntil,
+		Notes:      quote.Notes,
+		Lines:      make([]quoteLineResponse, 0, len(quote.Lines)),
+		Subtotal:   quote.Subtotal,
+		Tax:        quote.Tax,
+		Total:      quote.Total,
+		CreatedBy:  quote.CreatedBy,
+		CreatedAt:  quote.CreatedAt,
+		UpdatedAt:  quote.UpdatedAt,
+		SentAt:     quote.SentAt,
+		DecidedAt:  quote.DecidedAt,
+	}
+	for _, line := range quote.Lines {
+		resp.Lines = append(resp.Lines, quoteLineResponse{
+			ID:             line.ID,
+			Position:       line.Position,
+			ProductID:      line.ProductID.String,
+			SKU:            line.SKU,
+			Description:    line.Description,
+			Quantity:       line.Quantity,
+			UnitPrice:      line.UnitPrice,
+			Discount:       float64(line.Discount) / 100,
+			Gross:          line.Gross,
+			DiscountAmount: line.DiscountAmount,
+			Net:            line.Net,
+		})
+	}
+	return resp
+}
+
+type exchangeRateRequest struct {
+	From        string `json:"from"         validate:"required"`
+	To          string `json:"to"           validate:"required"`
+	Rate        string `json:"rate"         validate:"required"`
+	EffectiveOn string `json:"effective_on" validate:"required"`
+}
+
+type createExchangeRatesRequest struct {
+	Rates []exchangeRateRequest `json:"rates" validate:"required,min=1,dive"`
+}
+
+func (r createExchangeRatesRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type exchangeRateCommandRequest struct {
+	Command     string `json:"command"      validate:"required,oneof=delete"`
+	From        string `json:"from"         validate:"required"`
+	To          string `json:"to"           validate:"required"`
+	EffectiveOn string `json:"effective_on" validate:"required"`
+}
+
+func (r exchangeRateCommandRequest) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(r)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return err.(validator.ValidationErrors)
+	}
+	return nil
+}
+
+type exchangeRateResponse struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Rate        string `json:"rate"`
+	EffectiveOn string `json:"effective_on"`
+	CreatedAt   string `json:"created_at"`
+}
+
+func mapExchangeRateToResponse(rate db.ExchangeRate) exchangeRateResponse {
+	return exchangeRateResponse{
+		From:        rate.FromCurrency,
+		To:          rate.ToCurrency,
+		Rate:        rate.Rate,
+		EffectiveOn: rate.EffectiveOn,
+		CreatedAt:   rate.CreatedAt,
+	}
+}
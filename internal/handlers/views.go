@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+	"simplecrm/internal/ops"
+)
+
+func CreateSavedView(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createSavedViewRequest, savedViewResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createSavedViewRequest) (*httpResponse[savedViewResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		view, err := ops.CreateSavedView(r.Context(), dbc, querier, ops.CreateSavedViewParams(req))
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[savedViewResponse]{
+			Data:       mapSavedViewToResponse(view),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+// ListSavedViews lists the views the user making the request owns or can
+// see because they are shared with everyone or one of their teams,
+// optionally only those of one resource, marking the user's defaults.
+func ListSavedViews(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]savedViewResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]savedViewResponse], *httpError) {
+		q := r.URL.Query()
+		userID, httpErr := ownUser(r, q.Get("user_id"))
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		views, err := querier.ListSavedViews(r.Context(), dbc, userID, q.Get("resource"))
+		if err != nil {
+			return nil, commandError(err)
+		}
+		preferences, err := querier.ListListPreferences(r.Context(), dbc, userID)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		resp := make([]savedViewResponse, 0, len(views))
+		for _, view := range views {
+			v := mapSavedViewToResponse(view)
+			v.Default = slices.ContainsFunc(preferences, func(p db.ListPreference) bool { return p.ViewID == view.ID })
+			resp = append(resp, v)
+		}
+
+		return &httpResponse[[]savedViewResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleSavedViewCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[savedViewCommandRequest, map[string]string] {
+	return func(w http.ResponseWriter, r *http.Request, req savedViewCommandRequest) (*httpResponse[map[string]string], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		userID, httpErr := ownUser(r, req.UserID)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		var err error
+		switch req.Command {
+		case "delete":
+			err = ops.DeleteSavedView(r.Context(), dbc, querier, userID, req.ViewID)
+		case "set_default":
+			err = ops.SetDefaultSavedView(r.Context(), dbc, querier, userID, req.ViewID)
+		case "clear_default":
+			err = querier.DeleteListPreference(r.Context(), dbc, userID, req.Resource)
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]string]{
+			Data:       map[string]string{"id": req.ViewID},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// RunSavedView lists a page of the records matching a saved view, reduced to
+// the view's columns, among those the user making the request can see.
+// Views that are not shared can only be run by their owner or, when shared
+// with a team, by its members and managers.
+func RunSavedView(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[savedViewResultResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[savedViewResultResponse], *httpError) {
+		q := r.URL.Query()
+		userID, httpErr := ownUser(r, q.Get("user_id"))
+		if httpErr != nil {
+			return nil, httpErr
+		}
+
+		limit, offset, err := filters.Page(q)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		view, err := querier.GetSavedView(r.Context(), dbc, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, commandError(err)
+		}
+		// Views the user cannot see look the same as missing ones
+		if ok, err := ops.CanSeeSavedView(r.Context(), dbc, querier, view, userID); err != nil {
+			return nil, commandError(err)
+		} else if !ok {
+			return nil, &httpError{
+				Message:    "Not found",
+				StatusCode: http.StatusNotFound,
+			}
+		}
+
+		columns, err := ops.SavedViewColumns(view)
+		if err != nil {
+			return nil, commandError(err)
+		}
+		entityFilter, taskFilter, err := ops.SavedViewFilter(r.Context(), dbc, querier, view)
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		var rows []map[string]any
+		if view.Resource == ops.ResourceTasks {
+			taskFilter.VisibleTo = userID
+			taskFilter.Limit, taskFilter.Offset = pageSize(limit), offset
+			tasks, err := querier.ListTasks(r.Context(), dbc, taskFilter)
+			if err != nil {
+				return nil, commandError(err)
+			}
+
+			resp := make([]taskResponse, 0, len(tasks))
+			for _, task := range tasks {
+				resp = append(resp, mapTaskToResponse(task))
+			}
+			if err := attachTaskCustomFields(r.Context(), dbc, querier, resp); err != nil {
+				return nil, commandError(err)
+			}
+			rows, err = selectColumns(resp, columns)
+			if err != nil {
+				return nil, commandError(err)
+			}
+		} else {
+			entityFilter.VisibleTo = userID
+			entityFilter.Limit, entityFilter.Offset = pageSize(limit), offset
+			entities, err := querier.ListEntities(r.Context(), dbc, entityFilter)
+			if err != nil {
+				return nil, commandError(err)
+			}
+
+			resp := make([]entityResponse, 0, len(entities))
+			for _, entity := range entities {
+				resp = append(resp, mapEntityToResponse(entity))
+			}
+			if err := attachEntityDetails(r.Context(), dbc, querier, resp); err != nil {
+				return nil, commandError(err)
+			}
+			rows, err = selectColumns(resp, columns)
+			if err != nil {
+				return nil, commandError(err)
+			}
+		}
+
+		return &httpResponse[savedViewResultResponse]{
+			Data: savedViewResultResponse{
+				View:    mapSavedViewToResponse(view),
+				Columns: columns,
+				Rows:    rows,
+			},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// selectColumns turns responses into rows holding only the given fields, by
+// their JSON name, or every field if none are given. Custom fields are
+// flattened into cf.<key> fields.
+func selectColumns[T any](records []T, columns []string) ([]map[string]any, error) {
+	rows := make([]map[string]any, 0, len(records))
+	for _, record := range records {
+		b, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(b, &fields); err != nil {
+			return nil, err
+		}
+		if custom, ok := fields["custom_fields"].(map[string]any); ok {
+			for key, value := range custom {
+				fields["cf."+key] = value
+			}
+		}
+		delete(fields, "custom_fields")
+
+		if len(columns) == 0 {
+			rows = append(rows, fields)
+			continue
+		}
+		row := make(map[string]any, len(columns))
+		for _, column := range columns {
+			row[column] = fields[column]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
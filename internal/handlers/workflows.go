@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/workflows"
+)
+
+const workflowRunsLimit = 100
+
+func CreateWorkflow(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[createWorkflowRequest, workflowResponse] {
+	return func(w http.ResponseWriter, r *http.Request, req createWorkflowRequest) (*httpResponse[workflowResponse], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		params := workflows.CreateParams{
+			Name:      req.Name,
+			Trigger:   req.Trigger,
+			Condition: req.Condition,
+		}
+		for _, action := range req.Actions {
+			params.Actions = append(params.Actions, workflows.Action{Type: action.Type, Params: action.Params})
+		}
+
+		workflow, err := workflows.Create(r.Context(), dbc, querier, params)
+		if errors.Is(err, workflows.ErrInvalidWorkflow) {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[workflowResponse]{
+			Data:       mapWorkflowToResponse(workflow),
+			StatusCode: http.StatusCreated,
+		}, nil
+	}
+}
+
+func ListWorkflows(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]workflowResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]workflowResponse], *httpError) {
+		list, err := querier.ListWorkflows(r.Context(), dbc)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]workflowResponse, 0, len(list))
+		for _, workflow := range list {
+			resp = append(resp, mapWorkflowToResponse(workflow))
+		}
+
+		return &httpResponse[[]workflowResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+// ListWorkflowRuns returns the most recent runs of a workflow, newest first.
+func ListWorkflowRuns(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) getHandlerFunc[[]workflowRunResponse] {
+	return func(w http.ResponseWriter, r *http.Request) (*httpResponse[[]workflowRunResponse], *httpError) {
+		runs, err := querier.ListWorkflowRuns(r.Context(), dbc, chi.URLParam(r, "id"), workflowRunsLimit)
+		if err != nil {
+			return nil, &httpError{
+				Message:    err.Error(),
+				StatusCode: http.StatusInternalServerError,
+			}
+		}
+
+		resp := make([]workflowRunResponse, 0, len(runs))
+		for _, run := range runs {
+			resp = append(resp, mapWorkflowRunToResponse(run))
+		}
+
+		return &httpResponse[[]workflowRunResponse]{
+			Data:       resp,
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
+
+func HandleWorkflowCommand(
+	dbc *sqlx.DB,
+	querier db.Querier,
+) handlerFunc[workflowCommandRequest, map[string]string] {
+	return func(w http.ResponseWriter, r *http.Request, req workflowCommandRequest) (*httpResponse[map[string]string], *httpError) {
+		if validationError := req.Validate(); len(validationError) > 0 {
+			return nil, &httpError{
+				Message:    validationError.Error(),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		var err error
+		switch req.Command {
+		case "enable", "disable":
+			_, err = querier.SetWorkflowEnabled(r.Context(), dbc, req.WorkflowID, req.Command == "enable")
+		case "delete":
+			err = querier.DeleteWorkflow(r.Context(), dbc, req.WorkflowID)
+		}
+		if err != nil {
+			return nil, commandError(err)
+		}
+
+		return &httpResponse[map[string]string]{
+			Data:       map[string]string{"id": req.WorkflowID},
+			StatusCode: http.StatusOK,
+		}, nil
+	}
+}
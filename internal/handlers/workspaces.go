@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+)
+
+// userIDHeader names the user making a request. Authentication happens in
+// front of the server, which trusts the proxy doing it to set the header.
+const userIDHeader = "X-User-ID"
+
+// viewer returns the user making r, who only reads the records they can see
+// through their teams. Requests that name no user are rejected.
+func viewer(r *http.Request) (string, *httpError) {
+	userID := r.Header.Get(userIDHeader)
+	if userID == "" {
+		return "", &httpError{
+			Message:    "Missing " + userIDHeader + " header",
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	return userID, nil
+}
+
+// ownUser returns the user a request about a user's own records, such as
+// their notifications or saved views, is about: the one making it. Requests
+// that still name a userID must name that same user.
+func ownUser(r *http.Request, userID string) (string, *httpError) {
+	actor, httpErr := viewer(r)
+	if httpErr != nil {
+		return "", httpErr
+	}
+	if userID != "" && userID != actor {
+		return "", &httpError{
+			Message:    "Only available to the user making the request",
+			StatusCode: http.StatusForbidden,
+		}
+	}
+	return actor, nil
+}
+
+// WorkspaceMiddleware confines each request to the workspace of the user
+// named by the X-User-ID header, so that it can neither read nor change
+// another workspace's records. Requests without the header are rejected
+// unless a defaultWorkspace is configured for them to act in; requests
+// naming an unknown user are rejected.
+func WorkspaceMiddleware(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	defaultWorkspace string,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			workspaceID := defaultWorkspace
+			if userID := r.Header.Get(userIDHeader); userID != "" {
+				var err error
+				workspaceID, err = querier.GetUserWorkspaceID(r.Context(), dbc, userID)
+				if errors.Is(err, sql.ErrNoRows) {
+					http.Error(w, "Unknown user", http.StatusUnauthorized)
+					return
+				}
+				if err != nil {
+					slog.Error(err.Error())
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			if workspaceID == "" {
+				http.Error(w, "Missing "+userIDHeader+" header", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(db.WithWorkspace(r.Context(), workspaceID)))
+		})
+	}
+}
+
+// publicWorkspaceMiddleware confines public requests, which name no user, to
+// the workspace lookup finds for the URL parameter param, such as the
+// workspace of a lead form by its key. Requests it finds none for are not
+// found.
+func publicWorkspaceMiddleware(
+	dbc *sqlx.DB,
+	param string,
+	lookup func(ctx context.Context, dbc db.DBExecutor, value string) (string, error),
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			workspaceID, err := lookup(r.Context(), dbc, chi.URLParam(r, param))
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				slog.Error(err.Error())
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(db.WithWorkspace(r.Context(), workspaceID)))
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub/mocks"
+)
+
+func TestWorkspaces_IsolateRecords(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, eventService, cleanup := setupTest(t)
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	defer cleanup()
+
+	post := func(userID, url, pl string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	get := func(userID, url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(userIDHeader, userID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("", "/api/v1/user/create", `{"first_name": "John", "last_name": "Doe", "email": "john.doe@example.com"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var userA createUserResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &userA))
+
+	// The same email may be used in another workspace
+	workspaceEventService := mocks.NewMockUserCreatedEventServicer(gomock.NewController(t))
+	workspaceEventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	workspace, userB, err := ops.CreateWorkspace(context.Background(), dbc, &db.Queries{}, ops.CreateWorkspaceParams{
+		Name:      "Acme",
+		FirstName: "John",
+		LastName:  "Doe",
+		Email:     "john.doe@example.com",
+	}, workspaceEventService)
+	a.NoError(err)
+	a.Equal(workspace.ID, userB.WorkspaceID)
+
+	w = post(userA.ID, "/api/v1/lead/create", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "phone": "555-010-0100"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var lead entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &lead))
+
+	w = post(userA.ID, "/api/v1/lead/command", `{"command": "add_tags", "entity_id": "`+lead.ID+`", "tags": ["VIP"]}`)
+	a.Equal(http.StatusOK, w.Code)
+
+	w = post(userA.ID, "/api/v1/task/create", `{"name": "Call Jane", "entity_id": "`+lead.ID+`"}`)
+	a.Equal(http.StatusCreated, w.Code)
+	var task taskResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &task))
+
+	w = post(userA.ID, "/api/v1/team/create", `{"name": "East"}`)
+	a.Equal(http.StatusCreated, w.Code)
+
+	// Test
+	for _, url := range []string{
+		"/api/v1/query/leads",
+		"/api/v1/query/tasks",
+		"/api/v1/query/tags",
+		"/api/v1/query/teams",
+	} {
+		w = get(userB.ID, url)
+		a.Equal(http.StatusOK, w.Code, url)
+		a.JSONEq(`[]`, w.Body.String(), url)
+	}
+
+	w = get(userB.ID, "/api/v1/query/lead/"+lead.ID+"/score")
+	a.Equal(http.StatusNotFound, w.Code)
+	w = get(userB.ID, "/api/v1/query/task/"+task.ID)
+	a.Equal(http.StatusNotFound, w.Code)
+
+	w = post(userB.ID, "/api/v1/lead/command", `{"command": "assign", "entity_id": "`+lead.ID+`", "user_id": "`+userB.ID+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+	w = post(userB.ID, "/api/v1/task/command", `{"command": "complete", "task_id": "`+task.ID+`"}`)
+	a.Equal(http.StatusNotFound, w.Code)
+
+	// Records may not reference another workspace's records either
+	w = post(userB.ID, "/api/v1/lead/create", `{"first_name": "Jim", "last_name": "Doe", "email": "jim@example.com", "phone": "555-010-0101", "assigned_to": "`+userA.ID+`"}`)
+	a.Equal(http.StatusBadRequest, w.Code)
+
+	// Names only have to be unique within a workspace
+	w = post(userB.ID, "/api/v1/team/create", `{"name": "East"}`)
+	a.Equal(http.StatusCreated, w.Code)
+
+	w = get(userA.ID, "/api/v1/query/leads")
+	a.Equal(http.StatusOK, w.Code)
+	var leads []entityResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &leads))
+	a.Len(leads, 1)
+	a.Equal(lead.ID, leads[0].ID)
+
+	w = get(userA.ID, "/api/v1/query/teams")
+	a.Equal(http.StatusOK, w.Code)
+	var teams []teamResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &teams))
+	a.Len(teams, 1)
+}
+
+func TestWorkspaces_RejectsUnknownUsers(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	_, r, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	// Test
+	req := httptest.NewRequest("GET", "/api/v1/query/leads", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(userIDHeader, "missing")
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusUnauthorized, w.Code)
+}
+
+func TestWorkspaces_RequireUserByDefault(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, _, cleanup := setupTestWithDefaultWorkspace(t, "")
+	defer cleanup()
+
+	_, err := dbc.Exec(
+		"INSERT INTO users (id, first_name, last_name, email) VALUES ('testid', 'John', 'Doe', 'john.doe@example.com')",
+	)
+	a.NoError(err)
+
+	// Test
+	for _, tc := range []struct {
+		method, url, body string
+	}{
+		{"GET", "/api/v1/query/leads", ""},
+		{"GET", "/api/v1/export/leads", ""},
+		{"POST", "/api/v1/lead/create", `{"first_name": "Jane", "last_name": "Doe", "email": "jane@example.com", "phone": "555-010-0100"}`},
+		{"POST", "/api/v1/user/create", `{"first_name": "Ann", "last_name": "Lee", "email": "ann@example.com"}`},
+	} {
+		req := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		a.Equal(http.StatusUnauthorized, w.Code, tc.url)
+	}
+
+	var count int
+	a.NoError(dbc.Get(&count, "SELECT COUNT(*) FROM entities"))
+	a.Zero(count)
+
+	req := httptest.NewRequest("GET", "/api/v1/query/leads", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(userIDHeader, "testid")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	a.Equal(http.StatusOK, w.Code)
+}
+
+func TestWorkspaceMiddleware_RequiresUserWithoutDefault(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, _, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	var workspaceID string
+	handler := WorkspaceMiddleware(dbc, &db.Queries{}, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workspaceID, _ = db.WorkspaceFrom(r.Context())
+	}))
+
+	// Test
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	a.Equal(http.StatusUnauthorized, w.Code)
+	a.Empty(workspaceID)
+
+	_, err := dbc.Exec(
+		"INSERT INTO users (id, first_name, last_name, email) VALUES ('testid', 'John', 'Doe', 'john.doe@example.com')",
+	)
+	a.NoError(err)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(userIDHeader, "testid")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	a.Equal(http.StatusOK, w.Code)
+	a.Equal(db.DefaultWorkspaceID, workspaceID)
+}
+
+func TestQueries_RequireWorkspace(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, _, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	// Test
+	_, err := (&db.Queries{}).ListTags(context.Background(), dbc)
+	a.True(errors.Is(err, db.ErrNoWorkspace))
+}
+
+func TestQueries_ScopeJoinedTables(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, _, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	// Rows of another workspace that point at records of the default one,
+	// as a query joining on ids alone would pick them up
+	_, err := dbc.Exec(`
+	INSERT INTO workspaces (id, name) VALUES ('acme', 'Acme');
+	INSERT INTO entities (id, workspace_id, first_name, last_name, email, phone, status, created_at, converted_at) VALUES
+	('e1', 'acme', 'Jane', 'Doe', 'jane@example.com', '', 'new', '2026-01-01 09:00:00', '');
+	INSERT INTO quotes (id, number, entity_id, title, currency, status, tax_rate, decided_at) VALUES
+	('q1', 1, 'e1', 'Q', 'USD', 'accepted', 0, '2026-01-15 10:00:00');
+	INSERT INTO quote_lines (id, workspace_id, quote_id, position, description, quantity, unit_price, discount) VALUES
+	('l1', 'acme', 'q1', 1, 'Item', 1, 10000, 0);
+	INSERT INTO tags (id, name) VALUES ('t1', 'VIP');
+	INSERT INTO entity_tags (tag_id, entity_id, workspace_id) VALUES ('t1', 'e1', 'acme');
+	`)
+	a.NoError(err)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+
+	// Test
+	totals, err := (&db.Queries{}).ListDecidedQuoteTotals(ctx, dbc, db.ReportFilter{})
+	a.NoError(err)
+	a.Empty(totals)
+
+	tags, err := (&db.Queries{}).ListTags(ctx, dbc)
+	a.NoError(err)
+	a.Len(tags, 1)
+	a.Zero(tags[0].Entities)
+}
+
+func TestWorkspaces_IsolateEveryResource(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dbc, r, eventService, cleanup := setupTest(t)
+	eventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	defer cleanup()
+
+	// Everything is made in the default workspace, with ids and values that
+	// the other workspace's responses must not mention
+	_, err := dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email, calendar_token) VALUES
+	('ws-a-user', 'Ann', 'Lee', 'ann@example.com', 'ws-a-token');
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, assigned_to, created_at, converted_at) VALUES
+	('ws-a-lead', 'Jane', 'Doe', 'ws-a-jane@example.com', '+15550100100', 'new', 'ws-a-user', '2026-01-05 09:00:00', ''),
+	('ws-a-twin', 'Jane', 'Doe', 'ws-a-jane@example.com', '+15550100100', 'new', 'ws-a-user', '2026-01-06 09:00:00', ''),
+	('ws-a-contact', 'John', 'Doe', 'ws-a-john@example.com', '+15550100101', 'converted', 'ws-a-user', '2026-01-05 09:00:00', '2026-01-07 09:00:00');
+	UPDATE entities SET email_canonical = email;
+	INSERT INTO entity_history (entity_id, action, details) VALUES
+	('ws-a-lead', 'created', '{}'),
+	('ws-a-contact', 'created', '{}');
+	INSERT INTO tasks (id, name, description, due_date, status, assigned_to, entity_id) VALUES
+	('ws-a-task', 'ws-a-call', '', '2026-01-05', 'open', 'ws-a-user', 'ws-a-lead');
+	INSERT INTO custom_fields (id, object_type, key, label, type) VALUES
+	('ws-a-field', 'lead', 'budget', 'Budget', 'number');
+	INSERT INTO custom_field_values (field_id, record_id, value) VALUES ('ws-a-field', 'ws-a-lead', '100');
+	INSERT INTO tags (id, name) VALUES ('ws-a-tag', 'ws-a-vip');
+	INSERT INTO entity_tags (tag_id, entity_id) VALUES ('ws-a-tag', 'ws-a-lead');
+	INSERT INTO segments (id, name, object_type, filter) VALUES ('ws-a-segment', 'ws-a-new', 'lead', 'status=new');
+	INSERT INTO saved_views (id, owner_id, name, resource, shared) VALUES
+	('ws-a-view', 'ws-a-user', 'ws-a-mine', 'leads', 1);
+	INSERT INTO notifications (id, user_id, kind, title, event_id, created_at) VALUES
+	('ws-a-notification', 'ws-a-user', 'assigned', 'ws-a-assigned', 'ws-a-event', '2026-01-05 09:00:00');
+	INSERT INTO workflows (id, name, trigger, actions) VALUES
+	('ws-a-workflow', 'ws-a-flow', 'lead.created', '[]');
+	INSERT INTO workflow_runs (workflow_id, event_id, event_type, status, started_at, finished_at) VALUES
+	('ws-a-workflow', 'ws-a-event', 'lead.created', 'succeeded', '2026-01-05 09:00:00', '2026-01-05 09:00:00');
+	INSERT INTO scoring_rules (id, name, kind, field, value, points) VALUES
+	('ws-a-scoring', 'ws-a-web', 'attribute', 'source', 'web', 10);
+	INSERT INTO assignment_rules (id, name, priority, strategy) VALUES
+	('ws-a-assignment', 'ws-a-everyone', 1, 'round_robin');
+	INSERT INTO assignment_rule_members (rule_id, user_id) VALUES ('ws-a-assignment', 'ws-a-user');
+	INSERT INTO lead_forms (id, name, key) VALUES ('ws-a-form', 'ws-a-signup', 'ws-a-key');
+	INSERT INTO import_jobs (id, object_type, status, mapping, source) VALUES
+	('ws-a-import', 'lead', 'completed', '{}', 'first_name');
+	INSERT INTO import_errors (job_id, row_number, message, row_data) VALUES ('ws-a-import', 2, 'ws-a-bad', '{}');
+	INSERT INTO inbound_emails (id, message_id, from_address, subject, sent_at, created_at) VALUES
+	('ws-a-inbound', 'ws-a-message', 'ws-a-jane@example.com', 'Hello', '2026-01-05 09:00:00', '2026-01-05 09:00:00');
+	INSERT INTO email_outbox (id, from_address, to_addresses, subject, status, next_attempt_at, created_at) VALUES
+	('ws-a-outbox', 'crm@example.com', 'ws-a-jane@example.com', 'Hello', 'failed', '2026-01-05 09:00:00', '2026-01-05 09:00:00');
+	INSERT INTO attachments (id, entity_id, filename, content_type, size, blob_key) VALUES
+	('ws-a-attachment', 'ws-a-lead', 'ws-a-file.txt', 'text/plain', 5, 'ws-a-blob');
+	INSERT INTO scheduled_reports (id, name, metrics, recipients, schedule, next_run_at) VALUES
+	('ws-a-report', 'ws-a-digest', '["leads"]', '[]', '@weekly', '2999-01-01 00:00:00');
+	INSERT INTO report_snapshots (id, report_id, range_from, range_to, result, ran_at) VALUES
+	('ws-a-snapshot', 'ws-a-report', '2026-01-01', '2026-01-08', '{}', '2026-01-08 00:00:00');
+	INSERT INTO products (id, sku, name) VALUES ('ws-a-product', 'ws-a-sku', 'Widget');
+	INSERT INTO product_prices (product_id, currency, unit_price) VALUES ('ws-a-product', 'USD', 1000);
+	INSERT INTO quotes (id, number, entity_id, title, currency, status, decided_at) VALUES
+	('ws-a-quote', 1, 'ws-a-lead', 'ws-a-offer', 'USD', 'accepted', '2026-01-05 10:00:00');
+	INSERT INTO quote_lines (id, quote_id, position, description, quantity, unit_price) VALUES
+	('ws-a-line', 'ws-a-quote', 1, 'Widget', 1, 1000);
+	INSERT INTO exchange_rates (from_currency, to_currency, rate, effective_on) VALUES ('EUR', 'USD', '1.1', '2026-01-01');
+	`)
+	a.NoError(err)
+
+	request := func(method, url, pl string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("POST", "/api/v1/team/create", `{"name": "East"}`, map[string]string{
+		userIDHeader:         "ws-a-user",
+		idempotencyKeyHeader: "ws-a-idempotency",
+	})
+	a.Equal(http.StatusCreated, w.Code)
+	var teamA teamResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &teamA))
+
+	workspaceEventService := mocks.NewMockUserCreatedEventServicer(gomock.NewController(t))
+	workspaceEventService.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	_, userB, err := ops.CreateWorkspace(context.Background(), dbc, &db.Queries{}, ops.CreateWorkspaceParams{
+		Name:      "Acme",
+		FirstName: "Bob",
+		LastName:  "Ray",
+		Email:     "bob@example.com",
+	}, workspaceEventService)
+	a.NoError(err)
+	_, err = dbc.Exec("UPDATE users SET calendar_token = 'ws-b-token' WHERE id = ?", userB.ID)
+	a.NoError(err)
+	asB := map[string]string{userIDHeader: userB.ID}
+
+	// Test
+	for _, tc := range []struct {
+		name, method, url, body string
+		// want is 404 for records, or 200 for lists, which must come back
+		// without any of the default workspace's records
+		want int
+	}{
+		{"leads", "GET", "/api/v1/query/leads", "", http.StatusOK},
+		{"contacts", "GET", "/api/v1/query/contacts", "", http.StatusOK},
+		{"contact history", "GET", "/api/v1/query/contact/ws-a-contact/history", "", http.StatusOK},
+		{"contact command", "POST", "/api/v1/contact/command", `{"command": "change_status", "entity_id": "ws-a-contact", "status": "new"}`, http.StatusNotFound},
+		{"lead history", "GET", "/api/v1/query/lead/ws-a-lead/history", "", http.StatusOK},
+		{"lead score", "GET", "/api/v1/query/lead/ws-a-lead/score", "", http.StatusNotFound},
+		{"lead activities", "GET", "/api/v1/query/lead/ws-a-lead/activities", "", http.StatusOK},
+		{"tasks", "GET", "/api/v1/query/tasks", "", http.StatusOK},
+		{"task", "GET", "/api/v1/query/task/ws-a-task", "", http.StatusNotFound},
+		{"task command", "POST", "/api/v1/task/command", `{"command": "complete", "task_id": "ws-a-task"}`, http.StatusNotFound},
+		{"custom fields", "GET", "/api/v1/query/custom-fields", "", http.StatusOK},
+		{"custom field command", "POST", "/api/v1/custom-field/command", `{"command": "delete", "field_id": "ws-a-field"}`, http.StatusNotFound},
+		{"custom field values", "POST", "/api/v1/lead/command", `{"command": "set_custom_fields", "entity_id": "ws-a-lead", "custom_fields": {"budget": 5}}`, http.StatusNotFound},
+		{"tags", "GET", "/api/v1/query/tags", "", http.StatusOK},
+		{"tag command", "POST", "/api/v1/tag/command", `{"command": "delete", "tag_id": "ws-a-tag"}`, http.StatusNotFound},
+		{"segments", "GET", "/api/v1/query/segments", "", http.StatusOK},
+		{"segment entities", "GET", "/api/v1/query/segment/ws-a-segment/entities", "", http.StatusNotFound},
+		{"segment command", "POST", "/api/v1/segment/command", `{"command": "delete", "segment_id": "ws-a-segment"}`, http.StatusNotFound},
+		{"saved views", "GET", "/api/v1/query/views", "", http.StatusOK},
+		{"saved view", "GET", "/api/v1/query/view/ws-a-view", "", http.StatusNotFound},
+		{"saved view command", "POST", "/api/v1/view/command", `{"command": "set_default", "view_id": "ws-a-view"}`, http.StatusNotFound},
+		{"notifications", "GET", "/api/v1/query/notifications", "", http.StatusOK},
+		{"notification command", "POST", "/api/v1/notification/command", `{"command": "mark_read", "notification_id": "ws-a-notification"}`, http.StatusNotFound},
+		{"notification stream", "GET", "/api/v1/stream/notifications?user_id=ws-a-user", "", http.StatusForbidden},
+		{"workflows", "GET", "/api/v1/query/workflows", "", http.StatusOK},
+		{"workflow runs", "GET", "/api/v1/query/workflow/ws-a-workflow/runs", "", http.StatusOK},
+		{"workflow command", "POST", "/api/v1/workflow/command", `{"command": "disable", "workflow_id": "ws-a-workflow"}`, http.StatusNotFound},
+		{"scoring rules", "GET", "/api/v1/query/scoring-rules", "", http.StatusOK},
+		{"scoring command", "POST", "/api/v1/scoring/command", `{"command": "delete", "rule_id": "ws-a-scoring"}`, http.StatusNotFound},
+		{"assignment rules", "GET", "/api/v1/query/assignment-rules", "", http.StatusOK},
+		{"assignment command", "POST", "/api/v1/assignment/command", `{"command": "delete", "rule_id": "ws-a-assignment"}`, http.StatusNotFound},
+		{"lead forms", "GET", "/api/v1/query/forms", "", http.StatusOK},
+		{"lead form command", "POST", "/api/v1/form/command", `{"command": "disable", "form_id": "ws-a-form"}`, http.StatusNotFound},
+		{"import", "GET", "/api/v1/query/import/ws-a-import", "", http.StatusNotFound},
+		{"import errors", "GET", "/api/v1/query/import/ws-a-import/errors", "", http.StatusOK},
+		{"inbound email", "GET", "/api/v1/query/inbound-email/ws-a-inbound", "", http.StatusNotFound},
+		{"outbox", "GET", "/api/v1/query/emails", "", http.StatusOK},
+		{"outbox command", "POST", "/api/v1/email/command", `{"command": "retry", "email_id": "ws-a-outbox"}`, http.StatusNotFound},
+		{"duplicates", "GET", "/api/v1/query/duplicates", "", http.StatusOK},
+		{"merge", "POST", "/api/v1/lead/command", `{"command": "merge", "survivor_id": "ws-a-lead", "duplicate_id": "ws-a-twin"}`, http.StatusNotFound},
+		{"export", "GET", "/api/v1/export/leads", "", http.StatusOK},
+		{"segment export", "GET", "/api/v1/export/segment/ws-a-segment", "", http.StatusNotFound},
+		{"calendar feed of another workspace", "GET", "/api/v1/calendar/ws-a-user.ics?token=ws-b-token", "", http.StatusNotFound},
+		{"calendar feed", "GET", "/api/v1/calendar/" + userB.ID + ".ics?token=ws-b-token", "", http.StatusOK},
+		{"attachments", "GET", "/api/v1/query/lead/ws-a-lead/attachments", "", http.StatusNotFound},
+		{"attachment download", "GET", "/api/v1/attachment/ws-a-attachment/download", "", http.StatusNotFound},
+		{"attachment command", "POST", "/api/v1/attachment/command", `{"command": "delete", "attachment_id": "ws-a-attachment"}`, http.StatusNotFound},
+		{"lead report", "GET", "/api/v1/query/report/leads?from=2026-01-01", "", http.StatusOK},
+		{"task report", "GET", "/api/v1/query/report/tasks", "", http.StatusOK},
+		{"quote report", "GET", "/api/v1/query/report/quotes?from=2026-01-01", "", http.StatusOK},
+		{"scheduled reports", "GET", "/api/v1/query/scheduled-reports", "", http.StatusOK},
+		{"report snapshots", "GET", "/api/v1/query/scheduled-report/ws-a-report/snapshots", "", http.StatusNotFound},
+		{"scheduled report command", "POST", "/api/v1/scheduled-report/command", `{"command": "run", "report_id": "ws-a-report"}`, http.StatusNotFound},
+		{"products", "GET", "/api/v1/query/products", "", http.StatusOK},
+		{"product command", "POST", "/api/v1/product/command", `{"command": "set_active", "product_id": "ws-a-product", "active": false}`, http.StatusNotFound},
+		{"quotes", "GET", "/api/v1/query/quotes", "", http.StatusOK},
+		{"quote", "GET", "/api/v1/query/quote/ws-a-quote", "", http.StatusNotFound},
+		{"quote print", "GET", "/api/v1/quote/ws-a-quote/print", "", http.StatusNotFound},
+		{"quote command", "POST", "/api/v1/quote/command", `{"command": "set_status", "quote_id": "ws-a-quote", "status": "declined"}`, http.StatusNotFound},
+		{"exchange rates", "GET", "/api/v1/query/exchange-rates", "", http.StatusOK},
+		{"exchange rate command", "POST", "/api/v1/exchange-rate/command", `{"command": "delete", "from": "EUR", "to": "USD", "effective_on": "2026-01-01"}`, http.StatusNotFound},
+		{"team command", "POST", "/api/v1/team/command", `{"command": "delete", "team_id": "` + teamA.ID + `"}`, http.StatusNotFound},
+	} {
+		w := request(tc.method, tc.url, tc.body, asB)
+		a.Equal(tc.want, w.Code, "%s: %s", tc.name, w.Body.String())
+		if tc.want == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			a.JSONEq(`[]`, w.Body.String(), tc.name)
+		}
+		a.NotContains(w.Body.String(), "ws-a-", tc.name)
+	}
+
+	// An idempotency key of one workspace does not replay in another
+	w = request("POST", "/api/v1/team/create", `{"name": "East"}`, map[string]string{
+		userIDHeader:         userB.ID,
+		idempotencyKeyHeader: "ws-a-idempotency",
+	})
+	a.Equal(http.StatusCreated, w.Code)
+	var teamB teamResponse
+	a.NoError(json.Unmarshal(w.Body.Bytes(), &teamB))
+	a.NotEqual(teamA.ID, teamB.ID)
+
+	// Nothing in the default workspace was changed
+	var count int
+	a.NoError(dbc.Get(&count, `
+	SELECT (SELECT COUNT(*) FROM entities WHERE workspace_id = 'default')
+		+ (SELECT COUNT(*) FROM custom_fields) + (SELECT COUNT(*) FROM tags) + (SELECT COUNT(*) FROM segments)
+		+ (SELECT COUNT(*) FROM workflows WHERE enabled) + (SELECT COUNT(*) FROM scoring_rules)
+		+ (SELECT COUNT(*) FROM assignment_rules) + (SELECT COUNT(*) FROM lead_forms WHERE enabled)
+		+ (SELECT COUNT(*) FROM attachments) + (SELECT COUNT(*) FROM products WHERE active)
+		+ (SELECT COUNT(*) FROM exchange_rates) + (SELECT COUNT(*) FROM report_snapshots)
+	`))
+	a.Equal(14, count)
+	var status string
+	a.NoError(dbc.Get(&status, "SELECT status FROM quotes WHERE id = 'ws-a-quote'"))
+	a.Equal("accepted", status)
+	a.NoError(dbc.Get(&status, "SELECT status FROM tasks WHERE id = 'ws-a-task'"))
+	a.Equal("open", status)
+	a.NoError(dbc.Get(&status, "SELECT read_at FROM notifications WHERE id = 'ws-a-notification'"))
+	a.Empty(status)
+}
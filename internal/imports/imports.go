@@ -0,0 +1,450 @@
+package imports
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+)
+
+const (
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+)
+
+const batchSize = 100
+
+// ErrQueueFull is returned by Enqueue when too many imports are waiting
+// already. The job is marked failed, so it can be uploaded again later.
+var ErrQueueFull = errors.New("too many imports are queued, try again later")
+
+// Fields lists the entity fields a CSV column can be mapped onto.
+var Fields = []string{"first_name", "last_name", "email", "phone", "status", "assigned_to", "source", "region"}
+
+// fieldNames maps CreateLeadParams struct fields back to their import field
+// so validation errors can be reported against the mapped column.
+var fieldNames = map[string]string{
+	"FirstName":  "first_name",
+	"LastName":   "last_name",
+	"Email":      "email",
+	"Phone":      "phone",
+	"Status":     "status",
+	"AssignedTo": "assigned_to",
+	"Source":     "source",
+	"Region":     "region",
+}
+
+// Mapping maps entity fields onto CSV column headers. Fields that are not
+// mapped are read from a column named after the field, if there is one.
+type Mapping map[string]string
+
+func ParseMapping(raw string) (Mapping, error) {
+	mapping := Mapping{}
+	if strings.TrimSpace(raw) == "" {
+		return mapping, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+		return nil, fmt.Errorf("invalid mapping: %w", err)
+	}
+
+	for field := range mapping {
+		if _, ok := fieldIndex(field); !ok {
+			return nil, fmt.Errorf("invalid mapping: unknown field %q", field)
+		}
+	}
+
+	return mapping, nil
+}
+
+func (m Mapping) column(field string) string {
+	if header, ok := m[field]; ok {
+		return header
+	}
+	return field
+}
+
+func fieldIndex(field string) (int, bool) {
+	for i, f := range Fields {
+		if f == field {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Importer runs CSV import jobs one at a time in the background.
+type Importer struct {
+	dbc          *sqlx.DB
+	querier      db.Querier
+	eventService pubsub.EventServicer
+	phoneRegion  string
+	jobs         chan queuedJob
+}
+
+// queuedJob is a job waiting to be processed in its workspace.
+type queuedJob struct {
+	workspaceID string
+	id          string
+}
+
+func NewImporter(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+	phoneRegion string,
+) *Importer {
+	return &Importer{
+		dbc:          dbc,
+		querier:      querier,
+		eventService: eventService,
+		phoneRegion:  phoneRegion,
+		jobs:         make(chan queuedJob, 100),
+	}
+}
+
+// Enqueue stores the upload as a pending job and schedules it for
+// processing, returning ErrQueueFull when it cannot be scheduled.
+func (i *Importer) Enqueue(
+	ctx context.Context,
+	objectType string,
+	mapping Mapping,
+	source []byte,
+) (db.ImportJob, error) {
+	if objectType != ops.ObjectTypeLead && objectType != ops.ObjectTypeContact {
+		return db.ImportJob{}, fmt.Errorf("invalid object type %q", objectType)
+	}
+
+	rawMapping, err := json.Marshal(mapping)
+	if err != nil {
+		return db.ImportJob{}, err
+	}
+
+	job, err := i.querier.InsertImportJob(ctx, i.dbc, db.InsertImportJobParams{
+		ID:         uuid.New().String(),
+		ObjectType: objectType,
+		Status:     JobStatusPending,
+		Mapping:    string(rawMapping),
+		Source:     source,
+	})
+	if err != nil {
+		return db.ImportJob{}, err
+	}
+
+	select {
+	case i.jobs <- queuedJob{workspaceID: job.WorkspaceID, id: job.ID}:
+	default:
+		// Left pending, the job would only run after a restart
+		slog.Warn("Import queue full", "job", job.ID)
+		if err := i.fail(ctx, job, ErrQueueFull); err != nil {
+			return db.ImportJob{}, err
+		}
+		return db.ImportJob{}, ErrQueueFull
+	}
+
+	return job, nil
+}
+
+// Run processes queued jobs until ctx is cancelled. Jobs left pending or
+// running by a previous process are restarted first, in every workspace.
+func (i *Importer) Run(ctx context.Context) {
+	err := db.EachWorkspace(ctx, i.dbc, i.querier, func(ctx context.Context) error {
+		unfinished, err := i.querier.ListImportJobsByStatus(
+			ctx,
+			i.dbc,
+			[]string{JobStatusPending, JobStatusRunning},
+		)
+		if err != nil {
+			return err
+		}
+		for _, job := range unfinished {
+			if err := i.Process(ctx, job.ID); err != nil {
+				slog.Error("Import failed", "job", job.ID, "error", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to list unfinished imports", "error", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-i.jobs:
+			if err := i.Process(db.WithWorkspace(ctx, job.workspaceID), job.id); err != nil {
+				slog.Error("Import failed", "job", job.id, "error", err)
+			}
+		}
+	}
+}
+
+type pendingRow struct {
+	number int
+	data   string
+	params ops.CreateLeadParams
+}
+
+// Process imports every row of a job, inserting valid rows in batches and
+// recording an import error for each row that is rejected.
+func (i *Importer) Process(ctx context.Context, jobID string) error {
+	job, err := i.querier.GetImportJob(ctx, i.dbc, jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status == JobStatusCompleted || job.Status == JobStatusFailed {
+		return nil
+	}
+
+	// Rows from an interrupted run are not retried, so only restart from scratch
+	if job.Status == JobStatusRunning && job.ProcessedRows > 0 {
+		return i.fail(ctx, job, errors.New("import was interrupted and must be uploaded again"))
+	}
+
+	progress := db.UpdateImportJobProgressParams{
+		ID:        job.ID,
+		Status:    JobStatusRunning,
+		StartedAt: sql.NullString{String: now(), Valid: true},
+	}
+
+	var mapping Mapping
+	if err := json.Unmarshal([]byte(job.Mapping), &mapping); err != nil {
+		return i.fail(ctx, job, err)
+	}
+
+	header, records, err := readCSV(job.Source)
+	if err != nil {
+		return i.fail(ctx, job, err)
+	}
+
+	columns, err := resolveColumns(header, mapping)
+	if err != nil {
+		return i.fail(ctx, job, err)
+	}
+
+	progress.TotalRows = len(records)
+	if err := i.querier.UpdateImportJobProgress(ctx, i.dbc, progress); err != nil {
+		return err
+	}
+
+	var batch []pendingRow
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		params := make([]ops.CreateLeadParams, len(batch))
+		for n, row := range batch {
+			params[n] = row.params
+		}
+
+		_, err := ops.CreateEntities(ctx, i.dbc, i.querier, job.ObjectType, params, i.eventService)
+		if err != nil {
+			// A batch is inserted in one transaction, so a single bad row
+			// fails it whole. The rows are retried one at a time so that only
+			// the bad ones are reported.
+			for _, row := range batch {
+				rowErr := err
+				if len(batch) > 1 {
+					_, rowErr = ops.CreateEntities(
+						ctx, i.dbc, i.querier, job.ObjectType,
+						[]ops.CreateLeadParams{row.params}, i.eventService,
+					)
+				}
+				if rowErr == nil {
+					progress.SucceededRows++
+					continue
+				}
+
+				if err := i.rowError(ctx, job.ID, row.number, errorField(rowErr), rowErr.Error(), row.data); err != nil {
+					return err
+				}
+				progress.FailedRows++
+			}
+		} else {
+			progress.SucceededRows += len(batch)
+		}
+
+		progress.ProcessedRows = progress.SucceededRows + progress.FailedRows
+		batch = batch[:0]
+		return i.querier.UpdateImportJobProgress(ctx, i.dbc, progress)
+	}
+
+	for n, record := range records {
+		// Record numbers count the header as row 1, matching spreadsheet rows
+		rowNumber := n + 2
+		params, data := mapRecord(header, record, columns)
+
+		if validationErrors := params.Validate(); len(validationErrors) > 0 {
+			for _, fe := range validationErrors {
+				field := fieldNames[fe.StructField()]
+				message := fmt.Sprintf("%s failed the '%s' rule", mapping.column(field), fe.Tag())
+				if err := i.rowError(ctx, job.ID, rowNumber, field, message, data); err != nil {
+					return err
+				}
+			}
+			progress.FailedRows++
+			progress.ProcessedRows++
+			continue
+		}
+
+		params, err := params.Normalize(i.phoneRegion)
+		if err == nil && params.AssignedTo != "" {
+			// Checked per row so one bad assignee does not fail its whole batch
+			err = ops.CheckAssignable(ctx, i.dbc, i.querier, params.AssignedTo)
+		}
+		if err != nil {
+			if err := i.rowError(ctx, job.ID, rowNumber, errorField(err), err.Error(), data); err != nil {
+				return err
+			}
+			progress.FailedRows++
+			progress.ProcessedRows++
+			continue
+		}
+
+		batch = append(batch, pendingRow{number: rowNumber, data: data, params: params})
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	progress.Status = JobStatusCompleted
+	progress.FinishedAt = sql.NullString{String: now(), Valid: true}
+	return i.querier.UpdateImportJobProgress(ctx, i.dbc, progress)
+}
+
+// errorField returns the import field err is about, or an empty string when
+// it is not about one in particular.
+func errorField(err error) string {
+	var fieldError *ops.FieldError
+	if errors.As(err, &fieldError) {
+		return fieldError.Field
+	}
+	return ""
+}
+
+func (i *Importer) fail(ctx context.Context, job db.ImportJob, cause error) error {
+	return i.querier.UpdateImportJobProgress(ctx, i.dbc, db.UpdateImportJobProgressParams{
+		ID:            job.ID,
+		Status:        JobStatusFailed,
+		TotalRows:     job.TotalRows,
+		ProcessedRows: job.ProcessedRows,
+		SucceededRows: job.SucceededRows,
+		FailedRows:    job.FailedRows,
+		Error:         cause.Error(),
+		StartedAt:     job.StartedAt,
+		FinishedAt:    sql.NullString{String: now(), Valid: true},
+	})
+}
+
+func (i *Importer) rowError(ctx context.Context, jobID string, rowNumber int, field, message, data string) error {
+	return i.querier.InsertImportError(ctx, i.dbc, db.InsertImportErrorParams{
+		JobID:     jobID,
+		RowNumber: rowNumber,
+		Field:     field,
+		Message:   message,
+		RowData:   data,
+	})
+}
+
+func readCSV(source []byte) ([]string, [][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(source))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for n := range header {
+		header[n] = strings.TrimSpace(strings.TrimPrefix(header[n], "\ufeff"))
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, records, nil
+}
+
+// resolveColumns returns, for every entry in Fields, the index of the CSV
+// column holding it or -1 when the file has no such column.
+func resolveColumns(header []string, mapping Mapping) ([]int, error) {
+	columns := make([]int, len(Fields))
+	for n, field := range Fields {
+		columns[n] = -1
+		for c, h := range header {
+			if strings.EqualFold(h, mapping.column(field)) {
+				columns[n] = c
+				break
+			}
+		}
+
+		if _, mapped := mapping[field]; mapped && columns[n] == -1 {
+			return nil, fmt.Errorf("column %q mapped to %s not found", mapping[field], field)
+		}
+	}
+
+	return columns, nil
+}
+
+func mapRecord(header, record []string, columns []int) (ops.CreateLeadParams, string) {
+	value := func(field string) string {
+		n, _ := fieldIndex(field)
+		if c := columns[n]; c >= 0 && c < len(record) {
+			return strings.TrimSpace(record[c])
+		}
+		return ""
+	}
+
+	params := ops.CreateLeadParams{
+		FirstName:  value("first_name"),
+		LastName:   value("last_name"),
+		Email:      value("email"),
+		Phone:      value("phone"),
+		Status:     value("status"),
+		AssignedTo: value("assigned_to"),
+		Source:     value("source"),
+		Region:     value("region"),
+	}
+
+	row := map[string]string{}
+	for c, h := range header {
+		if c < len(record) {
+			row[h] = record[c]
+		}
+	}
+	data, _ := json.Marshal(row)
+
+	return params, string(data)
+}
+
+func now() string {
+	return time.Now().UTC().Format(db.TimeFormat)
+}
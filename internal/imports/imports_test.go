@@ -0,0 +1,166 @@
+package imports
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+)
+
+func setupTest(t *testing.T) (*sqlx.DB, *Importer, pubsub.EventServicer) {
+	a := require.New(t)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	t.Cleanup(func() { dbc.Close() })
+
+	err = database.Migrate(context.Background(), dbc)
+	a.NoError(err)
+
+	eventService := pubsub.NewEventService()
+	return dbc, NewImporter(dbc, db.NewQueries(), eventService, "US"), eventService
+}
+
+func TestProcess(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, importer, eventService := setupTest(t)
+
+	events := make(chan pubsub.Event, 10)
+	consumeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eventService.Subscribe(consumeCtx, func(e pubsub.Event) {
+		if e.Type == pubsub.EventLeadCreated {
+			events <- e
+		}
+	})
+
+	source := "Given Name,Surname,E-mail,phone\n" +
+		"Jane,Doe,Jane@Example.com,555-010-0100\n" +
+		"John,,john@example.com,555-010-0101\n" +
+		"Ann,Lee,not-an-email,555-010-0102\n" +
+		"Bob,Ray,bob@example.com,0102\n"
+	mapping, err := ParseMapping(`{"first_name": "Given Name", "last_name": "Surname", "email": "E-mail"}`)
+	a.NoError(err)
+
+	// Test
+	job, err := importer.Enqueue(ctx, ops.ObjectTypeLead, mapping, []byte(source))
+	a.NoError(err)
+	a.NoError(importer.Process(ctx, job.ID))
+
+	job, err = db.NewQueries().GetImportJob(ctx, dbc, job.ID)
+	a.NoError(err)
+	a.Equal(JobStatusCompleted, job.Status)
+	a.Equal(4, job.TotalRows)
+	a.Equal(4, job.ProcessedRows)
+	a.Equal(1, job.SucceededRows)
+	a.Equal(3, job.FailedRows)
+
+	importErrors, err := db.NewQueries().ListImportErrors(ctx, dbc, job.ID)
+	a.NoError(err)
+	a.Len(importErrors, 3)
+	a.Equal(3, importErrors[0].RowNumber)
+	a.Equal("last_name", importErrors[0].Field)
+	a.Equal(4, importErrors[1].RowNumber)
+	a.Equal("email", importErrors[1].Field)
+	a.Equal(5, importErrors[2].RowNumber)
+	a.Equal("phone", importErrors[2].Field)
+
+	var event pubsub.Event
+	select {
+	case event = <-events:
+	case <-time.After(time.Second):
+		a.Fail("lead created event not published")
+	}
+	a.Equal(pubsub.EventLeadCreated, event.Type)
+	a.Equal("jane@example.com", event.Payload["email"])
+	a.Equal("+15550100100", event.Payload["phone"])
+}
+
+func TestProcess_MissingMappedColumn(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, importer, _ := setupTest(t)
+
+	mapping, err := ParseMapping(`{"email": "Email Address"}`)
+	a.NoError(err)
+
+	// Test
+	job, err := importer.Enqueue(ctx, ops.ObjectTypeContact, mapping, []byte("first_name,email\nJane,jane@example.com\n"))
+	a.NoError(err)
+	a.NoError(importer.Process(ctx, job.ID))
+
+	job, err = db.NewQueries().GetImportJob(ctx, dbc, job.ID)
+	a.NoError(err)
+	a.Equal(JobStatusFailed, job.Status)
+	a.Contains(job.Error, "Email Address")
+}
+
+func TestProcess_BatchWithRejectedRow(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, importer, _ := setupTest(t)
+
+	// A row that passes validation but that the database refuses
+	_, err := dbc.Exec(`
+	CREATE TRIGGER reject_entity BEFORE INSERT ON entities
+	WHEN NEW.email = 'bob@example.com'
+	BEGIN SELECT RAISE(ABORT, 'rejected'); END;
+	`)
+	a.NoError(err)
+
+	source := "first_name,last_name,email,phone\n" +
+		"Jane,Doe,jane@example.com,555-010-0100\n" +
+		"Bob,Ray,bob@example.com,555-010-0101\n" +
+		"John,Doe,john@example.com,555-010-0102\n"
+
+	// Test
+	job, err := importer.Enqueue(ctx, ops.ObjectTypeLead, Mapping{}, []byte(source))
+	a.NoError(err)
+	a.NoError(importer.Process(ctx, job.ID))
+
+	job, err = db.NewQueries().GetImportJob(ctx, dbc, job.ID)
+	a.NoError(err)
+	a.Equal(JobStatusCompleted, job.Status)
+	a.Equal(3, job.ProcessedRows)
+	a.Equal(2, job.SucceededRows)
+	a.Equal(1, job.FailedRows)
+
+	importErrors, err := db.NewQueries().ListImportErrors(ctx, dbc, job.ID)
+	a.NoError(err)
+	a.Len(importErrors, 1)
+	a.Equal(3, importErrors[0].RowNumber)
+	a.Contains(importErrors[0].Message, "rejected")
+
+	var emails []string
+	a.NoError(dbc.Select(&emails, "SELECT email FROM entities ORDER BY email"))
+	a.Equal([]string{"jane@example.com", "john@example.com"}, emails)
+}
+
+func TestEnqueue_QueueFull(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, importer, _ := setupTest(t)
+	importer.jobs = make(chan queuedJob)
+
+	// Test
+	_, err := importer.Enqueue(ctx, ops.ObjectTypeLead, Mapping{}, []byte("first_name\nJane\n"))
+	a.ErrorIs(err, ErrQueueFull)
+
+	// Nothing is left pending to run only after a restart
+	jobs, err := db.NewQueries().ListImportJobsByStatus(ctx, dbc, []string{JobStatusPending})
+	a.NoError(err)
+	a.Empty(jobs)
+}
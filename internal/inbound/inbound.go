@@ -0,0 +1,233 @@
+package inbound
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+// maxDepth bounds how deeply nested multipart bodies are walked.
+const maxDepth = 10
+
+// Message is a received email reduced to what is logged on timelines.
+type Message struct {
+	// MessageID is the Message-ID header without angle brackets, or a hash of
+	// the raw message when the header is missing.
+	MessageID string
+	From      *mail.Address
+	To        []*mail.Address
+	Cc        []*mail.Address
+	Subject   string
+	// Date is zero when the header is missing or invalid.
+	Date time.Time
+	// Body is the first text/plain part, or the first text/html part with its
+	// markup removed.
+	Body        string
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Size        int64
+}
+
+// Addresses returns the sender followed by every recipient.
+func (m Message) Addresses() []*mail.Address {
+	addresses := []*mail.Address{m.From}
+	addresses = append(addresses, m.To...)
+	return append(addresses, m.Cc...)
+}
+
+// Parse reads a raw RFC 5322 message. Only a missing or malformed From header
+// is an error; recipients that do not parse are skipped.
+func Parse(raw []byte) (Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+
+	from, err := msg.Header.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return Message{}, fmt.Errorf("%w: missing or invalid From header", ErrInvalidMessage)
+	}
+
+	m := Message{
+		MessageID: strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>"),
+		From:      from[0],
+		To:        addressList(msg.Header.Get("To")),
+		Cc:        addressList(msg.Header.Get("Cc")),
+		Subject:   decodeHeader(msg.Header.Get("Subject")),
+	}
+	if m.MessageID == "" {
+		hash := sha256.Sum256(raw)
+		m.MessageID = hex.EncodeToString(hash[:]) + "@simplecrm.invalid"
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		m.Date = date
+	}
+
+	var htmlBody string
+	if err := m.walk(textproto.MIMEHeader(msg.Header), msg.Body, &htmlBody, 0); err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if m.Body == "" {
+		m.Body = stripHTML(htmlBody)
+	}
+	m.Body = strings.TrimSpace(strings.ReplaceAll(m.Body, "\r\n", "\n"))
+
+	return m, nil
+}
+
+// walk collects the body and attachments of a MIME entity.
+func (m *Message) walk(header textproto.MIMEHeader, body io.Reader, htmlBody *string, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxDepth {
+			return errors.New("multipart nesting too deep")
+		}
+
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.walk(part.Header, part, htmlBody, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	switch {
+	case disposition == "attachment" || filename != "" || !strings.HasPrefix(mediaType, "text/"):
+		m.Attachments = append(m.Attachments, Attachment{
+			Filename:    decodeHeader(filename),
+			ContentType: mediaType,
+			Size:        int64(len(data)),
+		})
+	case mediaType == "text/plain" && m.Body == "":
+		m.Body = decodeCharset(data, params["charset"])
+	case mediaType == "text/html" && *htmlBody == "":
+		*htmlBody = decodeCharset(data, params["charset"])
+	}
+
+	return nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// The decoder skips the line breaks between encoded lines
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// decodeCharset converts text to UTF-8. Latin-1 and its Windows superset are
+// converted, other charsets are assumed to be UTF-8 compatible.
+func decodeCharset(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		if utf8.Valid(data) {
+			return string(data)
+		}
+		return strings.ToValidUTF8(string(data), "�")
+	}
+}
+
+var wordDecoder = &mime.WordDecoder{}
+
+// decodeHeader decodes RFC 2047 encoded words, leaving the value as it is if
+// it uses an unsupported charset.
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// addressList parses a list of addresses, skipping those that do not parse.
+func addressList(value string) []*mail.Address {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	parser := &mail.AddressParser{WordDecoder: wordDecoder}
+	if addresses, err := parser.ParseList(value); err == nil {
+		return addresses
+	}
+
+	var addresses []*mail.Address
+	for _, item := range strings.Split(value, ",") {
+		if address, err := parser.Parse(item); err == nil {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+var (
+	htmlInvisible = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)>`)
+	htmlBreak     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6])>`)
+	htmlTag       = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines    = regexp.MustCompile(`\n\s*\n\s*`)
+)
+
+// stripHTML reduces an HTML body to its text, keeping paragraph breaks.
+func stripHTML(s string) string {
+	s = htmlInvisible.ReplaceAllString(s, "")
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
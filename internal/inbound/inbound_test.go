@@ -0,0 +1,123 @@
+package inbound
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func crlf(s string) []byte {
+	return []byte(strings.ReplaceAll(s, "\n", "\r\n"))
+}
+
+func TestParse(t *testing.T) {
+	a := require.New(t)
+
+	msg, err := Parse(crlf(`From: "Jane Doe" <jane@example.com>
+To: Ann Lee <ann@example.com>, not an address, bob@example.com
+Cc: =?UTF-8?Q?Ren=C3=A9?= <rene@example.com>
+Subject: =?UTF-8?Q?Caf=C3=A9_order?=
+Date: Mon, 4 Mar 2024 10:30:00 +0100
+Message-ID: <abc123@example.com>
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Hi Ann,=0A=0AHere is the caf=C3=A9 order.
+--inner
+Content-Type: text/html; charset=utf-8
+
+<p>Hi Ann,</p><p>Here is the order.</p>
+--inner--
+--outer
+Content-Type: application/pdf; name="order.pdf"
+Content-Disposition: attachment; filename="order.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0xLjQK
+JSVFT0YK
+--outer--
+`))
+	a.NoError(err)
+	a.Equal("abc123@example.com", msg.MessageID)
+	a.Equal("jane@example.com", msg.From.Address)
+	a.Len(msg.To, 2)
+	a.Equal("bob@example.com", msg.To[1].Address)
+	a.Equal("René", msg.Cc[0].Name)
+	a.Equal("Café order", msg.Subject)
+	a.Equal(time.Date(2024, 3, 4, 9, 30, 0, 0, time.UTC), msg.Date.UTC())
+	a.Equal("Hi Ann,\n\nHere is the café order.", msg.Body)
+	a.Equal([]Attachment{{Filename: "order.pdf", ContentType: "application/pdf", Size: 15}}, msg.Attachments)
+	a.Len(msg.Addresses(), 4)
+}
+
+func TestParse_HTMLOnly(t *testing.T) {
+	a := require.New(t)
+
+	raw := crlf(`From: jane@example.com
+Subject: Hello
+Content-Type: text/html; charset=iso-8859-1
+
+<html><head><style>p { color: red }</style></head>
+<body><p>Gr` + "\xfc" + `&szlig;e,</p><p>Jane<br>Doe</p></body></html>
+`)
+	msg, err := Parse(raw)
+	a.NoError(err)
+	a.Equal("Grüße,\nJane\nDoe", msg.Body)
+	a.True(msg.Date.IsZero())
+	a.Empty(msg.Attachments)
+
+	// Without a Message-ID the same message always gets the same id
+	again, err := Parse(raw)
+	a.NoError(err)
+	a.Equal(msg.MessageID, again.MessageID)
+	a.True(strings.HasSuffix(msg.MessageID, "@simplecrm.invalid"))
+
+	_, err = Parse(crlf("To: ann@example.com\nSubject: No sender\n\nHi\n"))
+	a.ErrorIs(err, ErrInvalidMessage)
+}
+
+func TestSplitMbox(t *testing.T) {
+	a := require.New(t)
+
+	mbox := `From jane@example.com Mon Mar  4 10:30:00 2024
+From: jane@example.com
+Subject: One
+
+>From the start
+>>From quoted twice
+
+From bob@example.com Tue Mar  5 10:30:00 2024
+From: bob@example.com
+Subject: Two
+
+Body
+`
+	var messages []string
+	err := Messages([]byte(mbox), func(raw []byte) error {
+		messages = append(messages, string(raw))
+		return nil
+	})
+	a.NoError(err)
+	a.Len(messages, 2)
+	a.Contains(messages[0], "\nFrom the start\n>From quoted twice\n")
+	a.True(strings.HasPrefix(messages[1], "From: bob@example.com\n"))
+
+	messages = nil
+	a.NoError(Messages([]byte("From: jane@example.com\n\nHi\n"), func(raw []byte) error {
+		messages = append(messages, string(raw))
+		return nil
+	}))
+	a.Len(messages, 1)
+
+	err = SplitMbox(strings.NewReader("Subject: no separator\n"), func([]byte) error { return nil })
+	a.Error(err)
+}
@@ -0,0 +1,75 @@
+package inbound
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+)
+
+var (
+	mboxSeparator = []byte("From ")
+	// mboxQuoted matches body lines quoted with > so they are not mistaken
+	// for separators
+	mboxQuoted = regexp.MustCompile(`^>+From `)
+)
+
+// IsMbox reports whether data starts like an mbox file rather than a single
+// message.
+func IsMbox(data []byte) bool {
+	return bytes.HasPrefix(data, mboxSeparator)
+}
+
+// Messages calls f with each message in data, which is either a single
+// message or an mbox file.
+func Messages(data []byte, f func(raw []byte) error) error {
+	if !IsMbox(data) {
+		return f(data)
+	}
+	return SplitMbox(bytes.NewReader(data), f)
+}
+
+// SplitMbox calls f with each message of an mbox file in order. The "From "
+// separator lines are dropped and ">From " quoting in bodies is undone.
+func SplitMbox(r io.Reader, f func(raw []byte) error) error {
+	br := bufio.NewReader(r)
+	var (
+		current []byte
+		started bool
+	)
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, mboxSeparator):
+				if started {
+					if err := f(current); err != nil {
+						return err
+					}
+				}
+				current, started = nil, true
+			case !started:
+				if len(bytes.TrimSpace(line)) > 0 {
+					return errors.New("not an mbox file")
+				}
+			case mboxQuoted.Match(line):
+				current = append(current, line[1:]...)
+			default:
+				current = append(current, line...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if started {
+		return f(current)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+type Message struct {
+	// ID becomes the Message-ID, generated when empty
+	ID string
+	// From defaults to the sender's configured address
+	From    string
+	To      []string
+	Subject string
+	Body    string
+	// HTML is an optional alternative to Body for clients that display it
+	HTML string
+}
+
+// Sender delivers email.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender writes messages to the log instead of delivering them, for
+// development setups without a mail server.
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, msg Message) error {
+	slog.Info("Email", "to", strings.Join(msg.To, ", "), "subject", msg.Subject, "body", msg.Body, "html", msg.HTML != "")
+	return nil
+}
@@ -0,0 +1,300 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+)
+
+type receivedMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTPServer speaks just enough SMTP for net/smtp clients, accepting AUTH
+// PLAIN for user:secret and recording every message it is sent.
+type fakeSMTPServer struct {
+	listener   net.Listener
+	rejectRcpt atomic.Bool
+
+	mu       sync.Mutex
+	messages []receivedMessage
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeSMTPServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+
+	return s
+}
+
+func (s *fakeSMTPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeSMTPServer) Messages() []receivedMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedMessage(nil), s.messages...)
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		io.WriteString(conn, strings.Join(lines, "\r\n")+"\r\n")
+	}
+
+	reply("220 localhost fake ESMTP")
+	var msg receivedMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250-localhost", "250-AUTH PLAIN", "250 8BITMIME")
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			if string(credentials) != "\x00user\x00secret" {
+				reply("535 5.7.8 authentication failed")
+				continue
+			}
+			reply("235 2.7.0 authenticated")
+		case "MAIL":
+			from, _, _ := strings.Cut(strings.TrimPrefix(arg, "FROM:<"), ">")
+			msg = receivedMessage{From: from}
+			reply("250 ok")
+		case "RCPT":
+			if s.rejectRcpt.Load() {
+				reply("550 5.1.1 mailbox unavailable")
+				continue
+			}
+			to, _, _ := strings.Cut(strings.TrimPrefix(arg, "TO:<"), ">")
+			msg.To = append(msg.To, to)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "RSET", "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPSender(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	server := newFakeSMTPServer(t)
+	sender := &SMTPSender{Addr: server.Addr(), Username: "user", Password: "secret", From: "CRM <crm@example.com>"}
+
+	// Test
+	err := sender.Send(context.Background(), Message{
+		To:      []string{"Ann Lee <ann@example.com>", "bob@example.com"},
+		Subject: "Grüße",
+		Body:    "Hello Ann",
+		HTML:    "<p>Hello <b>Ann</b></p>",
+	})
+	a.NoError(err)
+
+	messages := server.Messages()
+	a.Len(messages, 1)
+	a.Equal("crm@example.com", messages[0].From)
+	a.Equal([]string{"ann@example.com", "bob@example.com"}, messages[0].To)
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(messages[0].Data))
+	a.NoError(err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	a.NoError(err)
+	a.Equal("Grüße", subject)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	a.NoError(err)
+	a.Equal("multipart/alternative", mediaType)
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		a.NoError(err)
+		b, err := io.ReadAll(quotedprintable.NewReader(part))
+		a.NoError(err)
+		bodies = append(bodies, string(b))
+	}
+	a.Equal([]string{"Hello Ann", "<p>Hello <b>Ann</b></p>"}, bodies)
+
+	sender.Password = "wrong"
+	a.Error(sender.Send(context.Background(), Message{To: []string{"ann@example.com"}, Subject: "Hi"}))
+}
+
+func TestOutbox(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	defer dbc.Close()
+	a.NoError(database.Migrate(ctx, dbc))
+
+	server := newFakeSMTPServer(t)
+	querier := db.NewQueries()
+	outbox := NewOutbox(dbc, querier, &SMTPSender{Addr: server.Addr()}, "crm@example.com")
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	outbox.now = func() time.Time { return now }
+
+	// Test
+	a.Error(outbox.Send(ctx, Message{Subject: "Nobody"}))
+	a.Error(outbox.Send(ctx, Message{To: []string{"not an address"}, Subject: "Bad"}))
+
+	a.NoError(outbox.Send(ctx, Message{To: []string{"ann@example.com"}, Subject: "First"}))
+	result, err := outbox.Flush(ctx)
+	a.NoError(err)
+	a.Equal(FlushResult{Sent: 1}, result)
+	a.Len(server.Messages(), 1)
+
+	server.rejectRcpt.Store(true)
+	a.NoError(outbox.Send(ctx, Message{To: []string{"bob@example.com"}, Subject: "Second"}))
+	result, err = outbox.Flush(ctx)
+	a.NoError(err)
+	a.Equal(FlushResult{Retrying: 1}, result)
+
+	// Nothing is due until the retry delay has passed
+	result, err = outbox.Flush(ctx)
+	a.NoError(err)
+	a.Equal(FlushResult{}, result)
+
+	for range maxAttempts - 1 {
+		now = now.Add(maxRetry)
+		_, err = outbox.Flush(ctx)
+		a.NoError(err)
+	}
+	failed, err := querier.ListOutboxEmails(ctx, dbc, db.OutboxEmailFilter{Status: StatusFailed})
+	a.NoError(err)
+	a.Len(failed, 1)
+	a.Equal(maxAttempts, failed[0].Attempts)
+	a.Contains(failed[0].LastError, "mailbox unavailable")
+
+	_, err = outbox.Retry(ctx, "missing")
+	a.ErrorIs(err, sql.ErrNoRows)
+	sent, err := querier.ListOutboxEmails(ctx, dbc, db.OutboxEmailFilter{Status: StatusSent})
+	a.NoError(err)
+	_, err = outbox.Retry(ctx, sent[0].ID)
+	a.ErrorIs(err, ErrNotRetryable)
+
+	server.rejectRcpt.Store(false)
+	_, err = outbox.Retry(ctx, failed[0].ID)
+	a.NoError(err)
+	result, err = outbox.Flush(ctx)
+	a.NoError(err)
+	a.Equal(FlushResult{Sent: 1}, result)
+	a.Len(server.Messages(), 2)
+}
+
+func TestRetryDelay(t *testing.T) {
+	a := require.New(t)
+
+	a.Equal(time.Minute, retryDelay(1))
+	a.Equal(2*time.Minute, retryDelay(2))
+	a.Equal(8*time.Minute, retryDelay(4))
+	a.Equal(maxRetry, retryDelay(20))
+}
+
+func TestTemplate(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	tmpl, err := NewTemplate("welcome", "Welcome,\n{{.Name}}", "Hi {{.Name}}", "<p>Hi {{.Name}}</p>")
+	a.NoError(err)
+
+	// Test
+	msg, err := tmpl.Render([]string{"ann@example.com"}, map[string]string{"Name": "Ann <Lee>"})
+	a.NoError(err)
+	a.Equal("Welcome, Ann <Lee>", msg.Subject)
+	a.Equal("Hi Ann <Lee>", msg.Body)
+	a.Equal("<p>Hi Ann &lt;Lee&gt;</p>", msg.HTML)
+
+	_, err = NewTemplate("broken", "{{.Name", "", "")
+	a.Error(err)
+}
+
+func TestMaildirSender(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	dir := t.TempDir()
+	sender := &MaildirSender{Dir: dir, From: "crm@example.com"}
+
+	// Test
+	a.NoError(sender.Send(context.Background(), Message{To: []string{"ann@example.com"}, Subject: "Hi", Body: "Hello"}))
+	a.NoError(sender.Send(context.Background(), Message{To: []string{"ann@example.com"}, Subject: "Again", Body: "Hello"}))
+
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	a.NoError(err)
+	a.Len(entries, 2)
+	data, err := os.ReadFile(filepath.Join(dir, "new", entries[0].Name()))
+	a.NoError(err)
+	a.Contains(string(data), "To: ann@example.com\r\n")
+
+	tmp, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	a.NoError(err)
+	a.Empty(tmp)
+}
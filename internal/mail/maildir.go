@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// MaildirSender delivers messages into a Maildir, so that local development
+// setups can read what would have been sent with any mail client.
+type MaildirSender struct {
+	Dir string
+	// From is used for messages without a sender
+	From string
+}
+
+var maildirSeq atomic.Int64
+
+func (s *MaildirSender) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = s.From
+	}
+	now := time.Now()
+	data, err := encode(msg, now)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(s.Dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	// Written to tmp first so readers never see a partial message
+	name := fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), maildirSeq.Add(1), host)
+	tmp := filepath.Join(s.Dir, "tmp", name)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.Dir, "new", name))
+}
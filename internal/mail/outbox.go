@@ -0,0 +1,229 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+const (
+	// maxAttempts is how often a message is tried before it is failed
+	maxAttempts = 5
+	// flushBatch bounds how many messages a flush delivers
+	flushBatch = 50
+	firstRetry = time.Minute
+	maxRetry   = time.Hour
+)
+
+// ErrNotRetryable is returned when retrying a message that has not failed.
+var ErrNotRetryable = errors.New("only failed messages can be retried")
+
+// FlushResult counts the outcomes of a flush.
+type FlushResult struct {
+	Sent     int
+	Retrying int
+	Failed   int
+}
+
+// Outbox is a Sender that persists messages and delivers them from a worker
+// through a transport, retrying failed deliveries with exponential backoff.
+// Messages survive restarts and a slow or unavailable mail server does not
+// hold up the code sending them.
+type Outbox struct {
+	dbc       *sqlx.DB
+	querier   db.Querier
+	transport Sender
+	from      string
+	now       func() time.Time
+	wake      chan struct{}
+}
+
+// NewOutbox returns an outbox delivering through transport. from is the
+// sender of messages that do not set one.
+func NewOutbox(dbc *sqlx.DB, querier db.Querier, transport Sender, from string) *Outbox {
+	return &Outbox{
+		dbc:       dbc,
+		querier:   querier,
+		transport: transport,
+		from:      from,
+		now:       func() time.Time { return time.Now().UTC() },
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Send queues msg. It fails only if msg cannot be delivered at all.
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = o.from
+	}
+	if _, err := netmail.ParseAddress(msg.From); err != nil {
+		return fmt.Errorf("invalid sender %q: %w", msg.From, err)
+	}
+	if len(msg.To) == 0 {
+		return errors.New("no recipients")
+	}
+	for _, to := range msg.To {
+		if _, err := netmail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+	}
+
+	_, err := o.querier.InsertOutboxEmail(ctx, o.dbc, db.InsertOutboxEmailParams{
+		ID:          uuid.New().String(),
+		FromAddress: msg.From,
+		ToAddresses: strings.Join(msg.To, ","),
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		HTML:        msg.HTML,
+		CreatedAt:   o.now().Format(db.TimeFormat),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deliver right away rather than on the next tick
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers the queued messages of every workspace until ctx is
+// cancelled, checking every interval and whenever a message is queued.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := db.EachWorkspace(ctx, o.dbc, o.querier, func(ctx context.Context) error {
+			_, err := o.Flush(ctx)
+			return err
+		})
+		if err != nil {
+			slog.Error("Failed to flush email outbox", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// Flush tries every message of the context's workspace that is due, until
+// none are left.
+func (o *Outbox) Flush(ctx context.Context) (FlushResult, error) {
+	var result FlushResult
+	for {
+		now := o.now()
+		due, err := o.querier.ListDueOutboxEmails(ctx, o.dbc, now.Format(db.TimeFormat), flushBatch)
+		if err != nil {
+			return result, err
+		}
+		if len(due) == 0 {
+			return result, nil
+		}
+
+		for _, email := range due {
+			updated, err := o.deliver(ctx, email, now)
+			if err != nil {
+				return result, err
+			}
+			switch updated.Status {
+			case StatusSent:
+				result.Sent++
+			case StatusFailed:
+				result.Failed++
+			default:
+				result.Retrying++
+			}
+		}
+	}
+}
+
+func (o *Outbox) deliver(ctx context.Context, email db.OutboxEmail, now time.Time) (db.OutboxEmail, error) {
+	err := o.transport.Send(ctx, Message{
+		ID:      email.ID,
+		From:    email.FromAddress,
+		To:      strings.Split(email.ToAddresses, ","),
+		Subject: email.Subject,
+		Body:    email.Body,
+		HTML:    email.HTML,
+	})
+
+	arg := db.UpdateOutboxEmailParams{
+		ID:            email.ID,
+		Status:        StatusSent,
+		Attempts:      email.Attempts + 1,
+		NextAttemptAt: email.NextAttemptAt,
+	}
+	switch {
+	case err == nil:
+		arg.SentAt = now.Format(db.TimeFormat)
+	case arg.Attempts >= maxAttempts:
+		arg.Status = StatusFailed
+		arg.LastError = err.Error()
+		slog.Error("Giving up on email", "email", email.ID, "attempts", arg.Attempts, "error", err)
+	default:
+		arg.Status = StatusPending
+		arg.LastError = err.Error()
+		arg.NextAttemptAt = now.Add(retryDelay(arg.Attempts)).Format(db.TimeFormat)
+		slog.Warn("Failed to send email, will retry", "email", email.ID, "attempts", arg.Attempts, "error", err)
+	}
+
+	return o.querier.UpdateOutboxEmail(ctx, o.dbc, arg)
+}
+
+// Retry queues a failed message for another round of attempts.
+func (o *Outbox) Retry(ctx context.Context, id string) (db.OutboxEmail, error) {
+	email, err := o.querier.GetOutboxEmail(ctx, o.dbc, id)
+	if err != nil {
+		return db.OutboxEmail{}, err
+	}
+	if email.Status != StatusFailed {
+		return db.OutboxEmail{}, ErrNotRetryable
+	}
+
+	email, err = o.querier.UpdateOutboxEmail(ctx, o.dbc, db.UpdateOutboxEmailParams{
+		ID:            email.ID,
+		Status:        StatusPending,
+		LastError:     email.LastError,
+		NextAttemptAt: o.now().Format(db.TimeFormat),
+	})
+	if err != nil {
+		return db.OutboxEmail{}, err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return email, nil
+}
+
+// retryDelay doubles the wait after every failed attempt, up to maxRetry.
+func retryDelay(attempts int) time.Duration {
+	delay := firstRetry
+	for n := 1; n < attempts && delay < maxRetry; n++ {
+		delay *= 2
+	}
+	return min(delay, maxRetry)
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Template renders messages from Go templates. The subject and text body are
+// text/template, the HTML body is html/template so that data is escaped.
+type Template struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// NewTemplate parses a template. html may be empty for plain text messages.
+func NewTemplate(name, subject, text, html string) (*Template, error) {
+	t := &Template{}
+
+	var err error
+	if t.subject, err = texttemplate.New(name + ".subject").Parse(subject); err != nil {
+		return nil, err
+	}
+	if t.text, err = texttemplate.New(name + ".text").Parse(text); err != nil {
+		return nil, err
+	}
+	if html != "" {
+		if t.html, err = htmltemplate.New(name + ".html").Parse(html); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// MustTemplate is NewTemplate for templates known to be valid.
+func MustTemplate(name, subject, text, html string) *Template {
+	t, err := NewTemplate(name, subject, text, html)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render returns a message to the given recipients with its subject and
+// bodies executed against data.
+func (t *Template) Render(to []string, data any) (Message, error) {
+	msg := Message{To: to}
+
+	var sb strings.Builder
+	if err := t.subject.Execute(&sb, data); err != nil {
+		return Message{}, err
+	}
+	// Subjects are a single line
+	msg.Subject = strings.Join(strings.Fields(sb.String()), " ")
+
+	sb.Reset()
+	if err := t.text.Execute(&sb, data); err != nil {
+		return Message{}, err
+	}
+	msg.Body = sb.String()
+
+	if t.html != nil {
+		sb.Reset()
+		if err := t.html.Execute(&sb, data); err != nil {
+			return Message{}, err
+		}
+		msg.HTML = sb.String()
+	}
+
+	return msg, nil
+}
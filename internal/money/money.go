@@ -0,0 +1,84 @@
+// Package money handles amounts of money, kept as integers in the minor unit
+// of their currency, such as cents for USD, so that sums are exact.
+package money
+
+import (
+	"strconv"
+	"strings"
+)
+
+// exponents lists the ISO 4217 currencies whose minor unit is not a
+// hundredth of the major one.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code:
+// three upper case letters.
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Exponent returns how many decimal places the minor unit of currency
+// stands for.
+func Exponent(currency string) int {
+	if e, ok := exponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+// Format writes amount, in minor units, in the major unit of its currency
+// with thousands separated, as in 1,234.50 USD.
+func Format(amount int64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	exp := Exponent(currency)
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-exp], digits[len(digits)-exp:]
+
+	var sb strings.Builder
+	sb.WriteString(sign)
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(c)
+	}
+	if fraction != "" {
+		sb.WriteByte('.')
+		sb.WriteString(fraction)
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(currency)
+	return sb.String()
+}
+
+// BasisPoints is a rate in hundredths of a percent, so 825 is 8.25%.
+type BasisPoints int64
+
+// Of returns the rate's share of amount, rounded half away from zero to
+// the minor unit.
+func (bp BasisPoints) Of(amount int64) int64 {
+	product := amount * int64(bp)
+	if product < 0 {
+		return -((-product + 5000) / 10000)
+	}
+	return (product + 5000) / 10000
+}
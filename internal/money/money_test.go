@@ -0,0 +1,49 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	tcs := []struct {
+		amount   int64
+		currency string
+		expected string
+	}{
+		{amount: 123450, currency: "USD", expected: "1,234.50 USD"},
+		{amount: 5, currency: "EUR", expected: "0.05 EUR"},
+		{amount: 0, currency: "EUR", expected: "0.00 EUR"},
+		{amount: -99, currency: "GBP", expected: "-0.99 GBP"},
+		{amount: 1500000, currency: "JPY", expected: "1,500,000 JPY"},
+		{amount: 1234, currency: "KWD", expected: "1.234 KWD"},
+		{amount: 100000000, currency: "USD", expected: "1,000,000.00 USD"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.expected, func(t *testing.T) {
+			require.Equal(t, tc.expected, Format(tc.amount, tc.currency))
+		})
+	}
+}
+
+func TestBasisPoints(t *testing.T) {
+	a := require.New(t)
+
+	a.Equal(int64(825), BasisPoints(825).Of(10000))
+	// Half a cent rounds away from zero
+	a.Equal(int64(1), BasisPoints(5000).Of(1))
+	a.Equal(int64(-1), BasisPoints(5000).Of(-1))
+	a.Equal(int64(0), BasisPoints(4999).Of(1))
+	a.Equal(int64(0), BasisPoints(0).Of(123456))
+}
+
+func TestValidCurrency(t *testing.T) {
+	a := require.New(t)
+
+	a.True(ValidCurrency("USD"))
+	a.False(ValidCurrency("usd"))
+	a.False(ValidCurrency("US"))
+	a.False(ValidCurrency("US1"))
+}
@@ -0,0 +1,121 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"regexp"
+	"sort"
+)
+
+// ErrInvalidRate is returned for exchange rates that are not positive
+// decimals.
+var ErrInvalidRate = errors.New("exchange rates must be positive decimals such as 1.0845")
+
+var decimal = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// ParseRate reads an exchange rate written as a positive decimal. Rates are
+// kept as fractions so that conversions are exact until rounded.
+func ParseRate(s string) (*big.Rat, error) {
+	if !decimal.MatchString(s) {
+		return nil, ErrInvalidRate
+	}
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || rate.Sign() <= 0 {
+		return nil, ErrInvalidRate
+	}
+	return rate, nil
+}
+
+// Convert converts amount, in minor units of from, at rate, the price of
+// one unit of from in to, into minor units of to, rounded half away from
+// zero.
+func Convert(amount int64, from, to string, rate *big.Rat) int64 {
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(amount), rate)
+	v.Mul(v, pow10(Exponent(to)))
+	v.Quo(v, pow10(Exponent(from)))
+
+	// Adding a half before truncating toward zero rounds half away from it
+	half := big.NewRat(1, 2)
+	if v.Sign() < 0 {
+		half.Neg(half)
+	}
+	v.Add(v, half)
+	return new(big.Int).Quo(v.Num(), v.Denom()).Int64()
+}
+
+func pow10(n int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
+
+// Rate is the price of one unit of From in To from the date EffectiveOn,
+// formatted as 2006-01-02, until the next rate for the pair.
+type Rate struct {
+	From        string
+	To          string
+	EffectiveOn string
+	Rate        *big.Rat
+}
+
+// datedRate is the price of a currency in the base currency from a date.
+type datedRate struct {
+	on   string
+	rate *big.Rat
+}
+
+// Rates converts amounts into a base currency at the rate in effect on a
+// given date.
+type Rates struct {
+	base  string
+	rates map[string][]datedRate
+}
+
+// NewRates returns the conversions into base that rates allow. Rates from
+// base into another currency are used inverted where that currency has no
+// rate into base from the same date. Rates between other currencies are
+// ignored.
+func NewRates(base string, rates []Rate) *Rates {
+	r := &Rates{
+		base:  base,
+		rates: map[string][]datedRate{},
+	}
+
+	direct := map[[2]string]bool{}
+	for _, rate := range rates {
+		if rate.To == base && rate.From != base {
+			r.rates[rate.From] = append(r.rates[rate.From], datedRate{on: rate.EffectiveOn, rate: rate.Rate})
+			direct[[2]string{rate.From, rate.EffectiveOn}] = true
+		}
+	}
+	for _, rate := range rates {
+		if rate.From == base && rate.To != base && !direct[[2]string{rate.To, rate.EffectiveOn}] {
+			inverse := new(big.Rat).Inv(rate.Rate)
+			r.rates[rate.To] = append(r.rates[rate.To], datedRate{on: rate.EffectiveOn, rate: inverse})
+		}
+	}
+
+	for _, dated := range r.rates {
+		sort.Slice(dated, func(i, j int) bool { return dated[i].on < dated[j].on })
+	}
+	return r
+}
+
+// Base returns the currency amounts are converted into.
+func (r *Rates) Base() string {
+	return r.base
+}
+
+// Convert converts amount, in minor units of currency, into minor units of
+// the base currency at the latest rate effective on or before the date on.
+// It reports false when currency has no rate by then.
+func (r *Rates) Convert(amount int64, currency, on string) (int64, bool) {
+	if currency == r.base {
+		return amount, true
+	}
+
+	dated := r.rates[currency]
+	i := sort.Search(len(dated), func(i int) bool { return dated[i].on > on })
+	if i == 0 {
+		return 0, false
+	}
+	return Convert(amount, currency, r.base, dated[i-1].rate), true
+}
@@ -0,0 +1,72 @@
+package money
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	a := require.New(t)
+
+	rate, err := ParseRate("1.0845")
+	a.NoError(err)
+	a.Equal(big.NewRat(10845, 10000), rate)
+
+	for _, s := range []string{"", "0", "0.000", "-1.2", "1/3", "1e3", " 1.2", "1."} {
+		_, err := ParseRate(s)
+		a.ErrorIs(err, ErrInvalidRate, s)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	a := require.New(t)
+
+	rate := big.NewRat(10845, 10000)
+	a.Equal(int64(108450), Convert(100000, "EUR", "USD", rate))
+	// 0.01 EUR is 1.0845 cents
+	a.Equal(int64(1), Convert(1, "EUR", "USD", rate))
+	a.Equal(int64(-1), Convert(-1, "EUR", "USD", rate))
+	// Half a cent rounds away from zero
+	a.Equal(int64(2), Convert(1, "EUR", "USD", big.NewRat(3, 2)))
+	a.Equal(int64(-2), Convert(-1, "EUR", "USD", big.NewRat(3, 2)))
+
+	// Minor units differ between currencies: 100.00 USD at 157.5 JPY
+	a.Equal(int64(15750), Convert(10000, "USD", "JPY", big.NewRat(315, 2)))
+	a.Equal(int64(10000), Convert(15750, "JPY", "USD", big.NewRat(2, 315)))
+}
+
+func TestRates(t *testing.T) {
+	a := require.New(t)
+
+	rates := NewRates("USD", []Rate{
+		{From: "EUR", To: "USD", EffectiveOn: "2026-01-01", Rate: big.NewRat(11, 10)},
+		{From: "EUR", To: "USD", EffectiveOn: "2026-02-01", Rate: big.NewRat(12, 10)},
+		// Only known the other way around
+		{From: "USD", To: "GBP", EffectiveOn: "2026-01-01", Rate: big.NewRat(8, 10)},
+		// Superseded by the direct rate of the same date
+		{From: "USD", To: "EUR", EffectiveOn: "2026-02-01", Rate: big.NewRat(1, 2)},
+		// Between other currencies
+		{From: "EUR", To: "GBP", EffectiveOn: "2026-01-01", Rate: big.NewRat(9, 10)},
+	})
+
+	converted, ok := rates.Convert(1000, "EUR", "2026-01-31")
+	a.True(ok)
+	a.Equal(int64(1100), converted)
+	converted, ok = rates.Convert(1000, "EUR", "2026-02-01")
+	a.True(ok)
+	a.Equal(int64(1200), converted)
+	converted, ok = rates.Convert(800, "GBP", "2026-03-01")
+	a.True(ok)
+	a.Equal(int64(1000), converted)
+	converted, ok = rates.Convert(1000, "USD", "2000-01-01")
+	a.True(ok)
+	a.Equal(int64(1000), converted)
+
+	// Rates are never applied before they take effect
+	_, ok = rates.Convert(1000, "EUR", "2025-12-31")
+	a.False(ok)
+	_, ok = rates.Convert(1000, "CHF", "2026-03-01")
+	a.False(ok)
+}
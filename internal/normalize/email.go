@@ -0,0 +1,52 @@
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// Email trims and lowercases an address, rejecting anything that is not a
+// bare addr-spec.
+func Email(raw string) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(raw))
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, raw)
+	}
+
+	return email, nil
+}
+
+// gmailDomains deliver mail regardless of dots in the local part.
+var gmailDomains = map[string]bool{
+	"gmail.com":      true,
+	"googlemail.com": true,
+}
+
+// CanonicalEmail returns the form of an address used to match the same
+// mailbox written differently: lowercased, without a +tag and, for Gmail,
+// without dots in the local part.
+func CanonicalEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+
+	if plus := strings.Index(local, "+"); plus > 0 {
+		local = local[:plus]
+	}
+	if gmailDomains[domain] {
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+
+	return local + "@" + domain
+}
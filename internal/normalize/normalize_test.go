@@ -0,0 +1,63 @@
+package normalize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhone(t *testing.T) {
+	tcs := []struct {
+		name     string
+		raw      string
+		region   string
+		expected string
+		err      error
+	}{
+		{name: "US national", raw: "(555) 010-0100", region: "US", expected: "+15550100100"},
+		{name: "US with trunk prefix", raw: "1-555-010-0100", region: "US", expected: "+15550100100"},
+		{name: "GB national", raw: "020 7946 0018", region: "GB", expected: "+442079460018"},
+		{name: "International", raw: "+44 20 7946 0018", region: "US", expected: "+442079460018"},
+		{name: "International 00 prefix", raw: "0049 30 901820", region: "GB", expected: "+4930901820"},
+		{name: "Too short", raw: "555-0100", region: "US", err: ErrInvalidPhone},
+		{name: "Letters", raw: "555-CALL-NOW", region: "US", err: ErrInvalidPhone},
+		{name: "Empty", raw: " ", region: "US", err: ErrInvalidPhone},
+		{name: "Unknown region", raw: "555 010 0100", region: "XX", err: ErrUnknownRegion},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := require.New(t)
+
+			phone, err := Phone(tc.raw, tc.region)
+			if tc.err != nil {
+				a.ErrorIs(err, tc.err)
+				return
+			}
+			a.NoError(err)
+			a.Equal(tc.expected, phone)
+		})
+	}
+}
+
+func TestEmail(t *testing.T) {
+	a := require.New(t)
+
+	email, err := Email("  Jane.Doe@Example.COM ")
+	a.NoError(err)
+	a.Equal("jane.doe@example.com", email)
+
+	_, err = Email("Jane <jane@example.com>")
+	a.ErrorIs(err, ErrInvalidEmail)
+
+	_, err = Email("jane")
+	a.ErrorIs(err, ErrInvalidEmail)
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	a := require.New(t)
+
+	a.Equal("janedoe@gmail.com", CanonicalEmail("Jane.Doe+crm@googlemail.com"))
+	a.Equal("jane.doe@example.com", CanonicalEmail("jane.doe+newsletter@example.com"))
+	a.Equal("+tag@example.com", CanonicalEmail("+tag@example.com"))
+}
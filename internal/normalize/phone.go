@@ -0,0 +1,122 @@
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidPhone  = errors.New("invalid phone number")
+	ErrUnknownRegion = errors.New("unknown phone region")
+)
+
+type region struct {
+	callingCode string
+	// trunkPrefix is dialled before national numbers inside the region and
+	// dropped in international format
+	trunkPrefix string
+	// minLength and maxLength bound the national significant number
+	minLength int
+	maxLength int
+}
+
+// regions maps ISO 3166-1 alpha-2 codes to their numbering plan.
+var regions = map[string]region{
+	"AU": {callingCode: "61", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"BE": {callingCode: "32", trunkPrefix: "0", minLength: 8, maxLength: 9},
+	"BR": {callingCode: "55", trunkPrefix: "0", minLength: 10, maxLength: 11},
+	"CA": {callingCode: "1", trunkPrefix: "1", minLength: 10, maxLength: 10},
+	"CH": {callingCode: "41", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"DE": {callingCode: "49", trunkPrefix: "0", minLength: 6, maxLength: 13},
+	"DK": {callingCode: "45", minLength: 8, maxLength: 8},
+	"ES": {callingCode: "34", minLength: 9, maxLength: 9},
+	"FR": {callingCode: "33", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"GB": {callingCode: "44", trunkPrefix: "0", minLength: 9, maxLength: 10},
+	"IE": {callingCode: "353", trunkPrefix: "0", minLength: 7, maxLength: 9},
+	"IN": {callingCode: "91", trunkPrefix: "0", minLength: 10, maxLength: 10},
+	"IT": {callingCode: "39", minLength: 6, maxLength: 11},
+	"JP": {callingCode: "81", trunkPrefix: "0", minLength: 9, maxLength: 10},
+	"MX": {callingCode: "52", minLength: 10, maxLength: 10},
+	"NL": {callingCode: "31", trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"NO": {callingCode: "47", minLength: 8, maxLength: 8},
+	"NZ": {callingCode: "64", trunkPrefix: "0", minLength: 8, maxLength: 10},
+	"PL": {callingCode: "48", minLength: 9, maxLength: 9},
+	"PT": {callingCode: "351", minLength: 9, maxLength: 9},
+	"SE": {callingCode: "46", trunkPrefix: "0", minLength: 7, maxLength: 9},
+	"SG": {callingCode: "65", minLength: 8, maxLength: 8},
+	"US": {callingCode: "1", trunkPrefix: "1", minLength: 10, maxLength: 10},
+	"ZA": {callingCode: "27", trunkPrefix: "0", minLength: 9, maxLength: 9},
+}
+
+// ValidRegion reports whether phone numbers can be parsed relative to code.
+func ValidRegion(code string) bool {
+	_, ok := regions[strings.ToUpper(code)]
+	return ok
+}
+
+// Phone parses a phone number written in international format, or in the
+// national format of defaultRegion, and returns it in E.164 form.
+func Phone(raw, defaultRegion string) (string, error) {
+	digits, international, err := phoneDigits(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if !international {
+		r, ok := regions[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return "", fmt.Errorf("%w %q", ErrUnknownRegion, defaultRegion)
+		}
+
+		national := digits
+		if r.trunkPrefix != "" && len(national) > r.minLength && strings.HasPrefix(national, r.trunkPrefix) {
+			national = strings.TrimPrefix(national, r.trunkPrefix)
+		}
+		if len(national) < r.minLength || len(national) > r.maxLength {
+			return "", fmt.Errorf("%w: %q is not a valid %s number", ErrInvalidPhone, raw, strings.ToUpper(defaultRegion))
+		}
+		digits = r.callingCode + national
+	}
+
+	// E.164 numbers have at most 15 digits and never start with 0
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPhone, raw)
+	}
+
+	return "+" + digits, nil
+}
+
+// phoneDigits strips formatting from raw, reporting whether it carried an
+// international prefix (+ or 00).
+func phoneDigits(raw string) (string, bool, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return "", false, fmt.Errorf("%w: empty", ErrInvalidPhone)
+	}
+
+	international := false
+	if strings.HasPrefix(s, "+") {
+		international = true
+		s = s[1:]
+	}
+
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')' || r == '/':
+		default:
+			return "", false, fmt.Errorf("%w: unexpected %q in %q", ErrInvalidPhone, r, raw)
+		}
+	}
+	digits := b.String()
+
+	if !international && strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+
+	return digits, international, nil
+}
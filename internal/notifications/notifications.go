@@ -0,0 +1,223 @@
+// Package notifications turns domain events into in-app notifications for
+// the users they concern and delivers them live to subscribed clients.
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+const (
+	KindAssigned    = "assigned"
+	KindMentioned   = "mentioned"
+	KindTaskDueSoon = "task_due_soon"
+	KindTaskOverdue = "task_overdue"
+	KindWorkflow    = "workflow"
+)
+
+// subscriberBuffer is how many notifications a slow subscriber may fall
+// behind before further ones are dropped. Dropped notifications are still
+// listed, only their live delivery is lost.
+const subscriberBuffer = 16
+
+// Notifier stores a notification for every event that concerns a user.
+type Notifier struct {
+	dbc          *sqlx.DB
+	querier      db.Querier
+	eventService pubsub.EventServicer
+	now          func() time.Time
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan db.Notification]struct{}
+}
+
+func NewNotifier(dbc *sqlx.DB, querier db.Querier, eventService pubsub.EventServicer) *Notifier {
+	return &Notifier{
+		dbc:          dbc,
+		querier:      querier,
+		eventService: eventService,
+		now:          func() time.Time { return time.Now().UTC() },
+		subscribers:  map[string]map[chan db.Notification]struct{}{},
+	}
+}
+
+// Run handles events until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	n.eventService.Consume(ctx, n.handleEvents(ctx))
+}
+
+// Start handles events in the background until ctx is cancelled. It returns
+// once subscribed, so no event published afterwards is missed.
+func (n *Notifier) Start(ctx context.Context) {
+	n.eventService.Subscribe(ctx, n.handleEvents(ctx))
+}
+
+func (n *Notifier) handleEvents(ctx context.Context) func(pubsub.Event) {
+	return func(event pubsub.Event) {
+		if _, _, err := n.Handle(db.WithWorkspace(ctx, event.WorkspaceID), event); err != nil {
+			slog.Error("Failed to notify", "event", event.Type, "id", event.ID, "error", err)
+		}
+	}
+}
+
+// Handle stores the notification event calls for, if any, and delivers it to
+// the user's subscribers. It reports false when there was nothing to notify,
+// including when the user was already notified of the event.
+func (n *Notifier) Handle(ctx context.Context, event pubsub.Event) (db.Notification, bool, error) {
+	arg, ok, err := n.notification(ctx, event)
+	if err != nil || !ok {
+		return db.Notification{}, false, err
+	}
+
+	user, err := n.querier.GetUser(ctx, n.dbc, arg.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Notification{}, false, nil
+	}
+	if err != nil {
+		return db.Notification{}, false, err
+	}
+	if !user.Active {
+		return db.Notification{}, false, nil
+	}
+
+	arg.ID = uuid.New().String()
+	arg.EventID = event.ID
+	arg.CreatedAt = n.now().Format(db.TimeFormat)
+
+	notification, created, err := n.querier.InsertNotification(ctx, n.dbc, arg)
+	if err != nil || !created {
+		return db.Notification{}, false, err
+	}
+
+	n.deliver(notification)
+	return notification, true, nil
+}
+
+// notification builds the notification for event, reporting false for events
+// that concern nobody.
+func (n *Notifier) notification(ctx context.Context, event pubsub.Event) (db.InsertNotificationParams, bool, error) {
+	p := event.Payload
+
+	switch event.Type {
+	case pubsub.EventEntityAssigned:
+		userID := payloadString(p, "assigned_to")
+		if userID == "" || userID == payloadString(p, "previous_assigned_to") {
+			return db.InsertNotificationParams{}, false, nil
+		}
+		kind := "Lead"
+		if payloadString(p, "status") == "converted" {
+			kind = "Contact"
+		}
+		return db.InsertNotificationParams{
+			UserID:   userID,
+			Kind:     KindAssigned,
+			Title:    fmt.Sprintf("%s assigned to you: %s", kind, fullName(p)),
+			Body:     payloadString(p, "email"),
+			EntityID: payloadString(p, "id"),
+		}, true, nil
+
+	case pubsub.EventUserMentioned:
+		title := "You were mentioned"
+		if authorID := payloadString(p, "author_id"); authorID != "" {
+			author, err := n.querier.GetUser(ctx, n.dbc, authorID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return db.InsertNotificationParams{}, false, err
+			}
+			if err == nil {
+				title = strings.TrimSpace(author.FirstName+" "+author.LastName) + " mentioned you"
+			}
+		}
+		return db.InsertNotificationParams{
+			UserID:   payloadString(p, "user_id"),
+			Kind:     KindMentioned,
+			Title:    title,
+			Body:     payloadString(p, "notes"),
+			EntityID: payloadString(p, "entity_id"),
+		}, true, nil
+
+	case pubsub.EventTaskDueSoon, pubsub.EventTaskOverdue:
+		userID := payloadString(p, "assigned_to")
+		if userID == "" {
+			return db.InsertNotificationParams{}, false, nil
+		}
+		kind, title := KindTaskDueSoon, "Task due soon: "
+		if event.Type == pubsub.EventTaskOverdue {
+			kind, title = KindTaskOverdue, "Task overdue: "
+		}
+		return db.InsertNotificationParams{
+			UserID:   userID,
+			Kind:     kind,
+			Title:    title + payloadString(p, "name"),
+			Body:     "Due " + payloadString(p, "due_date"),
+			EntityID: payloadString(p, "entity_id"),
+			TaskID:   payloadString(p, "id"),
+		}, true, nil
+
+	case pubsub.EventWorkflowNotification:
+		return db.InsertNotificationParams{
+			UserID:   payloadString(p, "user_id"),
+			Kind:     KindWorkflow,
+			Title:    payloadString(p, "title"),
+			Body:     payloadString(p, "body"),
+			EntityID: payloadString(p, "entity_id"),
+		}, true, nil
+	}
+
+	return db.InsertNotificationParams{}, false, nil
+}
+
+// Subscribe returns a channel receiving the user's notifications as they are
+// created, and a function to call once they are no longer wanted.
+func (n *Notifier) Subscribe(userID string) (<-chan db.Notification, func()) {
+	ch := make(chan db.Notification, subscriberBuffer)
+
+	n.mu.Lock()
+	if n.subscribers[userID] == nil {
+		n.subscribers[userID] = map[chan db.Notification]struct{}{}
+	}
+	n.subscribers[userID][ch] = struct{}{}
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subscribers[userID], ch)
+		if len(n.subscribers[userID]) == 0 {
+			delete(n.subscribers, userID)
+		}
+	}
+}
+
+func (n *Notifier) deliver(notification db.Notification) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subscribers[notification.UserID] {
+		select {
+		case ch <- notification:
+		default:
+			slog.Warn("Dropped live notification for slow subscriber", "user", notification.UserID, "notification", notification.ID)
+		}
+	}
+}
+
+func payloadString(payload map[string]any, key string) string {
+	s, _ := payload[key].(string)
+	return s
+}
+
+func fullName(payload map[string]any) string {
+	return strings.TrimSpace(payloadString(payload, "first_name") + " " + payloadString(payload, "last_name"))
+}
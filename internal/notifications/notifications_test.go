@@ -0,0 +1,107 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+func TestHandle(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	defer dbc.Close()
+	a.NoError(database.Migrate(ctx, dbc))
+
+	_, err = dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email) VALUES
+	('u1', 'Ann', 'Lee', 'ann@example.com'),
+	('u2', 'Bob', 'Ray', 'bob@example.com');
+	UPDATE users SET active = 0 WHERE id = 'u2';
+	`)
+	a.NoError(err)
+
+	notifier := NewNotifier(dbc, db.NewQueries(), pubsub.NewEventService())
+	received, unsubscribe := notifier.Subscribe("u1")
+	defer unsubscribe()
+
+	assigned := pubsub.NewEvent(pubsub.EventEntityAssigned, map[string]any{
+		"id":                   "lead1",
+		"first_name":           "Jane",
+		"last_name":            "Doe",
+		"email":                "jane@example.com",
+		"status":               "new",
+		"assigned_to":          "u1",
+		"previous_assigned_to": "",
+	})
+
+	// Test
+	notification, ok, err := notifier.Handle(ctx, assigned)
+	a.NoError(err)
+	a.True(ok)
+	a.Equal(KindAssigned, notification.Kind)
+	a.Equal("Lead assigned to you: Jane Doe", notification.Title)
+	a.Equal("lead1", notification.EntityID)
+
+	select {
+	case n := <-received:
+		a.Equal(notification.ID, n.ID)
+	case <-time.After(time.Second):
+		a.FailNow("missing live notification")
+	}
+
+	// Redelivered events do not notify twice
+	_, ok, err = notifier.Handle(ctx, assigned)
+	a.NoError(err)
+	a.False(ok)
+
+	notification, ok, err = notifier.Handle(ctx, pubsub.NewEvent(pubsub.EventUserMentioned, map[string]any{
+		"user_id":   "u1",
+		"author_id": "u2",
+		"entity_id": "lead1",
+		"notes":     "@ann can you call her back?",
+	}))
+	a.NoError(err)
+	a.True(ok)
+	a.Equal("Bob Ray mentioned you", notification.Title)
+
+	notification, ok, err = notifier.Handle(ctx, pubsub.NewEvent(pubsub.EventTaskOverdue, map[string]any{
+		"id":          "task1",
+		"name":        "Send contract",
+		"due_date":    "2024-03-01",
+		"assigned_to": "u1",
+	}))
+	a.NoError(err)
+	a.True(ok)
+	a.Equal(KindTaskOverdue, notification.Kind)
+	a.Equal("task1", notification.TaskID)
+
+	// Inactive users, unassigned tasks and unrelated events notify nobody
+	_, ok, err = notifier.Handle(ctx, pubsub.NewEvent(pubsub.EventWorkflowNotification, map[string]any{
+		"user_id": "u2",
+		"title":   "Hot lead",
+	}))
+	a.NoError(err)
+	a.False(ok)
+	_, ok, err = notifier.Handle(ctx, pubsub.NewEvent(pubsub.EventTaskDueSoon, map[string]any{"id": "task2"}))
+	a.NoError(err)
+	a.False(ok)
+	_, ok, err = notifier.Handle(ctx, pubsub.NewEvent(pubsub.EventLeadCreated, map[string]any{"assigned_to": "u1"}))
+	a.NoError(err)
+	a.False(ok)
+
+	unread, err := db.NewQueries().CountUnreadNotifications(ctx, dbc, "u1")
+	a.NoError(err)
+	a.Equal(3, unread)
+}
@@ -0,0 +1,87 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+const (
+	ActivityKindCall    = "call"
+	ActivityKindMeeting = "meeting"
+	ActivityKindEmail   = "email"
+	ActivityKindNote    = "note"
+)
+
+type LogActivityParams struct {
+	EntityID string
+	Kind     string
+	UserID   string
+	Notes    string
+	// OccurredAt defaults to now
+	OccurredAt string
+}
+
+// LogActivity records an interaction with a lead or contact. Users mentioned
+// in its notes are notified.
+func LogActivity(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params LogActivityParams,
+	eventService pubsub.EventServicer,
+) (db.Activity, error) {
+	if params.OccurredAt == "" {
+		params.OccurredAt = time.Now().UTC().Format(db.TimeFormat)
+	} else if _, err := time.Parse(db.TimeFormat, params.OccurredAt); err != nil {
+		return db.Activity{}, fmt.Errorf("%w: occurred_at must be formatted as %s", ErrInvalidCommand, db.TimeFormat)
+	}
+
+	if _, err := querier.GetEntity(ctx, dbc, params.EntityID); err != nil {
+		return db.Activity{}, err
+	}
+	if params.UserID != "" {
+		_, err := querier.GetUser(ctx, dbc, params.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.Activity{}, fmt.Errorf("%w: unknown user %q", ErrInvalidCommand, params.UserID)
+		}
+		if err != nil {
+			return db.Activity{}, err
+		}
+	}
+
+	activity, err := querier.InsertActivity(ctx, dbc, db.InsertActivityParams{
+		ID:         uuid.New().String(),
+		EntityID:   params.EntityID,
+		Kind:       params.Kind,
+		UserID:     sql.NullString{String: params.UserID, Valid: params.UserID != ""},
+		Notes:      params.Notes,
+		OccurredAt: params.OccurredAt,
+	})
+	if err != nil {
+		return db.Activity{}, err
+	}
+
+	event := pubsub.NewEvent(pubsub.EventActivityLogged, map[string]any{
+		"id":          activity.ID,
+		"entity_id":   activity.EntityID,
+		"kind":        activity.Kind,
+		"user_id":     activity.UserID.String,
+		"occurred_at": activity.OccurredAt,
+	})
+	if err := eventService.Publish(ctx, event); err != nil {
+		slog.Error("Failed to publish event", "type", event.Type, "activity", activity.ID, "error", err)
+	}
+	publishMentions(ctx, dbc, querier, activity, eventService)
+
+	return activity, nil
+}
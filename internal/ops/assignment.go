@@ -0,0 +1,226 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/assignment"
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+const HistoryActionAssign = "assign"
+
+var ErrInactiveUser = errors.New("user is deactivated")
+
+// CheckAssignable returns an error unless userID is an existing, active user.
+func CheckAssignable(ctx context.Context, dbc db.DBExecutor, querier db.Querier, userID string) error {
+	user, err := querier.GetUser(ctx, dbc, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &FieldError{Field: "assigned_to", Err: fmt.Errorf("%w: unknown user %q", ErrInvalidCommand, userID)}
+	}
+	if err != nil {
+		return err
+	}
+	if !user.Active {
+		return &FieldError{Field: "assigned_to", Err: fmt.Errorf("%w: %w: %s", ErrInvalidCommand, ErrInactiveUser, userID)}
+	}
+	return nil
+}
+
+// AssignEntity hands an entity to userID, or unassigns it when userID is
+// empty, recording the change in its history.
+func AssignEntity(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	entityID, userID string,
+	eventService pubsub.EventServicer,
+) (entity db.Entity, err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Entity{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	previous, err := querier.GetEntity(ctx, tx, entityID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	if userID != "" {
+		if err = CheckAssignable(ctx, tx, querier, userID); err != nil {
+			return db.Entity{}, err
+		}
+	}
+
+	entity, err = querier.UpdateEntityAssignee(ctx, tx, entityID, sql.NullString{String: userID, Valid: userID != ""})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"previous_assigned_to": previous.AssignedTo.String,
+		"assigned_to":          userID,
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	err = querier.InsertEntityHistory(ctx, tx, db.InsertEntityHistoryParams{
+		EntityID: entity.ID,
+		Action:   HistoryActionAssign,
+		Details:  string(details),
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Entity{}, err
+	}
+
+	if previous.AssignedTo.String != userID {
+		publishAssigned(ctx, eventService, entity, previous.AssignedTo.String, "")
+	}
+
+	return entity, nil
+}
+
+func publishAssigned(
+	ctx context.Context,
+	eventService pubsub.EventServicer,
+	entity db.Entity,
+	previousAssignedTo, ruleID string,
+) {
+	payload := pubsub.EntityPayload(entity)
+	payload["previous_assigned_to"] = previousAssignedTo
+	payload["rule_id"] = ruleID
+
+	if err := eventService.Publish(ctx, pubsub.NewEvent(pubsub.EventEntityAssigned, payload)); err != nil {
+		slog.Error("Failed to publish event", "type", pubsub.EventEntityAssigned, "entity", entity.ID, "error", err)
+	}
+}
+
+type AssignmentRuleMember struct {
+	UserID string
+	Weight int
+}
+
+type CreateAssignmentRuleParams struct {
+	Name     string
+	Priority int
+	Field    string
+	Value    string
+	Strategy string
+	Members  []AssignmentRuleMember
+}
+
+func CreateAssignmentRule(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateAssignmentRuleParams,
+) (rule db.AssignmentRule, members []db.AssignmentRuleMember, err error) {
+	if err := assignment.ValidateRule(params.Field, params.Strategy); err != nil {
+		return db.AssignmentRule{}, nil, fmt.Errorf("%w: %s", ErrInvalidCommand, err)
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.AssignmentRule{}, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rule, err = querier.InsertAssignmentRule(ctx, tx, db.InsertAssignmentRuleParams{
+		ID:       uuid.New().String(),
+		Name:     params.Name,
+		Priority: params.Priority,
+		Field:    params.Field,
+		Value:    params.Value,
+		Strategy: params.Strategy,
+	})
+	if err != nil {
+		return db.AssignmentRule{}, nil, err
+	}
+
+	for _, m := range params.Members {
+		if _, err = querier.GetUser(ctx, tx, m.UserID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = fmt.Errorf("%w: unknown user %q", ErrInvalidCommand, m.UserID)
+			}
+			return db.AssignmentRule{}, nil, err
+		}
+
+		weight := m.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		err = querier.InsertAssignmentRuleMember(ctx, tx, db.InsertAssignmentRuleMemberParams{
+			RuleID: rule.ID,
+			UserID: m.UserID,
+			Weight: weight,
+		})
+		if err != nil {
+			return db.AssignmentRule{}, nil, err
+		}
+	}
+
+	members, err = querier.ListAssignmentRuleMembers(ctx, tx, rule.ID)
+	if err != nil {
+		return db.AssignmentRule{}, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.AssignmentRule{}, nil, err
+	}
+
+	return rule, members, nil
+}
+
+// SetUserActive activates or deactivates a user. Deactivated users are
+// skipped by the assignment rules and cannot be assigned records.
+func SetUserActive(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	userID string,
+	active bool,
+	eventService pubsub.EventServicer,
+) (db.User, error) {
+	user, err := querier.SetUserActive(ctx, dbc, userID, active)
+	if err != nil {
+		return db.User{}, err
+	}
+
+	eventType := pubsub.EventUserActivated
+	if !active {
+		eventType = pubsub.EventUserDeactivated
+	}
+	event := pubsub.NewEvent(eventType, map[string]any{
+		"id":         user.ID,
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"email":      user.Email,
+	})
+	if err := eventService.Publish(ctx, event); err != nil {
+		slog.Error("Failed to publish event", "type", eventType, "user", user.ID, "error", err)
+	}
+
+	return user, nil
+}
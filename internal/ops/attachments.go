@@ -0,0 +1,313 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/storage"
+)
+
+const HistoryActionAttachments = "attachments"
+
+// AttachmentObjectTypes lists the records files can be attached to.
+var AttachmentObjectTypes = []string{ObjectTypeLead, ObjectTypeContact, ObjectTypeTask}
+
+// maxFilenameLength bounds, in characters, attachment file names.
+const maxFilenameLength = 255
+
+// blobs serializes committing and removing attachment content, so that
+// content is not removed between being stored for a new attachment and the
+// attachment referencing it. Uploads are staged before taking it.
+var blobs sync.Mutex
+
+type AttachFileParams struct {
+	// ObjectType is lead, contact or task
+	ObjectType string
+	RecordID   string
+	Filename   string
+	// UploadedBy is the user attaching the file, empty when not known
+	UploadedBy string
+	// ViewerID, if set, must be able to see the record
+	ViewerID string
+}
+
+// AttachFile stores content and attaches it to a lead, contact or task.
+// Attaching to a lead or contact is recorded in its history.
+func AttachFile(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	store storage.Store,
+	params AttachFileParams,
+	content io.Reader,
+) (attachment db.Attachment, err error) {
+	if !slices.Contains(AttachmentObjectTypes, params.ObjectType) {
+		return db.Attachment{}, &FieldError{
+			Field: "object_type",
+			Err:   fmt.Errorf("%w: unknown object type %q", ErrInvalidCommand, params.ObjectType),
+		}
+	}
+
+	// Browsers may send the full client-side path
+	filename := strings.TrimSpace(path.Base(strings.ReplaceAll(params.Filename, `\`, "/")))
+	if filename == "" || filename == "." || filename == "/" || utf8.RuneCountInString(filename) > maxFilenameLength {
+		return db.Attachment{}, &FieldError{
+			Field: "file",
+			Err:   fmt.Errorf("%w: file names must be 1 to %d characters", ErrInvalidCommand, maxFilenameLength),
+		}
+	}
+
+	entityID, taskID, err := attachableRecord(ctx, dbc, querier, params.ObjectType, params.RecordID, params.ViewerID)
+	if err != nil {
+		return db.Attachment{}, err
+	}
+
+	staged, err := store.Stage(ctx, content)
+	if errors.Is(err, storage.ErrTooLarge) {
+		return db.Attachment{}, &FieldError{
+			Field: "file",
+			Err:   fmt.Errorf("%w: %w", ErrInvalidCommand, err),
+		}
+	}
+	if err != nil {
+		return db.Attachment{}, err
+	}
+	defer store.Discard(staged)
+
+	blobs.Lock()
+	defer blobs.Unlock()
+
+	blob := staged.Blob
+	if err = store.Commit(ctx, staged); err != nil {
+		return db.Attachment{}, err
+	}
+	defer func() {
+		if err != nil {
+			removeUnreferencedBlob(ctx, dbc, querier, store, blob.Key)
+		}
+	}()
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Attachment{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	attachment, err = querier.InsertAttachment(ctx, tx, db.InsertAttachmentParams{
+		ID:          uuid.New().String(),
+		EntityID:    entityID,
+		TaskID:      taskID,
+		Filename:    filename,
+		ContentType: blob.ContentType,
+		Size:        blob.Size,
+		BlobKey:     blob.Key,
+		UploadedBy:  params.UploadedBy,
+	})
+	if err != nil {
+		return db.Attachment{}, err
+	}
+
+	if err = recordAttachmentHistory(ctx, tx, querier, attachment, "attached"); err != nil {
+		return db.Attachment{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// ListAttachments returns the files attached to a lead, contact or task,
+// which viewerID, if set, must be able to see.
+func ListAttachments(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	objectType, recordID, viewerID string,
+) ([]db.Attachment, error) {
+	entityID, taskID, err := attachableRecord(ctx, dbc, querier, objectType, recordID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if taskID != "" {
+		return querier.ListTaskAttachments(ctx, dbc, taskID)
+	}
+	return querier.ListEntityAttachments(ctx, dbc, entityID)
+}
+
+// GetAttachment returns an attachment. With viewerID set, attachments on
+// records that user cannot see are not found.
+func GetAttachment(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	id, viewerID string,
+) (db.Attachment, error) {
+	attachment, err := querier.GetAttachment(ctx, dbc, id)
+	if err != nil {
+		return db.Attachment{}, err
+	}
+
+	objectType, recordID := ObjectTypeTask, attachment.TaskID
+	if attachment.EntityID != "" {
+		// Either kind of entity will do, whichever it is now
+		objectType, recordID = "", attachment.EntityID
+	}
+	if _, _, err := attachableRecord(ctx, dbc, querier, objectType, recordID, viewerID); err != nil {
+		return db.Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// DeleteAttachment removes an attachment, and its content once no other
+// attachment shares it. Removing it from a lead or contact is recorded in
+// its history.
+func DeleteAttachment(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	store storage.Store,
+	id, viewerID string,
+) (attachment db.Attachment, err error) {
+	blobs.Lock()
+	defer blobs.Unlock()
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Attachment{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	attachment, err = GetAttachment(ctx, tx, querier, id, viewerID)
+	if err != nil {
+		return db.Attachment{}, err
+	}
+
+	if err = querier.DeleteAttachment(ctx, tx, attachment.ID); err != nil {
+		return db.Attachment{}, err
+	}
+
+	if err = recordAttachmentHistory(ctx, tx, querier, attachment, "removed"); err != nil {
+		return db.Attachment{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Attachment{}, err
+	}
+
+	removeUnreferencedBlob(ctx, dbc, querier, store, attachment.BlobKey)
+
+	return attachment, nil
+}
+
+// attachableRecord looks up the lead, contact or task files are attached to,
+// returning its id as an entity or a task. An empty objectType matches both
+// leads and contacts. Records viewerID, if set, cannot see are not found.
+func attachableRecord(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	objectType, recordID, viewerID string,
+) (entityID, taskID string, err error) {
+	var assignedTo, teamID sql.NullString
+	if objectType == ObjectTypeTask {
+		task, err := querier.GetTask(ctx, dbc, recordID)
+		if err != nil {
+			return "", "", err
+		}
+		taskID, assignedTo, teamID = task.ID, task.AssignedTo, task.TeamID
+	} else {
+		entity, err := querier.GetEntity(ctx, dbc, recordID)
+		if err != nil {
+			return "", "", err
+		}
+		if objectType != "" && EntityObjectType(entity) != objectType {
+			return "", "", sql.ErrNoRows
+		}
+		entityID, assignedTo, teamID = entity.ID, entity.AssignedTo, entity.TeamID
+	}
+
+	if viewerID != "" {
+		ok, err := CanSeeRecord(ctx, dbc, querier, viewerID, assignedTo, teamID)
+		if err != nil {
+			return "", "", err
+		}
+		if !ok {
+			return "", "", sql.ErrNoRows
+		}
+	}
+
+	return entityID, taskID, nil
+}
+
+// recordAttachmentHistory notes in a lead's or contact's history that a
+// file was attached or removed. Tasks keep no history.
+func recordAttachmentHistory(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	attachment db.Attachment,
+	change string,
+) error {
+	if attachment.EntityID == "" {
+		return nil
+	}
+
+	details, err := json.Marshal(map[string]any{
+		change:          attachment.Filename,
+		"attachment_id": attachment.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return querier.InsertEntityHistory(ctx, dbc, db.InsertEntityHistoryParams{
+		EntityID: attachment.EntityID,
+		Action:   HistoryActionAttachments,
+		Details:  string(details),
+	})
+}
+
+// removeUnreferencedBlob removes content no attachment refers to anymore.
+// Failures are logged rather than returned, as the attachments are already
+// consistent and leftover content is harmless.
+func removeUnreferencedBlob(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	store storage.Store,
+	key string,
+) {
+	count, err := querier.CountBlobAttachments(ctx, dbc, key)
+	if err == nil && count == 0 {
+		err = store.Delete(ctx, key)
+	}
+	if err != nil {
+		slog.Error("Failed to remove attachment content", "blob", key, "error", err)
+	}
+}
@@ -0,0 +1,279 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/customfields"
+	"simplecrm/internal/db"
+)
+
+// CustomFieldObjectTypes lists the records custom fields can be defined on.
+var CustomFieldObjectTypes = []string{ObjectTypeLead, ObjectTypeContact, ObjectTypeTask}
+
+const HistoryActionCustomFields = "custom_fields"
+
+// customFieldKeyPattern keeps keys usable as query parameters, as in
+// cf.<key>=value.
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+type CreateCustomFieldParams struct {
+	ObjectType string
+	Key        string
+	Label      string
+	Type       string
+	// Options lists the allowed values of enum fields
+	Options []string
+}
+
+func CreateCustomField(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateCustomFieldParams,
+) (db.CustomField, error) {
+	if !slices.Contains(CustomFieldObjectTypes, params.ObjectType) {
+		return db.CustomField{}, &FieldError{
+			Field: "object_type",
+			Err:   fmt.Errorf("%w: unknown object type %q", ErrInvalidCommand, params.ObjectType),
+		}
+	}
+	if !customFieldKeyPattern.MatchString(params.Key) {
+		return db.CustomField{}, &FieldError{
+			Field: "key",
+			Err:   fmt.Errorf("%w: must be lowercase letters, digits and underscores", ErrInvalidCommand),
+		}
+	}
+	if !slices.Contains(customfields.Types, params.Type) {
+		return db.CustomField{}, &FieldError{
+			Field: "type",
+			Err:   fmt.Errorf("%w: unknown type %q", ErrInvalidCommand, params.Type),
+		}
+	}
+	if params.Type == customfields.TypeEnum {
+		if len(params.Options) == 0 {
+			return db.CustomField{}, &FieldError{
+				Field: "options",
+				Err:   fmt.Errorf("%w: enum fields need options", ErrInvalidCommand),
+			}
+		}
+		for i, option := range params.Options {
+			if option == "" || slices.Contains(params.Options[:i], option) {
+				return db.CustomField{}, &FieldError{
+					Field: "options",
+					Err:   fmt.Errorf("%w: options must be distinct and not empty", ErrInvalidCommand),
+				}
+			}
+		}
+	} else if len(params.Options) > 0 {
+		return db.CustomField{}, &FieldError{
+			Field: "options",
+			Err:   fmt.Errorf("%w: only enum fields have options", ErrInvalidCommand),
+		}
+	}
+
+	fields, err := querier.ListCustomFields(ctx, dbc, params.ObjectType)
+	if err != nil {
+		return db.CustomField{}, err
+	}
+	for _, field := range fields {
+		if field.Key == params.Key {
+			return db.CustomField{}, &FieldError{
+				Field: "key",
+				Err:   fmt.Errorf("%w: %s already has a field %q", ErrInvalidCommand, params.ObjectType, params.Key),
+			}
+		}
+	}
+
+	options := []byte("[]")
+	if len(params.Options) > 0 {
+		if options, err = json.Marshal(params.Options); err != nil {
+			return db.CustomField{}, err
+		}
+	}
+
+	label := params.Label
+	if label == "" {
+		label = params.Key
+	}
+
+	return querier.InsertCustomField(ctx, dbc, db.InsertCustomFieldParams{
+		ID:         uuid.New().String(),
+		ObjectType: params.ObjectType,
+		Key:        params.Key,
+		Label:      label,
+		Type:       params.Type,
+		Options:    string(options),
+	})
+}
+
+// EntityObjectType returns whether an entity is a lead or a contact.
+func EntityObjectType(entity db.Entity) string {
+	if entity.Status == EntityStatusConverted {
+		return ObjectTypeContact
+	}
+	return ObjectTypeLead
+}
+
+// setCustomFields validates values, keyed by custom field key, against the
+// fields defined on the object type and stores them on the record. A null
+// value clears the field.
+func setCustomFields(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	objectType, recordID string,
+	values map[string]any,
+) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	fields, err := querier.ListCustomFields(ctx, dbc, objectType)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	// Report errors for the same field whatever the map order
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		i := slices.IndexFunc(fields, func(f db.CustomField) bool { return f.Key == key })
+		if i < 0 {
+			return &FieldError{
+				Field: "custom_fields." + key,
+				Err:   fmt.Errorf("%w: %s has no field %q", ErrInvalidCommand, objectType, key),
+			}
+		}
+		field := fields[i]
+
+		if values[key] == nil {
+			if err := querier.DeleteCustomFieldValue(ctx, dbc, field.ID, recordID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		options, err := customfields.Options(field.Options)
+		if err != nil {
+			return fmt.Errorf("custom field %s: %w", field.ID, err)
+		}
+		value, err := customfields.Canonical(field.Type, options, values[key])
+		if err != nil {
+			return &FieldError{Field: "custom_fields." + key, Err: fmt.Errorf("%w: %s", ErrInvalidCommand, err)}
+		}
+		if field.Type == customfields.TypeUser {
+			if _, err := querier.GetUser(ctx, dbc, value); errors.Is(err, sql.ErrNoRows) {
+				return &FieldError{
+					Field: "custom_fields." + key,
+					Err:   fmt.Errorf("%w: user %s does not exist", ErrInvalidCommand, value),
+				}
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if err := querier.SetCustomFieldValue(ctx, dbc, field.ID, recordID, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetEntityCustomFields updates custom field values of a lead or contact,
+// recording the change in its history.
+func SetEntityCustomFields(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	entityID string,
+	values map[string]any,
+) (entity db.Entity, err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Entity{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	entity, err = querier.GetEntity(ctx, tx, entityID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	if err = setCustomFields(ctx, tx, querier, EntityObjectType(entity), entity.ID, values); err != nil {
+		return db.Entity{}, err
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"custom_fields": values,
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	err = querier.InsertEntityHistory(ctx, tx, db.InsertEntityHistoryParams{
+		EntityID: entity.ID,
+		Action:   HistoryActionCustomFields,
+		Details:  string(details),
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Entity{}, err
+	}
+
+	return entity, nil
+}
+
+// SetTaskCustomFields updates custom field values of a task.
+func SetTaskCustomFields(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	taskID string,
+	values map[string]any,
+) (task db.Task, err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Task{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	task, err = querier.GetTask(ctx, tx, taskID)
+	if err != nil {
+		return db.Task{}, err
+	}
+
+	if err = setCustomFields(ctx, tx, querier, ObjectTypeTask, task.ID, values); err != nil {
+		return db.Task{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Task{}, err
+	}
+
+	return task, nil
+}
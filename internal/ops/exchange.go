@@ -0,0 +1,115 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/money"
+)
+
+type ExchangeRateParams struct {
+	From string
+	To   string
+	// Rate is the price of one unit of From in To, as a decimal
+	Rate string
+	// EffectiveOn is the date the rate applies from
+	EffectiveOn string
+}
+
+// SetExchangeRates loads rates into the workspace's exchange rate table,
+// replacing those of the same currency pairs and dates. Either every rate
+// is loaded or, if any is invalid, none is.
+func SetExchangeRates(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params []ExchangeRateParams,
+) (rates []db.ExchangeRate, err error) {
+	for i, rate := range params {
+		if err := validateExchangeRate(rate); err != nil {
+			return nil, fmt.Errorf("rate %d: %w", i+1, err)
+		}
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rates = make([]db.ExchangeRate, 0, len(params))
+	for _, rate := range params {
+		set, err := querier.SetExchangeRate(ctx, tx, db.SetExchangeRateParams{
+			FromCurrency: rate.From,
+			ToCurrency:   rate.To,
+			Rate:         rate.Rate,
+			EffectiveOn:  rate.EffectiveOn,
+		})
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, set)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+func validateExchangeRate(rate ExchangeRateParams) error {
+	for _, currency := range []struct{ field, code string }{{"from", rate.From}, {"to", rate.To}} {
+		if !money.ValidCurrency(currency.code) {
+			return &FieldError{
+				Field: currency.field,
+				Err:   fmt.Errorf("%w: %q is not an ISO 4217 currency code", ErrInvalidCommand, currency.code),
+			}
+		}
+	}
+	if rate.From == rate.To {
+		return &FieldError{
+			Field: "to",
+			Err:   fmt.Errorf("%w: a currency has no rate to itself", ErrInvalidCommand),
+		}
+	}
+	if _, err := money.ParseRate(rate.Rate); err != nil {
+		return &FieldError{
+			Field: "rate",
+			Err:   fmt.Errorf("%w: %w", ErrInvalidCommand, err),
+		}
+	}
+	if _, err := time.Parse(time.DateOnly, rate.EffectiveOn); err != nil {
+		return &FieldError{
+			Field: "effective_on",
+			Err:   fmt.Errorf("%w: %q is not a date formatted as %s", ErrInvalidCommand, rate.EffectiveOn, time.DateOnly),
+		}
+	}
+	return nil
+}
+
+// SetBaseCurrency changes the currency a workspace's reports convert amounts
+// into. Rates are kept per currency pair, so those already loaded still
+// apply.
+func SetBaseCurrency(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	workspaceID, currency string,
+) (db.Workspace, error) {
+	if !money.ValidCurrency(currency) {
+		return db.Workspace{}, &FieldError{
+			Field: "base_currency",
+			Err:   fmt.Errorf("%w: %q is not an ISO 4217 currency code", ErrInvalidCommand, currency),
+		}
+	}
+	return querier.SetWorkspaceBaseCurrency(ctx, dbc, workspaceID, currency)
+}
@@ -0,0 +1,200 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+// DefaultFormSource is the source of leads submitted through a form that has
+// none configured.
+const DefaultFormSource = "web_form"
+
+// maxFormValueLength bounds, in characters, each submitted value.
+const maxFormValueLength = 500
+
+// FormFields lists the entity fields a web form can fill in. Status and
+// assignee are left to the assignment rules.
+var FormFields = []string{"first_name", "last_name", "email", "phone", "source", "region"}
+
+// UTMFields are the campaign parameters recorded on leads, read from the
+// submitted fields of the same name.
+var UTMFields = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"}
+
+// ErrSpam is returned for submissions that filled in a form's honeypot.
+var ErrSpam = errors.New("submission rejected as spam")
+
+type CreateLeadFormParams struct {
+	Name string
+	// Mapping maps entity fields onto submitted field names. Fields that are
+	// not mapped are read from a field named after them.
+	Mapping     map[string]string
+	Honeypot    string
+	RedirectURL string
+	Source      string
+}
+
+func CreateLeadForm(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateLeadFormParams,
+) (db.LeadForm, error) {
+	for field := range params.Mapping {
+		if !slices.Contains(FormFields, field) {
+			return db.LeadForm{}, &FieldError{
+				Field: "mapping",
+				Err:   fmt.Errorf("%w: unknown field %q", ErrInvalidCommand, field),
+			}
+		}
+	}
+	if params.Honeypot != "" {
+		for _, field := range append(slices.Clone(FormFields), UTMFields...) {
+			if formField(params.Mapping, field) == params.Honeypot {
+				return db.LeadForm{}, &FieldError{
+					Field: "honeypot",
+					Err:   fmt.Errorf("%w: %q is read as %s", ErrInvalidCommand, params.Honeypot, field),
+				}
+			}
+		}
+	}
+	if params.RedirectURL != "" {
+		u, err := url.Parse(params.RedirectURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return db.LeadForm{}, &FieldError{
+				Field: "redirect_url",
+				Err:   fmt.Errorf("%w: must be an absolute http or https URL", ErrInvalidCommand),
+			}
+		}
+	}
+
+	mapping, err := json.Marshal(params.Mapping)
+	if err != nil {
+		return db.LeadForm{}, err
+	}
+	if params.Mapping == nil {
+		mapping = []byte("{}")
+	}
+
+	key, err := randomToken(16)
+	if err != nil {
+		return db.LeadForm{}, err
+	}
+
+	return querier.InsertLeadForm(ctx, dbc, db.InsertLeadFormParams{
+		ID:          uuid.New().String(),
+		Name:        params.Name,
+		Key:         key,
+		Mapping:     string(mapping),
+		Honeypot:    params.Honeypot,
+		RedirectURL: params.RedirectURL,
+		Source:      params.Source,
+	})
+}
+
+// RotateLeadFormKey gives a form a new key, so submissions to the old URL are
+// no longer accepted.
+func RotateLeadFormKey(ctx context.Context, dbc *sqlx.DB, querier db.Querier, id string) (db.LeadForm, error) {
+	key, err := randomToken(16)
+	if err != nil {
+		return db.LeadForm{}, err
+	}
+
+	return querier.SetLeadFormKey(ctx, dbc, id, key)
+}
+
+// SubmitLeadForm creates a lead from the values submitted through a form,
+// read according to its mapping. Field errors name the submitted field so
+// they can be shown next to it. Submissions filling in the honeypot return
+// ErrSpam without creating anything.
+func SubmitLeadForm(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	form db.LeadForm,
+	values map[string]string,
+	phoneRegion string,
+	eventService pubsub.EventServicer,
+) (db.Entity, error) {
+	if form.Honeypot != "" && values[form.Honeypot] != "" {
+		return db.Entity{}, ErrSpam
+	}
+
+	var mapping map[string]string
+	if err := json.Unmarshal([]byte(form.Mapping), &mapping); err != nil {
+		return db.Entity{}, fmt.Errorf("form %s has an invalid mapping: %w", form.ID, err)
+	}
+
+	value := func(field string) string {
+		return values[formField(mapping, field)]
+	}
+	for _, field := range append(slices.Clone(FormFields), UTMFields...) {
+		if utf8.RuneCountInString(value(field)) > maxFormValueLength {
+			return db.Entity{}, &FieldError{
+				Field: formField(mapping, field),
+				Err:   fmt.Errorf("%w: longer than %d characters", ErrInvalidCommand, maxFormValueLength),
+			}
+		}
+	}
+	for _, field := range []string{"first_name", "last_name", "email", "phone"} {
+		if value(field) == "" {
+			return db.Entity{}, &FieldError{
+				Field: formField(mapping, field),
+				Err:   fmt.Errorf("%w: required", ErrInvalidCommand),
+			}
+		}
+	}
+
+	params := CreateLeadParams{
+		FirstName:   value("first_name"),
+		LastName:    value("last_name"),
+		Email:       value("email"),
+		Phone:       value("phone"),
+		Source:      value("source"),
+		Region:      value("region"),
+		UTMSource:   value("utm_source"),
+		UTMMedium:   value("utm_medium"),
+		UTMCampaign: value("utm_campaign"),
+		UTMTerm:     value("utm_term"),
+		UTMContent:  value("utm_content"),
+	}
+	if params.Source == "" {
+		params.Source = form.Source
+	}
+	if params.Source == "" {
+		params.Source = DefaultFormSource
+	}
+
+	params, err := params.Normalize(phoneRegion)
+	var fieldError *FieldError
+	if errors.As(err, &fieldError) {
+		return db.Entity{}, &FieldError{
+			Field: formField(mapping, fieldError.Field),
+			Err:   fmt.Errorf("%w: %v", ErrInvalidCommand, fieldError.Err),
+		}
+	}
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	return CreateLead(ctx, dbc, querier, params, eventService)
+}
+
+// formField returns the name of the submitted field an entity field is read
+// from.
+func formField(mapping map[string]string, field string) string {
+	if name, ok := mapping[field]; ok {
+		return name
+	}
+	return field
+}
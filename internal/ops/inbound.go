@@ -0,0 +1,216 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/inbound"
+	"simplecrm/internal/normalize"
+	"simplecrm/internal/pubsub"
+)
+
+// maxEmailExcerpt is the number of characters of an email body copied into
+// the notes of the activities logged for it.
+const maxEmailExcerpt = 1000
+
+type IngestEmailResult struct {
+	Email      db.InboundEmail
+	Activities []db.Activity
+	// Duplicate is set when an email with the same Message-ID was already
+	// ingested. Email is then the stored one and no activities are logged.
+	Duplicate bool
+}
+
+// IngestEmail stores a raw RFC 5322 message and logs it as an email activity
+// on every lead or contact whose address is its sender or one of its
+// recipients. The activity is attributed to the user who sent the email or,
+// failing that, the first user it was sent to. Messages are deduplicated by
+// Message-ID, so ingesting the same mailbox twice is harmless.
+func IngestEmail(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	raw []byte,
+	eventService pubsub.EventServicer,
+) (result IngestEmailResult, err error) {
+	msg, err := inbound.Parse(raw)
+	if err != nil {
+		return IngestEmailResult{}, fmt.Errorf("%w: %v", ErrInvalidCommand, err)
+	}
+
+	now := time.Now().UTC()
+	sentAt := now
+	if !msg.Date.IsZero() {
+		sentAt = msg.Date.UTC()
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return IngestEmailResult{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	email, inserted, err := querier.InsertInboundEmail(ctx, tx, db.InsertInboundEmailParams{
+		ID:          uuid.New().String(),
+		MessageID:   msg.MessageID,
+		FromAddress: msg.From.Address,
+		ToAddresses: joinAddresses(msg.To),
+		CcAddresses: joinAddresses(msg.Cc),
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		SentAt:      sentAt.Format(db.TimeFormat),
+		CreatedAt:   now.Format(db.TimeFormat),
+	})
+	if err != nil {
+		return IngestEmailResult{}, err
+	}
+	if !inserted {
+		tx.Rollback()
+		email, err = querier.GetInboundEmailByMessageID(ctx, dbc, msg.MessageID)
+		if err != nil {
+			return IngestEmailResult{}, err
+		}
+		return IngestEmailResult{Email: email, Duplicate: true}, nil
+	}
+
+	for i, attachment := range msg.Attachments {
+		err = querier.InsertInboundEmailAttachment(ctx, tx, db.InboundEmailAttachment{
+			EmailID:     email.ID,
+			Position:    i,
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Size:        attachment.Size,
+		})
+		if err != nil {
+			return IngestEmailResult{}, err
+		}
+	}
+
+	userID, entities, err := matchEmailAddresses(ctx, tx, querier, msg)
+	if err != nil {
+		return IngestEmailResult{}, err
+	}
+
+	notes := emailNotes(msg)
+	activities := make([]db.Activity, 0, len(entities))
+	for _, entity := range entities {
+		activity, err := querier.InsertActivity(ctx, tx, db.InsertActivityParams{
+			ID:         uuid.New().String(),
+			EntityID:   entity.ID,
+			Kind:       ActivityKindEmail,
+			UserID:     sql.NullString{String: userID, Valid: userID != ""},
+			Notes:      notes,
+			OccurredAt: email.SentAt,
+			EmailID:    email.ID,
+		})
+		if err != nil {
+			return IngestEmailResult{}, err
+		}
+		activities = append(activities, activity)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return IngestEmailResult{}, err
+	}
+
+	for _, activity := range activities {
+		event := pubsub.NewEvent(pubsub.EventActivityLogged, map[string]any{
+			"id":          activity.ID,
+			"entity_id":   activity.EntityID,
+			"kind":        activity.Kind,
+			"user_id":     activity.UserID.String,
+			"occurred_at": activity.OccurredAt,
+		})
+		if err := eventService.Publish(ctx, event); err != nil {
+			slog.Error("Failed to publish event", "type", event.Type, "activity", activity.ID, "error", err)
+		}
+	}
+
+	return IngestEmailResult{Email: email, Activities: activities}, nil
+}
+
+// matchEmailAddresses finds the user an email is attributed to and the
+// entities it was exchanged with. Addresses are compared in canonical form so
+// tagged and dotted Gmail addresses still match.
+func matchEmailAddresses(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	msg inbound.Message,
+) (userID string, entities []db.Entity, err error) {
+	users, err := querier.ListUsers(ctx, dbc)
+	if err != nil {
+		return "", nil, err
+	}
+	usersByEmail := map[string]string{}
+	for _, user := range users {
+		if user.Active {
+			usersByEmail[normalize.CanonicalEmail(user.Email)] = user.ID
+		}
+	}
+
+	var canonicals []string
+	seen := map[string]bool{}
+	for _, address := range msg.Addresses() {
+		canonical := normalize.CanonicalEmail(address.Address)
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+
+		if id, ok := usersByEmail[canonical]; ok {
+			if userID == "" {
+				userID = id
+			}
+			continue
+		}
+		canonicals = append(canonicals, canonical)
+	}
+
+	entities, err = querier.ListEntitiesByEmailCanonical(ctx, dbc, canonicals)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return userID, entities, nil
+}
+
+// emailNotes summarises an email for the activity timeline.
+func emailNotes(msg inbound.Message) string {
+	subject := msg.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+
+	body := msg.Body
+	if runes := []rune(body); len(runes) > maxEmailExcerpt {
+		body = strings.TrimSpace(string(runes[:maxEmailExcerpt])) + "…"
+	}
+
+	notes := fmt.Sprintf("Email from %s: %s", msg.From.Address, subject)
+	if body != "" {
+		notes += "\n\n" + body
+	}
+	return notes
+}
+
+func joinAddresses(addresses []*mail.Address) string {
+	parts := make([]string, len(addresses))
+	for i, address := range addresses {
+		parts[i] = address.Address
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,225 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/assignment"
+	"simplecrm/internal/db"
+	"simplecrm/internal/normalize"
+	"simplecrm/internal/pubsub"
+)
+
+// Entities are leads until they are converted, after which they are contacts.
+const (
+	LeadStatusNew         = "new"
+	LeadStatusContacted   = "contacted"
+	LeadStatusQualified   = "qualified"
+	LeadStatusUnqualified = "unqualified"
+	EntityStatusConverted = "converted"
+)
+
+const (
+	ObjectTypeLead    = "lead"
+	ObjectTypeContact = "contact"
+	ObjectTypeTask    = "task"
+)
+
+type CreateLeadParams struct {
+	FirstName string `validate:"required"`
+	LastName  string `validate:"required"`
+	// Email and Phone are checked by Normalize
+	Email      string `validate:"required"`
+	Phone      string `validate:"required"`
+	Status     string `validate:"omitempty,oneof=new contacted qualified unqualified"`
+	AssignedTo string
+	// TeamID is the team owning the lead along with its assignee
+	TeamID string
+	Source string
+	Region string
+	// Campaign attribution, as in the utm_* query parameters of the landing page
+	UTMSource   string
+	UTMMedium   string
+	UTMCampaign string
+	UTMTerm     string
+	UTMContent  string
+	// CustomFields holds values keyed by custom field key
+	CustomFields map[string]any
+}
+
+func (p CreateLeadParams) Validate() validator.ValidationErrors {
+	validate := validator.New()
+	err := validate.Struct(p)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if ok && len(validationErrors) > 0 {
+		return validationErrors
+	}
+	return nil
+}
+
+// FieldError reports a field whose value was rejected.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Normalize returns p with its email lowercased and its phone number in
+// E.164 form, parsing national numbers as belonging to phoneRegion.
+func (p CreateLeadParams) Normalize(phoneRegion string) (CreateLeadParams, error) {
+	email, err := normalize.Email(p.Email)
+	if err != nil {
+		return p, &FieldError{Field: "email", Err: err}
+	}
+	p.Email = email
+
+	phone, err := normalize.Phone(p.Phone, phoneRegion)
+	if err != nil {
+		return p, &FieldError{Field: "phone", Err: err}
+	}
+	p.Phone = phone
+
+	return p, nil
+}
+
+func CreateLead(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateLeadParams,
+	eventService pubsub.EventServicer,
+) (db.Entity, error) {
+	entities, err := CreateEntities(ctx, dbc, querier, ObjectTypeLead, []CreateLeadParams{params}, eventService)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	return entities[0], nil
+}
+
+// CreateEntities inserts leads or contacts in a single transaction and
+// publishes a created event for each of them once it has committed. params
+// are expected to have been normalized already. Leads without an assignee are
+// routed through the assignment rules.
+func CreateEntities(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	objectType string,
+	params []CreateLeadParams,
+	eventService pubsub.EventServicer,
+) (entities []db.Entity, err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	assignedBy := map[string]string{}
+	now := time.Now().UTC().Format(db.TimeFormat)
+	for _, p := range params {
+		arg := db.InsertAndReturnEntityParams{
+			ID:             uuid.New().String(),
+			FirstName:      p.FirstName,
+			LastName:       p.LastName,
+			Email:          p.Email,
+			EmailCanonical: normalize.CanonicalEmail(p.Email),
+			Phone:          p.Phone,
+			Status:         p.Status,
+			AssignedTo:     sql.NullString{String: p.AssignedTo, Valid: p.AssignedTo != ""},
+			CreatedAt:      now,
+			Source:         p.Source,
+			Region:         p.Region,
+			UTMSource:      p.UTMSource,
+			UTMMedium:      p.UTMMedium,
+			UTMCampaign:    p.UTMCampaign,
+			UTMTerm:        p.UTMTerm,
+			UTMContent:     p.UTMContent,
+			TeamID:         sql.NullString{String: p.TeamID, Valid: p.TeamID != ""},
+		}
+		if arg.Status == "" {
+			arg.Status = LeadStatusNew
+		}
+		if objectType == ObjectTypeContact {
+			arg.Status = EntityStatusConverted
+			arg.ConvertedAt = now
+		}
+
+		if p.TeamID != "" {
+			if err = checkTeam(ctx, tx, querier, "team_id", p.TeamID); err != nil {
+				return nil, err
+			}
+		}
+
+		var ruleID string
+		if p.AssignedTo != "" {
+			if err = CheckAssignable(ctx, tx, querier, p.AssignedTo); err != nil {
+				return nil, err
+			}
+		} else if objectType == ObjectTypeLead {
+			result, ok, err := assignment.Assign(ctx, tx, querier, assignment.Lead{
+				Email:  arg.Email,
+				Source: arg.Source,
+				Region: arg.Region,
+				Status: arg.Status,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				arg.AssignedTo = sql.NullString{String: result.UserID, Valid: true}
+				ruleID = result.RuleID
+			}
+		}
+
+		entity, err := querier.InsertAndReturnEntity(ctx, tx, arg)
+		if err != nil {
+			return nil, err
+		}
+		if err = setCustomFields(ctx, tx, querier, objectType, entity.ID, p.CustomFields); err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+		if ruleID != "" {
+			assignedBy[entity.ID] = ruleID
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	eventType := pubsub.EventLeadCreated
+	if objectType == ObjectTypeContact {
+		eventType = pubsub.EventContactCreated
+	}
+	for _, entity := range entities {
+		event := pubsub.NewEvent(eventType, pubsub.EntityPayload(entity))
+		if err := eventService.Publish(ctx, event); err != nil {
+			slog.Error("Failed to publish event", "type", eventType, "entity", entity.ID, "error", err)
+		}
+
+		if ruleID, ok := assignedBy[entity.ID]; ok {
+			publishAssigned(ctx, eventService, entity, "", ruleID)
+		}
+	}
+
+	return entities, nil
+}
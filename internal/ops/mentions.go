@@ -0,0 +1,93 @@
+package ops
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+// mentionPattern matches @handle, where handle is an email address or the
+// local part of one. The @ must not follow a word character, so addresses
+// written out in full are not read as mentions of their domain.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// Mentions returns the active users mentioned in text, each once. A user is
+// mentioned by @ followed by their email address or, when no other user
+// shares it, its local part.
+func Mentions(text string, users []db.User) []db.User {
+	if !strings.Contains(text, "@") {
+		return nil
+	}
+
+	byEmail := map[string]db.User{}
+	byLocal := map[string][]db.User{}
+	for _, user := range users {
+		if !user.Active {
+			continue
+		}
+		email := strings.ToLower(user.Email)
+		byEmail[email] = user
+		local, _, _ := strings.Cut(email, "@")
+		byLocal[local] = append(byLocal[local], user)
+	}
+
+	seen := map[string]bool{}
+	var mentioned []db.User
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		handle := strings.ToLower(strings.TrimRight(match[1], ".-"))
+
+		user, ok := byEmail[handle]
+		if !ok && len(byLocal[handle]) == 1 {
+			user, ok = byLocal[handle][0], true
+		}
+		if !ok || seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+		mentioned = append(mentioned, user)
+	}
+
+	return mentioned
+}
+
+// publishMentions publishes a mention event for every user mentioned in the
+// notes of activity other than its author.
+func publishMentions(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	activity db.Activity,
+	eventService pubsub.EventServicer,
+) {
+	if !strings.Contains(activity.Notes, "@") {
+		return
+	}
+
+	users, err := querier.ListUsers(ctx, dbc)
+	if err != nil {
+		slog.Error("Failed to resolve mentions", "activity", activity.ID, "error", err)
+		return
+	}
+
+	for _, user := range Mentions(activity.Notes, users) {
+		if user.ID == activity.UserID.String {
+			continue
+		}
+
+		event := pubsub.NewEvent(pubsub.EventUserMentioned, map[string]any{
+			"user_id":     user.ID,
+			"author_id":   activity.UserID.String,
+			"activity_id": activity.ID,
+			"entity_id":   activity.EntityID,
+			"kind":        activity.Kind,
+			"notes":       activity.Notes,
+		})
+		if err := eventService.Publish(ctx, event); err != nil {
+			slog.Error("Failed to publish event", "type", event.Type, "activity", activity.ID, "error", err)
+		}
+	}
+}
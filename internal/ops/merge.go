@@ -0,0 +1,189 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/normalize"
+	"simplecrm/internal/pubsub"
+)
+
+const (
+	MergeFromSurvivor  = "survivor"
+	MergeFromDuplicate = "duplicate"
+)
+
+const HistoryActionMerge = "merge"
+
+// ErrInvalidCommand is returned when a command's arguments are inconsistent
+// with the records it targets.
+var ErrInvalidCommand = errors.New("invalid command")
+
+// MergeableFields lists the entity fields a merge can take from either record.
+var MergeableFields = []string{
+	"first_name", "last_name", "email", "phone", "status", "assigned_to", "team_id", "source", "region",
+}
+
+type MergeEntitiesParams struct {
+	SurvivorID  string
+	DuplicateID string
+	// Fields picks, per field, whether the merged record keeps the survivor's
+	// value or takes the duplicate's. Fields not listed keep the survivor's
+	// value unless it is empty.
+	Fields map[string]string
+}
+
+// MergeEntities folds the duplicate into the survivor field-by-field, moves
+// the duplicate's tasks, activities, custom field values, tags, attachments
+// and quotes onto the survivor, deletes the duplicate and records the merge
+// in the survivor's history.
+func MergeEntities(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params MergeEntitiesParams,
+	eventService pubsub.EventServicer,
+) (merged db.Entity, err error) {
+	if params.SurvivorID == params.DuplicateID {
+		return db.Entity{}, fmt.Errorf("%w: cannot merge an entity into itself", ErrInvalidCommand)
+	}
+	for field, source := range params.Fields {
+		if !slices.Contains(MergeableFields, field) {
+			return db.Entity{}, fmt.Errorf("%w: unknown field %q", ErrInvalidCommand, field)
+		}
+		if source != MergeFromSurvivor && source != MergeFromDuplicate {
+			return db.Entity{}, fmt.Errorf("%w: field %q must come from survivor or duplicate", ErrInvalidCommand, field)
+		}
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Entity{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	survivor, err := querier.GetEntity(ctx, tx, params.SurvivorID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+	duplicate, err := querier.GetEntity(ctx, tx, params.DuplicateID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	pick := func(field, survivorValue, duplicateValue string) string {
+		switch params.Fields[field] {
+		case MergeFromDuplicate:
+			return duplicateValue
+		case MergeFromSurvivor:
+			return survivorValue
+		}
+		if survivorValue == "" {
+			return duplicateValue
+		}
+		return survivorValue
+	}
+
+	arg := db.UpdateEntityParams{
+		ID:          survivor.ID,
+		FirstName:   pick("first_name", survivor.FirstName, duplicate.FirstName),
+		LastName:    pick("last_name", survivor.LastName, duplicate.LastName),
+		Email:       pick("email", survivor.Email, duplicate.Email),
+		Phone:       pick("phone", survivor.Phone, duplicate.Phone),
+		Status:      pick("status", survivor.Status, duplicate.Status),
+		ConvertedAt: survivor.ConvertedAt,
+		Source:      pick("source", survivor.Source, duplicate.Source),
+		Region:      pick("region", survivor.Region, duplicate.Region),
+	}
+	arg.EmailCanonical = normalize.CanonicalEmail(arg.Email)
+	if assignedTo := pick("assigned_to", survivor.AssignedTo.String, duplicate.AssignedTo.String); assignedTo != "" {
+		arg.AssignedTo.String, arg.AssignedTo.Valid = assignedTo, true
+	}
+	if teamID := pick("team_id", survivor.TeamID.String, duplicate.TeamID.String); teamID != "" {
+		arg.TeamID.String, arg.TeamID.Valid = teamID, true
+	}
+	// Once either record was converted the person is a contact
+	if arg.ConvertedAt == "" || (duplicate.ConvertedAt != "" && duplicate.ConvertedAt < arg.ConvertedAt) {
+		arg.ConvertedAt = duplicate.ConvertedAt
+	}
+	if arg.ConvertedAt != "" {
+		arg.Status = EntityStatusConverted
+	}
+
+	merged, err = querier.UpdateEntity(ctx, tx, arg)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	tasksMoved, err := querier.ReassignTasksEntity(ctx, tx, duplicate.ID, survivor.ID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	activitiesMoved, err := querier.ReassignActivitiesEntity(ctx, tx, duplicate.ID, survivor.ID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	// Values the survivor already has win over the duplicate's
+	if err = querier.MoveCustomFieldValues(ctx, tx, duplicate.ID, survivor.ID); err != nil {
+		return db.Entity{}, err
+	}
+	if err = querier.MoveEntityTags(ctx, tx, duplicate.ID, survivor.ID); err != nil {
+		return db.Entity{}, err
+	}
+	if err = querier.MoveEntityAttachments(ctx, tx, duplicate.ID, survivor.ID); err != nil {
+		return db.Entity{}, err
+	}
+	if err = querier.MoveEntityQuotes(ctx, tx, duplicate.ID, survivor.ID); err != nil {
+		return db.Entity{}, err
+	}
+
+	if err = querier.DeleteEntity(ctx, tx, duplicate.ID); err != nil {
+		return db.Entity{}, err
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"merged_id":        duplicate.ID,
+		"survivor":         pubsub.EntityPayload(survivor),
+		"duplicate":        pubsub.EntityPayload(duplicate),
+		"fields":           params.Fields,
+		"tasks_moved":      tasksMoved,
+		"activities_moved": activitiesMoved,
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	err = querier.InsertEntityHistory(ctx, tx, db.InsertEntityHistoryParams{
+		EntityID: merged.ID,
+		Action:   HistoryActionMerge,
+		Details:  string(details),
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Entity{}, err
+	}
+
+	payload := pubsub.EntityPayload(merged)
+	payload["merged_id"] = duplicate.ID
+	if err := eventService.Publish(ctx, pubsub.NewEvent(pubsub.EventEntityMerged, payload)); err != nil {
+		slog.Error("Failed to publish event", "type", pubsub.EventEntityMerged, "entity", merged.ID, "error", err)
+	}
+
+	return merged, nil
+}
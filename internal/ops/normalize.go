@@ -0,0 +1,140 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/normalize"
+)
+
+type NormalizeFailure struct {
+	Table string
+	ID    string
+	Field string
+	Value string
+	Error string
+}
+
+type NormalizeReport struct {
+	Scanned  int
+	Updated  int
+	Failures []NormalizeFailure
+}
+
+// NormalizeExisting rewrites entity emails and phone numbers, and user
+// emails, written before normalization was enforced. Rows with a value that
+// cannot be parsed are left untouched and reported. With dryRun nothing is
+// written.
+func NormalizeExisting(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	phoneRegion string,
+	dryRun bool,
+) (report NormalizeReport, err error) {
+	// Read everything up front so no cursor is open while rows are rewritten
+	entities, err := querier.ListEntities(ctx, dbc, db.EntityFilter{})
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+	users, err := querier.ListUsers(ctx, dbc)
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return NormalizeReport{}, err
+	}
+	defer func() {
+		if err != nil || dryRun {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	fail := func(table, id, field, value string, cause error) {
+		report.Failures = append(report.Failures, NormalizeFailure{
+			Table: table,
+			ID:    id,
+			Field: field,
+			Value: value,
+			Error: cause.Error(),
+		})
+	}
+
+	for _, entity := range entities {
+		report.Scanned++
+
+		email, emailErr := normalize.Email(entity.Email)
+		if emailErr != nil {
+			fail("entities", entity.ID, "email", entity.Email, emailErr)
+			email = entity.Email
+		}
+		phone, phoneErr := normalize.Phone(entity.Phone, phoneRegion)
+		if phoneErr != nil {
+			fail("entities", entity.ID, "phone", entity.Phone, phoneErr)
+			phone = entity.Phone
+		}
+		canonical := normalize.CanonicalEmail(email)
+
+		if email == entity.Email && phone == entity.Phone && canonical == entity.EmailCanonical {
+			continue
+		}
+
+		_, err = querier.UpdateEntity(ctx, tx, db.UpdateEntityParams{
+			ID:             entity.ID,
+			FirstName:      entity.FirstName,
+			LastName:       entity.LastName,
+			Email:          email,
+			EmailCanonical: canonical,
+			Phone:          phone,
+			Status:         entity.Status,
+			AssignedTo:     entity.AssignedTo,
+			ConvertedAt:    entity.ConvertedAt,
+			Source:         entity.Source,
+			Region:         entity.Region,
+			TeamID:         entity.TeamID,
+		})
+		if err != nil {
+			return NormalizeReport{}, err
+		}
+		report.Updated++
+	}
+
+	// users.email is unique, so addresses differing only in case collide
+	owners := map[string]int{}
+	for _, user := range users {
+		if email, err := normalize.Email(user.Email); err == nil {
+			owners[email]++
+		}
+	}
+
+	for _, user := range users {
+		report.Scanned++
+
+		email, emailErr := normalize.Email(user.Email)
+		if emailErr != nil {
+			fail("users", user.ID, "email", user.Email, emailErr)
+			continue
+		}
+		if email == user.Email {
+			continue
+		}
+		if owners[email] > 1 {
+			fail("users", user.ID, "email", user.Email, fmt.Errorf("%s is used by %d users", email, owners[email]))
+			continue
+		}
+
+		if err = querier.UpdateUserEmail(ctx, tx, user.ID, email); err != nil {
+			return NormalizeReport{}, err
+		}
+		report.Updated++
+	}
+
+	return report, nil
+}
@@ -2,6 +2,8 @@ package ops
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -46,3 +48,32 @@ func CreateUser(
 
 	return user, nil
 }
+
+// RotateCalendarToken gives the user a new calendar feed token, revoking the
+// previous one. With revoke set the feed is disabled instead.
+func RotateCalendarToken(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	userID string,
+	revoke bool,
+) (db.User, error) {
+	token := ""
+	if !revoke {
+		var err error
+		if token, err = randomToken(32); err != nil {
+			return db.User{}, err
+		}
+	}
+
+	return querier.SetUserCalendarToken(ctx, dbc, userID, token)
+}
+
+// randomToken returns n random bytes, hex encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
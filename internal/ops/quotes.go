@@ -0,0 +1,705 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/money"
+)
+
+const HistoryActionQuotes = "quotes"
+
+const (
+	QuoteStatusDraft    = "draft"
+	QuoteStatusSent     = "sent"
+	QuoteStatusAccepted = "accepted"
+	QuoteStatusDeclined = "declined"
+)
+
+var QuoteStatuses = []string{QuoteStatusDraft, QuoteStatusSent, QuoteStatusAccepted, QuoteStatusDeclined}
+
+// quoteTransitions lists the statuses a quote can move to from each status.
+// A sent quote can go back to draft to be revised; accepted and declined
+// quotes are final.
+var quoteTransitions = map[string][]string{
+	QuoteStatusDraft: {QuoteStatusSent},
+	QuoteStatusSent:  {QuoteStatusAccepted, QuoteStatusDeclined, QuoteStatusDraft},
+}
+
+// percentToBasisPoints converts a percentage of 0 to 100 with at most two
+// decimals, such as 8.25, to basis points.
+func percentToBasisPoints(field string, percent float64) (int64, error) {
+	bp := math.Round(percent * 100)
+	if percent < 0 || percent > 100 || math.Abs(bp-percent*100) > 1e-6 {
+		return 0, &FieldError{
+			Field: field,
+			Err:   fmt.Errorf("%w: must be a percentage of 0 to 100 with at most two decimals", ErrInvalidCommand),
+		}
+	}
+	return int64(bp), nil
+}
+
+func validatePrice(currency string, unitPrice int64) error {
+	if !money.ValidCurrency(currency) {
+		return &FieldError{
+			Field: "currency",
+			Err:   fmt.Errorf("%w: %q is not an ISO 4217 currency code", ErrInvalidCommand, currency),
+		}
+	}
+	if unitPrice < 0 {
+		return &FieldError{
+			Field: "unit_price",
+			Err:   fmt.Errorf("%w: prices cannot be negative", ErrInvalidCommand),
+		}
+	}
+	return nil
+}
+
+type CreateProductParams struct {
+	SKU         string
+	Name        string
+	Description string
+	// Prices maps currency codes to unit prices in each currency's minor unit
+	Prices map[string]int64
+}
+
+// CreateProduct adds a product to the catalog with its prices. SKUs are
+// unique within the workspace, regardless of case.
+func CreateProduct(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateProductParams,
+) (product db.Product, prices []db.ProductPrice, err error) {
+	params.SKU = strings.TrimSpace(params.SKU)
+	if params.SKU == "" || strings.ContainsFunc(params.SKU, func(r rune) bool { return r == ' ' || r == '\t' }) {
+		return db.Product{}, nil, &FieldError{
+			Field: "sku",
+			Err:   fmt.Errorf("%w: SKUs cannot be empty or contain spaces", ErrInvalidCommand),
+		}
+	}
+	for currency, unitPrice := range params.Prices {
+		if err := validatePrice(currency, unitPrice); err != nil {
+			return db.Product{}, nil, err
+		}
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Product{}, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	existing, err := querier.ListProducts(ctx, tx, true)
+	if err != nil {
+		return db.Product{}, nil, err
+	}
+	for _, other := range existing {
+		if strings.EqualFold(other.SKU, params.SKU) {
+			return db.Product{}, nil, &FieldError{
+				Field: "sku",
+				Err:   fmt.Errorf("%w: a product with SKU %q already exists", ErrInvalidCommand, other.SKU),
+			}
+		}
+	}
+
+	product, err = querier.InsertProduct(ctx, tx, db.InsertProductParams{
+		ID:          uuid.New().String(),
+		SKU:         params.SKU,
+		Name:        params.Name,
+		Description: params.Description,
+	})
+	if err != nil {
+		return db.Product{}, nil, err
+	}
+
+	currencies := make([]string, 0, len(params.Prices))
+	for currency := range params.Prices {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+	for _, currency := range currencies {
+		price, err := querier.SetProductPrice(ctx, tx, db.ProductPrice{
+			ProductID: product.ID,
+			Currency:  currency,
+			UnitPrice: params.Prices[currency],
+		})
+		if err != nil {
+			return db.Product{}, nil, err
+		}
+		prices = append(prices, price)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Product{}, nil, err
+	}
+
+	return product, prices, nil
+}
+
+// SetProductPrice sets a product's price in the price book of currency.
+// Quotes already made keep the price their lines were made with.
+func SetProductPrice(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	productID, currency string,
+	unitPrice int64,
+) (db.ProductPrice, error) {
+	if err := validatePrice(currency, unitPrice); err != nil {
+		return db.ProductPrice{}, err
+	}
+
+	product, err := querier.GetProduct(ctx, dbc, productID)
+	if err != nil {
+		return db.ProductPrice{}, err
+	}
+
+	return querier.SetProductPrice(ctx, dbc, db.ProductPrice{
+		ProductID: product.ID,
+		Currency:  currency,
+		UnitPrice: unitPrice,
+	})
+}
+
+// PricedLine is a quote line with the amounts it comes to, in the minor
+// unit of the quote's currency.
+type PricedLine struct {
+	db.QuoteLine
+	// Gross is the quantity times the unit price
+	Gross          int64
+	DiscountAmount int64
+	// Net is the gross less the discount
+	Net int64
+}
+
+// PricedQuote is a quote with its lines and the totals they add up to, in
+// the minor unit of the quote's currency.
+type PricedQuote struct {
+	db.Quote
+	Lines []PricedLine
+	// Subtotal is the sum of the lines' net amounts
+	Subtotal int64
+	// Tax is the tax rate applied to the subtotal
+	Tax   int64
+	Total int64
+}
+
+// PriceQuote works out the totals of a quote. Each line's discount and the
+// tax are rounded to the minor unit on their own, so the totals are what a
+// customer adding up the printed amounts would get.
+func PriceQuote(quote db.Quote, lines []db.QuoteLine) PricedQuote {
+	priced := PricedQuote{
+		Quote: quote,
+		Lines: make([]PricedLine, 0, len(lines)),
+	}
+	for _, line := range lines {
+		gross := line.Quantity * line.UnitPrice
+		discount := money.BasisPoints(line.Discount).Of(gross)
+		priced.Lines = append(priced.Lines, PricedLine{
+			QuoteLine:      line,
+			Gross:          gross,
+			DiscountAmount: discount,
+			Net:            gross - discount,
+		})
+		priced.Subtotal += gross - discount
+	}
+	priced.Tax = money.BasisPoints(quote.TaxRate).Of(priced.Subtotal)
+	priced.Total = priced.Subtotal + priced.Tax
+	return priced
+}
+
+type CreateQuoteParams struct {
+	// EntityID is the lead or contact the quote is made to
+	EntityID string
+	Title    string
+	Currency string
+	// TaxRate is a percentage, such as 8.25
+	TaxRate float64
+	// ValidUntil is a date, or empty for quotes that do not expire
+	ValidUntil string
+	Notes      string
+	// CreatedBy is the user making the quote, empty when not known
+	CreatedBy string
+	// ViewerID, if set, must be able to see the lead or contact
+	ViewerID string
+}
+
+// CreateQuote makes a draft quote without lines to a lead or contact,
+// recorded in its history.
+func CreateQuote(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateQuoteParams,
+) (quote db.Quote, err error) {
+	if !money.ValidCurrency(params.Currency) {
+		return db.Quote{}, &FieldError{
+			Field: "currency",
+			Err:   fmt.Errorf("%w: %q is not an ISO 4217 currency code", ErrInvalidCommand, params.Currency),
+		}
+	}
+	taxRate, err := percentToBasisPoints("tax_rate", params.TaxRate)
+	if err != nil {
+		return db.Quote{}, err
+	}
+	if err := validateValidUntil(params.ValidUntil); err != nil {
+		return db.Quote{}, err
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Quote{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	entityID, _, err := attachableRecord(ctx, tx, querier, "", params.EntityID, params.ViewerID)
+	if err != nil {
+		return db.Quote{}, err
+	}
+
+	quote, err = querier.InsertQuote(ctx, tx, db.InsertQuoteParams{
+		ID:         uuid.New().String(),
+		EntityID:   entityID,
+		Title:      params.Title,
+		Currency:   params.Currency,
+		TaxRate:    taxRate,
+		ValidUntil: params.ValidUntil,
+		Notes:      params.Notes,
+		CreatedBy:  params.CreatedBy,
+	})
+	if err != nil {
+		return db.Quote{}, err
+	}
+
+	if err = recordQuoteHistory(ctx, tx, querier, quote, map[string]any{"created": quote.Title}); err != nil {
+		return db.Quote{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Quote{}, err
+	}
+
+	return quote, nil
+}
+
+func validateValidUntil(validUntil string) error {
+	if validUntil == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.DateOnly, validUntil); err != nil {
+		return &FieldError{
+			Field: "valid_until",
+			Err:   fmt.Errorf("%w: %q is not a date formatted as %s", ErrInvalidCommand, validUntil, time.DateOnly),
+		}
+	}
+	return nil
+}
+
+// GetQuote returns a quote with its lines and totals. With viewerID set,
+// quotes to leads and contacts that user cannot see are not found.
+func GetQuote(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	id, viewerID string,
+) (PricedQuote, error) {
+	quote, err := visibleQuote(ctx, dbc, querier, id, viewerID)
+	if err != nil {
+		return PricedQuote{}, err
+	}
+
+	lines, err := querier.ListQuoteLines(ctx, dbc, []string{quote.ID})
+	if err != nil {
+		return PricedQuote{}, err
+	}
+
+	return PriceQuote(quote, lines), nil
+}
+
+type UpdateQuoteParams struct {
+	ID         string
+	Title      string
+	TaxRate    float64
+	ValidUntil string
+	Notes      string
+	ViewerID   string
+}
+
+// UpdateQuote replaces the title, tax rate, expiry and notes of a draft
+// quote.
+func UpdateQuote(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params UpdateQuoteParams,
+) (quote PricedQuote, err error) {
+	taxRate, err := percentToBasisPoints("tax_rate", params.TaxRate)
+	if err != nil {
+		return PricedQuote{}, err
+	}
+	if err := validateValidUntil(params.ValidUntil); err != nil {
+		return PricedQuote{}, err
+	}
+
+	return editQuote(ctx, dbc, querier, params.ID, params.ViewerID, func(tx *sqlx.Tx, quote db.Quote) error {
+		_, err := querier.UpdateQuote(ctx, tx, db.UpdateQuoteParams{
+			ID:         quote.ID,
+			Title:      params.Title,
+			TaxRate:    taxRate,
+			ValidUntil: params.ValidUntil,
+			Notes:      params.Notes,
+		})
+		return err
+	})
+}
+
+type AddQuoteLineParams struct {
+	QuoteID string
+	// ProductID, if set, fills in the line's SKU, description and unit price
+	// from the catalog, at the product's price in the quote's currency.
+	// Description and UnitPrice, when set, take precedence.
+	ProductID   string
+	Description string
+	// UnitPrice is in the minor unit of the quote's currency, and required
+	// for lines not made from a product
+	UnitPrice *int64
+	Quantity  int64
+	// Discount is a percentage of the line's price
+	Discount float64
+	ViewerID string
+}
+
+// AddQuoteLine appends a line to a draft quote.
+func AddQuoteLine(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params AddQuoteLineParams,
+) (PricedQuote, error) {
+	if params.Quantity < 1 {
+		return PricedQuote{}, &FieldError{
+			Field: "quantity",
+			Err:   fmt.Errorf("%w: must be at least 1", ErrInvalidCommand),
+		}
+	}
+	discount, err := percentToBasisPoints("discount", params.Discount)
+	if err != nil {
+		return PricedQuote{}, err
+	}
+
+	return editQuote(ctx, dbc, querier, params.QuoteID, params.ViewerID, func(tx *sqlx.Tx, quote db.Quote) error {
+		line := db.InsertQuoteLineParams{
+			ID:          uuid.New().String(),
+			QuoteID:     quote.ID,
+			Description: params.Description,
+			Quantity:    params.Quantity,
+			Discount:    discount,
+		}
+
+		if params.ProductID != "" {
+			product, err := querier.GetProduct(ctx, tx, params.ProductID)
+			if err != nil {
+				return err
+			}
+			if !product.Active {
+				return &FieldError{
+					Field: "product_id",
+					Err:   fmt.Errorf("%w: product %s is no longer sold", ErrInvalidCommand, product.SKU),
+				}
+			}
+			prices, err := querier.ListProductPrices(ctx, tx, []string{product.ID})
+			if err != nil {
+				return err
+			}
+			i := slices.IndexFunc(prices, func(price db.ProductPrice) bool { return price.Currency == quote.Currency })
+			if i < 0 && params.UnitPrice == nil {
+				return &FieldError{
+					Field: "product_id",
+					Err:   fmt.Errorf("%w: product %s has no price in %s", ErrInvalidCommand, product.SKU, quote.Currency),
+				}
+			}
+
+			line.ProductID = sql.NullString{String: product.ID, Valid: true}
+			line.SKU = product.SKU
+			if line.Description == "" {
+				line.Description = product.Name
+			}
+			if i >= 0 {
+				line.UnitPrice = prices[i].UnitPrice
+			}
+		}
+
+		if params.UnitPrice != nil {
+			line.UnitPrice = *params.UnitPrice
+		} else if params.ProductID == "" {
+			return &FieldError{
+				Field: "unit_price",
+				Err:   fmt.Errorf("%w: required for lines not made from a product", ErrInvalidCommand),
+			}
+		}
+		if err := validatePrice(quote.Currency, line.UnitPrice); err != nil {
+			return err
+		}
+		if line.Description == "" {
+			return &FieldError{
+				Field: "description",
+				Err:   fmt.Errorf("%w: required for lines not made from a product", ErrInvalidCommand),
+			}
+		}
+
+		_, err := querier.InsertQuoteLine(ctx, tx, line)
+		return err
+	})
+}
+
+type UpdateQuoteLineParams struct {
+	QuoteID string
+	LineID  string
+	// UnitPrice is in the minor unit of the quote's currency
+	UnitPrice int64
+	Quantity  int64
+	// Discount is a percentage of the line's price
+	Discount float64
+	ViewerID string
+}
+
+// UpdateQuoteLine replaces the quantity, unit price and discount of a line
+// on a draft quote.
+func UpdateQuoteLine(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params UpdateQuoteLineParams,
+) (PricedQuote, error) {
+	if params.Quantity < 1 {
+		return PricedQuote{}, &FieldError{
+			Field: "quantity",
+			Err:   fmt.Errorf("%w: must be at least 1", ErrInvalidCommand),
+		}
+	}
+	discount, err := percentToBasisPoints("discount", params.Discount)
+	if err != nil {
+		return PricedQuote{}, err
+	}
+
+	return editQuote(ctx, dbc, querier, params.QuoteID, params.ViewerID, func(tx *sqlx.Tx, quote db.Quote) error {
+		if err := validatePrice(quote.Currency, params.UnitPrice); err != nil {
+			return err
+		}
+		_, err := querier.UpdateQuoteLine(ctx, tx, db.UpdateQuoteLineParams{
+			ID:        params.LineID,
+			QuoteID:   quote.ID,
+			Quantity:  params.Quantity,
+			UnitPrice: params.UnitPrice,
+			Discount:  discount,
+		})
+		return err
+	})
+}
+
+// RemoveQuoteLine removes a line from a draft quote.
+func RemoveQuoteLine(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	quoteID, lineID, viewerID string,
+) (PricedQuote, error) {
+	return editQuote(ctx, dbc, querier, quoteID, viewerID, func(tx *sqlx.Tx, quote db.Quote) error {
+		return querier.DeleteQuoteLine(ctx, tx, lineID, quote.ID)
+	})
+}
+
+// SetQuoteStatus moves a quote along draft, sent, then accepted or
+// declined, recording the change in the lead's or contact's history. Quotes
+// are only sent with at least one line, and cannot be accepted after they
+// expire.
+func SetQuoteStatus(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	id, status, viewerID string,
+) (priced PricedQuote, err error) {
+	if !slices.Contains(QuoteStatuses, status) {
+		return PricedQuote{}, &FieldError{
+			Field: "status",
+			Err:   fmt.Errorf("%w: unknown status %q", ErrInvalidCommand, status),
+		}
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return PricedQuote{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	quote, err := visibleQuote(ctx, tx, querier, id, viewerID)
+	if err != nil {
+		return PricedQuote{}, err
+	}
+	if !slices.Contains(quoteTransitions[quote.Status], status) {
+		return PricedQuote{}, &FieldError{
+			Field: "status",
+			Err:   fmt.Errorf("%w: a %s quote cannot become %s", ErrInvalidCommand, quote.Status, status),
+		}
+	}
+
+	lines, err := querier.ListQuoteLines(ctx, tx, []string{quote.ID})
+	if err != nil {
+		return PricedQuote{}, err
+	}
+
+	now := time.Now().UTC()
+	update := db.UpdateQuoteStatusParams{
+		ID:     quote.ID,
+		Status: status,
+		SentAt: quote.SentAt,
+	}
+	switch status {
+	case QuoteStatusDraft:
+		update.SentAt = ""
+	case QuoteStatusSent:
+		if len(lines) == 0 {
+			return PricedQuote{}, &FieldError{
+				Field: "status",
+				Err:   fmt.Errorf("%w: quotes without lines cannot be sent", ErrInvalidCommand),
+			}
+		}
+		update.SentAt = now.Format(db.TimeFormat)
+	case QuoteStatusAccepted, QuoteStatusDeclined:
+		if status == QuoteStatusAccepted && quote.ValidUntil != "" && now.Format(time.DateOnly) > quote.ValidUntil {
+			return PricedQuote{}, &FieldError{
+				Field: "status",
+				Err:   fmt.Errorf("%w: the quote expired on %s", ErrInvalidCommand, quote.ValidUntil),
+			}
+		}
+		update.DecidedAt = now.Format(db.TimeFormat)
+	}
+
+	updated, err := querier.UpdateQuoteStatus(ctx, tx, update)
+	if err != nil {
+		return PricedQuote{}, err
+	}
+
+	if err = recordQuoteHistory(ctx, tx, querier, updated, map[string]any{
+		"from": quote.Status,
+		"to":   updated.Status,
+	}); err != nil {
+		return PricedQuote{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return PricedQuote{}, err
+	}
+
+	return PriceQuote(updated, lines), nil
+}
+
+// visibleQuote returns a quote, not found when viewerID is set and cannot
+// see the lead or contact it is made to.
+func visibleQuote(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	id, viewerID string,
+) (db.Quote, error) {
+	quote, err := querier.GetQuote(ctx, dbc, id)
+	if err != nil {
+		return db.Quote{}, err
+	}
+	if _, _, err := attachableRecord(ctx, dbc, querier, "", quote.EntityID, viewerID); err != nil {
+		return db.Quote{}, err
+	}
+	return quote, nil
+}
+
+// editQuote runs edit on a visible draft quote in a transaction and returns
+// the quote as edit left it.
+func editQuote(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	id, viewerID string,
+	edit func(tx *sqlx.Tx, quote db.Quote) error,
+) (priced PricedQuote, err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return PricedQuote{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	quote, err := visibleQuote(ctx, tx, querier, id, viewerID)
+	if err != nil {
+		return PricedQuote{}, err
+	}
+	if quote.Status != QuoteStatusDraft {
+		return PricedQuote{}, fmt.Errorf("%w: only draft quotes can be changed, this one is %s", ErrInvalidCommand, quote.Status)
+	}
+
+	if err = edit(tx, quote); err != nil {
+		return PricedQuote{}, err
+	}
+	if err = querier.TouchQuote(ctx, tx, quote.ID); err != nil {
+		return PricedQuote{}, err
+	}
+
+	priced, err = GetQuote(ctx, tx, querier, quote.ID, "")
+	if err != nil {
+		return PricedQuote{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return PricedQuote{}, err
+	}
+
+	return priced, nil
+}
+
+// recordQuoteHistory notes a change to a quote in the history of the lead or
+// contact it is made to.
+func recordQuoteHistory(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	quote db.Quote,
+	change map[string]any,
+) error {
+	change["quote_id"] = quote.ID
+	change["number"] = quote.Number
+	details, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	return querier.InsertEntityHistory(ctx, dbc, db.InsertEntityHistoryParams{
+		EntityID: quote.EntityID,
+		Action:   HistoryActionQuotes,
+		Details:  string(details),
+	})
+}
@@ -0,0 +1,175 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	netmail "net/mail"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/reports"
+)
+
+type CreateScheduledReportParams struct {
+	Name string
+	// Metrics are any of reports.Metrics
+	Metrics    []string
+	Recipients []string
+	// Schedule is a cron expression, read in Timezone, UTC by default
+	Schedule string
+	Timezone string
+	// Period is the range each run covers, a week by default
+	Period string
+	// Filter is a query string of report parameters, without a range
+	Filter string
+}
+
+func CreateScheduledReport(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateScheduledReportParams,
+) (db.ScheduledReport, error) {
+	if len(params.Metrics) == 0 {
+		return db.ScheduledReport{}, &FieldError{
+			Field: "metrics",
+			Err:   fmt.Errorf("%w: at least one metric is required", ErrInvalidCommand),
+		}
+	}
+	for i, metric := range params.Metrics {
+		if !slices.Contains(reports.Metrics, metric) || slices.Contains(params.Metrics[:i], metric) {
+			return db.ScheduledReport{}, &FieldError{
+				Field: "metrics",
+				Err:   fmt.Errorf("%w: unknown or repeated metric %q", ErrInvalidCommand, metric),
+			}
+		}
+	}
+
+	for _, recipient := range params.Recipients {
+		if _, err := netmail.ParseAddress(recipient); err != nil {
+			return db.ScheduledReport{}, &FieldError{
+				Field: "recipients",
+				Err:   fmt.Errorf("%w: invalid address %q", ErrInvalidCommand, recipient),
+			}
+		}
+	}
+
+	if params.Timezone == "" {
+		params.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(params.Timezone); err != nil {
+		return db.ScheduledReport{}, &FieldError{
+			Field: "timezone",
+			Err:   fmt.Errorf("%w: unknown timezone %q", ErrInvalidCommand, params.Timezone),
+		}
+	}
+
+	if params.Period == "" {
+		params.Period = "week"
+	}
+	if _, ok := reports.Periods[params.Period]; !ok {
+		return db.ScheduledReport{}, &FieldError{
+			Field: "period",
+			Err:   fmt.Errorf("%w: unknown period %q", ErrInvalidCommand, params.Period),
+		}
+	}
+
+	q, err := url.ParseQuery(params.Filter)
+	if err != nil {
+		return db.ScheduledReport{}, &FieldError{
+			Field: "filter",
+			Err:   fmt.Errorf("%w: %s", ErrInvalidCommand, err),
+		}
+	}
+	if q.Has("from") || q.Has("to") {
+		return db.ScheduledReport{}, &FieldError{
+			Field: "filter",
+			Err:   fmt.Errorf("%w: the range is set by the period", ErrInvalidCommand),
+		}
+	}
+	for _, metric := range params.Metrics {
+		if _, err := reports.Filter(metric, q); err != nil {
+			return db.ScheduledReport{}, &FieldError{
+				Field: "filter",
+				Err:   fmt.Errorf("%w: %s report: %s", ErrInvalidCommand, metric, err),
+			}
+		}
+	}
+
+	nextRunAt, err := reports.NextRun(params.Schedule, params.Timezone, time.Now())
+	if err != nil {
+		return db.ScheduledReport{}, &FieldError{
+			Field: "schedule",
+			Err:   fmt.Errorf("%w: %s", ErrInvalidCommand, err),
+		}
+	}
+
+	existing, err := querier.ListScheduledReports(ctx, dbc)
+	if err != nil {
+		return db.ScheduledReport{}, err
+	}
+	for _, report := range existing {
+		if report.Name == params.Name {
+			return db.ScheduledReport{}, &FieldError{
+				Field: "name",
+				Err:   fmt.Errorf("%w: a scheduled report named %q already exists", ErrInvalidCommand, params.Name),
+			}
+		}
+	}
+
+	metrics, err := json.Marshal(params.Metrics)
+	if err != nil {
+		return db.ScheduledReport{}, err
+	}
+	recipients := []byte("[]")
+	if len(params.Recipients) > 0 {
+		if recipients, err = json.Marshal(params.Recipients); err != nil {
+			return db.ScheduledReport{}, err
+		}
+	}
+
+	return querier.InsertScheduledReport(ctx, dbc, db.InsertScheduledReportParams{
+		ID:         uuid.New().String(),
+		Name:       params.Name,
+		Metrics:    string(metrics),
+		Recipients: string(recipients),
+		Schedule:   params.Schedule,
+		Timezone:   params.Timezone,
+		Period:     params.Period,
+		Filter:     params.Filter,
+		NextRunAt:  nextRunAt,
+	})
+}
+
+// DeleteScheduledReport deletes a scheduled report along with its snapshots.
+func DeleteScheduledReport(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	id string,
+) (err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = querier.DeleteReportSnapshots(ctx, tx, id); err != nil {
+		return err
+	}
+	if err = querier.DeleteScheduledReport(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
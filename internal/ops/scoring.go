@@ -0,0 +1,75 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+	"simplecrm/internal/scoring"
+)
+
+type CreateScoringRuleParams struct {
+	Name         string
+	Kind         string
+	Field        string
+	Value        string
+	Points       int
+	HalfLifeDays int
+}
+
+// CreateScoringRule stores a rule and has every entity rescored.
+func CreateScoringRule(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateScoringRuleParams,
+	eventService pubsub.EventServicer,
+) (db.ScoringRule, error) {
+	if err := scoring.ValidateRule(params.Kind, params.Field); err != nil {
+		return db.ScoringRule{}, fmt.Errorf("%w: %s", ErrInvalidCommand, err)
+	}
+
+	rule, err := querier.InsertScoringRule(ctx, dbc, db.InsertScoringRuleParams{
+		ID:           uuid.New().String(),
+		Name:         params.Name,
+		Kind:         params.Kind,
+		Field:        params.Field,
+		Value:        params.Value,
+		Points:       params.Points,
+		HalfLifeDays: params.HalfLifeDays,
+	})
+	if err != nil {
+		return db.ScoringRule{}, err
+	}
+
+	publishScoringRulesChanged(ctx, eventService, rule.ID)
+	return rule, nil
+}
+
+// DeleteScoringRule removes a rule and has every entity rescored.
+func DeleteScoringRule(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	id string,
+	eventService pubsub.EventServicer,
+) error {
+	if err := querier.DeleteScoringRule(ctx, dbc, id); err != nil {
+		return err
+	}
+
+	publishScoringRulesChanged(ctx, eventService, id)
+	return nil
+}
+
+func publishScoringRulesChanged(ctx context.Context, eventService pubsub.EventServicer, ruleID string) {
+	event := pubsub.NewEvent(pubsub.EventScoringRulesChanged, map[string]any{"rule_id": ruleID})
+	if err := eventService.Publish(ctx, event); err != nil {
+		slog.Error("Failed to publish event", "type", event.Type, "rule", ruleID, "error", err)
+	}
+}
@@ -0,0 +1,96 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+)
+
+// SegmentObjectTypes lists the records segments can select.
+var SegmentObjectTypes = []string{ObjectTypeLead, ObjectTypeContact}
+
+type CreateSegmentParams struct {
+	Name       string
+	ObjectType string
+	// Filter is a query string in the syntax of the list filters
+	Filter string
+}
+
+func CreateSegment(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateSegmentParams,
+) (db.Segment, error) {
+	if !slices.Contains(SegmentObjectTypes, params.ObjectType) {
+		return db.Segment{}, &FieldError{
+			Field: "object_type",
+			Err:   fmt.Errorf("%w: unknown object type %q", ErrInvalidCommand, params.ObjectType),
+		}
+	}
+
+	segment := db.Segment{ObjectType: params.ObjectType, Filter: params.Filter}
+	if _, err := SegmentFilter(ctx, dbc, querier, segment); err != nil {
+		return db.Segment{}, &FieldError{Field: "filter", Err: err}
+	}
+
+	segments, err := querier.ListSegments(ctx, dbc)
+	if err != nil {
+		return db.Segment{}, err
+	}
+	for _, segment := range segments {
+		if segment.Name == params.Name {
+			return db.Segment{}, &FieldError{
+				Field: "name",
+				Err:   fmt.Errorf("%w: a segment named %q already exists", ErrInvalidCommand, params.Name),
+			}
+		}
+	}
+
+	return querier.InsertSegment(ctx, dbc, db.InsertSegmentParams{
+		ID:         uuid.New().String(),
+		Name:       params.Name,
+		ObjectType: params.ObjectType,
+		Filter:     params.Filter,
+	})
+}
+
+// SegmentFilter evaluates the filter a segment is stored with against the
+// custom fields currently defined. A segment is a set of records, so its
+// filter cannot page, and the same set for everyone, so it cannot depend on
+// the viewer.
+func SegmentFilter(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	segment db.Segment,
+) (db.EntityFilter, error) {
+	q, err := url.ParseQuery(segment.Filter)
+	if err != nil {
+		return db.EntityFilter{}, fmt.Errorf("%w: invalid filter: %s", ErrInvalidCommand, err)
+	}
+	if q.Has("limit") || q.Has("offset") {
+		return db.EntityFilter{}, fmt.Errorf("%w: segment filters cannot have a limit or offset", ErrInvalidCommand)
+	}
+	if q.Has("viewer_id") {
+		return db.EntityFilter{}, fmt.Errorf("%w: segment filters cannot have a viewer", ErrInvalidCommand)
+	}
+
+	fields, err := querier.ListCustomFields(ctx, dbc, segment.ObjectType)
+	if err != nil {
+		return db.EntityFilter{}, err
+	}
+
+	filter, err := filters.Entity(q, segment.ObjectType, fields)
+	if err != nil {
+		return db.EntityFilter{}, fmt.Errorf("%w: %s", ErrInvalidCommand, err)
+	}
+	return filter, nil
+}
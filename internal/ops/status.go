@@ -0,0 +1,89 @@
+package ops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+const HistoryActionStatus = "status"
+
+// LeadStatuses lists the statuses a lead can be moved between. Converting a
+// lead into a contact is not a plain status change.
+var LeadStatuses = []string{LeadStatusNew, LeadStatusContacted, LeadStatusQualified, LeadStatusUnqualified}
+
+// ChangeEntityStatus moves a lead to another status, recording the change in
+// its history.
+func ChangeEntityStatus(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	entityID, status string,
+	eventService pubsub.EventServicer,
+) (entity db.Entity, err error) {
+	if !slices.Contains(LeadStatuses, status) {
+		return db.Entity{}, fmt.Errorf("%w: unknown status %q", ErrInvalidCommand, status)
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Entity{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	previous, err := querier.GetEntity(ctx, tx, entityID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+	if previous.Status == EntityStatusConverted {
+		return db.Entity{}, fmt.Errorf("%w: %s is already converted", ErrInvalidCommand, entityID)
+	}
+
+	entity, err = querier.UpdateEntityStatus(ctx, tx, entityID, status)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"previous_status": previous.Status,
+		"status":          status,
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	err = querier.InsertEntityHistory(ctx, tx, db.InsertEntityHistoryParams{
+		EntityID: entity.ID,
+		Action:   HistoryActionStatus,
+		Details:  string(details),
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Entity{}, err
+	}
+
+	if previous.Status != status {
+		payload := pubsub.EntityPayload(entity)
+		payload["previous_status"] = previous.Status
+		event := pubsub.NewEvent(pubsub.EventEntityStatusChanged, payload)
+		if err := eventService.Publish(ctx, event); err != nil {
+			slog.Error("Failed to publish event", "type", event.Type, "entity", entity.ID, "error", err)
+		}
+	}
+
+	return entity, nil
+}
@@ -0,0 +1,113 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+)
+
+const HistoryActionTags = "tags"
+
+// maxTagLength bounds, in characters, tag names.
+const maxTagLength = 64
+
+// TagEntity adds tags to, or with remove set removes them from, a lead or
+// contact. Tags are created the first time they are used. Changes are
+// recorded in the entity's history.
+func TagEntity(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	entityID string,
+	tags []string,
+	remove bool,
+) (entity db.Entity, err error) {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		name := strings.TrimSpace(tag)
+		if name == "" || utf8.RuneCountInString(name) > maxTagLength {
+			return db.Entity{}, &FieldError{
+				Field: "tags",
+				Err:   fmt.Errorf("%w: tags must be 1 to %d characters", ErrInvalidCommand, maxTagLength),
+			}
+		}
+		names = append(names, name)
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Entity{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	entity, err = querier.GetEntity(ctx, tx, entityID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	changed := []string{}
+	for _, name := range names {
+		var (
+			tag db.Tag
+			ok  bool
+		)
+		if remove {
+			tag, err = querier.GetTagByName(ctx, tx, name)
+			if err == nil {
+				ok, err = querier.UntagEntity(ctx, tx, tag.ID, entity.ID)
+			} else if errors.Is(err, sql.ErrNoRows) {
+				err = nil
+			}
+		} else {
+			tag, err = querier.EnsureTag(ctx, tx, uuid.New().String(), name)
+			if err == nil {
+				ok, err = querier.TagEntity(ctx, tx, tag.ID, entity.ID)
+			}
+		}
+		if err != nil {
+			return db.Entity{}, err
+		}
+		if ok {
+			changed = append(changed, tag.Name)
+		}
+	}
+
+	if len(changed) > 0 {
+		key := "added"
+		if remove {
+			key = "removed"
+		}
+		details, err := json.Marshal(map[string]any{key: changed})
+		if err != nil {
+			return db.Entity{}, err
+		}
+
+		err = querier.InsertEntityHistory(ctx, tx, db.InsertEntityHistoryParams{
+			EntityID: entity.ID,
+			Action:   HistoryActionTags,
+			Details:  string(details),
+		})
+		if err != nil {
+			return db.Entity{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Entity{}, err
+	}
+
+	return entity, nil
+}
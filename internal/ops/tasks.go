@@ -0,0 +1,312 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+	"simplecrm/internal/recurrence"
+)
+
+const (
+	TaskStatusOpen = "open"
+	TaskStatusDone = "done"
+)
+
+type CreateTaskParams struct {
+	Name        string
+	Description string
+	DueDate     string
+	AssignedTo  string
+	// TeamID is the team owning the task along with its assignee
+	TeamID   string
+	EntityID string
+	// Recurrence is an RRULE, which requires a due date to anchor it
+	Recurrence string
+	// CustomFields holds values keyed by custom field key
+	CustomFields map[string]any
+}
+
+func CreateTask(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateTaskParams,
+	eventService pubsub.EventServicer,
+) (task db.Task, err error) {
+	if params.AssignedTo != "" {
+		if err := CheckAssignable(ctx, dbc, querier, params.AssignedTo); err != nil {
+			return db.Task{}, err
+		}
+	}
+	if params.TeamID != "" {
+		if err := checkTeam(ctx, dbc, querier, "team_id", params.TeamID); err != nil {
+			return db.Task{}, err
+		}
+	}
+	if params.EntityID != "" {
+		if _, err := querier.GetEntity(ctx, dbc, params.EntityID); err != nil {
+			return db.Task{}, err
+		}
+	}
+	if params.DueDate != "" {
+		if _, _, err := recurrence.ParseTime(params.DueDate); err != nil {
+			return db.Task{}, &FieldError{Field: "due_date", Err: fmt.Errorf("%w: %s", ErrInvalidCommand, err)}
+		}
+	}
+	if params.Recurrence != "" {
+		rule, err := recurrence.Parse(params.Recurrence)
+		if err != nil {
+			return db.Task{}, &FieldError{Field: "recurrence", Err: fmt.Errorf("%w: %s", ErrInvalidCommand, err)}
+		}
+		if params.DueDate == "" {
+			return db.Task{}, &FieldError{Field: "due_date", Err: fmt.Errorf("%w: recurring tasks need a due date", ErrInvalidCommand)}
+		}
+		params.Recurrence = rule.String()
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Task{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	id := uuid.New().String()
+	task, err = querier.InsertTask(ctx, tx, db.InsertTaskParams{
+		ID:          id,
+		Name:        params.Name,
+		Description: params.Description,
+		DueDate:     params.DueDate,
+		AssignedTo:  sql.NullString{String: params.AssignedTo, Valid: params.AssignedTo != ""},
+		Status:      TaskStatusOpen,
+		EntityID:    sql.NullString{String: params.EntityID, Valid: params.EntityID != ""},
+		Recurrence:  params.Recurrence,
+		SeriesID:    id,
+		Occurrence:  1,
+		TeamID:      sql.NullString{String: params.TeamID, Valid: params.TeamID != ""},
+	})
+	if err != nil {
+		return db.Task{}, err
+	}
+	if err = setCustomFields(ctx, tx, querier, ObjectTypeTask, task.ID, params.CustomFields); err != nil {
+		return db.Task{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Task{}, err
+	}
+
+	event := pubsub.NewEvent(pubsub.EventTaskCreated, pubsub.TaskPayload(task))
+	if err := eventService.Publish(ctx, event); err != nil {
+		slog.Error("Failed to publish event", "type", event.Type, "task", task.ID, "error", err)
+	}
+
+	return task, nil
+}
+
+// CompleteTask marks a task done. Completing an occurrence of a recurring
+// task creates the next occurrence, which is returned as well unless the
+// series has ended.
+func CompleteTask(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	taskID string,
+	eventService pubsub.EventServicer,
+) (completed db.Task, next *db.Task, err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Task{}, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	task, err := querier.GetTask(ctx, tx, taskID)
+	if err != nil {
+		return db.Task{}, nil, err
+	}
+	if task.Status == TaskStatusDone {
+		return db.Task{}, nil, fmt.Errorf("%w: task %s is already done", ErrInvalidCommand, taskID)
+	}
+
+	completed, err = querier.UpdateTaskStatus(ctx, tx, taskID, TaskStatusDone)
+	if err != nil {
+		return db.Task{}, nil, err
+	}
+
+	if dueDate, ok, err := nextDueDate(task); err != nil {
+		return db.Task{}, nil, err
+	} else if ok {
+		seriesID := task.SeriesID
+		if seriesID == "" {
+			seriesID = task.ID
+		}
+		created, err := querier.InsertTask(ctx, tx, db.InsertTaskParams{
+			ID:          uuid.New().String(),
+			Name:        task.Name,
+			Description: task.Description,
+			DueDate:     dueDate,
+			AssignedTo:  task.AssignedTo,
+			Status:      TaskStatusOpen,
+			EntityID:    task.EntityID,
+			Recurrence:  task.Recurrence,
+			SeriesID:    seriesID,
+			Occurrence:  task.Occurrence + 1,
+			TeamID:      task.TeamID,
+		})
+		if err != nil {
+			return db.Task{}, nil, err
+		}
+		// The next occurrence carries over the custom field values
+		values, err := querier.ListCustomFieldValues(ctx, tx, []string{task.ID})
+		if err != nil {
+			return db.Task{}, nil, err
+		}
+		for _, value := range values {
+			if err := querier.SetCustomFieldValue(ctx, tx, value.FieldID, created.ID, value.Value); err != nil {
+				return db.Task{}, nil, err
+			}
+		}
+		next = &created
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Task{}, nil, err
+	}
+
+	event := pubsub.NewEvent(pubsub.EventTaskCompleted, pubsub.TaskPayload(completed))
+	if err := eventService.Publish(ctx, event); err != nil {
+		slog.Error("Failed to publish event", "type", event.Type, "task", completed.ID, "error", err)
+	}
+	if next != nil {
+		event := pubsub.NewEvent(pubsub.EventTaskCreated, pubsub.TaskPayload(*next))
+		if err := eventService.Publish(ctx, event); err != nil {
+			slog.Error("Failed to publish event", "type", event.Type, "task", next.ID, "error", err)
+		}
+	}
+
+	return completed, next, nil
+}
+
+// nextDueDate returns the due date of the occurrence following task, if its
+// series continues.
+func nextDueDate(task db.Task) (string, bool, error) {
+	if task.Recurrence == "" {
+		return "", false, nil
+	}
+
+	rule, err := recurrence.Parse(task.Recurrence)
+	if err != nil {
+		return "", false, err
+	}
+	if rule.Count > 0 && task.Occurrence >= rule.Count {
+		return "", false, nil
+	}
+
+	due, layout, err := recurrence.ParseTime(task.DueDate)
+	if err != nil {
+		return "", false, err
+	}
+
+	next, ok := rule.Next(due)
+	if !ok {
+		return "", false, nil
+	}
+	return next.Format(layout), true, nil
+}
+
+// TaskOccurrence is a task, or a future occurrence of a recurring task that
+// has not been created yet.
+type TaskOccurrence struct {
+	Task       db.Task
+	DueDate    string
+	Occurrence int
+	Projected  bool
+}
+
+// ExpandTasks lists the tasks matching filter that are due within [from, to)
+// along with the occurrences their recurrence rules project into that range,
+// ordered by due date. At most max occurrences are returned.
+func ExpandTasks(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	filter db.TaskFilter,
+	from, to time.Time,
+	max int,
+) ([]TaskOccurrence, error) {
+	// Recurring tasks due before the range can still recur within it
+	filter.DueFrom = ""
+	filter.DueTo = to.Format(db.TimeFormat)
+	filter.Limit, filter.Offset = 0, 0
+
+	occurrences := []TaskOccurrence{}
+	err := querier.IterateTasks(ctx, dbc, filter, func(task db.Task) error {
+		due, layout, err := recurrence.ParseTime(task.DueDate)
+		if err != nil {
+			return nil
+		}
+
+		if !due.Before(from) && due.Before(to) {
+			occurrences = append(occurrences, TaskOccurrence{
+				Task:       task,
+				DueDate:    task.DueDate,
+				Occurrence: task.Occurrence,
+			})
+		}
+
+		if task.Recurrence == "" || task.Status == TaskStatusDone {
+			return nil
+		}
+		rule, err := recurrence.Parse(task.Recurrence)
+		if err != nil {
+			return nil
+		}
+
+		remaining := 0
+		if rule.Count > 0 {
+			if remaining = rule.Count - task.Occurrence; remaining <= 0 {
+				return nil
+			}
+		}
+		for _, occurrence := range rule.Between(due, from, to, remaining, max) {
+			occurrences = append(occurrences, TaskOccurrence{
+				Task:       task,
+				DueDate:    occurrence.Time.Format(layout),
+				Occurrence: task.Occurrence + occurrence.N,
+				Projected:  true,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if occurrences[i].DueDate != occurrences[j].DueDate {
+			return occurrences[i].DueDate < occurrences[j].DueDate
+		}
+		return occurrences[i].Task.ID < occurrences[j].Task.ID
+	})
+	if len(occurrences) > max {
+		occurrences = occurrences[:max]
+	}
+
+	return occurrences, nil
+}
@@ -0,0 +1,359 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+// Team roles. Members see the records their team owns, managers also those
+// assigned to the team's members and those of the teams below it.
+const (
+	TeamRoleMember  = "member"
+	TeamRoleManager = "manager"
+)
+
+var TeamRoles = []string{TeamRoleMember, TeamRoleManager}
+
+const HistoryActionAssignTeam = "assign_team"
+
+type CreateTeamParams struct {
+	Name string
+	// ParentID nests the team under another, whose managers manage it too
+	ParentID string
+}
+
+func CreateTeam(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateTeamParams,
+	eventService pubsub.EventServicer,
+) (db.Team, error) {
+	if params.ParentID != "" {
+		if err := checkTeam(ctx, dbc, querier, "parent_id", params.ParentID); err != nil {
+			return db.Team{}, err
+		}
+	}
+
+	teams, err := querier.ListTeams(ctx, dbc)
+	if err != nil {
+		return db.Team{}, err
+	}
+	for _, team := range teams {
+		if team.Name == params.Name {
+			return db.Team{}, &FieldError{
+				Field: "name",
+				Err:   fmt.Errorf("%w: a team named %q already exists", ErrInvalidCommand, params.Name),
+			}
+		}
+	}
+
+	team, err := querier.InsertTeam(ctx, dbc, db.InsertTeamParams{
+		ID:       uuid.New().String(),
+		Name:     params.Name,
+		ParentID: sql.NullString{String: params.ParentID, Valid: params.ParentID != ""},
+	})
+	if err != nil {
+		return db.Team{}, err
+	}
+
+	publishTeamEvent(ctx, eventService, pubsub.EventTeamCreated, team.ID, pubsub.TeamPayload(team))
+
+	return team, nil
+}
+
+// DeleteTeam deletes a team. Its sub-teams move up to its parent and the
+// records it owned stay with their assignees.
+func DeleteTeam(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	teamID string,
+	eventService pubsub.EventServicer,
+) (team db.Team, err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Team{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	team, err = querier.GetTeam(ctx, tx, teamID)
+	if err != nil {
+		return db.Team{}, err
+	}
+	if err = querier.DeleteTeam(ctx, tx, teamID); err != nil {
+		return db.Team{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Team{}, err
+	}
+
+	publishTeamEvent(ctx, eventService, pubsub.EventTeamDeleted, team.ID, pubsub.TeamPayload(team))
+
+	return team, nil
+}
+
+// SetTeamMember adds a user to a team with the given role, or changes the
+// role of a member.
+func SetTeamMember(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	teamID, userID, role string,
+	eventService pubsub.EventServicer,
+) (db.Team, error) {
+	if role == "" {
+		role = TeamRoleMember
+	}
+	if !slices.Contains(TeamRoles, role) {
+		return db.Team{}, &FieldError{
+			Field: "role",
+			Err:   fmt.Errorf("%w: unknown role %q", ErrInvalidCommand, role),
+		}
+	}
+
+	team, err := querier.GetTeam(ctx, dbc, teamID)
+	if err != nil {
+		return db.Team{}, err
+	}
+	if _, err := querier.GetUser(ctx, dbc, userID); errors.Is(err, sql.ErrNoRows) {
+		return db.Team{}, &FieldError{
+			Field: "user_id",
+			Err:   fmt.Errorf("%w: user %s does not exist", ErrInvalidCommand, userID),
+		}
+	} else if err != nil {
+		return db.Team{}, err
+	}
+
+	members, err := querier.ListTeamMembers(ctx, dbc, []string{teamID})
+	if err != nil {
+		return db.Team{}, err
+	}
+	previousRole := ""
+	if i := slices.IndexFunc(members, func(m db.TeamMember) bool { return m.UserID == userID }); i >= 0 {
+		previousRole = members[i].Role
+	}
+	if previousRole == role {
+		return team, nil
+	}
+
+	err = querier.SetTeamMember(ctx, dbc, db.TeamMember{TeamID: teamID, UserID: userID, Role: role})
+	if err != nil {
+		return db.Team{}, err
+	}
+
+	publishTeamEvent(ctx, eventService, pubsub.EventTeamMemberAdded, team.ID, map[string]any{
+		"team_id":       team.ID,
+		"team_name":     team.Name,
+		"user_id":       userID,
+		"role":          role,
+		"previous_role": previousRole,
+	})
+
+	return team, nil
+}
+
+func RemoveTeamMember(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	teamID, userID string,
+	eventService pubsub.EventServicer,
+) (db.Team, error) {
+	team, err := querier.GetTeam(ctx, dbc, teamID)
+	if err != nil {
+		return db.Team{}, err
+	}
+	if err := querier.DeleteTeamMember(ctx, dbc, teamID, userID); err != nil {
+		return db.Team{}, err
+	}
+
+	publishTeamEvent(ctx, eventService, pubsub.EventTeamMemberRemoved, team.ID, map[string]any{
+		"team_id":   team.ID,
+		"team_name": team.Name,
+		"user_id":   userID,
+	})
+
+	return team, nil
+}
+
+func publishTeamEvent(
+	ctx context.Context,
+	eventService pubsub.EventServicer,
+	eventType, teamID string,
+	payload map[string]any,
+) {
+	if err := eventService.Publish(ctx, pubsub.NewEvent(eventType, payload)); err != nil {
+		slog.Error("Failed to publish event", "type", eventType, "team", teamID, "error", err)
+	}
+}
+
+// checkTeam returns an error on field unless teamID is an existing team.
+func checkTeam(ctx context.Context, dbc db.DBExecutor, querier db.Querier, field, teamID string) error {
+	if _, err := querier.GetTeam(ctx, dbc, teamID); errors.Is(err, sql.ErrNoRows) {
+		return &FieldError{Field: field, Err: fmt.Errorf("%w: unknown team %q", ErrInvalidCommand, teamID)}
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// AssignEntityTeam hands an entity to a team, or takes it from its team when
+// teamID is empty, recording the change in its history. The assignee is
+// left as it is.
+func AssignEntityTeam(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	entityID, teamID string,
+	eventService pubsub.EventServicer,
+) (entity db.Entity, err error) {
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Entity{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	previous, err := querier.GetEntity(ctx, tx, entityID)
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	if teamID != "" {
+		if err = checkTeam(ctx, tx, querier, "team_id", teamID); err != nil {
+			return db.Entity{}, err
+		}
+	}
+
+	entity, err = querier.UpdateEntityTeam(ctx, tx, entityID, sql.NullString{String: teamID, Valid: teamID != ""})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"previous_team_id": previous.TeamID.String,
+		"team_id":          teamID,
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	err = querier.InsertEntityHistory(ctx, tx, db.InsertEntityHistoryParams{
+		EntityID: entity.ID,
+		Action:   HistoryActionAssignTeam,
+		Details:  string(details),
+	})
+	if err != nil {
+		return db.Entity{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Entity{}, err
+	}
+
+	if previous.TeamID.String != teamID {
+		payload := pubsub.EntityPayload(entity)
+		payload["previous_team_id"] = previous.TeamID.String
+		event := pubsub.NewEvent(pubsub.EventEntityTeamAssigned, payload)
+		if err := eventService.Publish(ctx, event); err != nil {
+			slog.Error("Failed to publish event", "type", event.Type, "entity", entity.ID, "error", err)
+		}
+	}
+
+	return entity, nil
+}
+
+// AssignTaskTeam hands a task to a team, or takes it from its team when
+// teamID is empty.
+func AssignTaskTeam(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	taskID, teamID string,
+	eventService pubsub.EventServicer,
+) (db.Task, error) {
+	previous, err := querier.GetTask(ctx, dbc, taskID)
+	if err != nil {
+		return db.Task{}, err
+	}
+	if teamID != "" {
+		if err := checkTeam(ctx, dbc, querier, "team_id", teamID); err != nil {
+			return db.Task{}, err
+		}
+	}
+
+	task, err := querier.UpdateTaskTeam(ctx, dbc, taskID, sql.NullString{String: teamID, Valid: teamID != ""})
+	if err != nil {
+		return db.Task{}, err
+	}
+
+	if previous.TeamID.String != teamID {
+		payload := pubsub.TaskPayload(task)
+		payload["previous_team_id"] = previous.TeamID.String
+		event := pubsub.NewEvent(pubsub.EventTaskTeamAssigned, payload)
+		if err := eventService.Publish(ctx, event); err != nil {
+			slog.Error("Failed to publish event", "type", event.Type, "task", task.ID, "error", err)
+		}
+	}
+
+	return task, nil
+}
+
+// CanSeeRecord reports whether viewerID can see a lead, contact or task with
+// the given assignee and team, by the same rules as the visibility filter
+// of the listings: unowned records are visible to everyone, assigned ones
+// to their assignee and the managers of the assignee's teams, and team
+// records to the team's members and managers.
+func CanSeeRecord(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	viewerID string,
+	assignedTo, teamID sql.NullString,
+) (bool, error) {
+	if !assignedTo.Valid && !teamID.Valid {
+		return true, nil
+	}
+
+	if assignedTo.Valid {
+		users, err := querier.ListVisibleUserIDs(ctx, dbc, viewerID)
+		if err != nil {
+			return false, err
+		}
+		if slices.Contains(users, assignedTo.String) {
+			return true, nil
+		}
+	}
+
+	if teamID.Valid {
+		teams, err := querier.ListVisibleTeamIDs(ctx, dbc, viewerID)
+		if err != nil {
+			return false, err
+		}
+		if slices.Contains(teams, teamID.String) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,283 @@
+package ops
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+)
+
+// Resources saved views can list
+const (
+	ResourceLeads    = "leads"
+	ResourceContacts = "contacts"
+	ResourceTasks    = "tasks"
+)
+
+// entityViewColumns are the fields of leads and contacts.
+var entityViewColumns = []string{
+	"id", "first_name", "last_name", "email", "phone", "status", "assigned_to", "created_at",
+	"converted_at", "source", "region", "score", "utm_source", "utm_medium", "utm_campaign",
+	"utm_term", "utm_content", "tags",
+}
+
+// ViewColumns lists, per resource, the response fields a saved view can
+// select. Custom fields are selected as cf.<key>.
+var ViewColumns = map[string][]string{
+	ResourceLeads:    entityViewColumns,
+	ResourceContacts: entityViewColumns,
+	ResourceTasks: {
+		"id", "name", "description", "due_date", "assigned_to", "status", "entity_id", "recurrence",
+		"series_id", "occurrence",
+	},
+}
+
+// ResourceObjectType returns the object type custom fields of the resource
+// are defined on.
+func ResourceObjectType(resource string) string {
+	switch resource {
+	case ResourceContacts:
+		return ObjectTypeContact
+	case ResourceTasks:
+		return ObjectTypeTask
+	default:
+		return ObjectTypeLead
+	}
+}
+
+type CreateSavedViewParams struct {
+	OwnerID  string
+	Name     string
+	Resource string
+	// Filter is a query string in the syntax of the list filters
+	Filter  string
+	Sort    string
+	Columns []string
+	Shared  bool
+	// TeamID shares the view with the members and managers of a team the
+	// owner belongs to or manages
+	TeamID string
+}
+
+func CreateSavedView(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateSavedViewParams,
+) (db.SavedView, error) {
+	if _, ok := ViewColumns[params.Resource]; !ok {
+		return db.SavedView{}, &FieldError{
+			Field: "resource",
+			Err:   fmt.Errorf("%w: unknown resource %q", ErrInvalidCommand, params.Resource),
+		}
+	}
+	if _, err := querier.GetUser(ctx, dbc, params.OwnerID); errors.Is(err, sql.ErrNoRows) {
+		return db.SavedView{}, &FieldError{
+			Field: "owner_id",
+			Err:   fmt.Errorf("%w: user %s does not exist", ErrInvalidCommand, params.OwnerID),
+		}
+	} else if err != nil {
+		return db.SavedView{}, err
+	}
+
+	view := db.SavedView{Resource: params.Resource, Filter: params.Filter, Sort: params.Sort}
+	if q, err := url.ParseQuery(params.Filter); err != nil {
+		return db.SavedView{}, &FieldError{
+			Field: "filter",
+			Err:   fmt.Errorf("%w: %s", ErrInvalidCommand, err),
+		}
+	} else if q.Has("sort") || q.Has("limit") || q.Has("offset") {
+		return db.SavedView{}, &FieldError{
+			Field: "filter",
+			Err:   fmt.Errorf("%w: sort and paging are not part of the filter", ErrInvalidCommand),
+		}
+	} else if q.Has("viewer_id") {
+		return db.SavedView{}, &FieldError{
+			Field: "filter",
+			Err:   fmt.Errorf("%w: views list what the user running them can see", ErrInvalidCommand),
+		}
+	}
+	if _, _, err := SavedViewFilter(ctx, dbc, querier, view); err != nil {
+		return db.SavedView{}, &FieldError{Field: "filter", Err: err}
+	}
+
+	fields, err := querier.ListCustomFields(ctx, dbc, ResourceObjectType(params.Resource))
+	if err != nil {
+		return db.SavedView{}, err
+	}
+	for i, column := range params.Columns {
+		ok := slices.Contains(ViewColumns[params.Resource], column)
+		if key, found := strings.CutPrefix(column, "cf."); found {
+			ok = slices.ContainsFunc(fields, func(f db.CustomField) bool { return f.Key == key })
+		}
+		if !ok || slices.Contains(params.Columns[:i], column) {
+			return db.SavedView{}, &FieldError{
+				Field: "columns",
+				Err:   fmt.Errorf("%w: unknown or repeated column %q", ErrInvalidCommand, column),
+			}
+		}
+	}
+
+	if params.TeamID != "" {
+		teams, err := querier.ListVisibleTeamIDs(ctx, dbc, params.OwnerID)
+		if err != nil {
+			return db.SavedView{}, err
+		}
+		if !slices.Contains(teams, params.TeamID) {
+			return db.SavedView{}, &FieldError{
+				Field: "team_id",
+				Err:   fmt.Errorf("%w: views can only be shared with your own teams", ErrInvalidCommand),
+			}
+		}
+	}
+
+	views, err := querier.ListSavedViews(ctx, dbc, params.OwnerID, params.Resource)
+	if err != nil {
+		return db.SavedView{}, err
+	}
+	for _, view := range views {
+		if view.OwnerID == params.OwnerID && view.Name == params.Name {
+			return db.SavedView{}, &FieldError{
+				Field: "name",
+				Err:   fmt.Errorf("%w: you already have a view named %q", ErrInvalidCommand, params.Name),
+			}
+		}
+	}
+
+	columns := []byte("[]")
+	if len(params.Columns) > 0 {
+		if columns, err = json.Marshal(params.Columns); err != nil {
+			return db.SavedView{}, err
+		}
+	}
+
+	return querier.InsertSavedView(ctx, dbc, db.InsertSavedViewParams{
+		ID:       uuid.New().String(),
+		OwnerID:  params.OwnerID,
+		Name:     params.Name,
+		Resource: params.Resource,
+		Filter:   params.Filter,
+		Sort:     params.Sort,
+		Columns:  string(columns),
+		Shared:   params.Shared,
+		TeamID:   sql.NullString{String: params.TeamID, Valid: params.TeamID != ""},
+	})
+}
+
+// SavedViewFilter evaluates a view's filter and sort against the custom
+// fields currently defined, returning the entity filter of lead and contact
+// views or the task filter of task views.
+func SavedViewFilter(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	view db.SavedView,
+) (db.EntityFilter, db.TaskFilter, error) {
+	q, err := url.ParseQuery(view.Filter)
+	if err != nil {
+		return db.EntityFilter{}, db.TaskFilter{}, fmt.Errorf("%w: invalid filter: %s", ErrInvalidCommand, err)
+	}
+	q.Del("limit")
+	q.Del("offset")
+	q.Del("sort")
+	q.Del("viewer_id")
+	if view.Sort != "" {
+		q.Set("sort", view.Sort)
+	}
+
+	objectType := ResourceObjectType(view.Resource)
+	fields, err := querier.ListCustomFields(ctx, dbc, objectType)
+	if err != nil {
+		return db.EntityFilter{}, db.TaskFilter{}, err
+	}
+
+	if view.Resource == ResourceTasks {
+		filter, err := filters.Task(q, fields)
+		if err != nil {
+			return db.EntityFilter{}, db.TaskFilter{}, fmt.Errorf("%w: %s", ErrInvalidCommand, err)
+		}
+		return db.EntityFilter{}, filter, nil
+	}
+
+	filter, err := filters.Entity(q, objectType, fields)
+	if err != nil {
+		return db.EntityFilter{}, db.TaskFilter{}, fmt.Errorf("%w: %s", ErrInvalidCommand, err)
+	}
+	return filter, db.TaskFilter{}, nil
+}
+
+// SavedViewColumns returns the columns a view selects, none meaning all.
+func SavedViewColumns(view db.SavedView) ([]string, error) {
+	var columns []string
+	if err := json.Unmarshal([]byte(view.Columns), &columns); err != nil {
+		return nil, fmt.Errorf("saved view %s has invalid columns: %w", view.ID, err)
+	}
+	return columns, nil
+}
+
+// CanSeeSavedView reports whether a user may run a view: its owner, anyone
+// if it is shared, or the members and managers of the team it is shared
+// with.
+func CanSeeSavedView(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	view db.SavedView,
+	userID string,
+) (bool, error) {
+	if view.OwnerID == userID || view.Shared {
+		return true, nil
+	}
+	if !view.TeamID.Valid || userID == "" {
+		return false, nil
+	}
+
+	teams, err := querier.ListVisibleTeamIDs(ctx, dbc, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(teams, view.TeamID.String), nil
+}
+
+// DeleteSavedView deletes a view, which only its owner may do.
+func DeleteSavedView(ctx context.Context, dbc *sqlx.DB, querier db.Querier, userID, viewID string) error {
+	view, err := querier.GetSavedView(ctx, dbc, viewID)
+	if err != nil {
+		return err
+	}
+	if view.OwnerID != userID {
+		return fmt.Errorf("%w: only the owner can delete a view", ErrInvalidCommand)
+	}
+
+	return querier.DeleteSavedView(ctx, dbc, viewID)
+}
+
+// SetDefaultSavedView makes a view the one the user opens its resource's
+// list with.
+func SetDefaultSavedView(ctx context.Context, dbc *sqlx.DB, querier db.Querier, userID, viewID string) error {
+	view, err := querier.GetSavedView(ctx, dbc, viewID)
+	if err != nil {
+		return err
+	}
+	if ok, err := CanSeeSavedView(ctx, dbc, querier, view, userID); err != nil {
+		return err
+	} else if !ok {
+		return sql.ErrNoRows
+	}
+
+	return querier.SetListPreference(ctx, dbc, db.ListPreference{
+		UserID:   userID,
+		Resource: view.Resource,
+		ViewID:   view.ID,
+	})
+}
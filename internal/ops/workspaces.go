@@ -0,0 +1,84 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/money"
+	"simplecrm/internal/pubsub"
+)
+
+type CreateWorkspaceParams struct {
+	Name string
+	// BaseCurrency is the currency reports convert amounts into, USD by
+	// default
+	BaseCurrency string
+	// The first user of the workspace, through whom its other records are
+	// created
+	FirstName string
+	LastName  string
+	Email     string
+}
+
+// CreateWorkspace creates a workspace along with its first user.
+func CreateWorkspace(
+	ctx context.Context,
+	dbc *sqlx.DB,
+	querier db.Querier,
+	params CreateWorkspaceParams,
+	userCreatedEventService pubsub.UserCreatedEventServicer,
+) (workspace db.Workspace, user db.User, err error) {
+	if params.BaseCurrency == "" {
+		params.BaseCurrency = "USD"
+	}
+	if !money.ValidCurrency(params.BaseCurrency) {
+		return db.Workspace{}, db.User{}, &FieldError{
+			Field: "base_currency",
+			Err:   fmt.Errorf("%w: %q is not an ISO 4217 currency code", ErrInvalidCommand, params.BaseCurrency),
+		}
+	}
+
+	tx, err := dbc.Beginx()
+	if err != nil {
+		return db.Workspace{}, db.User{}, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	workspace, err = querier.InsertWorkspace(ctx, tx, db.InsertWorkspaceParams{
+		ID:           uuid.New().String(),
+		Name:         params.Name,
+		BaseCurrency: params.BaseCurrency,
+	})
+	if err != nil {
+		return db.Workspace{}, db.User{}, err
+	}
+
+	ctx = db.WithWorkspace(ctx, workspace.ID)
+	user, err = querier.InsertAndReturnUser(ctx, tx, db.InsertAndReturnUserParams{
+		ID:        uuid.New().String(),
+		FirstName: params.FirstName,
+		LastName:  params.LastName,
+		Email:     params.Email,
+	})
+	if err != nil {
+		return db.Workspace{}, db.User{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return db.Workspace{}, db.User{}, err
+	}
+
+	if err := userCreatedEventService.Publish(ctx, user); err != nil {
+		return db.Workspace{}, db.User{}, err
+	}
+
+	return workspace, user, nil
+}
@@ -0,0 +1,221 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"simplecrm/internal/db"
+)
+
+const (
+	EventLeadCreated    = "lead.created"
+	EventContactCreated = "contact.created"
+	EventEntityMerged   = "entity.merged"
+	EventEntityAssigned = "entity.assigned"
+	EventEntityScored   = "entity.scored"
+	EventActivityLogged = "activity.logged"
+
+	EventEntityStatusChanged = "entity.status_changed"
+	EventTaskCreated         = "task.created"
+	EventTaskCompleted       = "task.completed"
+	EventTaskDueSoon         = "task.due_soon"
+	EventTaskOverdue         = "task.overdue"
+
+	EventScoringRulesChanged = "scoring_rules.changed"
+
+	EventUserActivated   = "user.activated"
+	EventUserDeactivated = "user.deactivated"
+	EventUserMentioned   = "user.mentioned"
+
+	EventWorkflowNotification = "workflow.notification"
+
+	EventTeamCreated        = "team.created"
+	EventTeamDeleted        = "team.deleted"
+	EventTeamMemberAdded    = "team.member_added"
+	EventTeamMemberRemoved  = "team.member_removed"
+	EventEntityTeamAssigned = "entity.team_assigned"
+	EventTaskTeamAssigned   = "task.team_assigned"
+)
+
+// Event is a CRM domain event. Payload holds the JSON-friendly fields of the
+// record the event is about.
+type Event struct {
+	ID         string
+	Type       string
+	OccurredAt time.Time
+	Payload    map[string]any
+	// Depth counts how many events led to this one, so that consumers
+	// reacting to events with more events can stop runaway chains.
+	Depth int
+	// WorkspaceID is the workspace the event happened in, taken from the
+	// context it was published with. Consumers act in the same workspace.
+	WorkspaceID string
+}
+
+type depthKey struct{}
+
+// WithDepth marks events published with the returned context as caused by
+// an event of the given depth.
+func WithDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, depthKey{}, depth)
+}
+
+func depthFrom(ctx context.Context) int {
+	depth, _ := ctx.Value(depthKey{}).(int)
+	return depth
+}
+
+// EventServicer fans every published event out to all consumers.
+type EventServicer interface {
+	Consume(ctx context.Context, f func(Event))
+	Subscribe(ctx context.Context, f func(Event))
+	Publish(ctx context.Context, event Event) error
+}
+
+type eventService struct {
+	mu          sync.RWMutex
+	subscribers []chan Event
+}
+
+func NewEventService() EventServicer {
+	return &eventService{}
+}
+
+func NewEvent(eventType string, payload map[string]any) Event {
+	return Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Payload:    payload,
+	}
+}
+
+// Consume blocks, calling f for every event published after it was called,
+// until ctx is cancelled.
+func (s *eventService) Consume(ctx context.Context, f func(Event)) {
+	s.consume(ctx, s.subscribe(), f)
+}
+
+// Subscribe is Consume in the background: it returns once subscribed, so
+// that f is called for every event published after it returns.
+func (s *eventService) Subscribe(ctx context.Context, f func(Event)) {
+	go s.consume(ctx, s.subscribe(), f)
+}
+
+func (s *eventService) subscribe() chan Event {
+	ch := make(chan Event, 100)
+
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, ch)
+	s.mu.Unlock()
+
+	return ch
+}
+
+func (s *eventService) consume(ctx context.Context, ch chan Event, f func(Event)) {
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, sub := range s.subscribers {
+			if sub == ch {
+				s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+				break
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-ch:
+			f(event)
+		}
+	}
+}
+
+// Publish delivers event to every consumer, waiting up to a second for each
+// one that is behind. A consumer that misses the event does not keep the
+// others from getting it; the failures are logged and returned together.
+func (s *eventService) Publish(ctx context.Context, event Event) error {
+	event.Depth = max(event.Depth, depthFrom(ctx))
+	if event.WorkspaceID == "" {
+		event.WorkspaceID, _ = db.WorkspaceFrom(ctx)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for i, ch := range s.subscribers {
+		select {
+		case ch <- event:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("consumer %d: %w", i, ctx.Err()))
+		case <-time.After(time.Second):
+			errs = append(errs, fmt.Errorf("consumer %d: timeout publishing %s", i, event.Type))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		slog.Error("Failed to deliver event", "type", event.Type, "id", event.ID, "error", err)
+		return err
+	}
+
+	slog.Debug("Event published", "type", event.Type, "id", event.ID)
+	return nil
+}
+
+func EntityPayload(entity db.Entity) map[string]any {
+	return map[string]any{
+		"id":           entity.ID,
+		"first_name":   entity.FirstName,
+		"last_name":    entity.LastName,
+		"email":        entity.Email,
+		"phone":        entity.Phone,
+		"status":       entity.Status,
+		"assigned_to":  entity.AssignedTo.String,
+		"created_at":   entity.CreatedAt,
+		"converted_at": entity.ConvertedAt,
+		"source":       entity.Source,
+		"region":       entity.Region,
+		"score":        entity.Score,
+		"utm_source":   entity.UTMSource,
+		"utm_medium":   entity.UTMMedium,
+		"utm_campaign": entity.UTMCampaign,
+		"utm_term":     entity.UTMTerm,
+		"utm_content":  entity.UTMContent,
+		"team_id":      entity.TeamID.String,
+	}
+}
+
+func TaskPayload(task db.Task) map[string]any {
+	return map[string]any{
+		"id":          task.ID,
+		"name":        task.Name,
+		"description": task.Description,
+		"due_date":    task.DueDate,
+		"assigned_to": task.AssignedTo.String,
+		"status":      task.Status,
+		"entity_id":   task.EntityID.String,
+		"recurrence":  task.Recurrence,
+		"series_id":   task.SeriesID,
+		"occurrence":  task.Occurrence,
+		"team_id":     task.TeamID.String,
+	}
+}
+
+func TeamPayload(team db.Team) map[string]any {
+	return map[string]any{
+		"id":         team.ID,
+		"name":       team.Name,
+		"parent_id":  team.ParentID.String,
+		"created_at": team.CreatedAt,
+	}
+}
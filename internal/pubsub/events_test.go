@@ -0,0 +1,35 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish_DeliversPastStalledConsumer(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := NewEventService().(*eventService)
+
+	// The first consumer never gets past its first event
+	stalled := make(chan struct{})
+	defer close(stalled)
+	service.Subscribe(ctx, func(Event) { <-stalled })
+	received := make(chan Event, 200)
+	service.Subscribe(ctx, func(e Event) { received <- e })
+
+	// Test
+	// One event is taken by the stalled consumer and its buffer holds 100
+	// more, so the last one cannot be delivered to it
+	var err error
+	for range 102 {
+		err = service.Publish(ctx, NewEvent(EventLeadCreated, nil))
+	}
+	a.ErrorContains(err, "timeout publishing")
+
+	a.Eventually(func() bool { return len(received) == 102 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many calls to Allow pass between sweeps of keys whose
+// hits have all expired.
+const sweepEvery = 1000
+
+// Limiter allows each key at most limit hits within any window. It keeps the
+// time of every recent hit, so it suits low limits such as form submissions
+// per client.
+type Limiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	hits  map[string][]time.Time
+	calls int
+}
+
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+		hits:   map[string][]time.Time{},
+	}
+}
+
+// Allow records a hit for key and reports whether it is within the limit. If
+// it is not, it also returns how long until the next hit would be allowed.
+// Rejected hits are not recorded.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	cutoff := now.Add(-l.window)
+
+	l.calls++
+	if l.calls%sweepEvery == 0 {
+		for k, hits := range l.hits {
+			if len(hits) == 0 || !hits[len(hits)-1].After(cutoff) {
+				delete(l.hits, k)
+			}
+		}
+	}
+
+	hits := l.hits[key]
+	n := 0
+	for n < len(hits) && !hits[n].After(cutoff) {
+		n++
+	}
+	hits = hits[n:]
+
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return false, hits[0].Sub(cutoff)
+	}
+
+	l.hits[key] = append(hits, now)
+	return true, 0
+}
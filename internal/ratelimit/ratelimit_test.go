@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllow(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	limiter := New(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	// Test
+	ok, _ := limiter.Allow("a")
+	a.True(ok)
+	now = now.Add(20 * time.Second)
+	ok, _ = limiter.Allow("a")
+	a.True(ok)
+
+	ok, retryAfter := limiter.Allow("a")
+	a.False(ok)
+	a.Equal(40*time.Second, retryAfter)
+
+	// Keys are limited independently
+	ok, _ = limiter.Allow("b")
+	a.True(ok)
+
+	// The first hit falls out of the window
+	now = now.Add(41 * time.Second)
+	ok, _ = limiter.Allow("a")
+	a.True(ok)
+	ok, retryAfter = limiter.Allow("a")
+	a.False(ok)
+	a.Equal(19*time.Second, retryAfter)
+}
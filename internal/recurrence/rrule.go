@@ -0,0 +1,355 @@
+// Package recurrence implements the subset of RFC 5545 recurrence rules used
+// by recurring tasks: FREQ of DAILY, WEEKLY or MONTHLY with INTERVAL, BYDAY,
+// UNTIL and COUNT. Weeks start on Monday.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// maxPeriods bounds how many periods are scanned for the next occurrence, so
+// that rules which can never produce one do not loop forever.
+const maxPeriods = 1000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Weekday is a BYDAY entry. Ordinal picks the nth such weekday of the month,
+// counting from the end when negative. It is zero for every such weekday and
+// is only allowed with MONTHLY.
+type Weekday struct {
+	Ordinal int
+	Day     time.Weekday
+}
+
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []Weekday
+	// Until is the last time an occurrence may fall on, zero for no limit
+	Until time.Time
+	// Count is the total number of occurrences, zero for no limit
+	Count int
+}
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Parse reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10".
+// A leading "RRULE:" is accepted.
+func Parse(s string) (Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	rule := Rule{Interval: 1}
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("%w: %q is not NAME=VALUE", ErrInvalidRule, part)
+		}
+
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+			if rule.Freq != FreqDaily && rule.Freq != FreqWeekly && rule.Freq != FreqMonthly {
+				return Rule{}, fmt.Errorf("%w: unsupported FREQ %q", ErrInvalidRule, value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Rule{}, fmt.Errorf("%w: invalid INTERVAL %q", ErrInvalidRule, value)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Rule{}, fmt.Errorf("%w: invalid COUNT %q", ErrInvalidRule, value)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return Rule{}, fmt.Errorf("%w: invalid UNTIL %q", ErrInvalidRule, value)
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, err := parseWeekday(strings.ToUpper(strings.TrimSpace(day)))
+				if err != nil {
+					return Rule{}, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return Rule{}, fmt.Errorf("%w: only WKST=MO is supported", ErrInvalidRule)
+			}
+		default:
+			return Rule{}, fmt.Errorf("%w: unsupported part %q", ErrInvalidRule, name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return Rule{}, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return Rule{}, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+	for _, wd := range rule.ByDay {
+		if wd.Ordinal != 0 && rule.Freq != FreqMonthly {
+			return Rule{}, fmt.Errorf("%w: numbered BYDAY requires FREQ=MONTHLY", ErrInvalidRule)
+		}
+	}
+
+	return rule, nil
+}
+
+func parseWeekday(s string) (Weekday, error) {
+	if len(s) < 2 {
+		return Weekday{}, fmt.Errorf("%w: invalid BYDAY %q", ErrInvalidRule, s)
+	}
+
+	day, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return Weekday{}, fmt.Errorf("%w: invalid BYDAY %q", ErrInvalidRule, s)
+	}
+
+	wd := Weekday{Day: day}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return Weekday{}, fmt.Errorf("%w: invalid BYDAY %q", ErrInvalidRule, s)
+		}
+		wd.Ordinal = n
+	}
+	return wd, nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			if layout == "20060102" {
+				// A date includes the whole day
+				t = t.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognized format")
+}
+
+// String formats the rule in canonical form.
+func (r Rule) String() string {
+	return r.format("20060102T150405Z")
+}
+
+// DateString formats the rule for a series starting on a date rather than at
+// a time, whose UNTIL must be a date as well (RFC 5545 3.3.10).
+func (r Rule) DateString() string {
+	return r.format("20060102")
+}
+
+func (r Rule) format(untilLayout string) string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, wd.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (wd Weekday) String() string {
+	for name, day := range weekdays {
+		if day == wd.Day {
+			if wd.Ordinal != 0 {
+				return strconv.Itoa(wd.Ordinal) + name
+			}
+			return name
+		}
+	}
+	return ""
+}
+
+// Next returns the first occurrence after anchor, itself an occurrence of
+// the rule. It reports false once the rule has ended. COUNT is not applied
+// here since only the caller knows how many occurrences came before.
+func (r Rule) Next(anchor time.Time) (time.Time, bool) {
+	for period := 0; period < maxPeriods; period++ {
+		for _, candidate := range r.candidates(anchor, period) {
+			if !candidate.After(anchor) {
+				continue
+			}
+			if !r.Until.IsZero() && candidate.After(r.Until) {
+				return time.Time{}, false
+			}
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Occurrence is an occurrence of a rule and its position after the anchor
+// it was counted from, 1 for the first.
+type Occurrence struct {
+	Time time.Time
+	N    int
+}
+
+// Between returns the occurrences after anchor that fall within [from, to),
+// at most max of them. remaining limits how many occurrences the series has
+// left, zero for no limit. Those skipped before from still count towards
+// the positions of the rest.
+func (r Rule) Between(anchor, from, to time.Time, remaining, max int) []Occurrence {
+	var occurrences []Occurrence
+	current := anchor
+	for n := 0; len(occurrences) < max; n++ {
+		if remaining > 0 && n >= remaining {
+			break
+		}
+		next, ok := r.Next(current)
+		if !ok || !next.Before(to) {
+			break
+		}
+		if !next.Before(from) {
+			occurrences = append(occurrences, Occurrence{Time: next, N: n + 1})
+		}
+		current = next
+	}
+	return occurrences
+}
+
+// candidates returns the sorted occurrence times within the nth period
+// counted from the one containing anchor.
+func (r Rule) candidates(anchor time.Time, period int) []time.Time {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, anchor.Hour(), anchor.Minute(), anchor.Second(), 0, anchor.Location())
+	}
+	step := period * r.Interval
+
+	switch r.Freq {
+	case FreqDaily:
+		day := at(anchor.Year(), anchor.Month(), anchor.Day()+step)
+		if len(r.ByDay) > 0 && !r.hasWeekday(day.Weekday()) {
+			return nil
+		}
+		return []time.Time{day}
+
+	case FreqWeekly:
+		// Monday of the anchor's week
+		offset := (int(anchor.Weekday()) + 6) % 7
+		monday := at(anchor.Year(), anchor.Month(), anchor.Day()-offset+7*step)
+		if len(r.ByDay) == 0 {
+			return []time.Time{monday.AddDate(0, 0, offset)}
+		}
+		var days []time.Time
+		for _, wd := range r.ByDay {
+			days = append(days, monday.AddDate(0, 0, (int(wd.Day)+6)%7))
+		}
+		sortTimes(days)
+		return days
+
+	case FreqMonthly:
+		first := at(anchor.Year(), anchor.Month()+time.Month(step), 1)
+		daysInMonth := first.AddDate(0, 1, -1).Day()
+		if len(r.ByDay) == 0 {
+			// Months without the anchor's day are skipped, as RFC 5545 does
+			if anchor.Day() > daysInMonth {
+				return nil
+			}
+			return []time.Time{first.AddDate(0, 0, anchor.Day()-1)}
+		}
+
+		var days []time.Time
+		for _, wd := range r.ByDay {
+			var matching []time.Time
+			for d := 1; d <= daysInMonth; d++ {
+				if day := first.AddDate(0, 0, d-1); day.Weekday() == wd.Day {
+					matching = append(matching, day)
+				}
+			}
+			switch {
+			case wd.Ordinal == 0:
+				days = append(days, matching...)
+			case wd.Ordinal > 0 && wd.Ordinal <= len(matching):
+				days = append(days, matching[wd.Ordinal-1])
+			case wd.Ordinal < 0 && -wd.Ordinal <= len(matching):
+				days = append(days, matching[len(matching)+wd.Ordinal])
+			}
+		}
+		sortTimes(days)
+		return days
+	}
+
+	return nil
+}
+
+func (r Rule) hasWeekday(day time.Weekday) bool {
+	for _, wd := range r.ByDay {
+		if wd.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+func sortTimes(times []time.Time) {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+}
+
+// ParseTime reads a due date stored either as a timestamp in the database
+// format or as a plain date, returning the layout it was written in so that
+// later occurrences are written the same way.
+func ParseTime(s string) (time.Time, string, error) {
+	for _, layout := range []string{time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, layout, nil
+		}
+	}
+	return time.Time{}, "", fmt.Errorf("invalid date %q", s)
+}
+
+// DueTime returns the time a task with the given due date is due by. Tasks
+// due at a time are due then; tasks due on a plain date are due by the end
+// of that day, when the next one starts. Reminders, calendar exports and
+// reports all go by it, so they agree on when a task is overdue.
+func DueTime(dueDate string) (time.Time, error) {
+	t, layout, err := ParseTime(dueDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if layout == time.DateOnly {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
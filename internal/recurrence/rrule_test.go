@@ -0,0 +1,144 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	tcs := []struct {
+		name     string
+		rule     string
+		anchor   string
+		expected []string
+	}{
+		{
+			name:     "Daily every other day",
+			rule:     "FREQ=DAILY;INTERVAL=2",
+			anchor:   "2026-01-01 09:00:00",
+			expected: []string{"2026-01-03 09:00:00", "2026-01-05 09:00:00", "2026-01-07 09:00:00"},
+		},
+		{
+			name:     "Fortnightly on Monday and Thursday",
+			rule:     "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+			anchor:   "2026-01-05 10:30:00",
+			expected: []string{"2026-01-08 10:30:00", "2026-01-19 10:30:00", "2026-01-22 10:30:00"},
+		},
+		{
+			name:     "Monthly skips short months",
+			rule:     "FREQ=MONTHLY",
+			anchor:   "2026-01-31 00:00:00",
+			expected: []string{"2026-03-31 00:00:00", "2026-05-31 00:00:00", "2026-07-31 00:00:00"},
+		},
+		{
+			name:     "Second Tuesday of the month",
+			rule:     "FREQ=MONTHLY;BYDAY=2TU",
+			anchor:   "2026-01-13 00:00:00",
+			expected: []string{"2026-02-10 00:00:00", "2026-03-10 00:00:00", "2026-04-14 00:00:00"},
+		},
+		{
+			name:     "Last Friday of the month",
+			rule:     "FREQ=MONTHLY;BYDAY=-1FR",
+			anchor:   "2026-01-30 00:00:00",
+			expected: []string{"2026-02-27 00:00:00", "2026-03-27 00:00:00", "2026-04-24 00:00:00"},
+		},
+		{
+			name:     "Until ends the series",
+			rule:     "FREQ=DAILY;UNTIL=20260103",
+			anchor:   "2026-01-01 09:00:00",
+			expected: []string{"2026-01-02 09:00:00", "2026-01-03 09:00:00"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := require.New(t)
+
+			rule, err := Parse(tc.rule)
+			a.NoError(err)
+			current, err := time.Parse(time.DateTime, tc.anchor)
+			a.NoError(err)
+
+			var got []string
+			for range 3 {
+				next, ok := rule.Next(current)
+				if !ok {
+					break
+				}
+				got = append(got, next.Format(time.DateTime))
+				current = next
+			}
+			a.Equal(tc.expected, got)
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=MO")
+	a.NoError(err)
+	anchor := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	from := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 15, 0, 0, 0, 0, time.UTC)
+
+	// Test
+	occurrences := rule.Between(anchor, from, to, 0, 10)
+	a.Len(occurrences, 4)
+	// 01-12 falls before from but is still the first occurrence
+	a.Equal(Occurrence{Time: time.Date(2026, 1, 19, 9, 0, 0, 0, time.UTC), N: 2}, occurrences[0])
+	a.Equal(Occurrence{Time: time.Date(2026, 2, 9, 9, 0, 0, 0, time.UTC), N: 5}, occurrences[3])
+
+	// Two occurrences remain, the first of which falls before from
+	a.Len(rule.Between(anchor, from, to, 2, 10), 1)
+	a.Len(rule.Between(anchor, from, to, 0, 2), 2)
+}
+
+func TestParse(t *testing.T) {
+	tcs := []struct {
+		name     string
+		rule     string
+		expected string
+	}{
+		{name: "Canonical", rule: "RRULE:freq=weekly;byday=mo,th;interval=1;count=10", expected: "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=10"},
+		{name: "Until", rule: "FREQ=DAILY;UNTIL=20261231T120000Z", expected: "FREQ=DAILY;UNTIL=20261231T120000Z"},
+		{name: "Missing FREQ", rule: "INTERVAL=2"},
+		{name: "Yearly", rule: "FREQ=YEARLY"},
+		{name: "Bad interval", rule: "FREQ=DAILY;INTERVAL=0"},
+		{name: "Count and until", rule: "FREQ=DAILY;COUNT=2;UNTIL=20261231"},
+		{name: "Numbered weekly BYDAY", rule: "FREQ=WEEKLY;BYDAY=2MO"},
+		{name: "Unknown weekday", rule: "FREQ=WEEKLY;BYDAY=XX"},
+		{name: "Unsupported part", rule: "FREQ=MONTHLY;BYMONTHDAY=1"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := require.New(t)
+
+			rule, err := Parse(tc.rule)
+			if tc.expected == "" {
+				a.ErrorIs(err, ErrInvalidRule)
+				return
+			}
+			a.NoError(err)
+			a.Equal(tc.expected, rule.String())
+		})
+	}
+}
+
+func TestDueTime(t *testing.T) {
+	a := require.New(t)
+
+	due, err := DueTime("2024-03-01 09:30:00")
+	a.NoError(err)
+	a.Equal(time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC), due)
+
+	due, err = DueTime("2024-03-01")
+	a.NoError(err)
+	a.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), due)
+
+	_, err = DueTime("next tuesday")
+	a.Error(err)
+}
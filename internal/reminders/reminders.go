@@ -0,0 +1,186 @@
+package reminders
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/mail"
+	"simplecrm/internal/pubsub"
+	"simplecrm/internal/recurrence"
+)
+
+const (
+	KindDueSoon = "due_soon"
+	KindOverdue = "overdue"
+)
+
+var reminderTemplate = mail.MustTemplate(
+	"task_reminder",
+	`Task {{if .Overdue}}overdue{{else}}due soon{{end}}: {{.Task.Name}}`,
+	`Hi {{.User.FirstName}},
+
+{{.Task.Name}} is {{if .Overdue}}overdue{{else}}due soon{{end}}.
+
+Due: {{.Task.DueDate}}
+{{with .Task.Description}}
+{{.}}
+{{end}}`,
+	`<p>Hi {{.User.FirstName}},</p>
+<p><strong>{{.Task.Name}}</strong> is {{if .Overdue}}overdue{{else}}due soon{{end}}.</p>
+<p>Due: {{.Task.DueDate}}</p>
+{{with .Task.Description}}<p>{{.}}</p>{{end}}`,
+)
+
+// Clock returns the current time. Tests substitute a fixed one.
+type Clock func() time.Time
+
+// Result counts the reminders sent by a tick.
+type Result struct {
+	DueSoon int
+	Overdue int
+}
+
+// Scheduler reminds assignees of tasks that are about to be or already are
+// past due. Each reminder is sent at most once per task and due date.
+type Scheduler struct {
+	dbc          *sqlx.DB
+	querier      db.Querier
+	eventService pubsub.EventServicer
+	mailer       mail.Sender
+	clock        Clock
+	window       time.Duration
+}
+
+// NewScheduler returns a scheduler sending due_soon reminders for tasks due
+// within window.
+func NewScheduler(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+	mailer mail.Sender,
+	clock Clock,
+	window time.Duration,
+) *Scheduler {
+	return &Scheduler{
+		dbc:          dbc,
+		querier:      querier,
+		eventService: eventService,
+		mailer:       mailer,
+		clock:        clock,
+		window:       window,
+	}
+}
+
+// Run ticks in every workspace every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := db.EachWorkspace(ctx, s.dbc, s.querier, func(ctx context.Context) error {
+			_, err := s.Tick(ctx)
+			return err
+		})
+		if err != nil {
+			slog.Error("Failed to send task reminders", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick sends the reminders of the context's workspace that are due as of
+// now.
+func (s *Scheduler) Tick(ctx context.Context) (Result, error) {
+	now := s.clock().UTC()
+	before := now.Add(s.window).Format(db.TimeFormat)
+
+	tasks, err := s.querier.ListTasksPendingReminder(ctx, s.dbc, before)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	for _, task := range tasks {
+		due, err := recurrence.DueTime(task.DueDate)
+		if err != nil {
+			slog.Warn("Skipping task with unparsable due date", "task", task.ID, "due_date", task.DueDate)
+			continue
+		}
+
+		kind := KindDueSoon
+		if !now.Before(due) {
+			kind = KindOverdue
+		} else if due.Sub(now) > s.window {
+			continue
+		}
+
+		sent, err := s.remind(ctx, task, kind, now)
+		if err != nil {
+			return result, err
+		}
+		if !sent {
+			continue
+		}
+		if kind == KindOverdue {
+			result.Overdue++
+		} else {
+			result.DueSoon++
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Scheduler) remind(ctx context.Context, task db.Task, kind string, now time.Time) (bool, error) {
+	// Claiming first means a crash between claiming and sending loses the
+	// reminder rather than sending it twice
+	claimed, err := s.querier.ClaimTaskReminder(ctx, s.dbc, task.ID, kind, task.DueDate, now.Format(db.TimeFormat))
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	eventType := pubsub.EventTaskDueSoon
+	if kind == KindOverdue {
+		eventType = pubsub.EventTaskOverdue
+	}
+	if err := s.eventService.Publish(ctx, pubsub.NewEvent(eventType, pubsub.TaskPayload(task))); err != nil {
+		slog.Error("Failed to publish event", "type", eventType, "task", task.ID, "error", err)
+	}
+
+	if !task.AssignedTo.Valid {
+		return true, nil
+	}
+
+	user, err := s.querier.GetUser(ctx, s.dbc, task.AssignedTo.String)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !user.Active) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	msg, err := reminderTemplate.Render([]string{user.Email}, map[string]any{
+		"Task":    task,
+		"User":    user,
+		"Overdue": kind == KindOverdue,
+	})
+	if err == nil {
+		err = s.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		slog.Error("Failed to send task reminder", "task", task.ID, "user", user.ID, "error", err)
+	}
+
+	return true, nil
+}
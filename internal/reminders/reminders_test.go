@@ -0,0 +1,95 @@
+package reminders
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/mail"
+	"simplecrm/internal/pubsub"
+)
+
+type recordingSender struct {
+	sent []mail.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg mail.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestTick(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	defer dbc.Close()
+	a.NoError(database.Migrate(ctx, dbc))
+
+	_, err = dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email) VALUES ('u1', 'Ann', 'Lee', 'ann@example.com');
+	INSERT INTO tasks (id, name, description, due_date, assigned_to, status) VALUES
+	('overdue', 'Send contract', '', '2024-03-01 09:00:00', 'u1', 'open'),
+	('soon', 'Call Jane', '', '2024-03-02 08:00:00', 'u1', 'open'),
+	('today', 'Renewal', '', '2024-03-01', NULL, 'open'),
+	('later', 'Quarterly review', '', '2024-04-01 09:00:00', 'u1', 'open'),
+	('done', 'Old task', '', '2024-02-01 09:00:00', 'u1', 'done');
+	`)
+	a.NoError(err)
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	eventService := pubsub.NewEventService()
+	events := make(chan pubsub.Event, 10)
+	consumeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eventService.Subscribe(consumeCtx, func(e pubsub.Event) { events <- e })
+
+	sender := &recordingSender{}
+	scheduler := NewScheduler(dbc, db.NewQueries(), eventService, sender, clock, 24*time.Hour)
+
+	// Test
+	result, err := scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Equal(Result{DueSoon: 2, Overdue: 1}, result)
+	a.Len(sender.sent, 2)
+	a.Equal("Task overdue: Send contract", sender.sent[0].Subject)
+	a.Equal([]string{"ann@example.com"}, sender.sent[0].To)
+
+	types := map[string]string{}
+	for range 3 {
+		select {
+		case e := <-events:
+			types[e.Payload["id"].(string)] = e.Type
+		case <-time.After(time.Second):
+			a.FailNow("missing event")
+		}
+	}
+	a.Equal(map[string]string{
+		"overdue": pubsub.EventTaskOverdue,
+		"soon":    pubsub.EventTaskDueSoon,
+		"today":   pubsub.EventTaskDueSoon,
+	}, types)
+
+	// A restarted scheduler does not send the same reminders again
+	scheduler = NewScheduler(dbc, db.NewQueries(), eventService, sender, clock, 24*time.Hour)
+	result, err = scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Equal(Result{}, result)
+
+	// Once past due, tasks get their overdue reminder
+	now = now.Add(24 * time.Hour)
+	result, err = scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Equal(Result{Overdue: 2}, result)
+	a.Len(sender.sent, 3)
+}
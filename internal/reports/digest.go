@@ -0,0 +1,262 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/mail"
+)
+
+var digestTemplate = mail.MustTemplate(
+	"report_digest",
+	`{{.Name}}: {{.From}} to {{.To}}`,
+	`{{.Name}}, {{.From}} to {{.To}}
+{{range .Sections}}
+{{.Title}}
+{{range .Rows}}  {{.Label}}: {{.Value}}{{with .Previous}} (previously {{.}}){{end}}
+{{end}}{{end}}`,
+	`<h2>{{.Name}}</h2>
+<p>{{.From}} to {{.To}}</p>
+{{range .Sections}}<h3>{{.Title}}</h3>
+<table>
+{{range .Rows}}<tr><td>{{.Label}}</td><td>{{.Value}}</td><td>{{with .Previous}}previously {{.}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}`,
+)
+
+// digestTimeLayout formats the range of a digest, in the report's timezone.
+const digestTimeLayout = "2006-01-02 15:04 MST"
+
+type digestRow struct {
+	Label string
+	Value string
+	// Previous is the value in the previous snapshot, for totals
+	Previous string
+}
+
+type digestSection struct {
+	Title string
+	Rows  []digestRow
+}
+
+// sendDigest emails the recipients of a report a summary of result: the
+// totals of each metric, compared with the previous snapshot if any, and
+// the rows they add up from.
+func (s *Scheduler) sendDigest(
+	ctx context.Context,
+	report db.ScheduledReport,
+	from, to time.Time,
+	result Result,
+	previous *Result,
+) error {
+	var recipients, metrics []string
+	if err := json.Unmarshal([]byte(report.Recipients), &recipients); err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(report.Metrics), &metrics); err != nil {
+		return err
+	}
+	q, err := url.ParseQuery(report.Filter)
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(report.Timezone)
+	if err != nil {
+		return err
+	}
+
+	// Metrics missing from the previous snapshot have nothing to compare with
+	if previous == nil {
+		previous = &Result{}
+	}
+
+	var sections []digestSection
+	for _, metric := range Metrics {
+		if !slices.Contains(metrics, metric) {
+			continue
+		}
+		filter, err := Filter(metric, q)
+		if err != nil {
+			return err
+		}
+		label := s.labeler(ctx, filter.GroupBy)
+
+		switch metric {
+		case MetricLeads:
+			sections = append(sections, leadSection(result.Leads, previous.Leads, label))
+		case MetricConversion:
+			sections = append(sections, conversionSection(result.Conversion, previous.Conversion, label))
+		case MetricTasks:
+			sections = append(sections, taskSection(result.Tasks, previous.Tasks, label))
+		}
+	}
+
+	msg, err := digestTemplate.Render(recipients, map[string]any{
+		"Name":     report.Name,
+		"From":     from.In(loc).Format(digestTimeLayout),
+		"To":       to.In(loc).Format(digestTimeLayout),
+		"Sections": sections,
+	})
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(ctx, msg)
+}
+
+// labeler returns the label of a report row, naming the users and teams it
+// is grouped by rather than giving their ids.
+func (s *Scheduler) labeler(ctx context.Context, groupBy string) func(period, group string) string {
+	names := map[string]string{}
+	name := func(group string) string {
+		if group == "" {
+			if groupBy == "assigned_to" || groupBy == "team_id" {
+				return "Unassigned"
+			}
+			return "None"
+		}
+		if n, ok := names[group]; ok {
+			return n
+		}
+
+		n := group
+		switch groupBy {
+		case "assigned_to":
+			if user, err := s.querier.GetUser(ctx, s.dbc, group); err == nil {
+				n = strings.TrimSpace(user.FirstName + " " + user.LastName)
+			}
+		case "team_id":
+			if team, err := s.querier.GetTeam(ctx, s.dbc, group); err == nil {
+				n = team.Name
+			}
+		}
+		names[group] = n
+		return n
+	}
+
+	return func(period, group string) string {
+		var parts []string
+		if period != "" {
+			parts = append(parts, period)
+		}
+		if groupBy != "none" {
+			parts = append(parts, name(group))
+		}
+		return strings.Join(parts, " ")
+	}
+}
+
+func leadSection(rows, previous []LeadRow, label func(period, group string) string) digestSection {
+	total := func(rows []LeadRow) int {
+		n := 0
+		for _, row := range rows {
+			n += row.Count
+		}
+		return n
+	}
+
+	section := digestSection{
+		Title: "Leads created",
+		Rows:  []digestRow{{Label: "Total", Value: fmt.Sprint(total(rows))}},
+	}
+	if previous != nil {
+		section.Rows[0].Previous = fmt.Sprint(total(previous))
+	}
+	for _, row := range rows {
+		if l := label(row.Period, row.Group); l != "" {
+			section.Rows = append(section.Rows, digestRow{Label: l, Value: fmt.Sprint(row.Count)})
+		}
+	}
+	return section
+}
+
+func conversionSection(rows, previous []ConversionRow, label func(period, group string) string) digestSection {
+	total := func(rows []ConversionRow) string {
+		created, converted := 0, 0
+		for _, row := range rows {
+			created += row.Created
+			converted += row.Converted
+		}
+		return conversionSummary(converted, created)
+	}
+
+	section := digestSection{
+		Title: "Conversion",
+		Rows:  []digestRow{{Label: "Converted", Value: total(rows)}},
+	}
+	if previous != nil {
+		section.Rows[0].Previous = total(previous)
+	}
+	for _, row := range rows {
+		l := label(row.Period, row.Group)
+		if l == "" {
+			// The median of the whole range is only known without rows
+			if row.MedianDaysToConvert != nil {
+				section.Rows = append(section.Rows, digestRow{
+					Label: "Median time to convert",
+					Value: fmt.Sprintf("%.1f days", *row.MedianDaysToConvert),
+				})
+			}
+			continue
+		}
+
+		value := conversionSummary(row.Converted, row.Created)
+		if row.MedianDaysToConvert != nil {
+			value += fmt.Sprintf(", median %.1f days", *row.MedianDaysToConvert)
+		}
+		section.Rows = append(section.Rows, digestRow{Label: l, Value: value})
+	}
+	return section
+}
+
+// conversionSummary describes how many of the leads created converted.
+func conversionSummary(converted, created int) string {
+	if created == 0 {
+		return "0 of 0"
+	}
+	return fmt.Sprintf("%d of %d (%.0f%%)", converted, created, 100*float64(converted)/float64(created))
+}
+
+func taskSection(rows, previous []TaskRow, label func(period, group string) string) digestSection {
+	totals := func(rows []TaskRow) (completed, open, overdue int) {
+		for _, row := range rows {
+			completed += row.Completed
+			open += row.Open
+			overdue += row.Overdue
+		}
+		return completed, open, overdue
+	}
+
+	completed, open, overdue := totals(rows)
+	section := digestSection{
+		Title: "Tasks",
+		Rows: []digestRow{
+			{Label: "Completed", Value: fmt.Sprint(completed)},
+			{Label: "Open", Value: fmt.Sprint(open)},
+			{Label: "Overdue", Value: fmt.Sprint(overdue)},
+		},
+	}
+	if previous != nil {
+		completed, open, overdue := totals(previous)
+		section.Rows[0].Previous = fmt.Sprint(completed)
+		section.Rows[1].Previous = fmt.Sprint(open)
+		section.Rows[2].Previous = fmt.Sprint(overdue)
+	}
+	for _, row := range rows {
+		if l := label(row.Period, row.Group); l != "" {
+			section.Rows = append(section.Rows, digestRow{
+				Label: l,
+				Value: fmt.Sprintf("%d completed, %d open, %d overdue", row.Completed, row.Open, row.Overdue),
+			})
+		}
+	}
+	return section
+}
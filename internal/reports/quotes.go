@@ -0,0 +1,108 @@
+package reports
+
+import (
+	"context"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/money"
+)
+
+// LoadRates returns the conversions into the base currency of the
+// context's workspace that its exchange rates allow.
+func LoadRates(ctx context.Context, dbc db.DBExecutor, querier db.Querier) (*money.Rates, error) {
+	workspaceID, ok := db.WorkspaceFrom(ctx)
+	if !ok {
+		return nil, db.ErrNoWorkspace
+	}
+	workspace, err := querier.GetWorkspace(ctx, dbc, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := querier.ListExchangeRates(ctx, dbc, db.ExchangeRateFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make([]money.Rate, 0, len(stored))
+	for _, rate := range stored {
+		r, err := money.ParseRate(rate.Rate)
+		if err != nil {
+			// Rates are validated when they are loaded
+			return nil, err
+		}
+		rates = append(rates, money.Rate{
+			From:        rate.FromCurrency,
+			To:          rate.ToCurrency,
+			EffectiveOn: rate.EffectiveOn,
+			Rate:        r,
+		})
+	}
+
+	return money.NewRates(workspace.BaseCurrency, rates), nil
+}
+
+type QuoteRow struct {
+	Period string `json:"period"`
+	Group  string `json:"group"`
+	// Currency is the workspace's base currency, which Value is in
+	Currency string `json:"currency"`
+	Count    int    `json:"count"`
+	// Value is the sum of the accepted quotes' totals in minor units of
+	// Currency
+	Value int64 `json:"value"`
+	// DeclinedValue is the same sum for the declined quotes, kept apart so
+	// that Value is only what was won
+	DeclinedValue int64 `json:"declined_value"`
+	// Unconverted counts the quotes left out of Value and DeclinedValue for
+	// lack of an exchange rate into Currency by the day they were decided
+	Unconverted int `json:"unconverted"`
+}
+
+// QuoteValues sums the value of the quotes accepted and, apart, of those
+// declined in each period and group of a report, converted into the
+// workspace's base currency at the rate in effect on the day each was
+// decided.
+func QuoteValues(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	filter db.ReportFilter,
+) ([]QuoteRow, error) {
+	rates, err := LoadRates(ctx, dbc, querier)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := querier.ListDecidedQuoteTotals(ctx, dbc, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Totals come ordered by period and group
+	rows := []QuoteRow{}
+	for _, total := range totals {
+		if len(rows) == 0 || rows[len(rows)-1].Period != total.Period || rows[len(rows)-1].Group != total.Group {
+			rows = append(rows, QuoteRow{
+				Period:   total.Period,
+				Group:    total.Group,
+				Currency: rates.Base(),
+			})
+		}
+		row := &rows[len(rows)-1]
+
+		row.Count++
+		value, ok := rates.Convert(total.Total, total.Currency, total.DecidedAt[:min(len(total.DecidedAt), 10)])
+		if !ok {
+			row.Unconverted++
+			continue
+		}
+		if total.Status == "declined" {
+			row.DeclinedValue += value
+		} else {
+			row.Value += value
+		}
+	}
+
+	return rows, nil
+}
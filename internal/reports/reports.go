@@ -0,0 +1,200 @@
+// Package reports runs scheduled reports, keeping the result of each run as
+// a snapshot and emailing a digest of it to the report's recipients.
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"simplecrm/internal/cron"
+	"simplecrm/internal/db"
+	"simplecrm/internal/filters"
+)
+
+const (
+	MetricLeads      = "leads"
+	MetricConversion = "conversion"
+	MetricTasks      = "tasks"
+)
+
+// Metrics lists the reports a scheduled report can run, in the order
+// digests show them.
+var Metrics = []string{MetricLeads, MetricConversion, MetricTasks}
+
+// Periods maps the ranges a scheduled report can cover to their length in
+// years, months and days.
+var Periods = map[string][3]int{
+	"day":   {0, 0, 1},
+	"week":  {0, 0, 7},
+	"month": {0, 1, 0},
+}
+
+// never is the next run of reports whose schedule does not run again.
+const never = "9999-12-31 23:59:59"
+
+// Filter reads the report filter of metric from a scheduled report's
+// filter. Unlike the live reports, scheduled ones default to covering their
+// range as a single period.
+func Filter(metric string, q url.Values) (db.ReportFilter, error) {
+	switch metric {
+	case MetricLeads:
+		return filters.Report(q, db.EntityReportGroups, "none", "status")
+	case MetricConversion:
+		return filters.Report(q, db.EntityReportGroups, "none", "none")
+	case MetricTasks:
+		return filters.Report(q, db.TaskReportGroups, "none", "assigned_to")
+	}
+	return db.ReportFilter{}, fmt.Errorf("unknown metric %q", metric)
+}
+
+// NextRun returns when a report on schedule in timezone first runs after t,
+// in UTC and db.TimeFormat. Schedules that do not run again are invalid.
+func NextRun(schedule, timezone string, t time.Time) (string, error) {
+	s, err := cron.Parse(schedule)
+	if err != nil {
+		return "", err
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return "", err
+	}
+
+	next, ok := s.Next(t.In(loc))
+	if !ok {
+		return "", fmt.Errorf("%w: %q never runs", cron.ErrInvalidSchedule, schedule)
+	}
+	return next.UTC().Format(db.TimeFormat), nil
+}
+
+// RangeFrom returns the start of the range a report covering period reports
+// on when run at to, in timezone so that months follow its calendar.
+func RangeFrom(period, timezone string, to time.Time) (time.Time, error) {
+	length, ok := Periods[period]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown period %q", period)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return to.In(loc).AddDate(-length[0], -length[1], -length[2]).UTC(), nil
+}
+
+type LeadRow struct {
+	Period string `json:"period"`
+	Group  string `json:"group"`
+	Count  int    `json:"count"`
+}
+
+type ConversionRow struct {
+	Period              string   `json:"period"`
+	Group               string   `json:"group"`
+	Created             int      `json:"created"`
+	Converted           int      `json:"converted"`
+	ConversionRate      float64  `json:"conversion_rate"`
+	MedianDaysToConvert *float64 `json:"median_days_to_convert"`
+}
+
+type TaskRow struct {
+	Period    string `json:"period"`
+	Group     string `json:"group"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+	Open      int    `json:"open"`
+	Overdue   int    `json:"overdue"`
+}
+
+// Result holds the rows of each metric a report ran, in the shape of the
+// live report endpoints. Metrics the report does not run are nil.
+type Result struct {
+	Leads      []LeadRow       `json:"leads"`
+	Conversion []ConversionRow `json:"conversion"`
+	Tasks      []TaskRow       `json:"tasks"`
+}
+
+// Compute runs the metrics of a scheduled report over the range [from, to),
+// counting overdue tasks as of now.
+func Compute(
+	ctx context.Context,
+	dbc db.DBExecutor,
+	querier db.Querier,
+	report db.ScheduledReport,
+	from, to, now time.Time,
+) (Result, error) {
+	var metrics []string
+	if err := json.Unmarshal([]byte(report.Metrics), &metrics); err != nil {
+		return Result{}, err
+	}
+	q, err := url.ParseQuery(report.Filter)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	for _, metric := range metrics {
+		filter, err := Filter(metric, q)
+		if err != nil {
+			return Result{}, err
+		}
+		filter.From = from.UTC().Format(db.TimeFormat)
+		filter.To = to.UTC().Format(db.TimeFormat)
+
+		switch metric {
+		case MetricLeads:
+			counts, err := querier.CountEntitiesCreated(ctx, dbc, filter)
+			if err != nil {
+				return Result{}, err
+			}
+			result.Leads = make([]LeadRow, 0, len(counts))
+			for _, count := range counts {
+				result.Leads = append(result.Leads, LeadRow{
+					Period: count.Period,
+					Group:  count.Group,
+					Count:  count.Count,
+				})
+			}
+		case MetricConversion:
+			stats, err := querier.GetConversionStats(ctx, dbc, filter)
+			if err != nil {
+				return Result{}, err
+			}
+			result.Conversion = make([]ConversionRow, 0, len(stats))
+			for _, s := range stats {
+				row := ConversionRow{
+					Period:    s.Period,
+					Group:     s.Group,
+					Created:   s.Created,
+					Converted: s.Converted,
+				}
+				if s.Created > 0 {
+					row.ConversionRate = float64(s.Converted) / float64(s.Created)
+				}
+				if s.MedianDaysToConvert.Valid {
+					row.MedianDaysToConvert = &s.MedianDaysToConvert.Float64
+				}
+				result.Conversion = append(result.Conversion, row)
+			}
+		case MetricTasks:
+			stats, err := querier.GetTaskStats(ctx, dbc, filter, now.UTC().Format(db.TimeFormat))
+			if err != nil {
+				return Result{}, err
+			}
+			result.Tasks = make([]TaskRow, 0, len(stats))
+			for _, s := range stats {
+				result.Tasks = append(result.Tasks, TaskRow{
+					Period:    s.Period,
+					Group:     s.Group,
+					Total:     s.Total,
+					Completed: s.Completed,
+					Open:      s.Open,
+					Overdue:   s.Overdue,
+				})
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/cron"
+	"simplecrm/internal/db"
+	"simplecrm/internal/mail"
+)
+
+// Clock returns the current time. Tests substitute a fixed one.
+type Clock func() time.Time
+
+// Scheduler runs scheduled reports when they are due.
+type Scheduler struct {
+	dbc     *sqlx.DB
+	querier db.Querier
+	mailer  mail.Sender
+	clock   Clock
+}
+
+func NewScheduler(dbc *sqlx.DB, querier db.Querier, mailer mail.Sender, clock Clock) *Scheduler {
+	return &Scheduler{
+		dbc:     dbc,
+		querier: querier,
+		mailer:  mailer,
+		clock:   clock,
+	}
+}
+
+// Run ticks in every workspace every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := db.EachWorkspace(ctx, s.dbc, s.querier, func(ctx context.Context) error {
+			_, err := s.Tick(ctx)
+			return err
+		})
+		if err != nil {
+			slog.Error("Failed to run scheduled reports", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick runs the reports of the context's workspace that are due as of now,
+// returning how many ran. Runs missed while the server was down are not
+// caught up on: a report runs once, on the range ending when it was due. A
+// report that fails is logged, retried on the next tick and does not keep
+// the others from running.
+func (s *Scheduler) Tick(ctx context.Context) (int, error) {
+	now := s.clock().UTC()
+
+	reports, err := s.querier.ListDueScheduledReports(ctx, s.dbc, now.Format(db.TimeFormat))
+	if err != nil {
+		return 0, err
+	}
+
+	ran := 0
+	for _, report := range reports {
+		ok, err := s.runDue(ctx, report, now)
+		if err != nil {
+			slog.Error("Failed to run scheduled report", "report", report.ID, "error", err)
+			continue
+		}
+		if ok {
+			ran++
+		}
+	}
+
+	return ran, nil
+}
+
+// runDue claims the due run of a report and runs it, reporting whether it
+// ran. It does not when another instance claimed the run first. Runs that
+// fail are given back to be retried.
+func (s *Scheduler) runDue(ctx context.Context, report db.ScheduledReport, now time.Time) (bool, error) {
+	due, err := time.Parse(db.TimeFormat, report.NextRunAt)
+	if err != nil {
+		return false, err
+	}
+	next, err := NextRun(report.Schedule, report.Timezone, now)
+	if errors.Is(err, cron.ErrInvalidSchedule) {
+		// The schedule has run for the last time
+		next = never
+	} else if err != nil {
+		return false, err
+	}
+
+	// Claiming first means a crash between claiming and running loses the
+	// run rather than running it twice
+	claimed, err := s.querier.ClaimScheduledReportRun(ctx, s.dbc, report.ID, report.NextRunAt, next)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	if _, err := s.RunReport(ctx, report, due); err != nil {
+		// Give the run back for the next tick to retry, unless the report
+		// was rescheduled meanwhile
+		if _, releaseErr := s.querier.ClaimScheduledReportRun(ctx, s.dbc, report.ID, next, report.NextRunAt); releaseErr != nil {
+			slog.Error("Failed to release scheduled report run", "report", report.ID, "error", releaseErr)
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RunReport runs a report on the range ending at to, stores the result as
+// a snapshot and emails the digest comparing it with the previous snapshot.
+// Failing to send the digest is logged rather than returned, as the snapshot
+// is already kept.
+func (s *Scheduler) RunReport(ctx context.Context, report db.ScheduledReport, to time.Time) (db.ReportSnapshot, error) {
+	now := s.clock().UTC()
+
+	from, err := RangeFrom(report.Period, report.Timezone, to)
+	if err != nil {
+		return db.ReportSnapshot{}, err
+	}
+
+	result, err := Compute(ctx, s.dbc, s.querier, report, from, to, now)
+	if err != nil {
+		return db.ReportSnapshot{}, err
+	}
+	content, err := json.Marshal(result)
+	if err != nil {
+		return db.ReportSnapshot{}, err
+	}
+
+	previous, err := s.querier.ListReportSnapshots(ctx, s.dbc, report.ID, 1, 0)
+	if err != nil {
+		return db.ReportSnapshot{}, err
+	}
+
+	snapshot, err := s.querier.InsertReportSnapshot(ctx, s.dbc, db.InsertReportSnapshotParams{
+		ID:        uuid.New().String(),
+		ReportID:  report.ID,
+		RangeFrom: from.Format(db.TimeFormat),
+		RangeTo:   to.UTC().Format(db.TimeFormat),
+		Result:    string(content),
+		RanAt:     now.Format(db.TimeFormat),
+	})
+	if err != nil {
+		return db.ReportSnapshot{}, err
+	}
+
+	var last *Result
+	if len(previous) > 0 {
+		last = &Result{}
+		if err := json.Unmarshal([]byte(previous[0].Result), last); err != nil {
+			slog.Warn("Ignoring unreadable report snapshot", "snapshot", previous[0].ID, "error", err)
+			last = nil
+		}
+	}
+
+	if err := s.sendDigest(ctx, report, from, to, result, last); err != nil {
+		slog.Error("Failed to send report digest", "report", report.ID, "snapshot", snapshot.ID, "error", err)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,213 @@
+package reports
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/mail"
+)
+
+type recordingSender struct {
+	sent []mail.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg mail.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestTick(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	defer dbc.Close()
+	a.NoError(database.Migrate(ctx, dbc))
+
+	_, err = dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email) VALUES ('u1', 'Ann', 'Lee', 'ann@example.com');
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, assigned_to, created_at, converted_at) VALUES
+	('e1', 'Jane', 'Doe', 'e1@example.com', '', 'new', 'u1', '2026-01-05 09:00:00', ''),
+	('e2', 'John', 'Doe', 'e2@example.com', '', 'new', 'u1', '2026-01-09 09:00:00', ''),
+	('e3', 'Joan', 'Doe', 'e3@example.com', '', 'new', NULL, '2026-01-11 09:00:00', ''),
+	('e4', 'Jim', 'Doe', 'e4@example.com', '', 'new', 'u1', '2026-01-01 09:00:00', '');
+	INSERT INTO tasks (id, name, description, due_date, assigned_to, status, completed_at) VALUES
+	('t1', 'Send contract', '', '2026-01-06', 'u1', 'done', '2026-01-08 16:00:00'),
+	('t2', 'Call Jane', '', '2026-01-07 10:00:00', 'u1', 'open', '');
+	INSERT INTO scheduled_reports (id, name, metrics, recipients, schedule, period, filter, next_run_at) VALUES
+	('r1', 'Weekly digest', '["leads","tasks"]', '["sales@example.com"]', '0 8 * * MON', 'week', 'group_by=assigned_to', '2026-01-12 08:00:00');
+	`)
+	a.NoError(err)
+
+	now := time.Date(2026, 1, 12, 8, 5, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	querier := db.NewQueries()
+	sender := &recordingSender{}
+	scheduler := NewScheduler(dbc, querier, sender, clock)
+
+	// Test
+	ran, err := scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Equal(1, ran)
+
+	report, err := querier.GetScheduledReport(ctx, dbc, "r1")
+	a.NoError(err)
+	a.Equal("2026-01-19 08:00:00", report.NextRunAt)
+
+	snapshots, err := querier.ListReportSnapshots(ctx, dbc, "r1", 0, 0)
+	a.NoError(err)
+	a.Len(snapshots, 1)
+	a.Equal("2026-01-05 08:00:00", snapshots[0].RangeFrom)
+	a.Equal("2026-01-12 08:00:00", snapshots[0].RangeTo)
+	var result Result
+	a.NoError(json.Unmarshal([]byte(snapshots[0].Result), &result))
+	a.Equal([]LeadRow{{Group: "", Count: 1}, {Group: "u1", Count: 2}}, result.Leads)
+	a.Equal([]TaskRow{{Group: "u1", Total: 2, Completed: 1, Open: 1, Overdue: 1}}, result.Tasks)
+	a.Nil(result.Conversion)
+
+	a.Len(sender.sent, 1)
+	a.Equal([]string{"sales@example.com"}, sender.sent[0].To)
+	a.Equal("Weekly digest: 2026-01-05 08:00 UTC to 2026-01-12 08:00 UTC", sender.sent[0].Subject)
+	a.Contains(sender.sent[0].Body, "  Total: 3\n")
+	a.Contains(sender.sent[0].Body, "  Ann Lee: 2\n")
+	a.Contains(sender.sent[0].Body, "  Unassigned: 1\n")
+	a.Contains(sender.sent[0].Body, "  Overdue: 1\n")
+	a.Contains(sender.sent[0].HTML, "<td>Ann Lee</td>")
+
+	// Reports run once per scheduled time
+	ran, err = scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Zero(ran)
+
+	// The next digest compares its totals with the previous run
+	_, err = dbc.Exec(`
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, assigned_to, created_at, converted_at) VALUES
+	('e5', 'Jill', 'Doe', 'e5@example.com', '', 'new', 'u1', '2026-01-14 09:00:00', '');
+	`)
+	a.NoError(err)
+	now = time.Date(2026, 1, 19, 8, 0, 0, 0, time.UTC)
+	ran, err = scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Equal(1, ran)
+	a.Len(sender.sent, 2)
+	a.Contains(sender.sent[1].Body, "  Total: 1 (previously 3)\n")
+	a.Contains(sender.sent[1].Body, "  Completed: 0 (previously 1)\n")
+}
+
+func TestRangeFrom(t *testing.T) {
+	a := require.New(t)
+
+	// Months follow the calendar of the report's timezone
+	to := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	from, err := RangeFrom("month", "Europe/Berlin", to)
+	a.NoError(err)
+	a.Equal(time.Date(2026, 2, 1, 7, 0, 0, 0, time.UTC), from)
+
+	// Weeks are seven days of the local calendar, across daylight saving
+	to = time.Date(2026, 3, 30, 6, 0, 0, 0, time.UTC)
+	from, err = RangeFrom("week", "Europe/Berlin", to)
+	a.NoError(err)
+	a.Equal(time.Date(2026, 3, 23, 7, 0, 0, 0, time.UTC), from)
+
+	_, err = RangeFrom("quarter", "UTC", to)
+	a.Error(err)
+}
+
+func TestTick_ContinuesAfterFailedReport(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	defer dbc.Close()
+	a.NoError(database.Migrate(ctx, dbc))
+
+	// r1 is due first, but its snapshot cannot be stored
+	_, err = dbc.Exec(`
+	INSERT INTO scheduled_reports (id, name, metrics, recipients, schedule, period, next_run_at) VALUES
+	('r1', 'Broken digest', '["leads"]', '[]', '0 8 * * MON', 'week', '2026-01-12 07:00:00'),
+	('r2', 'Weekly digest', '["leads"]', '[]', '0 8 * * MON', 'week', '2026-01-12 08:00:00');
+	CREATE TRIGGER reject_snapshot BEFORE INSERT ON report_snapshots
+	WHEN NEW.report_id = 'r1'
+	BEGIN SELECT RAISE(ABORT, 'rejected'); END;
+	`)
+	a.NoError(err)
+
+	now := time.Date(2026, 1, 12, 8, 5, 0, 0, time.UTC)
+	querier := db.NewQueries()
+	scheduler := NewScheduler(dbc, querier, &recordingSender{}, func() time.Time { return now })
+
+	// Test
+	ran, err := scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Equal(1, ran)
+
+	snapshots, err := querier.ListReportSnapshots(ctx, dbc, "r1", 0, 0)
+	a.NoError(err)
+	a.Empty(snapshots)
+	snapshots, err = querier.ListReportSnapshots(ctx, dbc, "r2", 0, 0)
+	a.NoError(err)
+	a.Len(snapshots, 1)
+}
+
+func TestTick_RetriesFailedReport(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	defer dbc.Close()
+	a.NoError(database.Migrate(ctx, dbc))
+
+	_, err = dbc.Exec(`
+	INSERT INTO scheduled_reports (id, name, metrics, recipients, schedule, period, next_run_at) VALUES
+	('r1', 'Weekly digest', '["leads"]', '[]', '0 8 * * MON', 'week', '2026-01-12 08:00:00');
+	CREATE TRIGGER reject_snapshot BEFORE INSERT ON report_snapshots
+	BEGIN SELECT RAISE(ABORT, 'rejected'); END;
+	`)
+	a.NoError(err)
+
+	now := time.Date(2026, 1, 12, 8, 5, 0, 0, time.UTC)
+	querier := db.NewQueries()
+	scheduler := NewScheduler(dbc, querier, &recordingSender{}, func() time.Time { return now })
+
+	// Test
+	ran, err := scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Zero(ran)
+
+	// The failed run stays due
+	report, err := querier.GetScheduledReport(ctx, dbc, "r1")
+	a.NoError(err)
+	a.Equal("2026-01-12 08:00:00", report.NextRunAt)
+
+	// and runs on the next tick, on the range it was due for
+	_, err = dbc.Exec("DROP TRIGGER reject_snapshot")
+	a.NoError(err)
+	now = now.Add(time.Minute)
+
+	ran, err = scheduler.Tick(ctx)
+	a.NoError(err)
+	a.Equal(1, ran)
+
+	report, err = querier.GetScheduledReport(ctx, dbc, "r1")
+	a.NoError(err)
+	a.Equal("2026-01-19 08:00:00", report.NextRunAt)
+	snapshots, err := querier.ListReportSnapshots(ctx, dbc, "r1", 0, 0)
+	a.NoError(err)
+	a.Len(snapshots, 1)
+	a.Equal("2026-01-12 08:00:00", snapshots[0].RangeTo)
+}
@@ -0,0 +1,297 @@
+package scoring
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+const (
+	KindAttribute = "attribute"
+	KindActivity  = "activity"
+)
+
+// AttributeFields lists the entity attributes attribute rules can match on.
+// corporate_email and has_phone ignore the rule's value.
+var AttributeFields = []string{"email_domain", "corporate_email", "source", "region", "status", "has_phone"}
+
+// freeEmailDomains are consumer mailbox providers, whose addresses do not
+// count as corporate email.
+var freeEmailDomains = []string{
+	"gmail.com", "googlemail.com", "yahoo.com", "hotmail.com", "outlook.com", "live.com",
+	"msn.com", "aol.com", "icloud.com", "me.com", "proton.me", "protonmail.com", "gmx.com",
+	"gmx.de", "web.de", "yandex.ru", "mail.ru", "zoho.com",
+}
+
+// Item is what a single rule contributed to a score.
+type Item struct {
+	RuleID string
+	Name   string
+	Kind   string
+	Field  string
+	Points int
+	// Matches is 1 for a matching attribute rule and the number of counted
+	// activities for an activity rule.
+	Matches int
+	// Contribution is the points earned after decay.
+	Contribution float64
+}
+
+// Explanation breaks a score down into the rules that produced it.
+type Explanation struct {
+	Score      int
+	ComputedAt time.Time
+	Items      []Item
+}
+
+// Compute scores entity against rules as of now. Rules that do not match
+// are left out of the explanation.
+func Compute(rules []db.ScoringRule, entity db.Entity, activities []db.Activity, now time.Time) Explanation {
+	explanation := Explanation{ComputedAt: now, Items: []Item{}}
+	total := 0.0
+
+	for _, rule := range rules {
+		item := Item{
+			RuleID: rule.ID,
+			Name:   rule.Name,
+			Kind:   rule.Kind,
+			Field:  rule.Field,
+			Points: rule.Points,
+		}
+
+		switch rule.Kind {
+		case KindAttribute:
+			if !Matches(rule, entity) {
+				continue
+			}
+			item.Matches = 1
+			item.Contribution = decay(rule.Points, rule.HalfLifeDays, age(entity.CreatedAt, now))
+		case KindActivity:
+			for _, activity := range activities {
+				if rule.Field != "" && !strings.EqualFold(rule.Field, activity.Kind) {
+					continue
+				}
+				item.Matches++
+				item.Contribution += decay(rule.Points, rule.HalfLifeDays, age(activity.OccurredAt, now))
+			}
+			if item.Matches == 0 {
+				continue
+			}
+		default:
+			continue
+		}
+
+		item.Contribution = math.Round(item.Contribution*100) / 100
+		total += item.Contribution
+		explanation.Items = append(explanation.Items, item)
+	}
+
+	explanation.Score = int(math.Round(total))
+	return explanation
+}
+
+// Matches reports whether an attribute rule applies to entity.
+func Matches(rule db.ScoringRule, entity db.Entity) bool {
+	var value string
+	switch rule.Field {
+	case "email_domain":
+		value = domain(entity.Email)
+	case "corporate_email":
+		d := domain(entity.Email)
+		return d != "" && !slices.Contains(freeEmailDomains, d)
+	case "has_phone":
+		return entity.Phone != ""
+	case "source":
+		value = entity.Source
+	case "region":
+		value = entity.Region
+	case "status":
+		value = entity.Status
+	default:
+		return false
+	}
+
+	for _, v := range strings.Split(rule.Value, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRule checks a rule definition before it is stored.
+func ValidateRule(kind, field string) error {
+	switch kind {
+	case KindAttribute:
+		if !slices.Contains(AttributeFields, field) {
+			return fmt.Errorf("unknown field %q", field)
+		}
+	case KindActivity:
+	default:
+		return fmt.Errorf("unknown kind %q", kind)
+	}
+	return nil
+}
+
+// decay halves points every halfLifeDays of age. A half life of zero or less
+// disables decay.
+func decay(points, halfLifeDays int, age time.Duration) float64 {
+	if halfLifeDays <= 0 || age <= 0 {
+		return float64(points)
+	}
+	halfLife := time.Duration(halfLifeDays) * 24 * time.Hour
+	return float64(points) * math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+func age(timestamp string, now time.Time) time.Duration {
+	t, err := time.Parse(db.TimeFormat, timestamp)
+	if err != nil {
+		return 0
+	}
+	return now.Sub(t)
+}
+
+func domain(email string) string {
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		return strings.ToLower(email[at+1:])
+	}
+	return ""
+}
+
+// Scorer keeps the scores stored on entities up to date.
+type Scorer struct {
+	dbc          *sqlx.DB
+	querier      db.Querier
+	eventService pubsub.EventServicer
+}
+
+func NewScorer(dbc *sqlx.DB, querier db.Querier, eventService pubsub.EventServicer) *Scorer {
+	return &Scorer{
+		dbc:          dbc,
+		querier:      querier,
+		eventService: eventService,
+	}
+}
+
+// Explain computes the current score of an entity without storing it.
+func (s *Scorer) Explain(ctx context.Context, entityID string) (db.Entity, Explanation, error) {
+	entity, err := s.querier.GetEntity(ctx, s.dbc, entityID)
+	if err != nil {
+		return db.Entity{}, Explanation{}, err
+	}
+
+	rules, err := s.querier.ListScoringRules(ctx, s.dbc)
+	if err != nil {
+		return db.Entity{}, Explanation{}, err
+	}
+
+	activities, err := s.querier.ListActivities(ctx, s.dbc, entityID)
+	if err != nil {
+		return db.Entity{}, Explanation{}, err
+	}
+
+	return entity, Compute(rules, entity, activities, time.Now().UTC()), nil
+}
+
+// Rescore recomputes and stores the score of an entity, publishing an
+// entity.scored event when it changed.
+func (s *Scorer) Rescore(ctx context.Context, entityID string) (Explanation, error) {
+	entity, explanation, err := s.Explain(ctx, entityID)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	scoredAt := explanation.ComputedAt.Format(db.TimeFormat)
+	if err := s.querier.UpdateEntityScore(ctx, s.dbc, entity.ID, explanation.Score, scoredAt); err != nil {
+		return Explanation{}, err
+	}
+
+	if explanation.Score != entity.Score {
+		payload := pubsub.EntityPayload(entity)
+		payload["score"] = explanation.Score
+		payload["previous_score"] = entity.Score
+		if err := s.eventService.Publish(ctx, pubsub.NewEvent(pubsub.EventEntityScored, payload)); err != nil {
+			slog.Error("Failed to publish event", "type", pubsub.EventEntityScored, "entity", entity.ID, "error", err)
+		}
+	}
+
+	return explanation, nil
+}
+
+// RescoreAll recomputes the score of every entity and returns how many were
+// scored.
+func (s *Scorer) RescoreAll(ctx context.Context) (int, error) {
+	var ids []string
+	err := s.querier.IterateEntities(ctx, s.dbc, db.EntityFilter{}, func(entity db.Entity) error {
+		ids = append(ids, entity.ID)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if _, err := s.Rescore(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(ids), nil
+}
+
+// Run rescores entities as events about them arrive, everything when the
+// rules change, and everything in every workspace again every interval so
+// that decayed points are reflected. It blocks until ctx is cancelled.
+func (s *Scorer) Run(ctx context.Context, interval time.Duration) {
+	go s.eventService.Consume(ctx, func(event pubsub.Event) {
+		ctx := db.WithWorkspace(ctx, event.WorkspaceID)
+		var err error
+		switch event.Type {
+		case pubsub.EventLeadCreated, pubsub.EventContactCreated, pubsub.EventEntityMerged,
+			pubsub.EventEntityStatusChanged:
+			id, _ := event.Payload["id"].(string)
+			_, err = s.Rescore(ctx, id)
+		case pubsub.EventActivityLogged:
+			id, _ := event.Payload["entity_id"].(string)
+			_, err = s.Rescore(ctx, id)
+		case pubsub.EventScoringRulesChanged:
+			_, err = s.RescoreAll(ctx)
+		default:
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to rescore", "event", event.Type, "id", event.ID, "error", err)
+		}
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n := 0
+			err := db.EachWorkspace(ctx, s.dbc, s.querier, func(ctx context.Context) error {
+				scored, err := s.RescoreAll(ctx)
+				n += scored
+				return err
+			})
+			if err != nil {
+				slog.Error("Failed to rescore entities", "error", err)
+				continue
+			}
+			slog.Info("Rescored entities", "count", n)
+		}
+	}
+}
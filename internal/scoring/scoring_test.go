@@ -0,0 +1,94 @@
+package scoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/pubsub"
+)
+
+func TestCompute(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	rules := []db.ScoringRule{
+		{ID: "corp", Name: "Corporate email", Kind: KindAttribute, Field: "corporate_email", Points: 10},
+		{ID: "web", Name: "Web lead", Kind: KindAttribute, Field: "source", Value: "web, ads", Points: 5},
+		{ID: "meeting", Name: "Meeting", Kind: KindActivity, Field: "meeting", Points: 20, HalfLifeDays: 30},
+		{ID: "any", Name: "Any activity", Kind: KindActivity, Points: 1},
+		{ID: "region", Name: "EMEA", Kind: KindAttribute, Field: "region", Value: "DE", Points: 50},
+	}
+	entity := db.Entity{
+		ID:        "e1",
+		Email:     "jane@acme.com",
+		Source:    "Web",
+		CreatedAt: "2024-01-01 00:00:00",
+	}
+	activities := []db.Activity{
+		{Kind: "meeting", OccurredAt: "2024-03-31 12:00:00"},
+		{Kind: "meeting", OccurredAt: "2024-03-01 12:00:00"},
+		{Kind: "call", OccurredAt: "2024-03-30 12:00:00"},
+	}
+
+	// Test
+	explanation := Compute(rules, entity, activities, now)
+
+	// 10 + 5 + 20 + 20/2 + 3
+	a.Equal(48, explanation.Score)
+	a.Len(explanation.Items, 4)
+	a.Equal("meeting", explanation.Items[2].RuleID)
+	a.Equal(2, explanation.Items[2].Matches)
+	a.InDelta(30.0, explanation.Items[2].Contribution, 0.01)
+
+	entity.Email = "jane@gmail.com"
+	a.Equal(38, Compute(rules, entity, activities, now).Score)
+}
+
+func TestRescore(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	defer dbc.Close()
+	a.NoError(database.Migrate(ctx, dbc))
+
+	querier := db.NewQueries()
+	_, err = querier.InsertAndReturnEntity(ctx, dbc, db.InsertAndReturnEntityParams{
+		ID:        "e1",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     "jane@acme.com",
+		Status:    "new",
+		CreatedAt: time.Now().UTC().Format(db.TimeFormat),
+	})
+	a.NoError(err)
+	_, err = querier.InsertScoringRule(ctx, dbc, db.InsertScoringRuleParams{
+		ID:     "corp",
+		Name:   "Corporate email",
+		Kind:   KindAttribute,
+		Field:  "corporate_email",
+		Points: 10,
+	})
+	a.NoError(err)
+
+	scorer := NewScorer(dbc, querier, pubsub.NewEventService())
+
+	// Test
+	n, err := scorer.RescoreAll(ctx)
+	a.NoError(err)
+	a.Equal(1, n)
+
+	entity, err := querier.GetEntity(ctx, dbc, "e1")
+	a.NoError(err)
+	a.Equal(10, entity.Score)
+	a.NotEmpty(entity.ScoredAt)
+}
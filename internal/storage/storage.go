@@ -0,0 +1,204 @@
+// Package storage keeps the contents of files uploaded to the CRM.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// DefaultMaxSize bounds, in bytes, the files a store accepts unless
+// configured otherwise.
+const DefaultMaxSize = 25 << 20
+
+var (
+	ErrNotFound = errors.New("blob not found")
+	ErrTooLarge = errors.New("file too large")
+)
+
+// Blob describes stored content.
+type Blob struct {
+	// Key is the hex SHA-256 of the content, so that identical files are
+	// stored once.
+	Key  string
+	Size int64
+	// ContentType is sniffed from the content rather than taken from the
+	// uploader, so that it can be trusted when serving the file back.
+	ContentType string
+}
+
+// Staged is content written aside by Store.Stage, not yet stored under its
+// key.
+type Staged struct {
+	Blob
+	path string
+}
+
+// Store keeps blobs by the hash of their content.
+//
+// Storing is split in two so that callers can stream and hash uploads
+// concurrently and only serialize the quick Commit: Stage writes the content
+// aside, Commit stores it under its key and Discard drops whatever Commit
+// did not take.
+type Store interface {
+	Stage(ctx context.Context, r io.Reader) (Staged, error)
+	Commit(ctx context.Context, staged Staged) error
+	Discard(staged Staged)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Put stores the content of r in store, in one go.
+func Put(ctx context.Context, store Store, r io.Reader) (Blob, error) {
+	staged, err := store.Stage(ctx, r)
+	if err != nil {
+		return Blob{}, err
+	}
+	defer store.Discard(staged)
+
+	if err := store.Commit(ctx, staged); err != nil {
+		return Blob{}, err
+	}
+
+	return staged.Blob, nil
+}
+
+// LocalStore keeps blobs as files under a directory, sharded by the first
+// two characters of their key.
+type LocalStore struct {
+	dir     string
+	maxSize int64
+}
+
+// NewLocalStore returns a store keeping blobs of at most maxSize bytes under
+// dir, creating it if needed.
+func NewLocalStore(dir string, maxSize int64) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, maxSize: maxSize}, nil
+}
+
+// Stage writes the content of r to a temporary file and hashes it,
+// returning ErrTooLarge if it exceeds the size limit.
+func (s *LocalStore) Stage(ctx context.Context, r io.Reader) (staged Staged, err error) {
+	tmp, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return Staged{}, err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	// The first bytes are all content sniffing looks at
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Staged{}, err
+	}
+	head = head[:n]
+
+	hash := sha256.New()
+	w := io.MultiWriter(tmp, hash)
+	if _, err = w.Write(head); err != nil {
+		return Staged{}, err
+	}
+	rest, err := io.Copy(w, io.LimitReader(r, s.maxSize-int64(n)+1))
+	if err != nil {
+		return Staged{}, err
+	}
+	size := int64(n) + rest
+	if size > s.maxSize {
+		return Staged{}, fmt.Errorf("%w: files may be at most %d bytes", ErrTooLarge, s.maxSize)
+	}
+	if err = tmp.Close(); err != nil {
+		return Staged{}, err
+	}
+
+	return Staged{
+		Blob: Blob{
+			Key:         hex.EncodeToString(hash.Sum(nil)),
+			Size:        size,
+			ContentType: http.DetectContentType(head),
+		},
+		path: tmp.Name(),
+	}, nil
+}
+
+// Commit moves staged content under its key. Storing content that is
+// already there leaves it untouched.
+func (s *LocalStore) Commit(ctx context.Context, staged Staged) error {
+	path := s.path(staged.Key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	return os.Rename(staged.path, path)
+}
+
+// Discard removes the temporary file of staged content, if Commit has not
+// moved it.
+func (s *LocalStore) Discard(staged Staged) {
+	if staged.path != "" {
+		os.Remove(staged.path)
+	}
+}
+
+// Open returns the content stored under key.
+func (s *LocalStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Delete removes the content stored under key. Deleting missing content is
+// not an error.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return nil
+	}
+
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+// validKey reports whether key is a hex SHA-256, keeping keys from naming
+// paths outside the store.
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
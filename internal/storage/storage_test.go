@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir, 1024)
+	a.NoError(err)
+
+	// Test
+	blob, err := Put(ctx, store, strings.NewReader("%PDF-1.4 contract"))
+	a.NoError(err)
+	a.Equal("application/pdf", blob.ContentType)
+	a.Equal(int64(17), blob.Size)
+	a.Len(blob.Key, 64)
+
+	// The same content is stored once under the same key
+	again, err := Put(ctx, store, strings.NewReader("%PDF-1.4 contract"))
+	a.NoError(err)
+	a.Equal(blob, again)
+
+	f, err := store.Open(ctx, blob.Key)
+	a.NoError(err)
+	content, err := io.ReadAll(f)
+	a.NoError(err)
+	a.NoError(f.Close())
+	a.Equal("%PDF-1.4 contract", string(content))
+
+	_, err = Put(ctx, store, strings.NewReader(strings.Repeat("x", 1025)))
+	a.True(errors.Is(err, ErrTooLarge))
+
+	// Staged content is not stored until committed
+	staged, err := store.Stage(ctx, strings.NewReader("draft"))
+	a.NoError(err)
+	_, err = store.Open(ctx, staged.Key)
+	a.True(errors.Is(err, ErrNotFound))
+	store.Discard(staged)
+
+	// Only the stored blob remains, without leftover uploads
+	entries, err := os.ReadDir(dir)
+	a.NoError(err)
+	a.Len(entries, 1)
+	a.Equal(blob.Key[:2], entries[0].Name())
+
+	a.NoError(store.Delete(ctx, blob.Key))
+	_, err = store.Open(ctx, blob.Key)
+	a.True(errors.Is(err, ErrNotFound))
+	a.NoError(store.Delete(ctx, blob.Key))
+
+	_, err = store.Open(ctx, "../../etc/passwd")
+	a.True(errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,64 @@
+package workflows
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpr(t *testing.T) {
+	payload := map[string]any{
+		"status":          "qualified",
+		"previous_status": "contacted",
+		"score":           42,
+		"email":           "Jane@Acme.com",
+		"assigned_to":     "",
+		"owner":           map[string]any{"region": "EMEA"},
+	}
+
+	tcs := []struct {
+		condition string
+		expected  bool
+	}{
+		{``, true},
+		{`status == "qualified"`, true},
+		{`status == "qualified" && previous_status != "qualified"`, true},
+		{`status == 'new' || score >= 40`, true},
+		{`score > 42`, false},
+		{`score <= 42.0 && !(score < 10)`, true},
+		{`email contains "@acme.com"`, true},
+		{`assigned_to`, false},
+		{`!assigned_to && status`, true},
+		{`owner.region == "EMEA"`, true},
+		{`missing == null`, true},
+		{`missing > 3`, false},
+		{`created_at >= "2024-01-01"`, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.condition, func(t *testing.T) {
+			a := require.New(t)
+			expr, err := Parse(tc.condition)
+			a.NoError(err)
+
+			matched, err := expr.Match(payload)
+			a.NoError(err)
+			a.Equal(tc.expected, matched)
+		})
+	}
+}
+
+func TestExpr_SyntaxErrors(t *testing.T) {
+	for _, condition := range []string{
+		`status ==`,
+		`(status == "new"`,
+		`status = "new"`,
+		`"unterminated`,
+		`status == "new" extra`,
+	} {
+		t.Run(condition, func(t *testing.T) {
+			_, err := Parse(condition)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,497 @@
+package workflows
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"simplecrm/internal/db"
+	"simplecrm/internal/mail"
+	"simplecrm/internal/ops"
+	"simplecrm/internal/pubsub"
+)
+
+const (
+	ActionCreateTask   = "create_task"
+	ActionChangeStatus = "change_status"
+	ActionAssign       = "assign"
+	ActionWebhook      = "webhook"
+	ActionSendEmail    = "send_email"
+	ActionNotify       = "notify"
+)
+
+const (
+	RunStatusSucceeded = "succeeded"
+	RunStatusFailed    = "failed"
+	RunStatusSkipped   = "skipped"
+)
+
+// maxDepth bounds chains of workflows triggering each other through the
+// events their actions publish.
+const maxDepth = 5
+
+// requiredParams lists, per action type, the params it cannot run without.
+var requiredParams = map[string][]string{
+	ActionCreateTask:   {"name"},
+	ActionChangeStatus: {"status"},
+	ActionAssign:       {"user_id"},
+	ActionWebhook:      {"url"},
+	ActionSendEmail:    {"to", "subject"},
+	ActionNotify:       {"title"},
+}
+
+// Action is a step of a workflow. Params are text/template strings executed
+// against the event payload, so "Follow up with {{.first_name}}" names the
+// lead that triggered the run.
+//
+// create_task takes name, description, due_in_days and assign_to, which is
+// "owner" for the entity's assignee (the default), a user id or "none".
+// change_status takes status, assign takes user_id, webhook takes an http or
+// https url, which may not point at loopback, link-local or private
+// addresses, and send_email takes to, subject, body and html, whose template
+// escapes data as HTML. notify sends an in-app notification with title and
+// body to user_id, which like assign_to defaults to the owner.
+type Action struct {
+	Type   string            `json:"type"`
+	Params map[string]string `json:"params"`
+}
+
+// ActionResult is the log entry of one action of a run.
+type ActionResult struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ErrInvalidWorkflow is returned when a workflow definition cannot be run.
+var ErrInvalidWorkflow = errors.New("invalid workflow")
+
+// Validate checks a workflow definition before it is stored.
+func Validate(condition string, actions []Action) error {
+	if _, err := Parse(condition); err != nil {
+		return fmt.Errorf("%w: condition: %s", ErrInvalidWorkflow, err)
+	}
+	if len(actions) == 0 {
+		return fmt.Errorf("%w: at least one action is required", ErrInvalidWorkflow)
+	}
+
+	for n, action := range actions {
+		required, ok := requiredParams[action.Type]
+		if !ok {
+			return fmt.Errorf("%w: action %d: unknown type %q", ErrInvalidWorkflow, n, action.Type)
+		}
+		for _, param := range required {
+			if action.Params[param] == "" {
+				return fmt.Errorf("%w: action %d: %s requires %s", ErrInvalidWorkflow, n, action.Type, param)
+			}
+		}
+		for param, value := range action.Params {
+			if _, err := template.New(param).Parse(value); err != nil {
+				return fmt.Errorf("%w: action %d: %s: %s", ErrInvalidWorkflow, n, param, err)
+			}
+		}
+		// URLs built from the event can only be checked once rendered
+		if url := action.Params["url"]; action.Type == ActionWebhook && !strings.Contains(url, "{{") {
+			if err := checkWebhookURL(url); err != nil {
+				return fmt.Errorf("%w: action %d: url: %s", ErrInvalidWorkflow, n, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+type CreateParams struct {
+	Name      string
+	Trigger   string
+	Condition string
+	Actions   []Action
+}
+
+func Create(ctx context.Context, dbc db.DBExecutor, querier db.Querier, params CreateParams) (db.Workflow, error) {
+	if err := Validate(params.Condition, params.Actions); err != nil {
+		return db.Workflow{}, err
+	}
+
+	actions, err := json.Marshal(params.Actions)
+	if err != nil {
+		return db.Workflow{}, err
+	}
+
+	return querier.InsertWorkflow(ctx, dbc, db.InsertWorkflowParams{
+		ID:        uuid.New().String(),
+		Name:      params.Name,
+		Trigger:   params.Trigger,
+		Condition: params.Condition,
+		Actions:   string(actions),
+	})
+}
+
+// Engine runs workflows in reaction to events.
+type Engine struct {
+	dbc          *sqlx.DB
+	querier      db.Querier
+	eventService pubsub.EventServicer
+	mailer       mail.Sender
+	client       *http.Client
+	now          func() time.Time
+}
+
+func NewEngine(
+	dbc *sqlx.DB,
+	querier db.Querier,
+	eventService pubsub.EventServicer,
+	mailer mail.Sender,
+) *Engine {
+	return &Engine{
+		dbc:          dbc,
+		querier:      querier,
+		eventService: eventService,
+		mailer:       mailer,
+		client:       webhookClient(),
+		now:          func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Run handles events until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context) {
+	e.eventService.Consume(ctx, func(event pubsub.Event) {
+		if _, err := e.Handle(db.WithWorkspace(ctx, event.WorkspaceID), event); err != nil {
+			slog.Error("Failed to run workflows", "event", event.Type, "id", event.ID, "error", err)
+		}
+	})
+}
+
+// Handle runs every enabled workflow triggered by event and records a run
+// for each of them. A workflow whose run cannot be recorded is logged and
+// does not keep the others from running.
+func (e *Engine) Handle(ctx context.Context, event pubsub.Event) ([]db.WorkflowRun, error) {
+	workflows, err := e.querier.ListEnabledWorkflowsByTrigger(ctx, e.dbc, event.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]db.WorkflowRun, 0, len(workflows))
+	for _, workflow := range workflows {
+		run, err := e.run(ctx, workflow, event)
+		if err != nil {
+			slog.Error("Failed to run workflow", "workflow", workflow.ID, "event", event.ID, "error", err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+func (e *Engine) run(ctx context.Context, workflow db.Workflow, event pubsub.Event) (db.WorkflowRun, error) {
+	arg := db.InsertWorkflowRunParams{
+		WorkflowID: workflow.ID,
+		EventID:    event.ID,
+		EventType:  event.Type,
+		Log:        "[]",
+		StartedAt:  e.now().Format(db.TimeFormat),
+	}
+
+	results, err := e.execute(ctx, workflow, event)
+	switch {
+	case errors.Is(err, errSkipped):
+		arg.Status = RunStatusSkipped
+	case err != nil:
+		arg.Status = RunStatusFailed
+		arg.Error = err.Error()
+	default:
+		arg.Status = RunStatusSucceeded
+	}
+
+	if len(results) > 0 {
+		log, err := json.Marshal(results)
+		if err != nil {
+			return db.WorkflowRun{}, err
+		}
+		arg.Log = string(log)
+	}
+	arg.FinishedAt = e.now().Format(db.TimeFormat)
+
+	return e.querier.InsertWorkflowRun(ctx, e.dbc, arg)
+}
+
+var errSkipped = errors.New("condition not met")
+
+func (e *Engine) execute(ctx context.Context, workflow db.Workflow, event pubsub.Event) ([]ActionResult, error) {
+	if event.Depth >= maxDepth {
+		return nil, fmt.Errorf("event is %d workflow runs deep, stopping", event.Depth)
+	}
+
+	condition, err := Parse(workflow.Condition)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := condition.Match(event.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errSkipped
+	}
+
+	var actions []Action
+	if err := json.Unmarshal([]byte(workflow.Actions), &actions); err != nil {
+		return nil, err
+	}
+
+	// Events published by actions are one level deeper than their cause
+	ctx = pubsub.WithDepth(ctx, event.Depth+1)
+
+	results := []ActionResult{}
+	for _, action := range actions {
+		result, err := e.perform(ctx, action, event)
+		if err != nil {
+			results = append(results, ActionResult{Type: action.Type, Status: RunStatusFailed, Error: err.Error()})
+			return results, fmt.Errorf("%s: %w", action.Type, err)
+		}
+		results = append(results, ActionResult{Type: action.Type, Status: RunStatusSucceeded, Result: result})
+	}
+
+	return results, nil
+}
+
+func (e *Engine) perform(ctx context.Context, action Action, event pubsub.Event) (string, error) {
+	params := map[string]string{}
+	for name, value := range action.Params {
+		renderParam := render
+		if action.Type == ActionSendEmail && name == "html" {
+			renderParam = renderHTML
+		}
+		rendered, err := renderParam(value, event.Payload)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		params[name] = rendered
+	}
+
+	entityID := payloadString(event.Payload, "entity_id")
+	if entityID == "" {
+		entityID = payloadString(event.Payload, "id")
+	}
+
+	switch action.Type {
+	case ActionCreateTask:
+		due := e.now()
+		if v := params["due_in_days"]; v != "" {
+			days, err := strconv.Atoi(v)
+			if err != nil {
+				return "", fmt.Errorf("invalid due_in_days %q", v)
+			}
+			due = due.AddDate(0, 0, days)
+		}
+
+		assignee := params["assign_to"]
+		switch assignee {
+		case "", "owner":
+			assignee = payloadString(event.Payload, "assigned_to")
+		case "none":
+			assignee = ""
+		}
+
+		task, err := ops.CreateTask(ctx, e.dbc, e.querier, ops.CreateTaskParams{
+			Name:        params["name"],
+			Description: params["description"],
+			DueDate:     due.Format(db.TimeFormat),
+			AssignedTo:  assignee,
+			EntityID:    entityID,
+		}, e.eventService)
+		if err != nil {
+			return "", err
+		}
+		return "task " + task.ID, nil
+
+	case ActionChangeStatus:
+		entity, err := ops.ChangeEntityStatus(ctx, e.dbc, e.querier, entityID, params["status"], e.eventService)
+		if err != nil {
+			return "", err
+		}
+		return "status " + entity.Status, nil
+
+	case ActionAssign:
+		entity, err := ops.AssignEntity(ctx, e.dbc, e.querier, entityID, params["user_id"], e.eventService)
+		if err != nil {
+			return "", err
+		}
+		return "assigned to " + entity.AssignedTo.String, nil
+
+	case ActionWebhook:
+		return e.webhook(ctx, params["url"], event)
+
+	case ActionSendEmail:
+		to := strings.FieldsFunc(params["to"], func(r rune) bool { return r == ',' || r == ' ' })
+		if len(to) == 0 {
+			return "", errors.New("no recipients")
+		}
+		err := e.mailer.Send(ctx, mail.Message{
+			To:      to,
+			Subject: params["subject"],
+			Body:    params["body"],
+			HTML:    params["html"],
+		})
+		if err != nil {
+			return "", err
+		}
+		return "sent to " + strings.Join(to, ", "), nil
+
+	case ActionNotify:
+		userID := params["user_id"]
+		if userID == "" || userID == "owner" {
+			userID = payloadString(event.Payload, "assigned_to")
+		}
+		if userID == "" {
+			return "", errors.New("no user to notify")
+		}
+
+		notification := pubsub.NewEvent(pubsub.EventWorkflowNotification, map[string]any{
+			"user_id":   userID,
+			"title":     params["title"],
+			"body":      params["body"],
+			"entity_id": entityID,
+			"cause_id":  event.ID,
+		})
+		if err := e.eventService.Publish(ctx, notification); err != nil {
+			return "", err
+		}
+		return "notified " + userID, nil
+	}
+
+	return "", fmt.Errorf("unknown action type %q", action.Type)
+}
+
+func (e *Engine) webhook(ctx context.Context, url string, event pubsub.Event) (string, error) {
+	if err := checkWebhookURL(url); err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"id":          event.ID,
+		"type":        event.Type,
+		"occurred_at": event.OccurredAt,
+		"payload":     event.Payload,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return "webhook " + resp.Status, nil
+}
+
+// checkWebhookURL accepts only absolute http and https URLs.
+func checkWebhookURL(raw string) error {
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook URL %q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("webhook URL %q has no host", raw)
+	}
+	return nil
+}
+
+// webhookClient calls webhooks without following them onto the server's own
+// network: loopback, link-local addresses such as cloud metadata endpoints,
+// and private ones. The addresses are checked as they are dialed, so names
+// that resolve to them and redirects to them are refused as well.
+func webhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddress(net.ParseIP(host)) {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+// publicAddress reports whether webhooks may be sent to ip: it is not
+// loopback, link-local, unspecified or private, which covers RFC 1918 and
+// unique local IPv6 addresses.
+func publicAddress(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() && !ip.IsPrivate()
+}
+
+func render(text string, payload map[string]any) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New("").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, payload); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func renderHTML(text string, payload map[string]any) (string, error) {
+	tmpl, err := htmltemplate.New("").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, payload); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func payloadString(payload map[string]any, key string) string {
+	s, _ := payload[key].(string)
+	return s
+}
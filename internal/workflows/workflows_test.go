@@ -0,0 +1,272 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"simplecrm/database"
+	"simplecrm/internal/db"
+	"simplecrm/internal/mail"
+	"simplecrm/internal/pubsub"
+)
+
+type recordingSender struct {
+	sent []mail.Message
+}
+
+func (s *recordingSender) Send(ctx context.Context, msg mail.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func setupTest(t *testing.T) (*sqlx.DB, *Engine, *recordingSender) {
+	a := require.New(t)
+	dbc, err := sqlx.Connect("sqlite3", ":memory:")
+	a.NoError(err)
+	dbc.SetMaxOpenConns(1)
+	t.Cleanup(func() { dbc.Close() })
+
+	err = database.Migrate(context.Background(), dbc)
+	a.NoError(err)
+
+	_, err = dbc.Exec(`
+	INSERT INTO users (id, first_name, last_name, email) VALUES ('owner', 'Ann', 'Lee', 'ann@example.com');
+	INSERT INTO entities (id, first_name, last_name, email, phone, status, assigned_to, created_at, converted_at) VALUES
+	('lead1', 'Jane', 'Doe', 'jane@example.com', '', 'qualified', 'owner', '2024-01-01 09:00:00', '');
+	`)
+	a.NoError(err)
+
+	sender := &recordingSender{}
+	engine := NewEngine(dbc, db.NewQueries(), pubsub.NewEventService(), sender)
+	engine.now = func() time.Time { return time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC) }
+	return dbc, engine, sender
+}
+
+func TestHandle_CreatesFollowUpTask(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, engine, sender := setupTest(t)
+	querier := db.NewQueries()
+
+	workflow, err := Create(ctx, dbc, querier, CreateParams{
+		Name:      "Follow up qualified leads",
+		Trigger:   pubsub.EventEntityStatusChanged,
+		Condition: `status == "qualified" && previous_status != "qualified"`,
+		Actions: []Action{
+			{Type: ActionCreateTask, Params: map[string]string{
+				"name":        "Follow up with {{.first_name}}",
+				"due_in_days": "2",
+			}},
+			{Type: ActionSendEmail, Params: map[string]string{
+				"to":      "sales@example.com",
+				"subject": "{{.first_name}} {{.last_name}} is qualified",
+			}},
+		},
+	})
+	a.NoError(err)
+
+	// Test
+	event := pubsub.NewEvent(pubsub.EventEntityStatusChanged, map[string]any{
+		"id":              "lead1",
+		"first_name":      "Jane",
+		"last_name":       "Doe",
+		"status":          "qualified",
+		"previous_status": "contacted",
+		"assigned_to":     "owner",
+	})
+	runs, err := engine.Handle(ctx, event)
+	a.NoError(err)
+	a.Len(runs, 1)
+	a.Equal(RunStatusSucceeded, runs[0].Status, runs[0].Error)
+	a.Equal(workflow.ID, runs[0].WorkflowID)
+
+	var task db.Task
+	err = dbc.Get(&task, "SELECT * FROM tasks")
+	a.NoError(err)
+	a.Equal("Follow up with Jane", task.Name)
+	a.Equal("2024-01-12 09:00:00", task.DueDate)
+	a.Equal("owner", task.AssignedTo.String)
+	a.Equal("lead1", task.EntityID.String)
+
+	a.Len(sender.sent, 1)
+	a.Equal("Jane Doe is qualified", sender.sent[0].Subject)
+
+	// Events not matching the condition are logged as skipped
+	event.Payload["previous_status"] = "qualified"
+	runs, err = engine.Handle(ctx, event)
+	a.NoError(err)
+	a.Equal(RunStatusSkipped, runs[0].Status)
+
+	runs, err = querier.ListWorkflowRuns(ctx, dbc, workflow.ID, 10)
+	a.NoError(err)
+	a.Len(runs, 2)
+}
+
+func TestHandle_WebhookAndFailures(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, engine, _ := setupTest(t)
+	querier := db.NewQueries()
+
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+	// The test server listens on loopback, which webhooks may not call
+	engine.client = server.Client()
+
+	_, err := Create(ctx, dbc, querier, CreateParams{
+		Name:    "Notify and disqualify",
+		Trigger: pubsub.EventLeadCreated,
+		Actions: []Action{
+			{Type: ActionWebhook, Params: map[string]string{"url": server.URL}},
+			{Type: ActionChangeStatus, Params: map[string]string{"status": "bogus"}},
+		},
+	})
+	a.NoError(err)
+
+	// Test
+	event := pubsub.NewEvent(pubsub.EventLeadCreated, map[string]any{"id": "lead1"})
+	runs, err := engine.Handle(ctx, event)
+	a.NoError(err)
+	a.Equal(RunStatusFailed, runs[0].Status)
+	a.Contains(runs[0].Error, "change_status")
+	a.Equal(pubsub.EventLeadCreated, received["type"])
+
+	var log []ActionResult
+	a.NoError(json.Unmarshal([]byte(runs[0].Log), &log))
+	a.Len(log, 2)
+	a.Equal(RunStatusSucceeded, log[0].Status)
+	a.Equal(RunStatusFailed, log[1].Status)
+
+	// Runaway chains are cut off
+	event.Depth = maxDepth
+	runs, err = engine.Handle(ctx, event)
+	a.NoError(err)
+	a.Equal(RunStatusFailed, runs[0].Status)
+	a.Contains(runs[0].Error, "deep")
+}
+
+func TestHandle_WebhookRefusesInternalAddresses(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, engine, _ := setupTest(t)
+	querier := db.NewQueries()
+
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	loopback, err := Create(ctx, dbc, querier, CreateParams{
+		Name:    "Loopback",
+		Trigger: pubsub.EventLeadCreated,
+		Actions: []Action{{Type: ActionWebhook, Params: map[string]string{"url": server.URL}}},
+	})
+	a.NoError(err)
+	_, err = Create(ctx, dbc, querier, CreateParams{
+		Name:    "Rendered",
+		Trigger: pubsub.EventLeadCreated,
+		Actions: []Action{{Type: ActionWebhook, Params: map[string]string{"url": "{{.website}}"}}},
+	})
+	a.NoError(err)
+
+	// Test
+	event := pubsub.NewEvent(pubsub.EventLeadCreated, map[string]any{"id": "lead1", "website": "file:///etc/passwd"})
+	runs, err := engine.Handle(ctx, event)
+	a.NoError(err)
+	a.Len(runs, 2)
+	for _, run := range runs {
+		a.Equal(RunStatusFailed, run.Status)
+		if run.WorkflowID == loopback.ID {
+			a.Contains(run.Error, "not allowed")
+		} else {
+			a.Contains(run.Error, "http or https")
+		}
+	}
+	a.False(called)
+}
+
+func TestHandle_ContinuesAfterFailedRun(t *testing.T) {
+	// Setup
+	a := require.New(t)
+	ctx := db.WithWorkspace(context.Background(), db.DefaultWorkspaceID)
+	dbc, engine, sender := setupTest(t)
+	querier := db.NewQueries()
+
+	var second db.Workflow
+	for _, subject := range []string{"First", "Second"} {
+		workflow, err := Create(ctx, dbc, querier, CreateParams{
+			Name:    subject,
+			Trigger: pubsub.EventLeadCreated,
+			Actions: []Action{{Type: ActionSendEmail, Params: map[string]string{"to": "sales@example.com", "subject": subject}}},
+		})
+		a.NoError(err)
+		second = workflow
+	}
+
+	// The first workflow's run cannot be recorded
+	_, err := dbc.Exec(`
+	CREATE TRIGGER reject_run BEFORE INSERT ON workflow_runs
+	WHEN (SELECT name FROM workflows WHERE id = NEW.workflow_id) = 'First'
+	BEGIN SELECT RAISE(ABORT, 'rejected'); END;
+	`)
+	a.NoError(err)
+
+	// Test
+	runs, err := engine.Handle(ctx, pubsub.NewEvent(pubsub.EventLeadCreated, map[string]any{"id": "lead1"}))
+	a.NoError(err)
+	a.Len(runs, 1)
+	a.Equal(second.ID, runs[0].WorkflowID)
+	a.Equal(RunStatusSucceeded, runs[0].Status)
+	a.Len(sender.sent, 2)
+}
+
+func TestValidate(t *testing.T) {
+	a := require.New(t)
+	a.NoError(Validate(`status == "new"`, []Action{{Type: ActionAssign, Params: map[string]string{"user_id": "u1"}}}))
+	a.ErrorIs(Validate(`status ==`, []Action{{Type: ActionAssign, Params: map[string]string{"user_id": "u1"}}}), ErrInvalidWorkflow)
+	a.ErrorIs(Validate(``, nil), ErrInvalidWorkflow)
+	a.ErrorIs(Validate(``, []Action{{Type: "launch_rocket"}}), ErrInvalidWorkflow)
+	a.ErrorIs(Validate(``, []Action{{Type: ActionWebhook}}), ErrInvalidWorkflow)
+	a.ErrorIs(Validate(``, []Action{{Type: ActionWebhook, Params: map[string]string{"url": "ftp://example.com/hook"}}}), ErrInvalidWorkflow)
+	a.NoError(Validate(``, []Action{{Type: ActionWebhook, Params: map[string]string{"url": "https://example.com/{{.id}}"}}}))
+	a.ErrorIs(Validate(``, []Action{{Type: ActionCreateTask, Params: map[string]string{"name": "{{.x"}}}), ErrInvalidWorkflow)
+}
+
+func TestPublicAddress(t *testing.T) {
+	a := require.New(t)
+
+	for address, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:2800::1":    true,
+		"127.0.0.1":       false,
+		"::1":             false,
+		"169.254.169.254": false,
+		"0.0.0.0":         false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"172.31.255.255":  false,
+		"192.168.1.1":     false,
+		"fd00::1":         false,
+		"fc00::1":         false,
+	} {
+		a.Equal(public, publicAddress(net.ParseIP(address)), address)
+	}
+}